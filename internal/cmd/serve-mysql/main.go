@@ -19,9 +19,11 @@ import (
 	"github.com/jsmit257/userservice/internal/messaging/maild"
 	"github.com/jsmit257/userservice/internal/messaging/smsd"
 	"github.com/jsmit257/userservice/internal/metrics"
+	"github.com/jsmit257/userservice/internal/oidc"
 	data "github.com/jsmit257/userservice/internal/relational"
 	"github.com/jsmit257/userservice/internal/router"
 	valid "github.com/jsmit257/userservice/internal/validation"
+	"github.com/jsmit257/userservice/shared/v1"
 )
 
 const APP_NAME = "serve-mysql"
@@ -58,22 +60,31 @@ func main() {
 	}
 	log.Info("fetched mysql DML")
 
-	authn, err := newRedis(cfg)
+	store, err := newStore(cfg, db, sqls, log)
 	if err != nil {
-		panic("failed to connect redis client")
+		log.Panicf("failed to create session store: %q", err)
 	}
-	log.Info("created redis authn store")
+	log.WithField("store", cfg.SessionStore).Info("created authn store")
 
-	conn := data.NewUserService(db, sqls, log, metrics.DataMetrics.MustCurryWith(prometheus.Labels{
+	conn, err := data.NewUserService(db, sqls, cfg, log, metrics.DataMetrics.MustCurryWith(prometheus.Labels{
 		"pkg": "data",
 	}))
+	if err != nil {
+		log.Panicf("failed to create user service: %q", err)
+	}
 
 	us := &router.UserService{
-		Addresser: conn,
-		Auther:    conn,
-		Contacter: conn,
-		Userer:    conn,
-		Validator: valid.NewValidator(authn, cfg, log),
+		APIKeyer:    conn,
+		Addresser:   conn,
+		Auther:      conn,
+		Authorizer:  conn,
+		Clienter:    conn,
+		Contacter:   conn,
+		Inviter:     conn,
+		MagicLinker: conn,
+		MFAer:       conn,
+		Userer:      conn,
+		Validator:   valid.NewValidator(store, cfg, log),
 	}
 
 	if us.MailSender, err = maild.NewSender(cfg, log); err != nil {
@@ -86,6 +97,22 @@ func main() {
 	}
 	defer us.SmsSender.Close()
 
+	if us.Keys, err = oidc.NewKeys(cfg, log); err != nil {
+		log.Panicf("failed to load oidc signing key: %q", err)
+	}
+
+	if us.VerifyKey, err = oidc.NewVerifyKey(cfg, log); err != nil {
+		log.Panicf("failed to load verify key: %q", err)
+	}
+
+	sweeping, stopSweeping := context.WithCancel(context.WithValue(
+		context.Background(),
+		shared.CTXKey("cid"),
+		shared.CID("sweeper"),
+	))
+	defer stopSweeping()
+	go sweep(sweeping, us.Validator, cfg.SessionSweepInterval, log)
+
 	srv := router.NewInstance(us, cfg, log)
 
 	startServer(srv, log).Wait()
@@ -128,24 +155,56 @@ func newMysql(cfg *config.Config) (*sql.DB, error) {
 	return db, err
 }
 
-func newRedis(cfg *config.Config) (*redis.Client, error) {
-	url := fmt.Sprintf("redis://%s:%s@%s:%d/0",
-		cfg.RedisUser,
-		cfg.RedisPass,
-		cfg.RedisHost,
-		cfg.RedisPort)
-	opt, err := redis.ParseURL(url)
+// newStore is wherever SESSION_STORE says sessions live
+func newStore(cfg *config.Config, db *sql.DB, sqls config.Sqls, log *logrus.Entry) (valid.Store, error) {
+	switch cfg.SessionStore {
+	case valid.RedisStore:
+		client, err := newRedis(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return valid.NewKeyspace(valid.NewRedisStore(client), cfg.RedisKeyPrefix), nil
+	case valid.MemoryStore:
+		log.Warn("sessions are only in memory: they aren't shared with other instances and won't survive a restart")
+		return valid.NewMemoryStore(), nil
+	case valid.MySQLStore:
+		return data.NewSessionStore(db, sqls), nil
+	}
+	return nil, fmt.Errorf("unknown session store: %q", cfg.SessionStore)
+}
+
+func newRedis(cfg *config.Config) (redis.UniversalClient, error) {
+	client, err := valid.NewRedisClient(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	client := redis.NewClient(opt)
-
 	_, err = client.Ping(context.Background()).Result()
 
 	return client, err
 }
 
+// sweep has the validator clean up after itself every interval until ctx is
+// done; Sweep logs its own results
+func sweep(ctx context.Context, v valid.Validator, interval time.Duration, log *logrus.Entry) {
+	if interval <= 0 {
+		log.Info("session sweeper is off")
+		return
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			v.Sweep(ctx)
+		}
+	}
+}
+
 func startServer(srv *http.Server, log *logrus.Entry) *sync.WaitGroup {
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
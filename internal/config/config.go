@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
@@ -12,11 +13,26 @@ type Config struct {
 	MySQLPwd  string `envconfig:"MYSQL_PASSWORD" required:"true" json:"-"`
 	MySQLUser string `envconfig:"MYSQL_USER" required:"true" json:"mysql_user"`
 
+	SessionStore string `envconfig:"SESSION_STORE" default:"redis" json:"session_store"` // redis, memory (one instance only) or mysql
+
 	RedisUser string `envconfig:"REDIS_USER" json:"redis_user"`
 	RedisPass string `envconfig:"REDIS_PASS" json:"-"`
 	RedisHost string `envconfig:"REDIS_HOST" default:"redis" json:"redis_host"`
 	RedisPort int16  `envconfig:"REDIS_PORT" default:"6379" json:"redis_port"`
 
+	RedisMode         string   `envconfig:"REDIS_MODE" default:"standalone" json:"redis_mode"` // standalone, sentinel or cluster
+	RedisAddrs        []string `envconfig:"REDIS_ADDRS" json:"redis_addrs,omitempty"`          // host:port of sentinels or cluster seeds, comma separated
+	RedisMaster       string   `envconfig:"REDIS_MASTER" json:"redis_master,omitempty"`        // the master's name, for sentinel
+	RedisSentinelUser string   `envconfig:"REDIS_SENTINEL_USER" json:"redis_sentinel_user,omitempty"`
+	RedisSentinelPass string   `envconfig:"REDIS_SENTINEL_PASS" json:"-"`
+	RedisDB           int      `envconfig:"REDIS_DB" default:"0" json:"redis_db"` // not for cluster
+	RedisTLS          bool     `envconfig:"REDIS_TLS" default:"false" json:"redis_tls"`
+	RedisCAFile       string   `envconfig:"REDIS_CA_FILE" json:"redis_ca_file,omitempty"`     // PEM; the system roots if empty
+	RedisCertFile     string   `envconfig:"REDIS_CERT_FILE" json:"redis_cert_file,omitempty"` // PEM client certificate, with REDIS_KEY_FILE
+	RedisKeyFile      string   `envconfig:"REDIS_KEY_FILE" json:"redis_key_file,omitempty"`
+	RedisServerName   string   `envconfig:"REDIS_SERVER_NAME" json:"redis_server_name,omitempty"` // what the server's certificate says, if not the host
+	RedisKeyPrefix    string   `envconfig:"REDIS_KEY_PREFIX" json:"redis_key_prefix,omitempty"`   // so environments can share a redis
+
 	MaildHost   string `envconfig:"MAILD_HOST" default:"mail.google.com" json:"maild_host,omitempty"`
 	MaildPort   uint16 `envconfig:"MAILD_PORT" default:"587" json:"maild_port,omitempty"`
 	MaildUser   string `envconfig:"MAILD_USER" default:"svc" json:"maild_user,omitempty"`
@@ -27,9 +43,76 @@ type Config struct {
 	SmsAuthToken string `envconfig:"SMS_AUTH_TOKEN" json:"sms_auth_token,omitempty"`
 	SmsSender    string `envconfig:"SMS_SENDER" json:"sms_sender,omitempty"`
 
-	AuthnTimeout int64  `envconfig:"AUTHN_TIMEOUT" default:"15" json:"authn_timeout"`
-	MaxLogins    int    `envconfig:"MAX_LOGINS" default:"5" json:"max_logins"`
-	CookieName   string `envconfig:"AUTHN_COOKIE" default:"us-authn" json:"authn_cookie"`
+	AuthnTimeout    int64  `envconfig:"AUTHN_TIMEOUT" default:"15" json:"authn_timeout"`
+	MaxLogins       int    `envconfig:"MAX_LOGINS" default:"5" json:"max_logins"`
+	MaxLoginsPolicy string `envconfig:"MAX_LOGINS_POLICY" default:"reject" json:"max_logins_policy"` // reject, oldest or lru
+	CookieName      string `envconfig:"AUTHN_COOKIE" default:"us-authn" json:"authn_cookie"`
+
+	SessionMaxLifetime   time.Duration `envconfig:"SESSION_MAX_LIFETIME" default:"24h" json:"session_max_lifetime"`    // from login, no matter how active; 0 never ends
+	SessionSeenInterval  time.Duration `envconfig:"SESSION_SEEN_INTERVAL" default:"1m" json:"session_seen_interval"`   // how stale last seen gets before it's written again
+	SessionBinding       []string      `envconfig:"SESSION_BINDING" default:"none" json:"session_binding"`             // none, or any of ip, subnet and agent, comma separated
+	TrustedProxies       []string      `envconfig:"TRUSTED_PROXIES" json:"trusted_proxies,omitempty"`                  // CIDRs whose X-Forwarded-For is believed
+	SessionSweepInterval time.Duration `envconfig:"SESSION_SWEEP_INTERVAL" default:"1h" json:"session_sweep_interval"` // how often orphaned session keys get cleaned up; 0 never
+
+	SessionJWT        bool          `envconfig:"SESSION_JWT" default:"false" json:"session_jwt"`              // signed tokens in the cookie instead of opaque ones
+	SessionKeyFile    string        `envconfig:"SESSION_KEY_FILE" json:"session_key_file,omitempty"`          // PEM encoded RSA (RS256) or Ed25519 (EdDSA) private key
+	SessionJWTTimeout time.Duration `envconfig:"SESSION_JWT_TIMEOUT" default:"5m" json:"session_jwt_timeout"` // how long a token is good for offline
+	SessionIssuer     string        `envconfig:"SESSION_ISSUER" default:"userservice" json:"session_issuer"`
+
+	RefreshTimeout time.Duration `envconfig:"REFRESH_TIMEOUT" default:"720h" json:"refresh_timeout"` // idle time before a refresh token family is forgotten
+	RefreshCookie  string        `envconfig:"REFRESH_COOKIE" default:"us-refresh" json:"refresh_cookie"`
+
+	AdminPermission string `envconfig:"ADMIN_PERMISSION" default:"admin" json:"admin_permission"` // lets a caller past the ownership checks
+
+	PasswordHash  string `envconfig:"PASSWORD_HASH" default:"argon2id" json:"password_hash"` // argon2id, bcrypt or scrypt
+	Argon2Time    uint32 `envconfig:"ARGON2_TIME" default:"3" json:"argon2_time"`
+	Argon2Memory  uint32 `envconfig:"ARGON2_MEMORY" default:"65536" json:"argon2_memory"` // KiB
+	Argon2Threads uint8  `envconfig:"ARGON2_THREADS" default:"2" json:"argon2_threads"`
+	BcryptCost    int    `envconfig:"BCRYPT_COST" default:"12" json:"bcrypt_cost"`
+	ScryptLogN    uint8  `envconfig:"SCRYPT_LOGN" default:"15" json:"scrypt_logn"`
+	ScryptR       int    `envconfig:"SCRYPT_R" default:"8" json:"scrypt_r"`
+	ScryptP       int    `envconfig:"SCRYPT_P" default:"1" json:"scrypt_p"`
+
+	PasswordMinLength     int      `envconfig:"PASSWORD_MIN_LENGTH" default:"8" json:"password_min_length"`
+	PasswordMaxLength     int      `envconfig:"PASSWORD_MAX_LENGTH" default:"128" json:"password_max_length"`
+	PasswordRequireLower  bool     `envconfig:"PASSWORD_REQUIRE_LOWER" default:"false" json:"password_require_lower"`
+	PasswordRequireUpper  bool     `envconfig:"PASSWORD_REQUIRE_UPPER" default:"false" json:"password_require_upper"`
+	PasswordRequireDigit  bool     `envconfig:"PASSWORD_REQUIRE_DIGIT" default:"false" json:"password_require_digit"`
+	PasswordRequireSymbol bool     `envconfig:"PASSWORD_REQUIRE_SYMBOL" default:"false" json:"password_require_symbol"`
+	PasswordMaxRepeat     int      `envconfig:"PASSWORD_MAX_REPEAT" default:"0" json:"password_max_repeat"`
+	PasswordHistory       int      `envconfig:"PASSWORD_HISTORY" default:"5" json:"password_history"`
+	PasswordBanned        []string `envconfig:"PASSWORD_BANNED" json:"-"`                                 // comma separated
+	PasswordDictionary    string   `envconfig:"PASSWORD_DICTIONARY" json:"password_dictionary,omitempty"` // breached passwords, one per line
+
+	LockoutThreshold  uint8         `envconfig:"LOCKOUT_THRESHOLD" default:"5" json:"lockout_threshold"` // 0 never locks
+	LockoutDuration   time.Duration `envconfig:"LOCKOUT_DURATION" default:"15m" json:"lockout_duration"` // 0 locks until unlocked
+	LockoutBackoff    time.Duration `envconfig:"LOCKOUT_BACKOFF" default:"1s" json:"lockout_backoff"`
+	LockoutMaxBackoff time.Duration `envconfig:"LOCKOUT_MAX_BACKOFF" default:"1m" json:"lockout_max_backoff"`
+
+	MFAIssuer      string `envconfig:"MFA_ISSUER" default:"userservice" json:"mfa_issuer"` // shows up in authenticator apps
+	MFATimeout     int64  `envconfig:"MFA_TIMEOUT" default:"5" json:"mfa_timeout"`         // minutes to enter a code
+	MFAMaxAttempts int    `envconfig:"MFA_MAX_ATTEMPTS" default:"5" json:"mfa_max_attempts"`
+	TOTPSkew       int    `envconfig:"TOTP_SKEW" default:"1" json:"totp_skew"` // 30s periods either side of now
+	RecoveryCodes  int    `envconfig:"RECOVERY_CODES" default:"10" json:"recovery_codes"`
+
+	MFACodeDigits   int           `envconfig:"MFA_CODE_DIGITS" default:"6" json:"mfa_code_digits"`     // emailed/texted codes
+	MFACodeTimeout  time.Duration `envconfig:"MFA_CODE_TIMEOUT" default:"10m" json:"mfa_code_timeout"` // how long a sent code is good for
+	MFACodeAttempts int           `envconfig:"MFA_CODE_ATTEMPTS" default:"3" json:"mfa_code_attempts"` // guesses before a code is thrown away
+	MFACodeResend   time.Duration `envconfig:"MFA_CODE_RESEND" default:"30s" json:"mfa_code_resend"`   // minimum time between sends
+
+	MagicLinkTimeout  time.Duration `envconfig:"MAGIC_LINK_TIMEOUT" default:"10m" json:"magic_link_timeout"`   // how long a sent link is good for
+	MagicLinkResend   time.Duration `envconfig:"MAGIC_LINK_RESEND" default:"1m" json:"magic_link_resend"`      // minimum time between sends to one user
+	MagicLinkMaxSends int           `envconfig:"MAGIC_LINK_MAX_SENDS" default:"5" json:"magic_link_max_sends"` // sends to one user per window; 0 never caps
+	MagicLinkWindow   time.Duration `envconfig:"MAGIC_LINK_WINDOW" default:"1h" json:"magic_link_window"`
+
+	VerifyTimeout time.Duration `envconfig:"VERIFY_TIMEOUT" default:"72h" json:"verify_timeout"`  // how long an address verification link is good for
+	VerifyKeyFile string        `envconfig:"VERIFY_KEY_FILE" json:"verify_key_file,omitempty"`    // at least 32 bytes of secret; email verification is off if empty
+	InviteTimeout time.Duration `envconfig:"INVITE_TIMEOUT" default:"168h" json:"invite_timeout"` // how long an invitation is good for
+
+	OIDCIssuer       string        `envconfig:"OIDC_ISSUER" default:"http://localhost:3000" json:"oidc_issuer"` // has to be exactly what clients see
+	OIDCKeyFile      string        `envconfig:"OIDC_KEY_FILE" json:"oidc_key_file,omitempty"`                   // PEM encoded RSA key; a throwaway one is generated if empty
+	OIDCCodeTimeout  time.Duration `envconfig:"OIDC_CODE_TIMEOUT" default:"1m" json:"oidc_code_timeout"`
+	OIDCTokenTimeout time.Duration `envconfig:"OIDC_TOKEN_TIMEOUT" default:"15m" json:"oidc_token_timeout"`
 
 	LogonURL   string `envconfig:"LOGIN_URL" default:"/authnz/login.html" json:"logon_url"`
 	ResetURL   string `envconfig:"RESET_URL" default:"/authnz/login.html?reset" json:"reset_url"`
@@ -21,27 +21,114 @@ func Test_NewSqls(t *testing.T) {
 		"happy_path": {
 			vendor: "mysql",
 			result: Sqls{
+				"api-key": map[string]string{
+					"delete":     "delete from api_keys where id = ? and user_uuid = ?",
+					"insert":     "insert into  api_keys(id, user_uuid, name, hash, scopes, expires, ctime) values  (?, ?, ?, ?, ?, ?, ?)",
+					"select":     "select  k.user_uuid, k.name, k.hash, k.scopes, k.expires, k.lastused, k.ctime from  api_keys k join  users u on u.uuid = k.user_uuid where  k.id = ? and  u.dtime is null",
+					"select-all": "select  id, name, scopes, expires, lastused, ctime from  api_keys where  user_uuid = ? order  by ctime",
+					"update":     "update api_keys set lastused = ? where id = ?",
+				},
 				"address": map[string]string{
 					"insert":     "insert into  addresses( uuid, street1, street2, city, state, country, zip, mtime, ctime) values  (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+					"owner":      "select  count(*) from  contacts where  uuid = ? and  ? in (billto_uuid, shipto_uuid)",
 					"select":     "select  uuid, street1, street2, city, state, country, zip, mtime, ctime from  addresses where  uuid = ?",
 					"select-all": "select  uuid, street1, street2, city, state, country, zip, mtime, ctime from  addresses",
 					"update":     "update  addresses set  street1 = ?, street2 = ?, city = ?, state = ?, country = ?, zip = ?, mtime = ? where  uuid = ?",
 				},
 				"basic-auth": map[string]string{
-					"select": "select  uuid, name, password, salt, loginsuccess, loginfailure, failurecount, mtime, ctime from  users where  uuid = coalesce(?, uuid) and  name = coalesce(?, name)",
-					"update": "update  users set  password = ?, salt = ?, loginsuccess = ?, loginfailure = ?, failurecount = ?, mtime = current_timestamp where  uuid = ?",
+					"active": "select  dtime, locked from  users where  uuid = ?",
+					"select": "select  uuid, name, password, salt, loginsuccess, loginfailure, failurecount, mtime, ctime, email, locked from  users where  uuid = coalesce(?, uuid) and  name = coalesce(?, name)",
+					"update": "update  users set  password = ?, salt = ?, loginsuccess = ?, loginfailure = ?, failurecount = ?, locked = ?, mtime = current_timestamp where  uuid = ?",
+					"unlock": "update  users set  failurecount = 0, locked = null, mtime = current_timestamp where  uuid = ?",
+				},
+				"invitation": map[string]string{
+					"accept":            "update  invitations set  accepted = ?, accepted_by = ? where  uuid = ? and  accepted is null and  revoked is null",
+					"insert":            "insert into  invitations(uuid, email, cell, hash, created_by, expires, ctime) values  (?, ?, ?, ?, ?, ?, ?)",
+					"revoke":            "update  invitations set  revoked = ?, revoked_by = ? where  uuid = ? and  accepted is null and  revoked is null",
+					"select":            "select  email, cell, hash, created_by, expires, accepted, accepted_by, revoked, revoked_by, ctime from  invitations where  uuid = ?",
+					"select-all":        "select  uuid, email, cell, created_by, expires, accepted, accepted_by, revoked, revoked_by, ctime from  invitations order  by ctime desc",
+					"select-for-update": "select  email, cell, hash, created_by, expires, accepted, accepted_by, revoked, revoked_by, ctime from  invitations where  uuid = ? for  update",
 				},
 				"contact": map[string]string{
 					"insert": "insert into  contacts( uuid, firstname, lastname, billto_uuid, shipto_uuid, mtime, ctime) select  uuid, ?, ?, ?, ?, ?, ? from users where uuid = ?",
 					"select": "select  firstname, lastname, billto_uuid, shipto_uuid, mtime, ctime from  contacts where  uuid = ?",
 					"update": "update  contacts set  firstname = ?, lastname = ?, billto_uuid = ?, shipto_uuid = ?, mtime = ? where  uuid = ?",
 				},
+				"password-history": map[string]string{
+					"delete": "delete from password_history where user_uuid = ?",
+					"insert": "insert into  password_history(user_uuid, password, salt, ctime) values  (?, ?, ?, ?)",
+					"prune":  "delete from  password_history where  user_uuid = ? and  id not in ( select  id from ( select  id from  password_history where  user_uuid = ? order by  ctime desc, id desc limit  ? ) keep)",
+					"select": "select  password, salt from  password_history where  user_uuid = ? order by  ctime desc, id desc limit  ?",
+				},
+				"oauth-client": map[string]string{
+					"delete": "delete from oauth_clients where client_id = ?",
+					"insert": "insert into  oauth_clients(client_id, name, secret, redirect_uris, public, mtime, ctime) values  (?, ?, ?, ?, ?, ?, ?)",
+					"select": "select  name, secret, redirect_uris, public, mtime, ctime from  oauth_clients where  client_id = ?",
+				},
+				"mfa-channel": map[string]string{
+					"delete": "delete from mfa_channel where user_uuid = ?",
+					"insert": "insert into  mfa_channel(user_uuid, channel, confirmed, mtime, ctime) values  (?, ?, null, ?, ?) on  duplicate key update channel = values(channel), confirmed = null, mtime = values(mtime)",
+					"select": "select  channel, confirmed, mtime, ctime from  mfa_channel where  user_uuid = ?",
+					"update": "update  mfa_channel set  confirmed = ?, mtime = ? where  user_uuid = ? and  confirmed is null",
+				},
+				"recovery-code": map[string]string{
+					"delete": "delete from recovery_codes where user_uuid = ?",
+					"insert": "insert into recovery_codes(user_uuid, code, ctime) values (?, ?, ?)",
+					"select": "select count(*) from recovery_codes where user_uuid = ? and used is null",
+					"prune":  "delete  from recovery_codes where  user_uuid = ? and  not exists (select 1 from totp where user_uuid = ? and confirmed is not null) and  not exists (select 1 from mfa_channel where user_uuid = ? and confirmed is not null)",
+					"update": "update  recovery_codes set  used = ? where  user_uuid = ? and  code = ? and  used is null",
+				},
+				"totp": map[string]string{
+					"delete": "delete from totp where user_uuid = ?",
+					"insert": "insert into  totp(user_uuid, secret, confirmed, mtime, ctime) values  (?, ?, null, ?, ?) on  duplicate key update secret = values(secret), confirmed = null, last_step = 0, mtime = values(mtime)",
+					"select": "select  secret, confirmed, last_step, mtime, ctime from  totp where  user_uuid = ?",
+					"update": "update  totp set  confirmed = ?, mtime = ? where  user_uuid = ? and  confirmed is null",
+					"use":    "update totp set last_step = ? where user_uuid = ? and last_step < ?",
+				},
+				"role": map[string]string{
+					"delete":     "delete from roles where uuid = ?",
+					"insert":     "insert into  roles(uuid, name, description, mtime, ctime) values  (?, ?, ?, ?, ?)",
+					"select":     "select  r.uuid, r.name, r.description, coalesce(group_concat(p.name order by p.name separator ' '), ''), r.mtime, r.ctime from  roles r left  join permissions p on p.role_uuid = r.uuid where  r.uuid = ? group  by r.uuid",
+					"select-all": "select  r.uuid, r.name, r.description, coalesce(group_concat(p.name order by p.name separator ' '), ''), r.mtime, r.ctime from  roles r left  join permissions p on p.role_uuid = r.uuid group  by r.uuid order  by r.name",
+					"update":     "update  roles set  name = ?, description = ?, mtime = ? where  uuid = ?",
+				},
+				"permission": map[string]string{
+					"delete": "delete from permissions where role_uuid = ? and name = ?",
+					"insert": "insert into  permissions(role_uuid, name, ctime) values  (?, ?, ?) on  duplicate key update name = name",
+				},
+				"user-role": map[string]string{
+					"check":  "select  count(*) from  user_roles ur join  permissions p on p.role_uuid = ur.role_uuid where  ur.user_uuid = ? and  p.name = ?",
+					"delete": "delete from user_roles where user_uuid = ? and role_uuid = ?",
+					"insert": "insert into  user_roles(user_uuid, role_uuid, ctime) values  (?, ?, ?) on  duplicate key update role_uuid = role_uuid",
+					"select": "select  r.uuid, r.name, r.description, coalesce(group_concat(p.name order by p.name separator ' '), ''), r.mtime, r.ctime from  user_roles ur join  roles r on r.uuid = ur.role_uuid left  join permissions p on p.role_uuid = r.uuid where  ur.user_uuid = ? group  by r.uuid order  by r.name",
+				},
 				"user": map[string]string{
-					"delete":     "update users set dtime = ? where uuid = ?",
-					"insert":     "insert into  users(uuid, name, email, cell, password, salt, mtime, ctime) values  (?, ?, ?, ?, ?, ?, ?, ?)",
-					"select":     "select  uuid, name, email, cell, mtime, ctime, dtime from  users where  uuid = ?",
-					"select-all": "select  uuid, name, mtime, ctime, dtime from  users",
-					"update":     "update  users set  name = ?, email = ?, cell = ?, mtime = ? where  uuid = ?",
+					"delete":       "update users set dtime = ? where uuid = ?",
+					"insert":       "insert into  users(uuid, name, email, cell, password, salt, mtime, ctime) values  (?, ?, ?, ?, ?, ?, ?, ?)",
+					"select":       "select  uuid, name, email, cell, email_verified, cell_verified, mtime, ctime, dtime from  users where  uuid = ?",
+					"select-all":   "select  uuid, name, mtime, ctime, dtime from  users",
+					"update":       "update  users set  name = ?, email_verified = case when email <=> ? then email_verified end, email = ?, cell_verified = case when cell <=> ? then cell_verified end, cell = ?, mtime = ? where  uuid = ?",
+					"verify-cell":  "update  users set  cell_verified = ? where  uuid = ? and  cell = ?",
+					"verify-email": "update  users set  email_verified = ? where  uuid = ? and  email = ?",
+				},
+				"magic-link": map[string]string{
+					"delete": "delete from magic_links where user_uuid = ?",
+					"insert": "insert into  magic_links(user_uuid, channel, mtime, ctime) values  (?, ?, ?, ?) on  duplicate key update channel = values(channel), mtime = values(mtime)",
+					"select": "select  channel, mtime, ctime from  magic_links where  user_uuid = ?",
+				},
+				"session-store": map[string]string{
+					"count-fields":  "select count(*) from session_fields where name = ?",
+					"delete-field":  "delete from session_fields where name = ? and field = ?",
+					"delete-key":    "delete from session_keys where name = ?",
+					"expire":        "update session_keys set expires = ? where name = ?",
+					"insert-key":    "insert into  session_keys(name, kind, expires) values  (?, ?, ?)",
+					"insert-member": "insert ignore into session_fields(name, field) values (?, ?)",
+					"prune":         "delete from session_keys where name like ? and expires <= ?",
+					"select-field":  "select value from session_fields where name = ? and field = ?",
+					"select-fields": "select  field, value from  session_fields where  name = ? order  by field",
+					"select-key":    "select  kind, expires from  session_keys where  name = ? for  update",
+					"select-keys":   "select  name from  session_keys where  name like ? order  by name",
+					"upsert-field":  "insert into  session_fields(name, field, value) values  (?, ?, ?) on  duplicate key update value = values(value)",
 				},
 			},
 		},
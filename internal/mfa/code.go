@@ -0,0 +1,23 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// NumericCode is a random, zero-padded code short enough to read off a text
+// message; it's only good for one delivery, so it doesn't need the entropy
+// of a recovery code
+func NumericCode(digits int) (string, error) {
+	if digits < 1 || digits > 18 {
+		return "", fmt.Errorf("can't generate a %d digit code", digits)
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(0).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", digits, n.Int64()), nil
+}
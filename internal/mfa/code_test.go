@@ -0,0 +1,38 @@
+package mfa
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NumericCode(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		digits int
+		err    error
+	}{
+		"six":      {digits: 6},
+		"one":      {digits: 1},
+		"eighteen": {digits: 18},
+		"zero":     {digits: 0, err: fmt.Errorf("can't generate a 0 digit code")},
+		"too_many": {digits: 19, err: fmt.Errorf("can't generate a 19 digit code")},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			code, err := NumericCode(tc.digits)
+			require.Equal(t, tc.err, err)
+			if err == nil {
+				require.Regexp(t, regexp.MustCompile(fmt.Sprintf(`^[0-9]{%d}$`, tc.digits)), code)
+			}
+		})
+	}
+}
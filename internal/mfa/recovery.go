@@ -0,0 +1,33 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const recoveryLen = 10 // base32 characters, 50 bits each
+
+// RecoveryCodes generates n single-use codes formatted for people to copy
+// down, like "abcde-fghij"
+func RecoveryCodes(n int) ([]string, error) {
+	result := make([]string, 0, n)
+	b := make([]byte, recoveryLen*5/8)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		result = append(result, code[:recoveryLen/2]+"-"+code[recoveryLen/2:])
+	}
+	return result, nil
+}
+
+// HashRecoveryCode is what gets stored and compared; dashes, spaces and case
+// don't matter when a user types one back in
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
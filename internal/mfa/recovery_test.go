@@ -0,0 +1,37 @@
+package mfa
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes, err := RecoveryCodes(10)
+	require.Nil(t, err)
+	require.Len(t, codes, 10)
+
+	seen := map[string]struct{}{}
+	for _, code := range codes {
+		require.Regexp(t, regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`), code)
+		seen[code] = struct{}{}
+	}
+	require.Len(t, seen, 10)
+
+	codes, err = RecoveryCodes(0)
+	require.Nil(t, err)
+	require.Empty(t, codes)
+}
+
+func Test_HashRecoveryCode(t *testing.T) {
+	t.Parallel()
+
+	hash := HashRecoveryCode("abcde-fghij")
+	require.Len(t, hash, 64)
+	require.Equal(t, hash, HashRecoveryCode("ABCDEFGHIJ"))
+	require.Equal(t, hash, HashRecoveryCode(" abcde fghij "))
+	require.NotEqual(t, hash, HashRecoveryCode("abcde-fghik"))
+}
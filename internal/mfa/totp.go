@@ -0,0 +1,135 @@
+// Package mfa holds the second factors that don't need a database of their
+// own: RFC 6238 time-based codes for authenticator apps
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/userservice/internal/config"
+)
+
+const (
+	secretLen = 20 // 160 bits, what RFC 4226 recommends for SHA1
+	digits    = 6
+	period    = 30 * time.Second
+)
+
+var (
+	encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	BadSecretError = fmt.Errorf("totp secret isn't valid base32")
+)
+
+type TOTP struct {
+	Issuer string
+	Digits int
+	Period time.Duration
+	Skew   int // how many periods either side of now are still accepted
+	Now    func() time.Time
+}
+
+func NewTOTP(cfg *config.Config) *TOTP {
+	return &TOTP{
+		Issuer: cfg.MFAIssuer,
+		Digits: digits,
+		Period: period,
+		Skew:   cfg.TOTPSkew,
+		Now:    time.Now,
+	}
+}
+
+// Secret generates a new shared secret, base32 encoded the way authenticator
+// apps expect it
+func (t *TOTP) Secret() (string, error) {
+	b := make([]byte, secretLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI is the otpauth:// link that gets rendered as a QR code for enrollment
+func (t *TOTP) URI(account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", t.Issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(t.Digits))
+	q.Set("period", fmt.Sprint(int(t.Period/time.Second)))
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + t.Issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}).String()
+}
+
+// Code is the value an authenticator app would show at time `at`
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.hotp(key, uint64(at.Unix())/uint64(t.Period/time.Second)), nil
+}
+
+// Verify checks code against the current period and Skew periods either side
+// of it, to allow for clock drift and slow typists, and says which period it
+// matched; a code from used or any period before it was already spent, so it
+// doesn't count again (RFC 6238 section 5.2)
+func (t *TOTP) Verify(secret, code string, used int64) (int64, bool, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return 0, false, err
+	} else if len(code) != t.Digits {
+		return 0, false, nil
+	}
+
+	counter := int64(t.Now().Unix()) / int64(t.Period/time.Second)
+	for i := -int64(t.Skew); i <= int64(t.Skew); i++ {
+		if step := counter + i; step < 0 || step <= used {
+			continue
+		} else if hmac.Equal([]byte(t.hotp(key, uint64(step))), []byte(code)) {
+			return step, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// hotp is RFC 4226 section 5.3
+func (t *TOTP) hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", t.Digits, value%mod)
+}
+
+func decode(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", BadSecretError, err)
+	}
+	return key, nil
+}
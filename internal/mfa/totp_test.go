@@ -0,0 +1,145 @@
+package mfa
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/config"
+)
+
+// the SHA1 seed from RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func fixedClock(sec int64) func() time.Time {
+	return func() time.Time { return time.Unix(sec, 0).UTC() }
+}
+
+func Test_NewTOTP(t *testing.T) {
+	t.Parallel()
+
+	totp := NewTOTP(&config.Config{MFAIssuer: "cffc", TOTPSkew: 2})
+	require.Equal(t, "cffc", totp.Issuer)
+	require.Equal(t, 6, totp.Digits)
+	require.Equal(t, 30*time.Second, totp.Period)
+	require.Equal(t, 2, totp.Skew)
+	require.NotNil(t, totp.Now)
+}
+
+func Test_Code(t *testing.T) {
+	t.Parallel()
+
+	totp := &TOTP{Digits: 8, Period: period}
+
+	// RFC 6238 appendix B, SHA1 column
+	tcs := map[string]struct {
+		at   int64
+		code string
+	}{
+		"59":          {at: 59, code: "94287082"},
+		"1111111109":  {at: 1111111109, code: "07081804"},
+		"1111111111":  {at: 1111111111, code: "14050471"},
+		"1234567890":  {at: 1234567890, code: "89005924"},
+		"2000000000":  {at: 2000000000, code: "69279037"},
+		"20000000000": {at: 20000000000, code: "65353130"},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			code, err := totp.Code(rfcSecret, time.Unix(tc.at, 0))
+			require.Nil(t, err)
+			require.Equal(t, tc.code, code)
+		})
+	}
+
+	_, err := totp.Code("not base32!", time.Now())
+	require.ErrorIs(t, err, BadSecretError)
+}
+
+func Test_Verify(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		secret, code string
+		now          int64
+		skew         int
+		used         int64
+		step         int64
+		ok           bool
+		err          error
+	}{
+		"happy_path":       {secret: rfcSecret, code: "287082", now: 59, step: 1, ok: true},
+		"lowercase_secret": {secret: strings.ToLower(rfcSecret), code: "287082", now: 59, step: 1, ok: true},
+		"previous_period":  {secret: rfcSecret, code: "287082", now: 89, skew: 1, step: 1, ok: true},
+		"outside_skew":     {secret: rfcSecret, code: "287082", now: 89},
+		"first_period":     {secret: rfcSecret, code: "287082", now: 30, skew: 3, step: 1, ok: true},
+		"already_used":     {secret: rfcSecret, code: "287082", now: 59, skew: 1, used: 1},
+		"later_used":       {secret: rfcSecret, code: "287082", now: 59, skew: 1, used: 2},
+		"wrong_code":       {secret: rfcSecret, code: "123456", now: 59, skew: 1},
+		"wrong_length":     {secret: rfcSecret, code: "94287082", now: 59},
+		"bad_secret":       {secret: "1!", code: "123456", now: 59, err: BadSecretError},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			step, ok, err := (&TOTP{
+				Digits: digits,
+				Period: period,
+				Skew:   tc.skew,
+				Now:    fixedClock(tc.now),
+			}).Verify(tc.secret, tc.code, tc.used)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.step, step)
+		})
+	}
+}
+
+func Test_VerifyOnce(t *testing.T) {
+	t.Parallel()
+
+	totp := &TOTP{Digits: digits, Period: period, Skew: 1, Now: fixedClock(59)}
+
+	step, ok, err := totp.Verify(rfcSecret, "287082", 0)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// the same code, still inside the window, is no good the second time
+	_, ok, err = totp.Verify(rfcSecret, "287082", step)
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func Test_SecretURI(t *testing.T) {
+	t.Parallel()
+
+	totp := &TOTP{Issuer: "cffc", Digits: digits, Period: period, Now: fixedClock(59)}
+
+	secret, err := totp.Secret()
+	require.Nil(t, err)
+	require.Len(t, secret, 32)
+
+	again, _ := totp.Secret()
+	require.NotEqual(t, secret, again)
+
+	code, err := totp.Code(secret, totp.Now())
+	require.Nil(t, err)
+	_, ok, err := totp.Verify(secret, code, 0)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	require.Equal(t,
+		"otpauth://totp/cffc:jimbo@example.com?algorithm=SHA1&digits=6&issuer=cffc&period=30&secret="+secret,
+		totp.URI("jimbo@example.com", secret))
+}
@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type (
+	// Profile is the part of a shared.User a client gets to see, depending on
+	// the scopes it asked for
+	Profile struct {
+		Name              string        `json:"name,omitempty"`
+		GivenName         string        `json:"given_name,omitempty"`
+		FamilyName        string        `json:"family_name,omitempty"`
+		PreferredUsername string        `json:"preferred_username,omitempty"`
+		Email             *shared.Email `json:"email,omitempty"`
+		PhoneNumber       *shared.Cell  `json:"phone_number,omitempty"`
+		UpdatedAt         int64         `json:"updated_at,omitempty"`
+	}
+
+	IDClaims struct {
+		jwt.StandardClaims
+		Nonce string `json:"nonce,omitempty"`
+		Profile
+	}
+
+	// VerifyClaims go out in address verification links; Purpose is what
+	// keeps an ID token, which can carry an email too, from passing for one
+	VerifyClaims struct {
+		jwt.StandardClaims
+		Purpose string        `json:"purpose"`
+		Email   *shared.Email `json:"email,omitempty"`
+	}
+
+	// AccessClaims are what userinfo takes; TokenUse is what keeps any other
+	// token signed with the same key from passing for one
+	AccessClaims struct {
+		jwt.StandardClaims
+		TokenUse string `json:"token_use"`
+		Scope    string `json:"scope"`
+		ClientID string `json:"client_id"`
+	}
+
+	UserInfo struct {
+		Subject shared.UUID `json:"sub"`
+		Profile
+	}
+
+	// Tokens is the token endpoint's response
+	Tokens struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		IDToken     string `json:"id_token"`
+		Scope       string `json:"scope"`
+	}
+)
+
+const (
+	VerifyEmailPurpose = "verify_email"
+	AccessTokenUse     = "access"
+)
+
+func NewProfile(u *shared.User, scope string) Profile {
+	var result Profile
+
+	if HasScope(scope, "profile") {
+		result.PreferredUsername = u.Name
+		result.UpdatedAt = u.MTime.Unix()
+		if u.Contact != nil {
+			result.GivenName = u.Contact.FirstName
+			result.FamilyName = u.Contact.LastName
+			result.Name = strings.TrimSpace(u.Contact.FirstName + " " + u.Contact.LastName)
+		}
+	}
+	if HasScope(scope, "email") {
+		result.Email = u.Email
+	}
+	if HasScope(scope, "phone") {
+		result.PhoneNumber = u.Cell
+	}
+
+	return result
+}
+
+func HasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_NewProfile(t *testing.T) {
+	t.Parallel()
+
+	email := shared.Email("user@example.com")
+	cell := shared.Cell("+15555555555")
+	mtime := time.Unix(1700000000, 0).UTC()
+	user := &shared.User{
+		Name:    "username",
+		Email:   &email,
+		Cell:    &cell,
+		Contact: &shared.Contact{FirstName: "First", LastName: "Last"},
+		MTime:   mtime,
+	}
+
+	tcs := map[string]struct {
+		user   *shared.User
+		scope  string
+		result Profile
+	}{
+		"openid_only": {
+			user:  user,
+			scope: "openid",
+		},
+		"everything": {
+			user:  user,
+			scope: "openid profile email phone",
+			result: Profile{
+				Name:              "First Last",
+				GivenName:         "First",
+				FamilyName:        "Last",
+				PreferredUsername: "username",
+				Email:             &email,
+				PhoneNumber:       &cell,
+				UpdatedAt:         mtime.Unix(),
+			},
+		},
+		"profile_without_contact": {
+			user:  &shared.User{Name: "username", MTime: mtime},
+			scope: "openid profile",
+			result: Profile{
+				PreferredUsername: "username",
+				UpdatedAt:         mtime.Unix(),
+			},
+		},
+		"email": {
+			user:   user,
+			scope:  "email openid",
+			result: Profile{Email: &email},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.result, NewProfile(tc.user, tc.scope))
+		})
+	}
+}
+
+func Test_NewDiscovery(t *testing.T) {
+	t.Parallel()
+
+	d := NewDiscovery("https://us.example.com/")
+	require.Equal(t, "https://us.example.com", d.Issuer)
+	require.Equal(t, "https://us.example.com/oauth/authorize", d.AuthorizationEndpoint)
+	require.Equal(t, "https://us.example.com/oauth/token", d.TokenEndpoint)
+	require.Equal(t, "https://us.example.com/oauth/userinfo", d.UserInfoEndpoint)
+	require.Equal(t, "https://us.example.com/oauth/jwks", d.JWKSURI)
+	require.Equal(t, []string{"S256"}, d.CodeChallengeMethods)
+}
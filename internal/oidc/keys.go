@@ -0,0 +1,191 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/userservice/internal/config"
+)
+
+type (
+	// Keys signs everything the provider hands out and publishes the public
+	// half for anyone who wants to check
+	Keys struct {
+		ID      string
+		issuer  string
+		private *rsa.PrivateKey
+	}
+
+	// VerifyKey signs address verification links; they're good for days, so
+	// unlike Keys it can't be generated, it has to be a secret that outlives
+	// a restart
+	VerifyKey struct {
+		issuer string
+		secret []byte
+	}
+
+	JWK struct {
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+
+	JWKS struct {
+		Keys []JWK `json:"keys"`
+	}
+
+	issued interface {
+		jwt.Claims
+		VerifyIssuer(string, bool) bool
+	}
+)
+
+var (
+	BadTokenError    = fmt.Errorf("token wasn't issued by us")
+	NoVerifyKeyError = fmt.Errorf("no verify key is configured")
+)
+
+// verifyKeyMin is the shortest secret that's as strong as the hash it keys
+const verifyKeyMin = 32
+
+func NewKeys(cfg *config.Config, log *logrus.Entry) (*Keys, error) {
+	if cfg.OIDCKeyFile == "" {
+		log.WithField("pkg", "oidc").Warn("no key file, generating one; tokens won't survive a restart")
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return newKeys(key, cfg.OIDCIssuer), nil
+	}
+
+	b, err := os.ReadFile(cfg.OIDCKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(b) // pkcs1 or pkcs8
+	if err != nil {
+		return nil, err
+	}
+
+	return newKeys(key, cfg.OIDCIssuer), nil
+}
+
+func newKeys(key *rsa.PrivateKey, issuer string) *Keys {
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey) // rsa keys always marshal
+	sum := sha256.Sum256(der)
+
+	return &Keys{
+		ID:      base64.RawURLEncoding.EncodeToString(sum[:12]),
+		issuer:  strings.TrimRight(issuer, "/"),
+		private: key,
+	}
+}
+
+func (k *Keys) Sign(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = k.ID
+	return t.SignedString(k.private)
+}
+
+// Parse fills in claims from a token, as long as it's one of ours: right
+// algorithm, right key, right issuer and not expired
+func (k *Keys) Parse(token string, claims issued) error {
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodRS256 {
+			return nil, BadTokenError
+		} else if kid, _ := t.Header["kid"].(string); kid != k.ID {
+			return nil, BadTokenError
+		}
+		return &k.private.PublicKey, nil
+	})
+	if err != nil {
+		return err
+	} else if !claims.VerifyIssuer(k.issuer, true) {
+		return BadTokenError
+	}
+	return nil
+}
+
+func (k *Keys) JWKS() JWKS {
+	return JWKS{Keys: []JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: jwt.SigningMethodRS256.Alg(),
+		Kid: k.ID,
+		N:   base64.RawURLEncoding.EncodeToString(k.private.PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.private.PublicKey.E)).Bytes()),
+	}}}
+}
+
+// NewVerifyKey reads the secret from VERIFY_KEY_FILE; without one it's nil,
+// and a nil VerifyKey refuses to sign or parse anything
+func NewVerifyKey(cfg *config.Config, log *logrus.Entry) (*VerifyKey, error) {
+	if cfg.VerifyKeyFile == "" {
+		log.WithField("pkg", "oidc").Warn("no verify key file, email verification is off")
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(cfg.VerifyKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := []byte(strings.TrimSpace(string(b)))
+	if len(secret) < verifyKeyMin {
+		return nil, fmt.Errorf("verify key needs at least %d bytes, got %d", verifyKeyMin, len(secret))
+	}
+
+	return &VerifyKey{issuer: strings.TrimRight(cfg.OIDCIssuer, "/"), secret: secret}, nil
+}
+
+func (k *VerifyKey) Sign(claims jwt.Claims) (string, error) {
+	if k == nil {
+		return "", NoVerifyKeyError
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+}
+
+// Parse is Keys.Parse for verification links: right algorithm, right
+// secret, right issuer and not expired
+func (k *VerifyKey) Parse(token string, claims issued) error {
+	if k == nil {
+		return NoVerifyKeyError
+	}
+
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, BadTokenError
+		}
+		return k.secret, nil
+	})
+	if err != nil {
+		return err
+	} else if !claims.VerifyIssuer(k.issuer, true) {
+		return BadTokenError
+	}
+	return nil
+}
+
+// NewSecret is for confidential clients; it's shown once and only a hash is
+// kept
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,340 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/config"
+)
+
+var testKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+func Test_NewKeys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name string, b []byte) string {
+		path := filepath.Join(dir, name)
+		require.Nil(t, os.WriteFile(path, b, 0o600))
+		return path
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(testKey)
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		file string
+		err  bool
+	}{
+		"generated": {},
+		"pkcs1": {
+			file: write("pkcs1.pem", pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(testKey),
+			})),
+		},
+		"pkcs8": {
+			file: write("pkcs8.pem", pem.EncodeToMemory(&pem.Block{
+				Type:  "PRIVATE KEY",
+				Bytes: pkcs8,
+			})),
+		},
+		"missing_file": {
+			file: filepath.Join(dir, "missing.pem"),
+			err:  true,
+		},
+		"not_a_key": {
+			file: write("garbage.pem", []byte("garbage")),
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := NewKeys(
+				&config.Config{OIDCIssuer: "https://us.example.com", OIDCKeyFile: tc.file},
+				logrus.WithField("test", name))
+			if tc.err {
+				require.NotNil(t, err)
+				require.Nil(t, keys)
+				return
+			}
+			require.Nil(t, err)
+			require.NotEmpty(t, keys.ID)
+			if tc.file != "" {
+				require.Equal(t, newKeys(testKey, "").ID, keys.ID)
+			}
+		})
+	}
+}
+
+func Test_SignParse(t *testing.T) {
+	t.Parallel()
+
+	keys := newKeys(testKey, "https://us.example.com/")
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	now := time.Now().UTC()
+
+	claims := func(iss string, exp time.Time) *AccessClaims {
+		return &AccessClaims{
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    iss,
+				Subject:   "uuid",
+				ExpiresAt: exp.Unix(),
+			},
+			Scope: "openid email",
+		}
+	}
+
+	tcs := map[string]struct {
+		token func() string
+		err   bool
+	}{
+		"happy_path": {
+			token: func() string {
+				s, _ := keys.Sign(claims("https://us.example.com", now.Add(time.Minute)))
+				return s
+			},
+		},
+		"expired": {
+			token: func() string {
+				s, _ := keys.Sign(claims("https://us.example.com", now.Add(-time.Minute)))
+				return s
+			},
+			err: true,
+		},
+		"wrong_issuer": {
+			token: func() string {
+				s, _ := keys.Sign(claims("https://evil.example.com", now.Add(time.Minute)))
+				return s
+			},
+			err: true,
+		},
+		"wrong_key": {
+			token: func() string {
+				s, _ := newKeys(other, "https://us.example.com").Sign(claims("https://us.example.com", now.Add(time.Minute)))
+				return s
+			},
+			err: true,
+		},
+		"forged_kid": {
+			token: func() string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims("https://us.example.com", now.Add(time.Minute)))
+				tok.Header["kid"] = keys.ID
+				s, _ := tok.SignedString(other)
+				return s
+			},
+			err: true,
+		},
+		"wrong_alg": {
+			token: func() string {
+				tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims("https://us.example.com", now.Add(time.Minute)))
+				tok.Header["kid"] = keys.ID
+				s, _ := tok.SignedString([]byte("secret"))
+				return s
+			},
+			err: true,
+		},
+		"garbage": {
+			token: func() string { return "not.a.token" },
+			err:   true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result := &AccessClaims{}
+			err := keys.Parse(tc.token(), result)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, "uuid", result.Subject)
+			require.Equal(t, "openid email", result.Scope)
+		})
+	}
+}
+
+func Test_NewVerifyKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, secret string) string {
+		path := filepath.Join(dir, name)
+		require.Nil(t, os.WriteFile(path, []byte(secret), 0o600))
+		return path
+	}
+
+	tcs := map[string]struct {
+		file string
+		none bool
+		err  bool
+	}{
+		"happy_path": {
+			file: write("good", "0123456789abcdef0123456789abcdef\n"),
+		},
+		"no_file": {
+			none: true,
+		},
+		"missing_file": {
+			file: filepath.Join(dir, "missing"),
+			none: true,
+			err:  true,
+		},
+		"too_short": {
+			file: write("short", "secret"),
+			none: true,
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			key, err := NewVerifyKey(
+				&config.Config{OIDCIssuer: "https://us.example.com", VerifyKeyFile: tc.file},
+				logrus.WithField("test", name))
+			require.Equal(t, tc.err, err != nil)
+			require.Equal(t, tc.none, key == nil)
+		})
+	}
+}
+
+func Test_VerifyKeySignParse(t *testing.T) {
+	t.Parallel()
+
+	key := &VerifyKey{issuer: "https://us.example.com", secret: []byte("0123456789abcdef0123456789abcdef")}
+	now := time.Now().UTC()
+
+	claims := func(iss string, exp time.Time) *VerifyClaims {
+		return &VerifyClaims{
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    iss,
+				Subject:   "uuid",
+				ExpiresAt: exp.Unix(),
+			},
+			Purpose: VerifyEmailPurpose,
+		}
+	}
+
+	tcs := map[string]struct {
+		token func() string
+		err   bool
+	}{
+		"happy_path": {
+			token: func() string {
+				s, _ := key.Sign(claims("https://us.example.com", now.Add(time.Minute)))
+				return s
+			},
+		},
+		"expired": {
+			token: func() string {
+				s, _ := key.Sign(claims("https://us.example.com", now.Add(-time.Minute)))
+				return s
+			},
+			err: true,
+		},
+		"wrong_issuer": {
+			token: func() string {
+				s, _ := key.Sign(claims("https://evil.example.com", now.Add(time.Minute)))
+				return s
+			},
+			err: true,
+		},
+		"wrong_secret": {
+			token: func() string {
+				s, _ := (&VerifyKey{secret: []byte("fedcba9876543210fedcba9876543210")}).
+					Sign(claims("https://us.example.com", now.Add(time.Minute)))
+				return s
+			},
+			err: true,
+		},
+		"provider_key": {
+			token: func() string {
+				s, _ := newKeys(testKey, "https://us.example.com").Sign(claims("https://us.example.com", now.Add(time.Minute)))
+				return s
+			},
+			err: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result := &VerifyClaims{}
+			err := key.Parse(tc.token(), result)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, "uuid", result.Subject)
+			require.Equal(t, VerifyEmailPurpose, result.Purpose)
+		})
+	}
+
+	// without a key there's nothing to sign or check with
+	var none *VerifyKey
+	_, err := none.Sign(claims("https://us.example.com", now.Add(time.Minute)))
+	require.Equal(t, NoVerifyKeyError, err)
+	require.Equal(t, NoVerifyKeyError, none.Parse("not.a.token", &VerifyClaims{}))
+}
+
+func Test_JWKS(t *testing.T) {
+	t.Parallel()
+
+	keys := newKeys(testKey, "https://us.example.com")
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 1)
+
+	jwk := jwks.Keys[0]
+	require.Equal(t, "RSA", jwk.Kty)
+	require.Equal(t, "RS256", jwk.Alg)
+	require.Equal(t, keys.ID, jwk.Kid)
+
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	require.Nil(t, err)
+	require.Equal(t, testKey.PublicKey.N, big.NewInt(0).SetBytes(n))
+
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	require.Nil(t, err)
+	require.Equal(t, int64(testKey.PublicKey.E), big.NewInt(0).SetBytes(e).Int64())
+}
+
+func Test_NewSecret(t *testing.T) {
+	t.Parallel()
+
+	a, err := NewSecret()
+	require.Nil(t, err)
+	b, err := NewSecret()
+	require.Nil(t, err)
+	require.Len(t, a, 43)
+	require.NotEqual(t, a, b)
+}
@@ -0,0 +1,30 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// RFC 7636 section 4.1: 43-128 unreserved characters; an S256 challenge is
+// always the 43 character encoding of a sha256
+var (
+	verifierFormat  = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+	challengeFormat = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+)
+
+func ValidChallenge(challenge string) bool {
+	return challengeFormat.MatchString(challenge)
+}
+
+// VerifyPKCE only knows S256; plain doesn't protect anything the client
+// couldn't protect with S256
+func VerifyPKCE(challenge, verifier string) bool {
+	if !verifierFormat.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	want := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(want), []byte(challenge)) == 1
+}
@@ -0,0 +1,47 @@
+package oidc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_VerifyPKCE(t *testing.T) {
+	t.Parallel()
+
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tcs := map[string]struct {
+		challenge, verifier string
+		result              bool
+	}{
+		"rfc_vector":      {challenge: challenge, verifier: verifier, result: true},
+		"wrong_verifier":  {challenge: challenge, verifier: strings.Replace(verifier, "d", "e", 1)},
+		"short_verifier":  {challenge: challenge, verifier: verifier[:42]},
+		"long_verifier":   {challenge: challenge, verifier: strings.Repeat("a", 129)},
+		"bad_characters":  {challenge: challenge, verifier: verifier[:42] + "+"},
+		"empty_challenge": {verifier: verifier},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.result, VerifyPKCE(tc.challenge, tc.verifier))
+		})
+	}
+}
+
+func Test_ValidChallenge(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, ValidChallenge("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"))
+	require.False(t, ValidChallenge(""))
+	require.False(t, ValidChallenge("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-c"))
+	require.False(t, ValidChallenge("E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw+cM"))
+}
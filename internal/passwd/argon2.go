@@ -0,0 +1,76 @@
+package passwd
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type argon2Scheme struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func (a *argon2Scheme) hash(pass shared.Password) (shared.Password, error) {
+	salt, err := generateSalt()
+	if err != nil {
+		return "", err
+	}
+
+	return a.encode(salt, a.key(pass, salt, keyLen)), nil
+}
+
+func (a *argon2Scheme) verify(pass, stored shared.Password) (bool, bool, error) {
+	params, salt, key, err := a.decode(stored)
+	if err != nil {
+		return false, false, err
+	}
+
+	ok := subtle.ConstantTimeCompare(params.key(pass, salt, uint32(len(key))), key) == 1
+
+	return ok, *params == *a, nil
+}
+
+func (a *argon2Scheme) key(pass shared.Password, salt []byte, keyLen uint32) []byte {
+	return argon2.IDKey([]byte(pass), salt, a.time, a.memory, a.threads, keyLen)
+}
+
+func (a *argon2Scheme) encode(salt, key []byte) shared.Password {
+	return shared.Password(fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id,
+		argon2.Version,
+		a.memory,
+		a.time,
+		a.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)))
+}
+
+func (a *argon2Scheme) decode(stored shared.Password) (*argon2Scheme, []byte, []byte, error) {
+	var version int
+	result := &argon2Scheme{}
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	if parts := strings.Split(string(stored), "$"); len(parts) != 6 {
+		return nil, nil, nil, MalformedHashError
+	} else if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", MalformedHashError, err)
+	} else if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("%w: unsupported argon2 version %d", MalformedHashError, version)
+	} else if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &result.memory, &result.time, &result.threads); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", MalformedHashError, err)
+	} else if salt, err := base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", MalformedHashError, err)
+	} else if key, err := base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", MalformedHashError, err)
+	} else {
+		return result, salt, key, nil
+	}
+}
@@ -0,0 +1,43 @@
+package passwd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_argon2Decode(t *testing.T) {
+	t.Parallel()
+
+	a := &argon2Scheme{time: 1, memory: 64, threads: 1}
+	good, err := a.hash("snakeoil")
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		stored shared.Password
+		params *argon2Scheme
+		err    error
+	}{
+		"happy_path":    {stored: good, params: a},
+		"too_few_parts": {stored: "$argon2id$v=19$m=64,t=1,p=1$salt", err: MalformedHashError},
+		"bad_version":   {stored: "$argon2id$v=x$m=64,t=1,p=1$c2FsdA$a2V5", err: MalformedHashError},
+		"old_version":   {stored: "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", err: MalformedHashError},
+		"bad_params":    {stored: "$argon2id$v=19$m=64;t=1;p=1$c2FsdA$a2V5", err: MalformedHashError},
+		"bad_salt":      {stored: "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5", err: MalformedHashError},
+		"bad_key":       {stored: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$!!!", err: MalformedHashError},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			params, _, _, err := a.decode(tc.stored)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.params, params)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package passwd
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type bcryptScheme struct {
+	cost int
+}
+
+// bcrypt already has its own modular crypt format ($2a$12$<salt+key>), and
+// generates its own salt
+func (b *bcryptScheme) hash(pass shared.Password) (shared.Password, error) {
+	result, err := bcrypt.GenerateFromPassword([]byte(pass), b.cost)
+	return shared.Password(result), err
+}
+
+func (b *bcryptScheme) verify(pass, stored shared.Password) (bool, bool, error) {
+	cost, err := bcrypt.Cost([]byte(stored))
+	if err != nil {
+		return false, false, fmt.Errorf("%w: %v", MalformedHashError, err)
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(stored), []byte(pass))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	} else if err != nil {
+		return false, false, fmt.Errorf("%w: %v", MalformedHashError, err)
+	}
+
+	return true, cost == b.cost, nil
+}
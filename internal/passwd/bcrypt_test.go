@@ -0,0 +1,35 @@
+package passwd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_bcryptVerify(t *testing.T) {
+	t.Parallel()
+
+	b := &bcryptScheme{cost: 4}
+	good, err := b.hash("snakeoil")
+	require.Nil(t, err)
+
+	ok, current, err := b.verify("snakeoil", good)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.True(t, current)
+
+	ok, current, err = (&bcryptScheme{cost: 5}).verify("snakeoil", good)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.False(t, current)
+
+	ok, _, err = b.verify("snakeoyl", good)
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	_, _, err = b.verify("snakeoil", "$2a$")
+	require.ErrorIs(t, err, MalformedHashError)
+
+	_, _, err = b.verify("snakeoil", good[:len(good)-10])
+	require.ErrorIs(t, err, MalformedHashError)
+}
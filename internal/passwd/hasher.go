@@ -0,0 +1,132 @@
+package passwd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+	Scrypt   = "scrypt"
+
+	saltLen = 16
+	keyLen  = 32
+
+	// Unusable is stored for accounts that haven't got a password yet; Hash
+	// never produces it and Verify never accepts it
+	Unusable shared.Password = "!"
+)
+
+type (
+	// Hasher turns plaintext passwords into self-describing, PHC-style strings
+	// and verifies plaintext against anything we've ever stored, including the
+	// legacy password+salt concatenation
+	Hasher interface {
+		Hash(shared.Password) (shared.Password, error)
+		// Verify reports whether the password matches and, if it does, whether
+		// the stored value should be replaced with a fresh Hash
+		Verify(pass, stored shared.Password, salt string) (ok, rehash bool, err error)
+	}
+
+	scheme interface {
+		hash(shared.Password) (shared.Password, error)
+		verify(pass, stored shared.Password) (ok, current bool, err error)
+	}
+
+	hasher struct {
+		current string
+		schemes map[string]scheme
+	}
+)
+
+var (
+	UnknownSchemeError = fmt.Errorf("unknown password hashing scheme")
+	MalformedHashError = fmt.Errorf("malformed password hash")
+)
+
+func NewHasher(cfg *config.Config) (Hasher, error) {
+	result := &hasher{
+		current: cfg.PasswordHash,
+		schemes: map[string]scheme{
+			Argon2id: &argon2Scheme{
+				time:    cfg.Argon2Time,
+				memory:  cfg.Argon2Memory,
+				threads: cfg.Argon2Threads,
+			},
+			Bcrypt: &bcryptScheme{cost: cfg.BcryptCost},
+			Scrypt: &scryptScheme{
+				logN: cfg.ScryptLogN,
+				r:    cfg.ScryptR,
+				p:    cfg.ScryptP,
+			},
+		},
+	}
+
+	if _, ok := result.schemes[result.current]; !ok {
+		return nil, fmt.Errorf("%w: %q", UnknownSchemeError, result.current)
+	}
+
+	return result, nil
+}
+
+func (h *hasher) Hash(pass shared.Password) (shared.Password, error) {
+	return h.schemes[h.current].hash(pass)
+}
+
+func (h *hasher) Verify(pass, stored shared.Password, salt string) (bool, bool, error) {
+	if pass == "" || stored == "" || stored == Unusable {
+		return false, false, nil
+	}
+
+	id, ok := identify(stored)
+	if !ok {
+		ok = verifyLegacy(pass, stored, salt)
+		return ok, ok, nil
+	} else if s, ok := h.schemes[id]; !ok {
+		// plaintext is allowed to start with a '$' too
+		if verifyLegacy(pass, stored, salt) {
+			return true, true, nil
+		}
+		return false, false, fmt.Errorf("%w: %q", UnknownSchemeError, id)
+	} else if ok, current, err := s.verify(pass, stored); errors.Is(err, MalformedHashError) && verifyLegacy(pass, stored, salt) {
+		return true, true, nil
+	} else if err != nil || !ok {
+		return false, false, err
+	} else {
+		return true, id != h.current || !current, nil
+	}
+}
+
+// verifyLegacy checks against whatever was stored before we hashed anything:
+// plaintext with the salt tacked on the end; always worth replacing
+func verifyLegacy(pass, stored shared.Password, salt string) bool {
+	return subtle.ConstantTimeCompare([]byte(pass+shared.Password(salt)), []byte(stored)) == 1
+}
+
+// identify maps the leading `$id$` of an encoded hash to one of our scheme names;
+// the second return is false for anything that isn't in modular crypt format
+func identify(stored shared.Password) (string, bool) {
+	if !strings.HasPrefix(string(stored), "$") {
+		return "", false
+	}
+
+	switch id, _, _ := strings.Cut(string(stored[1:]), "$"); id {
+	case "2a", "2b", "2y":
+		return Bcrypt, true
+	default:
+		return id, true
+	}
+}
+
+func generateSalt() ([]byte, error) {
+	result := make([]byte, saltLen)
+	_, err := rand.Read(result)
+	return result, err
+}
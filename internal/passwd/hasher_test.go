@@ -0,0 +1,224 @@
+package passwd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// cheap parameters so the tests don't take all day
+func testConfig(scheme string) *config.Config {
+	return &config.Config{
+		PasswordHash:  scheme,
+		Argon2Time:    1,
+		Argon2Memory:  64,
+		Argon2Threads: 1,
+		BcryptCost:    4,
+		ScryptLogN:    4,
+		ScryptR:       8,
+		ScryptP:       1,
+	}
+}
+
+func Test_NewHasher(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		scheme string
+		err    error
+	}{
+		"argon2id": {scheme: Argon2id},
+		"bcrypt":   {scheme: Bcrypt},
+		"scrypt":   {scheme: Scrypt},
+		"unknown":  {scheme: "rot13", err: UnknownSchemeError},
+		"empty":    {err: UnknownSchemeError},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := NewHasher(testConfig(tc.scheme))
+			require.ErrorIs(t, err, tc.err)
+			if tc.err == nil {
+				require.NotNil(t, h)
+			}
+		})
+	}
+}
+
+func Test_HashVerify(t *testing.T) {
+	t.Parallel()
+
+	for _, scheme := range []string{Argon2id, Bcrypt, Scrypt} {
+		scheme := scheme
+
+		t.Run(scheme, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := NewHasher(testConfig(scheme))
+			require.Nil(t, err)
+
+			stored, err := h.Hash("snakeoil")
+			require.Nil(t, err)
+			require.NotContains(t, stored, "snakeoil")
+
+			again, err := h.Hash("snakeoil")
+			require.Nil(t, err)
+			require.NotEqual(t, stored, again, "salts should be random")
+
+			ok, rehash, err := h.Verify("snakeoil", stored, "")
+			require.Nil(t, err)
+			require.True(t, ok)
+			require.False(t, rehash)
+
+			ok, rehash, err = h.Verify("snakeoyl", stored, "")
+			require.Nil(t, err)
+			require.False(t, ok)
+			require.False(t, rehash)
+		})
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	t.Parallel()
+
+	argon, _ := NewHasher(testConfig(Argon2id))
+	argonHash, _ := argon.Hash("snakeoil")
+
+	stronger := testConfig(Argon2id)
+	stronger.Argon2Time = 2
+	stale, _ := NewHasher(stronger)
+
+	scrypt, _ := NewHasher(testConfig(Scrypt))
+
+	tcs := map[string]struct {
+		h            Hasher
+		pass, stored shared.Password
+		salt         string
+		ok, rehash   bool
+		err          error
+	}{
+		"legacy_match": {
+			h:      argon,
+			pass:   "snakeoil",
+			stored: "snakeoilsalt",
+			salt:   "salt",
+			ok:     true,
+			rehash: true,
+		},
+		"legacy_mismatch": {
+			h:      argon,
+			pass:   "snakeoyl",
+			stored: "snakeoilsalt",
+			salt:   "salt",
+		},
+		"legacy_dollar": {
+			h:      argon,
+			pass:   "$md5$whatever",
+			stored: "$md5$whateversalt",
+			salt:   "salt",
+			ok:     true,
+			rehash: true,
+		},
+		"legacy_malformed": {
+			h:      argon,
+			pass:   "$argon2id$v=19",
+			stored: "$argon2id$v=19",
+			ok:     true,
+			rehash: true,
+		},
+		"empty_pass": {
+			h:      argon,
+			stored: "salt",
+			salt:   "salt",
+		},
+		"empty_stored": {
+			h: argon,
+		},
+		"unusable": {
+			h:      argon,
+			pass:   Unusable,
+			stored: Unusable,
+		},
+		"other_scheme": {
+			h:      scrypt,
+			pass:   "snakeoil",
+			stored: argonHash,
+			ok:     true,
+			rehash: true,
+		},
+		"stale_params": {
+			h:      stale,
+			pass:   "snakeoil",
+			stored: argonHash,
+			ok:     true,
+			rehash: true,
+		},
+		"stale_mismatch": {
+			h:      stale,
+			pass:   "snakeoyl",
+			stored: argonHash,
+		},
+		"unknown_scheme": {
+			h:      argon,
+			pass:   "snakeoil",
+			stored: "$md5$whatever",
+			err:    UnknownSchemeError,
+		},
+		"malformed": {
+			h:      argon,
+			pass:   "snakeoil",
+			stored: "$argon2id$v=19",
+			err:    MalformedHashError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ok, rehash, err := tc.h.Verify(tc.pass, tc.stored, tc.salt)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.rehash, rehash)
+		})
+	}
+}
+
+func Test_identify(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		stored shared.Password
+		id     string
+		ok     bool
+	}{
+		"legacy":   {stored: "snakeoilsalt"},
+		"empty":    {},
+		"argon2id": {stored: "$argon2id$v=19$...", id: Argon2id, ok: true},
+		"bcrypt2a": {stored: "$2a$04$...", id: Bcrypt, ok: true},
+		"bcrypt2b": {stored: "$2b$04$...", id: Bcrypt, ok: true},
+		"bcrypt2y": {stored: "$2y$04$...", id: Bcrypt, ok: true},
+		"scrypt":   {stored: "$scrypt$ln=4...", id: Scrypt, ok: true},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			id, ok := identify(tc.stored)
+			require.Equal(t, tc.id, id)
+			require.Equal(t, tc.ok, ok)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package passwd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// bcrypt refuses anything longer, in bytes, not characters
+const bcryptMaxLength = 72
+
+func NewPolicy(cfg *config.Config) (*shared.PasswordPolicy, error) {
+	result := &shared.PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		MaxLength:     cfg.PasswordMaxLength,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		MaxRepeat:     cfg.PasswordMaxRepeat,
+		History:       cfg.PasswordHistory,
+		Banned:        cfg.PasswordBanned,
+	}
+
+	if cfg.PasswordHash == Bcrypt {
+		result.MaxBytes = bcryptMaxLength
+		if result.MaxLength == 0 || result.MaxLength > bcryptMaxLength {
+			result.MaxLength = bcryptMaxLength
+		}
+	}
+
+	if cfg.PasswordDictionary == "" {
+		return result, nil
+	}
+
+	var err error
+	result.Breached, err = loadDictionary(cfg.PasswordDictionary)
+
+	return result, err
+}
+
+// loadDictionary reads one password per line; blank lines and lines
+// starting with '#' are skipped
+func loadDictionary(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			result[line] = struct{}{}
+		}
+	}
+
+	return result, scanner.Err()
+}
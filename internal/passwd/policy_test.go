@@ -0,0 +1,90 @@
+package passwd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_NewPolicy(t *testing.T) {
+	t.Parallel()
+
+	dict := filepath.Join(t.TempDir(), "breached.txt")
+	err := os.WriteFile(dict, []byte("# comments are skipped\n\npassword1\n  letmein  \n"), 0o600)
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		cfg    *config.Config
+		result *shared.PasswordPolicy
+		err    bool
+	}{
+		"happy_path": {
+			cfg: &config.Config{
+				PasswordHash:         Argon2id,
+				PasswordMinLength:    10,
+				PasswordMaxLength:    64,
+				PasswordRequireDigit: true,
+				PasswordMaxRepeat:    3,
+				PasswordHistory:      4,
+				PasswordBanned:       []string{"cffc"},
+			},
+			result: &shared.PasswordPolicy{
+				MinLength:    10,
+				MaxLength:    64,
+				RequireDigit: true,
+				MaxRepeat:    3,
+				History:      4,
+				Banned:       []string{"cffc"},
+			},
+		},
+		"bcrypt_clamps_length": {
+			cfg: &config.Config{
+				PasswordHash:      Bcrypt,
+				PasswordMinLength: 8,
+			},
+			result: &shared.PasswordPolicy{
+				MinLength: 8,
+				MaxLength: bcryptMaxLength,
+				MaxBytes:  bcryptMaxLength,
+			},
+		},
+		"dictionary": {
+			cfg: &config.Config{
+				PasswordMinLength:  8,
+				PasswordDictionary: dict,
+			},
+			result: &shared.PasswordPolicy{
+				MinLength: 8,
+				Breached: map[string]struct{}{
+					"password1": {},
+					"letmein":   {},
+				},
+			},
+		},
+		"missing_dictionary": {
+			cfg: &config.Config{PasswordDictionary: filepath.Join(t.TempDir(), "missing")},
+			err: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := NewPolicy(tc.cfg)
+			if tc.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
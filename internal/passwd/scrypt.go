@@ -0,0 +1,73 @@
+package passwd
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type scryptScheme struct {
+	logN uint8
+	r, p int
+}
+
+// $scrypt$ln=15,r=8,p=1$<salt>$<key>
+func (s *scryptScheme) hash(pass shared.Password) (shared.Password, error) {
+	salt, err := generateSalt()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := s.key(pass, salt, keyLen)
+	if err != nil {
+		return "", err
+	}
+
+	return shared.Password(fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s",
+		Scrypt,
+		s.logN,
+		s.r,
+		s.p,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))), nil
+}
+
+func (s *scryptScheme) verify(pass, stored shared.Password) (bool, bool, error) {
+	params, salt, key, err := s.decode(stored)
+	if err != nil {
+		return false, false, err
+	}
+
+	actual, err := params.key(pass, salt, len(key))
+	if err != nil {
+		return false, false, err
+	}
+
+	return subtle.ConstantTimeCompare(actual, key) == 1, *params == *s, nil
+}
+
+func (s *scryptScheme) key(pass shared.Password, salt []byte, keyLen int) ([]byte, error) {
+	return scrypt.Key([]byte(pass), salt, 1<<s.logN, s.r, s.p, keyLen)
+}
+
+func (s *scryptScheme) decode(stored shared.Password) (*scryptScheme, []byte, []byte, error) {
+	result := &scryptScheme{}
+
+	// "", "scrypt", "ln=...,r=...,p=...", salt, key
+	if parts := strings.Split(string(stored), "$"); len(parts) != 5 {
+		return nil, nil, nil, MalformedHashError
+	} else if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &result.logN, &result.r, &result.p); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", MalformedHashError, err)
+	} else if salt, err := base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", MalformedHashError, err)
+	} else if key, err := base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", MalformedHashError, err)
+	} else {
+		return result, salt, key, nil
+	}
+}
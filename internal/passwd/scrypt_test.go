@@ -0,0 +1,49 @@
+package passwd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_scryptDecode(t *testing.T) {
+	t.Parallel()
+
+	s := &scryptScheme{logN: 4, r: 8, p: 1}
+	good, err := s.hash("snakeoil")
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		stored shared.Password
+		params *scryptScheme
+		err    error
+	}{
+		"happy_path":    {stored: good, params: s},
+		"too_few_parts": {stored: "$scrypt$ln=4,r=8,p=1$c2FsdA", err: MalformedHashError},
+		"bad_params":    {stored: "$scrypt$n=16,r=8,p=1$c2FsdA$a2V5", err: MalformedHashError},
+		"bad_salt":      {stored: "$scrypt$ln=4,r=8,p=1$!!!$a2V5", err: MalformedHashError},
+		"bad_key":       {stored: "$scrypt$ln=4,r=8,p=1$c2FsdA$!!!", err: MalformedHashError},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			params, _, _, err := s.decode(tc.stored)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.params, params)
+		})
+	}
+}
+
+func Test_scryptVerifyBadParams(t *testing.T) {
+	t.Parallel()
+
+	// r*p too large for scrypt.Key to accept
+	_, _, err := (&scryptScheme{}).verify("snakeoil", "$scrypt$ln=4,r=1073741824,p=2$c2FsdA$a2V5")
+	require.NotNil(t, err)
+}
@@ -116,3 +116,14 @@ func (db *Conn) UpdateAddress(ctx context.Context, addr *shared.Address) error {
 
 	return done(err, log)
 }
+
+// OwnsAddress is true if the address is either of the user's contact
+// addresses; nobody owns an address that isn't attached to a contact yet
+func (db *Conn) OwnsAddress(ctx context.Context, uid, id shared.UUID) (bool, error) {
+	done, log := db.logging("OwnsAddress", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	var count int
+	err := db.QueryRowContext(ctx, db.sqls["address"]["owner"], uid, id).Scan(&count)
+
+	return count > 0, done(err, log)
+}
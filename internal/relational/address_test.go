@@ -90,6 +90,9 @@ func TestGetAllAddresses(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).GetAllAddresses(mockContext(cid))
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.addr, addr)
@@ -137,6 +140,9 @@ func TestGetAddress(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).GetAddress(mockContext(cid), "1")
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.result, addr)
@@ -194,6 +200,9 @@ func TestAddAddress(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).AddAddress(mockContext(cid), tc.addr)
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.result, uuid)
@@ -247,7 +256,65 @@ func TestUpdateAddress(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).UpdateAddress(mockContext(cid), tc.addr))
 		})
 	}
 }
+
+func TestOwnsAddress(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, logrus.Fields{"app": "address_test.go", "test": "TestOwnsAddress"})
+
+	tcs := map[string]struct {
+		mockDB getMockDB
+		result bool
+		err    error
+	}{
+		"owner": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery(".*").
+					WithArgs(shared.UUID("uid"), shared.UUID("uuid")).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				return db
+			},
+			result: true,
+		},
+		"someone_else": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery(".*").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				return db
+			},
+		},
+		"query_fails": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery(".*").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cid := shared.CID("TestOwnsAddress-" + name)
+			result, err := (&Conn{
+				tc.mockDB(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).OwnsAddress(mockContext(cid), "uid", "uuid")
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/userservice/internal/oidc"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+const apiKeyPrefix = "usk_"
+
+// GetAPIKeys never fills in Secret, same as GetClient
+func (db *Conn) GetAPIKeys(ctx context.Context, uid shared.UUID) ([]shared.APIKey, error) {
+	done, log := db.logging("GetAPIKeys", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	rows, err := db.QueryContext(ctx, db.sqls["api-key"]["select-all"], uid)
+	if err != nil {
+		return nil, done(err, log)
+	}
+	defer rows.Close()
+
+	result := []shared.APIKey{}
+	for rows.Next() {
+		var scopes string
+		row := shared.APIKey{UserID: uid}
+		if err = rows.Scan(
+			&row.ID,
+			&row.Name,
+			&scopes,
+			&row.Expires,
+			&row.LastUsed,
+			&row.CTime,
+		); err != nil {
+			return nil, done(err, log)
+		}
+		row.Scopes = strings.Fields(scopes)
+		result = append(result, row)
+	}
+
+	return result, done(rows.Err(), log)
+}
+
+// AddAPIKey makes up the id and the secret and stores a hash of the secret;
+// the bearer token comes back in k.Secret and this is the only time anyone
+// gets to see it
+func (db *Conn) AddAPIKey(ctx context.Context, uid shared.UUID, k *shared.APIKey) (string, error) {
+	done, log := db.logging("AddAPIKey", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	secret, err := oidc.NewSecret()
+	if err != nil {
+		return "", done(err, log)
+	}
+
+	k.ID = string(db.uuidgen())
+	k.UserID = uid
+	k.CTime = time.Now().UTC()
+
+	if _, err = db.ExecContext(ctx, db.sqls["api-key"]["insert"],
+		k.ID,
+		uid,
+		k.Name,
+		hashAPIKey(secret),
+		strings.Join(k.Scopes, " "),
+		k.Expires,
+		k.CTime,
+	); err != nil {
+		return "", done(err, log)
+	}
+
+	k.Secret = apiKeyPrefix + k.ID + "." + secret
+
+	return k.ID, done(nil, log)
+}
+
+// CheckAPIKey takes the whole bearer token and returns the key it belongs
+// to; anything malformed or mismatched, or a key whose user was deleted, is
+// just a BadAPIKeyError so callers can't tell a wrong id from a wrong secret
+func (db *Conn) CheckAPIKey(ctx context.Context, token string) (*shared.APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), ".")
+	if !ok || id == "" || secret == "" || !strings.HasPrefix(token, apiKeyPrefix) {
+		return nil, shared.BadAPIKeyError
+	}
+
+	done, log := db.logging("CheckAPIKey", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	var scopes, hash string
+	result := &shared.APIKey{ID: id}
+	err := db.
+		QueryRowContext(ctx, db.sqls["api-key"]["select"], id).
+		Scan(
+			&result.UserID,
+			&result.Name,
+			&hash,
+			&scopes,
+			&result.Expires,
+			&result.LastUsed,
+			&result.CTime)
+
+	now := time.Now().UTC()
+	if err == sql.ErrNoRows {
+		return nil, done(shared.BadAPIKeyError, log)
+	} else if err != nil {
+		return nil, done(err, log)
+	} else if subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPIKey(secret))) != 1 {
+		return nil, done(shared.BadAPIKeyError, log)
+	} else if result.Expires != nil && !now.Before(*result.Expires) {
+		return nil, done(shared.APIKeyExpiredError, log)
+	}
+
+	// bookkeeping only; a key that checks out shouldn't be refused because
+	// the timestamp didn't stick
+	if _, err = db.ExecContext(ctx, db.sqls["api-key"]["update"], now, id); err != nil {
+		log.WithError(err).Warn("failed to record last use")
+	} else {
+		result.LastUsed = &now
+	}
+
+	result.Scopes = strings.Fields(scopes)
+
+	return result, done(nil, log)
+}
+
+// DeleteAPIKey only deletes the key if it belongs to uid, otherwise it's
+// APIKeyNotFoundError, same as a key that doesn't exist
+func (db *Conn) DeleteAPIKey(ctx context.Context, uid shared.UUID, id string) error {
+	done, log := db.logging("DeleteAPIKey", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["api-key"]["delete"], id, uid)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.APIKeyNotFoundError
+		}
+	}
+
+	return done(err, log)
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
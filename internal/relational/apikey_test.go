@@ -0,0 +1,362 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+var (
+	apiKeyFields     = []string{"id", "name", "scopes", "expires", "lastused", "ctime"}
+	apiKeyAuthFields = []string{"user_uuid", "name", "hash", "scopes", "expires", "lastused", "ctime"}
+)
+
+func Test_GetAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "apikey_test.go", "test": "Test_GetAPIKeys"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result []shared.APIKey
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs(shared.UUID("uid")).
+					WillReturnRows(sqlmock.
+						NewRows(apiKeyFields).
+						AddRow("0", "ci", "read write", nil, rightaboutnow, rightaboutnow).
+						AddRow("1", "cron", "", rightaboutnow, nil, rightaboutnow))
+				return db
+			},
+			result: []shared.APIKey{
+				{ID: "0", UserID: "uid", Name: "ci", Scopes: []string{"read", "write"}, LastUsed: &rightaboutnow, CTime: rightaboutnow},
+				{ID: "1", UserID: "uid", Name: "cron", Scopes: []string{}, Expires: &rightaboutnow, CTime: rightaboutnow},
+			},
+		},
+		"no_keys": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(apiKeyFields))
+				return db
+			},
+			result: []shared.APIKey{},
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"scan_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(apiKeyFields).
+						AddRow("0", "ci", "read", nil, nil, "not a time"))
+				return db
+			},
+			err: fmt.Errorf(`sql: Scan error on column index 5, name "ctime": unsupported Scan, storing driver.Value type string into type *time.Time`),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetAPIKeys(mockContext(shared.CID("Test_GetAPIKeys-"+name)), "uid")
+
+			if tc.err != nil {
+				require.EqualError(t, err, tc.err.Error())
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_AddAPIKey(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "apikey_test.go", "test": "Test_AddAPIKey"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		key *shared.APIKey
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(
+						string(mockUUIDGen()),
+						shared.UUID("uid"),
+						"ci",
+						notNil{},
+						"read write",
+						&rightaboutnow,
+						sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+			key: &shared.APIKey{Name: "ci", Scopes: []string{"read", "write"}, Expires: &rightaboutnow},
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			key: &shared.APIKey{Name: "ci"},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			id, err := (&Conn{
+				tc.db(sqlmock.New()),
+				mockUUIDGen,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).AddAPIKey(mockContext(shared.CID("Test_AddAPIKey-"+name)), "uid", tc.key)
+
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				require.Empty(t, id)
+				require.Empty(t, tc.key.Secret)
+				return
+			}
+			require.Equal(t, string(mockUUIDGen()), id)
+			require.True(t, strings.HasPrefix(tc.key.Secret, apiKeyPrefix+id+"."))
+		})
+	}
+}
+
+func Test_CheckAPIKey(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "apikey_test.go", "test": "Test_CheckAPIKey"})
+
+	hash, later := hashAPIKey("snakeoil"), rightaboutnow.Add(time.Hour)
+
+	tcs := map[string]struct {
+		db     getMockDB
+		token  string
+		result *shared.APIKey
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs("key").
+					WillReturnRows(sqlmock.
+						NewRows(apiKeyAuthFields).
+						AddRow("uid", "ci", hash, "read write", later, nil, rightaboutnow))
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), "key").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+			token: "usk_key.snakeoil",
+			result: &shared.APIKey{
+				ID:      "key",
+				UserID:  "uid",
+				Name:    "ci",
+				Scopes:  []string{"read", "write"},
+				Expires: &later,
+				CTime:   rightaboutnow,
+			},
+		},
+		"lastused_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(apiKeyAuthFields).
+						AddRow("uid", "ci", hash, "", nil, rightaboutnow, rightaboutnow))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			token: "usk_key.snakeoil",
+			result: &shared.APIKey{
+				ID:       "key",
+				UserID:   "uid",
+				Name:     "ci",
+				Scopes:   []string{},
+				LastUsed: &rightaboutnow,
+				CTime:    rightaboutnow,
+			},
+		},
+		"wrong_secret": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(apiKeyAuthFields).
+						AddRow("uid", "ci", hash, "", nil, nil, rightaboutnow))
+				return db
+			},
+			token: "usk_key.snakeoyl",
+			err:   shared.BadAPIKeyError,
+		},
+		"expired": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(apiKeyAuthFields).
+						AddRow("uid", "ci", hash, "", rightaboutnow, nil, rightaboutnow))
+				return db
+			},
+			token: "usk_key.snakeoil",
+			err:   shared.APIKeyExpiredError,
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(apiKeyAuthFields))
+				return db
+			},
+			token: "usk_key.snakeoil",
+			err:   shared.BadAPIKeyError,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			token: "usk_key.snakeoil",
+			err:   fmt.Errorf("some error"),
+		},
+		"no_prefix": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				return db
+			},
+			token: "key.snakeoil",
+			err:   shared.BadAPIKeyError,
+		},
+		"no_secret": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				return db
+			},
+			token: "usk_key.",
+			err:   shared.BadAPIKeyError,
+		},
+		"no_separator": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				return db
+			},
+			token: "usk_keysnakeoil",
+			err:   shared.BadAPIKeyError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).CheckAPIKey(mockContext(shared.CID("Test_CheckAPIKey-"+name)), tc.token)
+
+			require.Equal(t, tc.err, err)
+			if tc.result != nil && tc.result.LastUsed == nil {
+				// it's whenever the update ran, just make sure it did
+				require.NotNil(t, result.LastUsed)
+				result.LastUsed = nil
+			}
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_DeleteAPIKey(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "apikey_test.go", "test": "Test_DeleteAPIKey"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs("key", shared.UUID("uid")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.APIKeyNotFoundError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).DeleteAPIKey(mockContext(shared.CID("Test_DeleteAPIKey-"+name)), "uid", "key")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
@@ -2,16 +2,13 @@ package data
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jsmit257/userservice/shared/v1"
 )
 
-var (
-	maxfailure uint8 = 3 // config value
-)
-
 func (db *Conn) GetAuthByAttrs(ctx context.Context, id *shared.UUID, name *string) (*shared.BasicAuth, error) {
 	done, log := db.logging("GetAuthByAttrs", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
 
@@ -25,7 +22,9 @@ func (db *Conn) GetAuthByAttrs(ctx context.Context, id *shared.UUID, name *strin
 		&result.LoginFailure,
 		&result.FailureCount,
 		&result.MTime,
-		&result.CTime)
+		&result.CTime,
+		&result.Email,
+		&result.Locked)
 
 	return result, done(err, log)
 }
@@ -36,32 +35,75 @@ func (db *Conn) ChangePassword(ctx context.Context, uid shared.UUID, old, new sh
 	auth, err := db.Login(ctx, &shared.BasicAuth{UUID: uid, Pass: old})
 	if err != nil {
 		return done(err, log)
-	} else if err = validate(auth, new); err != nil {
+	} else if err = db.validate(auth, new); err != nil {
+		return done(err, log)
+	} else if err = db.checkHistory(ctx, uid, new); err != nil {
+		return done(err, log)
+	} else if auth.Pass, err = db.hasher.Hash(new); err != nil {
 		return done(err, log)
 	}
 
 	now := time.Now().UTC()
 
-	auth.Salt = generateSalt()
-	auth.Pass = hash(new, auth.Salt)
+	auth.Salt = "" // the salt lives in the encoded hash now
 	auth.LoginSuccess = &now
 	auth.FailureCount = 0
 
-	err = db.updateBasicAuth(ctx, auth)
+	if err = db.updateBasicAuth(ctx, auth); err == nil {
+		err = db.addHistory(ctx, auth)
+	}
 
 	return done(err, log)
 }
 
+// CheckPassword runs new through the same policy and history checks as
+// ChangePassword without changing anything, so a reset can find out the new
+// password won't do before it spends the one-time pad
+func (db *Conn) CheckPassword(ctx context.Context, uid shared.UUID, new shared.Password) error {
+	done, log := db.logging("CheckPassword", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	auth, err := db.GetAuthByAttrs(ctx, &uid, nil)
+	if err != nil {
+		return done(err, log)
+	} else if err = db.validate(auth, new); err != nil {
+		return done(err, log)
+	}
+
+	return done(db.checkHistory(ctx, uid, new), log)
+}
+
 func (db *Conn) SoftLogin(ctx context.Context, login *shared.BasicAuth) (*shared.BasicAuth, error) {
 	done, log := db.logging("SoftLogin", login.UUID, ctx.Value(shared.CTXKey("cid")).(shared.CID))
 
+	var ok, rehash bool
+	now := time.Now().UTC()
 	result, err := db.GetAuthByAttrs(ctx, &login.UUID, nil)
 	if err != nil {
 		return result, done(err, log)
-	} else if result.FailureCount > maxfailure {
+	} else if db.lockout.Locked(result.Locked, now) {
 		err = shared.MaxFailedLoginError
-	} else if hash(login.Pass, result.Salt) != result.Pass {
+	} else if result.Locked != nil {
+		// the lock expired on its own; start counting from scratch, the caller
+		// persists this with the rest of the login bookkeeping
+		result.Locked, result.FailureCount = nil, 0
+	}
+
+	if err != nil {
+		return result, done(err, log)
+	} else if wait := db.lockout.Wait(result.FailureCount, result.LoginFailure, now); wait > 0 {
+		log.WithField("wait", wait.String()).Info("backing off")
+		err = shared.LoginBackoffError
+	} else if ok, rehash, err = db.hasher.Verify(login.Pass, result.Pass, result.Salt); err != nil {
+		return result, done(err, log)
+	} else if !ok {
 		err = shared.BadUserOrPassError
+	} else if rehash {
+		// legacy format or stale parameters; the caller persists the new hash
+		// with the rest of the login bookkeeping
+		log.Info("upgrading password hash")
+		if result.Pass, err = db.hasher.Hash(login.Pass); err == nil {
+			result.Salt = ""
+		}
 	}
 
 	return result, done(err, log)
@@ -73,17 +115,19 @@ func (db *Conn) Login(ctx context.Context, login *shared.BasicAuth) (*shared.Bas
 	now := time.Now().UTC()
 	result, err := db.SoftLogin(ctx, login)
 	if err == shared.BadUserOrPassError {
-		if err = db.updateBasicAuth(
-			ctx,
-			&shared.BasicAuth{
-				UUID:         result.UUID,
-				Pass:         result.Pass,
-				Salt:         result.Salt,
-				LoginSuccess: result.LoginSuccess,
-				LoginFailure: &now,
-				FailureCount: result.FailureCount + 1,
-			},
-		); err == nil {
+		failed := &shared.BasicAuth{
+			UUID:         result.UUID,
+			Pass:         result.Pass,
+			Salt:         result.Salt,
+			LoginSuccess: result.LoginSuccess,
+			LoginFailure: &now,
+			FailureCount: result.FailureCount + 1,
+		}
+		if db.lockout.Lock(failed.FailureCount) {
+			log.WithField("failures", failed.FailureCount).Warn("locking account")
+			failed.Locked = &now
+		}
+		if err = db.updateBasicAuth(ctx, failed); err == nil {
 			err = shared.BadUserOrPassError
 		}
 	} else if err == nil {
@@ -116,14 +160,50 @@ func (db *Conn) ResetPassword(ctx context.Context, id *shared.UUID) (shared.Pass
 	now := time.Now().UTC()
 	seed := shared.Password(uuid.NewString())
 
-	auth.Salt = generateSalt()
-	auth.Pass = hash(seed, auth.Salt)
+	if auth.Pass, err = db.hasher.Hash(seed); err != nil {
+		return "", done(err, log)
+	}
+	auth.Salt = ""
 	auth.LoginSuccess = &now
 	auth.FailureCount = 0
+	auth.Locked = nil
 
 	return seed, done(db.updateBasicAuth(ctx, auth), log)
 }
 
+// Unlock clears a lockout and the failure count that led to it without
+// touching the password
+func (db *Conn) Unlock(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("Unlock", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["basic-auth"]["unlock"], uid)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.UserNotUpdatedError
+		}
+	}
+
+	return done(err, log)
+}
+
+// Active is for whatever keeps a user logged in without a password, refresh
+// tokens for one: a user that's gone, deleted or never there, is
+// UserDeletedError and one that's locked out is MaxFailedLoginError
+func (db *Conn) Active(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("Active", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	var dtime, locked *time.Time
+	err := db.QueryRowContext(ctx, db.sqls["basic-auth"]["active"], uid).Scan(&dtime, &locked)
+	if err == sql.ErrNoRows || (err == nil && dtime != nil) {
+		err = shared.UserDeletedError
+	} else if err == nil && db.lockout.Locked(locked, time.Now().UTC()) {
+		err = shared.MaxFailedLoginError
+	}
+
+	return done(err, log)
+}
+
 func (db *Conn) updateBasicAuth(ctx context.Context, login *shared.BasicAuth) error {
 	done, log := db.logging("Login", nil, ctx.Value(shared.CTXKey("cid")).(shared.CID))
 
@@ -133,6 +213,7 @@ func (db *Conn) updateBasicAuth(ctx context.Context, login *shared.BasicAuth) er
 		login.LoginSuccess,
 		login.LoginFailure,
 		login.FailureCount,
+		login.Locked,
 		login.UUID)
 
 	if err == nil {
@@ -145,12 +226,12 @@ func (db *Conn) updateBasicAuth(ctx context.Context, login *shared.BasicAuth) er
 	return done(err, log)
 }
 
-func validate(old *shared.BasicAuth, new shared.Password) error {
-	if !new.Valid() { // all the complexity rules
-		return shared.BadUserOrPassError
-	} else if string(new) == old.Name { // can't use username as password
-		return shared.PasswordsMatch
-	} else if hash(new, old.Salt) == old.Pass { // can't re-use passwords
+func (db *Conn) validate(old *shared.BasicAuth, new shared.Password) error {
+	if v := db.policy.Check(new, old.Name, old.Email.LocalPart()); len(v) != 0 { // all the complexity rules
+		return v
+	} else if same, _, err := db.hasher.Verify(new, old.Pass, old.Salt); err != nil {
+		return err
+	} else if same { // can't re-use passwords
 		return shared.PasswordsMatch
 	}
 	return nil
@@ -2,17 +2,26 @@ package data
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 
+	"github.com/jsmit257/userservice/internal/passwd"
 	"github.com/jsmit257/userservice/shared/v1"
 )
 
+type (
+	hashPrefix string
+	notNil     struct{}
+)
+
 var (
 	_basic = shared.BasicAuth{
 		UUID:         "basic",
@@ -25,6 +34,7 @@ var (
 		MTime:        rightaboutnow,
 		CTime:        rightaboutnow,
 	}
+	_hashed, _  = testhasher.Hash("snakeoil")
 	basicFields = row{
 		"uuid",
 		"name",
@@ -35,6 +45,8 @@ var (
 		"count",
 		"mtime",
 		"ctime",
+		"email",
+		"locked",
 	}
 	basicValues = values{
 		_basic.UUID,
@@ -46,6 +58,8 @@ var (
 		_basic.FailureCount,
 		_basic.MTime,
 		_basic.CTime,
+		_basic.Email,
+		_basic.Locked,
 	}
 )
 
@@ -101,6 +115,9 @@ func Test_GetAuthByAttrs(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).GetAuthByAttrs(mockContext(shared.CID("Test_GetAuthByAttrs-"+name)), tc.id, tc.name)
 
 			require.Equal(t, tc.err, err)
@@ -113,12 +130,73 @@ func Test_ChangePassword(t *testing.T) {
 
 	l := testLogger(t, log.Fields{"app": "basicauth_test.go", "test": "Test_ResetPassword"})
 
+	historic := &shared.PasswordPolicy{MinLength: 8, History: 3}
+	reused, _ := testhasher.Hash("whiskeytango")
+
 	tcs := map[string]struct {
 		db       getMockDB
+		policy   *shared.PasswordPolicy
 		uid      shared.UUID
 		old, new shared.Password
 		err      error
 	}{
+		"history_happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"password", "salt"}).
+						AddRow(_hashed, ""))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+				return db
+			},
+			policy: historic,
+			old:    "snakeoil",
+			new:    "whiskeytango",
+		},
+		"reused": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"password", "salt"}).
+						AddRow(_hashed, "").
+						AddRow(reused, ""))
+
+				return db
+			},
+			policy: historic,
+			old:    "snakeoil",
+			new:    "whiskeytango",
+			err:    shared.PasswordsMatch,
+		},
+		"history_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+
+				return db
+			},
+			policy: historic,
+			old:    "snakeoil",
+			new:    "whiskeytango",
+			err:    fmt.Errorf("some error"),
+		},
 		"happy_path": {
 			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
 				mock.ExpectQuery("").
@@ -145,7 +223,10 @@ func Test_ChangePassword(t *testing.T) {
 			},
 			old: "snakeoil",
 			new: "shorty",
-			err: shared.BadUserOrPassError,
+			err: shared.PolicyViolations{{
+				Rule:   shared.MinLengthRule,
+				Detail: "must be at least 8 characters",
+			}},
 		},
 		"vanity": {
 			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
@@ -159,7 +240,27 @@ func Test_ChangePassword(t *testing.T) {
 			},
 			old: "snakeoil",
 			new: "anaconda",
-			err: shared.PasswordsMatch,
+			err: shared.PolicyViolations{{
+				Rule:   shared.PersonalRule,
+				Detail: "must not contain your username or email",
+			}},
+		},
+		"email": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{9, "jimbo@example.com"})...))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			old: "snakeoil",
+			new: "itsJimbo!",
+			err: shared.PolicyViolations{{
+				Rule:   shared.PersonalRule,
+				Detail: "must not contain your username or email",
+			}},
 		},
 		"login_fails": {
 			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
@@ -190,12 +291,19 @@ func Test_ChangePassword(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			if tc.policy == nil {
+				tc.policy = testpolicy
+			}
+
 			err := (&Conn{
 				tc.db(sqlmock.New()),
 				nil,
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				tc.policy,
+				testlockout,
 			}).ChangePassword(mockContext(shared.CID("Test_ResetPassword-"+name)), tc.uid, tc.old, tc.new)
 
 			require.Equal(t, tc.err, err)
@@ -203,15 +311,129 @@ func Test_ChangePassword(t *testing.T) {
 	}
 }
 
+func Test_CheckPassword(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "basicauth_test.go", "test": "Test_CheckPassword"})
+
+	historic := &shared.PasswordPolicy{MinLength: 8, History: 3}
+	reused, _ := testhasher.Hash("whiskeytango")
+
+	tcs := map[string]struct {
+		db     getMockDB
+		policy *shared.PasswordPolicy
+		new    shared.Password
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"password", "salt"}).
+						AddRow(_hashed, ""))
+
+				return db
+			},
+			policy: historic,
+			new:    "whiskeytango",
+		},
+		"lookup_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			new: "whiskeytango",
+			err: fmt.Errorf("some error"),
+		},
+		"too_short": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+
+				return db
+			},
+			new: "shorty",
+			err: shared.PolicyViolations{{
+				Rule:   shared.MinLengthRule,
+				Detail: "must be at least 8 characters",
+			}},
+		},
+		"same_password": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+
+				return db
+			},
+			new: "snakeoil",
+			err: shared.PasswordsMatch,
+		},
+		"reused": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"password", "salt"}).
+						AddRow(reused, ""))
+
+				return db
+			},
+			policy: historic,
+			new:    "whiskeytango",
+			err:    shared.PasswordsMatch,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.policy == nil {
+				tc.policy = testpolicy
+			}
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				tc.policy,
+				testlockout,
+			}).CheckPassword(mockContext(shared.CID("Test_CheckPassword-"+name)), "uuid", tc.new)
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
 func Test_Login(t *testing.T) {
 	t.Parallel()
 
 	l := testLogger(t, log.Fields{"app": "basicauth_test.go", "test": "Test_Login"})
 
+	recently := time.Now().UTC().Add(-time.Second)
+	longago := time.Now().UTC().Add(-time.Hour)
+
 	tcs := map[string]struct {
-		db    getMockDB
-		login shared.BasicAuth
-		err   error
+		db      getMockDB
+		lockout *shared.LockoutPolicy
+		login   shared.BasicAuth
+		err     error
 	}{
 		"happy_path": {
 			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
@@ -241,17 +463,122 @@ func Test_Login(t *testing.T) {
 			},
 			err: fmt.Errorf("some error"),
 		},
-		"failure_count": {
+		"locked": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{6, 3}, repl{10, recently})...))
+
+				return db
+			},
+			login: shared.BasicAuth{Pass: "snakeoil"},
+			err:   shared.MaxFailedLoginError,
+		},
+		"locked_forever": {
 			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
 				mock.ExpectQuery("").
 					WillReturnRows(sqlmock.
 						NewRows(basicFields).
-						AddRow(basicValues.replace(repl{6, maxfailure + 1})...))
+						AddRow(basicValues.replace(repl{6, 3}, repl{10, longago})...))
+
+				return db
+			},
+			lockout: &shared.LockoutPolicy{Threshold: 3},
+			login:   shared.BasicAuth{Pass: "snakeoil"},
+			err:     shared.MaxFailedLoginError,
+		},
+		"lock_expired": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{6, 3}, repl{10, longago})...))
+				mock.ExpectExec("").
+					WithArgs(hashPrefix("$argon2id$"), "", sqlmock.AnyArg(), nil, 0, nil, _basic.UUID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			login: shared.BasicAuth{Pass: "snakeoil"},
+		},
+		"lock_expired_bad_password": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{6, 3}, repl{10, longago})...))
+				mock.ExpectExec("").
+					WithArgs(_basic.Pass, _basic.Salt, sqlmock.AnyArg(), notNil{}, 1, nil, _basic.UUID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			err: shared.BadUserOrPassError,
+		},
+		"locks_at_threshold": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{6, 2})...))
+				mock.ExpectExec("").
+					WithArgs(_basic.Pass, _basic.Salt, sqlmock.AnyArg(), notNil{}, 3, notNil{}, _basic.UUID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			err: shared.BadUserOrPassError,
+		},
+		"backoff": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{5, recently}, repl{6, 1})...))
+
+				return db
+			},
+			lockout: &shared.LockoutPolicy{Threshold: 3, Backoff: time.Minute},
+			login:   shared.BasicAuth{Pass: "snakeoil"},
+			err:     shared.LoginBackoffError,
+		},
+		"backoff_elapsed": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{5, longago}, repl{6, 2})...))
 				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
 
 				return db
 			},
-			err: shared.MaxFailedLoginError,
+			lockout: &shared.LockoutPolicy{Threshold: 3, Backoff: time.Minute},
+			login:   shared.BasicAuth{Pass: "snakeoil"},
+		},
+		"new_user_empty_password": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{2, passwd.Unusable}, repl{3, ""})...))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			err: shared.BadUserOrPassError,
+		},
+		"legacy_empty_password": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{2, ""}, repl{3, ""})...))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			err: shared.BadUserOrPassError,
 		},
 		"bad_password": {
 			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
@@ -303,6 +630,46 @@ func Test_Login(t *testing.T) {
 			login: shared.BasicAuth{Pass: "snakeoil"},
 			err:   shared.UserNotUpdatedError,
 		},
+		"legacy_rehash": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues...))
+				mock.ExpectExec("").
+					WithArgs(hashPrefix("$argon2id$"), "", sqlmock.AnyArg(), nil, 0, nil, _basic.UUID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			login: shared.BasicAuth{Pass: "snakeoil"},
+		},
+		"current_hash": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{2, _hashed}, repl{3, ""})...))
+				mock.ExpectExec("").
+					WithArgs(_hashed, "", sqlmock.AnyArg(), nil, 0, nil, _basic.UUID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				return db
+			},
+			login: shared.BasicAuth{Pass: "snakeoil"},
+		},
+		"malformed_hash": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(basicFields).
+						AddRow(basicValues.replace(repl{2, "$argon2id$garbage"})...))
+
+				return db
+			},
+			login: shared.BasicAuth{Pass: "snakeoil"},
+			err:   passwd.MalformedHashError,
+		},
 	}
 
 	for name, tc := range tcs {
@@ -311,12 +678,19 @@ func Test_Login(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			if tc.lockout == nil {
+				tc.lockout = testlockout
+			}
+
 			_, err := (&Conn{
 				tc.db(sqlmock.New()),
 				nil,
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				tc.lockout,
 			}).Login(mockContext(shared.CID("Test_Login-"+name)), &tc.login)
 
 			require.Equal(t, tc.err, err)
@@ -415,6 +789,9 @@ func Test_ResetPassword(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).ResetPassword(mockContext(shared.CID("Test_ResetPassword-"+name)), &tc.login.UUID)
 
 			require.Equal(t, tc.err, err)
@@ -465,9 +842,154 @@ func Test_updateBasicAuth(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).updateBasicAuth(mockContext(shared.CID("Test_updateBasicAuth-"+name)), &tc.login)
 
 			require.Equal(t, tc.err, err)
 		})
 	}
 }
+
+func Test_Unlock(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "basicauth_test.go", "test": "Test_Unlock"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WithArgs("basic").WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"no_rows_updated": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.UserNotUpdatedError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).Unlock(mockContext(shared.CID("Test_Unlock-"+name)), "basic")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_Active(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "basicauth_test.go", "test": "Test_Active"})
+
+	now := time.Now().UTC()
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs("basic").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"dtime", "locked"}).
+						AddRow(nil, nil))
+				return db
+			},
+		},
+		"deleted": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"dtime", "locked"}).
+						AddRow(now, nil))
+				return db
+			},
+			err: shared.UserDeletedError,
+		},
+		"never_there": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.NewRows([]string{"dtime", "locked"}))
+				return db
+			},
+			err: shared.UserDeletedError,
+		},
+		"locked": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"dtime", "locked"}).
+						AddRow(nil, now))
+				return db
+			},
+			err: shared.MaxFailedLoginError,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).Active(mockContext(shared.CID("Test_Active-"+name)), "basic")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func (p hashPrefix) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, string(p))
+}
+
+func (notNil) Match(v driver.Value) bool {
+	return v != nil
+}
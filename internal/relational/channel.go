@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// GetMFAChannel fails with MFANotEnrolledError when the user never picked a
+// channel; an unconfirmed one comes back with a nil Confirmed
+func (db *Conn) GetMFAChannel(ctx context.Context, uid shared.UUID) (*shared.MFAChannel, error) {
+	done, log := db.logging("GetMFAChannel", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result := &shared.MFAChannel{}
+	err := db.
+		QueryRowContext(ctx, db.sqls["mfa-channel"]["select"], uid).
+		Scan(
+			&result.Channel,
+			&result.Confirmed,
+			&result.MTime,
+			&result.CTime)
+
+	if err == sql.ErrNoRows {
+		return nil, done(shared.MFANotEnrolledError, log)
+	} else if err != nil {
+		return nil, done(err, log)
+	}
+
+	return result, done(err, log)
+}
+
+// EnrollMFAChannel picks a channel, replacing any existing choice; it stops
+// counting as a second factor until it's confirmed again
+func (db *Conn) EnrollMFAChannel(ctx context.Context, uid shared.UUID, ch shared.Channel) error {
+	done, log := db.logging("EnrollMFAChannel", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, db.sqls["mfa-channel"]["insert"], uid, ch, now, now)
+
+	return done(err, log)
+}
+
+func (db *Conn) ConfirmMFAChannel(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("ConfirmMFAChannel", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, db.sqls["mfa-channel"]["update"], now, now, uid)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.MFANotEnrolledError
+		}
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) DeleteMFAChannel(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("DeleteMFAChannel", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["mfa-channel"]["delete"], uid)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.MFANotEnrolledError
+		} else if err == nil {
+			err = db.pruneRecoveryCodes(ctx, uid)
+		}
+	}
+
+	return done(err, log)
+}
@@ -0,0 +1,245 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_GetMFAChannel(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "channel_test.go", "test": "Test_GetMFAChannel"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result *shared.MFAChannel
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs("0").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"channel", "confirmed", "mtime", "ctime"}).
+						AddRow("email", rightaboutnow, rightaboutnow, rightaboutnow))
+				return db
+			},
+			result: &shared.MFAChannel{
+				Channel:   shared.EmailChannel,
+				Confirmed: &rightaboutnow,
+				MTime:     rightaboutnow,
+				CTime:     rightaboutnow,
+			},
+		},
+		"not_enrolled": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.NewRows([]string{"channel", "confirmed", "mtime", "ctime"}))
+				return db
+			},
+			err: shared.MFANotEnrolledError,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetMFAChannel(mockContext(shared.CID("Test_GetMFAChannel-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_EnrollMFAChannel(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "channel_test.go", "test": "Test_EnrollMFAChannel"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs("0", shared.EmailChannel, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).EnrollMFAChannel(mockContext(shared.CID("Test_EnrollMFAChannel-"+name)), "0", shared.EmailChannel)
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_ConfirmMFAChannel(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "channel_test.go", "test": "Test_ConfirmMFAChannel"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_enrolled": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.MFANotEnrolledError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).ConfirmMFAChannel(mockContext(shared.CID("Test_ConfirmMFAChannel-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_DeleteMFAChannel(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "channel_test.go", "test": "Test_DeleteMFAChannel"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs("0", "0", "0").
+					WillReturnResult(sqlmock.NewResult(0, 10))
+				return db
+			},
+		},
+		"not_enrolled": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.MFANotEnrolledError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"recovery_codes_fail": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).DeleteMFAChannel(mockContext(shared.CID("Test_DeleteMFAChannel-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
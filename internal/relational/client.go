@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// GetClient never fills in Secret; there's only a hash to fill it in with
+func (db *Conn) GetClient(ctx context.Context, id string) (*shared.OAuthClient, error) {
+	done, log := db.logging("GetClient", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, _, err := db.getClient(ctx, id)
+
+	return result, done(err, log)
+}
+
+// AddClient makes up the client_id and stores a hash of the secret, if the
+// client has one
+func (db *Conn) AddClient(ctx context.Context, c *shared.OAuthClient) (string, error) {
+	done, log := db.logging("AddClient", c.Name, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	var secret shared.Password
+	var err error
+	if !c.Public {
+		if secret, err = db.hasher.Hash(shared.Password(c.Secret)); err != nil {
+			return "", done(err, log)
+		}
+	}
+
+	now := time.Now().UTC()
+	c.ID = string(db.uuidgen())
+	c.MTime = now
+	c.CTime = now
+
+	_, err = db.ExecContext(ctx, db.sqls["oauth-client"]["insert"],
+		c.ID,
+		c.Name,
+		secret,
+		strings.Join(c.RedirectURIs, " "),
+		c.Public,
+		now,
+		now)
+
+	return c.ID, done(err, log)
+}
+
+// CheckClient authenticates a confidential client; public clients never pass
+// since there's nothing to check their secret against
+func (db *Conn) CheckClient(ctx context.Context, id, secret string) (*shared.OAuthClient, error) {
+	done, log := db.logging("CheckClient", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, stored, err := db.getClient(ctx, id)
+	if err != nil {
+		return nil, done(err, log)
+	} else if result.Public || stored == "" {
+		return nil, done(shared.BadClientError, log)
+	} else if ok, _, err := db.hasher.Verify(shared.Password(secret), stored, ""); err != nil {
+		return nil, done(err, log)
+	} else if !ok {
+		return nil, done(shared.BadClientError, log)
+	}
+
+	return result, done(nil, log)
+}
+
+func (db *Conn) DeleteClient(ctx context.Context, id string) error {
+	done, log := db.logging("DeleteClient", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["oauth-client"]["delete"], id)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.ClientNotFoundError
+		}
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) getClient(ctx context.Context, id string) (*shared.OAuthClient, shared.Password, error) {
+	var secret shared.Password
+	var redirects string
+
+	result := &shared.OAuthClient{ID: id}
+	err := db.
+		QueryRowContext(ctx, db.sqls["oauth-client"]["select"], id).
+		Scan(
+			&result.Name,
+			&secret,
+			&redirects,
+			&result.Public,
+			&result.MTime,
+			&result.CTime)
+
+	if err == sql.ErrNoRows {
+		return nil, "", shared.ClientNotFoundError
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	result.RedirectURIs = strings.Fields(redirects)
+
+	return result, secret, nil
+}
@@ -0,0 +1,321 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+var clientFields = []string{"name", "secret", "redirect_uris", "public", "mtime", "ctime"}
+
+func Test_GetClient(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "client_test.go", "test": "Test_GetClient"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result *shared.OAuthClient
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs("client").
+					WillReturnRows(sqlmock.
+						NewRows(clientFields).
+						AddRow("rp", "hash", "https://a/cb https://b/cb", false, rightaboutnow, rightaboutnow))
+				return db
+			},
+			result: &shared.OAuthClient{
+				ID:           "client",
+				Name:         "rp",
+				RedirectURIs: []string{"https://a/cb", "https://b/cb"},
+				MTime:        rightaboutnow,
+				CTime:        rightaboutnow,
+			},
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(clientFields))
+				return db
+			},
+			err: shared.ClientNotFoundError,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetClient(mockContext(shared.CID("Test_GetClient-"+name)), "client")
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_AddClient(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "client_test.go", "test": "Test_AddClient"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		client *shared.OAuthClient
+		err    error
+	}{
+		"confidential": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(
+						string(mockUUIDGen()),
+						"rp",
+						notNil{},
+						"https://a/cb https://b/cb",
+						false,
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+			client: &shared.OAuthClient{
+				Name:         "rp",
+				Secret:       "snakeoil",
+				RedirectURIs: []string{"https://a/cb", "https://b/cb"},
+			},
+		},
+		"public": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(
+						string(mockUUIDGen()),
+						"spa",
+						shared.Password(""),
+						"https://a/cb",
+						true,
+						sqlmock.AnyArg(),
+						sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+			client: &shared.OAuthClient{
+				Name:         "spa",
+				RedirectURIs: []string{"https://a/cb"},
+				Public:       true,
+			},
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			client: &shared.OAuthClient{Name: "rp", Secret: "snakeoil"},
+			err:    fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			id, err := (&Conn{
+				tc.db(sqlmock.New()),
+				mockUUIDGen,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).AddClient(mockContext(shared.CID("Test_AddClient-"+name)), tc.client)
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, string(mockUUIDGen()), id)
+		})
+	}
+}
+
+func Test_CheckClient(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "client_test.go", "test": "Test_CheckClient"})
+
+	hash, err := testhasher.Hash("snakeoil")
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		db     getMockDB
+		secret string
+		result *shared.OAuthClient
+		err    error
+		anyErr bool // the hasher's own parse errors
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs("client").
+					WillReturnRows(sqlmock.
+						NewRows(clientFields).
+						AddRow("rp", hash, "https://a/cb", false, rightaboutnow, rightaboutnow))
+				return db
+			},
+			secret: "snakeoil",
+			result: &shared.OAuthClient{
+				ID:           "client",
+				Name:         "rp",
+				RedirectURIs: []string{"https://a/cb"},
+				MTime:        rightaboutnow,
+				CTime:        rightaboutnow,
+			},
+		},
+		"wrong_secret": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(clientFields).
+						AddRow("rp", hash, "https://a/cb", false, rightaboutnow, rightaboutnow))
+				return db
+			},
+			secret: "snakeoyl",
+			err:    shared.BadClientError,
+		},
+		"public_client": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(clientFields).
+						AddRow("spa", "", "https://a/cb", true, rightaboutnow, rightaboutnow))
+				return db
+			},
+			secret: "snakeoil",
+			err:    shared.BadClientError,
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(clientFields))
+				return db
+			},
+			secret: "snakeoil",
+			err:    shared.ClientNotFoundError,
+		},
+		"unreadable_hash": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(clientFields).
+						AddRow("rp", "$argon2id$garbage", "https://a/cb", false, rightaboutnow, rightaboutnow))
+				return db
+			},
+			secret: "snakeoil",
+			anyErr: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).CheckClient(mockContext(shared.CID("Test_CheckClient-"+name)), "client", tc.secret)
+
+			if tc.anyErr {
+				require.NotNil(t, err)
+			} else {
+				require.Equal(t, tc.err, err)
+			}
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_DeleteClient(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "client_test.go", "test": "Test_DeleteClient"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WithArgs("client").WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.ClientNotFoundError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).DeleteClient(mockContext(shared.CID("Test_DeleteClient-"+name)), "client")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/internal/passwd"
 	"github.com/jsmit257/userservice/shared/v1"
 )
 
@@ -22,6 +23,9 @@ type (
 		// mx      maild.Sender
 		log     *logrus.Entry
 		metrics *prometheus.CounterVec
+		hasher  passwd.Hasher
+		policy  *shared.PasswordPolicy
+		lockout *shared.LockoutPolicy
 	}
 
 	query interface {
@@ -38,13 +42,28 @@ type (
 	userVec struct{ *prometheus.CounterVec }
 )
 
-func NewUserService(db *sql.DB, sqls config.Sqls, l *logrus.Entry, m *prometheus.CounterVec) *Conn {
+func NewUserService(db *sql.DB, sqls config.Sqls, cfg *config.Config, l *logrus.Entry, m *prometheus.CounterVec) (*Conn, error) {
+	hasher, err := passwd.NewHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := passwd.NewPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Conn{db, uuidGen, sqls, l.WithFields(logrus.Fields{
 		"pkg": "data",
 		"db":  "mysql",
 	}), m.MustCurryWith(prometheus.Labels{
 		"db": "mysql",
-	})}
+	}), hasher, policy, &shared.LockoutPolicy{
+		Threshold:  cfg.LockoutThreshold,
+		Duration:   cfg.LockoutDuration,
+		Backoff:    cfg.LockoutBackoff,
+		MaxBackoff: cfg.LockoutMaxBackoff,
+	}}, nil
 }
 
 // func Obfuscate(s string) string {
@@ -95,21 +114,3 @@ func (u userVec) done(err error) {
 func uuidGen() shared.UUID {
 	return shared.UUID(uuid.NewString())
 }
-
-func hash(pass shared.Password, salt string) shared.Password {
-	// result := string(pass)
-	// for i, sum := 0, [32]byte{}; i < 483; i++ {
-	// 	result += salt
-	// 	sum = sha256.Sum256([]byte(result))
-	// 	result = string(sum[:])
-	// }
-	// return shared.Password(hex.EncodeToString([]byte(result)))
-	return pass + shared.Password(salt)
-}
-
-func generateSalt() string {
-	// result := make([]byte, 4)
-	// _, _ = rand.Read(result)
-	// return string(result)
-	return "salt"
-}
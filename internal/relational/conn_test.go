@@ -16,6 +16,7 @@ import (
 
 	"github.com/jsmit257/userservice/internal/config"
 	"github.com/jsmit257/userservice/internal/metrics"
+	"github.com/jsmit257/userservice/internal/passwd"
 	"github.com/jsmit257/userservice/shared/v1"
 )
 
@@ -35,11 +36,29 @@ var (
 	testmetrics   = metrics.DataMetrics.MustCurryWith(prometheus.Labels{
 		"pkg": "data test",
 	})
+	testhasher, _ = passwd.NewHasher(&config.Config{
+		PasswordHash:  passwd.Argon2id,
+		Argon2Time:    1,
+		Argon2Memory:  64,
+		Argon2Threads: 1,
+	})
+	testpolicy  = &shared.DefaultPasswordPolicy
+	testlockout = &shared.LockoutPolicy{Threshold: 3, Duration: time.Minute}
 )
 
 func Test_NewUserService(t *testing.T) {
-	result := NewUserService(nil, nil, logrus.WithTime(time.Now().UTC()), testmetrics)
+	result, err := NewUserService(nil, nil, &config.Config{
+		PasswordHash:     passwd.Argon2id,
+		LockoutThreshold: 4,
+		LockoutDuration:  time.Minute,
+	}, logrus.WithTime(time.Now().UTC()), testmetrics)
+	require.Nil(t, err)
 	require.NotNil(t, result)
+	require.Equal(t, &shared.LockoutPolicy{Threshold: 4, Duration: time.Minute}, result.lockout)
+
+	result, err = NewUserService(nil, nil, &config.Config{PasswordHash: "rot13"}, logrus.WithTime(time.Now().UTC()), testmetrics)
+	require.ErrorIs(t, err, passwd.UnknownSchemeError)
+	require.Nil(t, result)
 }
 
 func (v values) nil(ord ...uint) values {
@@ -60,19 +79,15 @@ func (v values) replace(r ...repl) values {
 	return result
 }
 
-// func Test_hash(t *testing.T) {
-// 	require.Equal(t, 64, len(hash("foobar", generateSalt())), hash("foobar", generateSalt()))
-// }
-
 func mockUUIDGen() shared.UUID {
 	return shared.UUID(uuid.Must(uuid.FromBytes([]byte("0123456789abcdef"))).String())
 }
 
 func mockSqls() config.Sqls {
-	result := make(config.Sqls, 4)
-	for _, table := range []string{"address", "basic-auth", "contact", "user"} {
-		temp := make(map[string]string, 5)
-		for _, verb := range []string{"select-all", "select", "insert", "update", "delete"} {
+	result := make(config.Sqls, 13)
+	for _, table := range []string{"address", "api-key", "basic-auth", "contact", "password-history", "mfa-channel", "oauth-client", "permission", "recovery-code", "role", "totp", "user", "user-role"} {
+		temp := make(map[string]string, 11)
+		for _, verb := range []string{"select-all", "select", "insert", "update", "delete", "prune", "unlock", "check", "owner", "active", "use"} {
 			temp[verb] = "snakeoil"
 		}
 		result[table] = temp
@@ -151,6 +151,9 @@ func TestGetContact(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).getContact(mockContext(shared.CID("TestGetContact-"+name)), "1")
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.result, contact)
@@ -219,6 +222,9 @@ func TestAddContact(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).addContact(mockContext(shared.CID("TestAddContact-"+name)), tc.userid, tc.contact)
 			require.Equal(t, tc.err, err)
 			// require.Equal(t, tc.result, result) // there's no way to match mtime/ctime
@@ -273,6 +279,9 @@ func TestUpdateContact(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).UpdateContact(mockContext(shared.CID("TestUpdateContact-"+name)), tc.userid, tc.contact))
 		})
 	}
@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// checkHistory fails with PasswordsMatch if pass matches any of the user's last
+// policy.History passwords; hashes are recorded when they're set, not when
+// they're replaced, so random pads from ResetPassword never make the list
+func (db *Conn) checkHistory(ctx context.Context, uid shared.UUID, pass shared.Password) error {
+	done, log := db.logging("checkHistory", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	if db.policy.History == 0 {
+		return done(nil, log)
+	}
+
+	rows, err := db.QueryContext(ctx, db.sqls["password-history"]["select"], uid, db.policy.History)
+	if err != nil {
+		return done(err, log)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var same bool
+		old := shared.BasicAuth{}
+		if err = rows.Scan(&old.Pass, &old.Salt); err != nil {
+			break
+		} else if same, _, err = db.hasher.Verify(pass, old.Pass, old.Salt); err != nil {
+			break
+		} else if same {
+			err = shared.PasswordsMatch
+			break
+		}
+	}
+
+	if err == nil {
+		err = rows.Err()
+	}
+
+	return done(err, log)
+}
+
+// addHistory records a newly set password and drops anything older than the
+// last policy.History entries
+func (db *Conn) addHistory(ctx context.Context, auth *shared.BasicAuth) error {
+	done, log := db.logging("addHistory", auth.UUID, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	if db.policy.History == 0 {
+		return done(nil, log)
+	}
+
+	_, err := db.ExecContext(ctx, db.sqls["password-history"]["insert"],
+		auth.UUID,
+		auth.Pass,
+		auth.Salt,
+		time.Now().UTC())
+	if err == nil {
+		_, err = db.ExecContext(ctx, db.sqls["password-history"]["prune"],
+			auth.UUID,
+			auth.UUID,
+			db.policy.History)
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) deleteHistory(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("deleteHistory", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	_, err := db.ExecContext(ctx, db.sqls["password-history"]["delete"], uid)
+
+	return done(err, log)
+}
@@ -0,0 +1,158 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_addHistory(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "history_test.go", "test": "Test_addHistory"})
+
+	tcs := map[string]struct {
+		db      getMockDB
+		history int
+		err     error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+			history: 3,
+		},
+		"disabled": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				return db
+			},
+		},
+		"insert_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			history: 3,
+			err:     fmt.Errorf("some error"),
+		},
+		"prune_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			history: 3,
+			err:     fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				&shared.PasswordPolicy{History: tc.history},
+				testlockout,
+			}).addHistory(mockContext(shared.CID("Test_addHistory-"+name)), &shared.BasicAuth{
+				UUID: "0",
+				Pass: _hashed,
+			})
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_checkHistory(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "history_test.go", "test": "Test_checkHistory"})
+
+	tcs := map[string]struct {
+		db      getMockDB
+		history int
+		err     error
+	}{
+		"disabled": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				return db
+			},
+		},
+		"no_history": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"password", "salt"}))
+				return db
+			},
+			history: 3,
+		},
+		"legacy_match": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"password", "salt"}).
+						AddRow("snakeoilsalt", "salt"))
+				return db
+			},
+			history: 3,
+			err:     shared.PasswordsMatch,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			history: 3,
+			err:     fmt.Errorf("some error"),
+		},
+		"row_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"password", "salt"}).
+						AddRow("whiskeytango", "").
+						RowError(0, fmt.Errorf("some error")))
+				return db
+			},
+			history: 3,
+			err:     fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				&shared.PasswordPolicy{History: tc.history},
+				testlockout,
+			}).checkHistory(mockContext(shared.CID("Test_checkHistory-"+name)), "0", "snakeoil")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/userservice/internal/oidc"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// GetInvitations is every invitation there ever was, newest first; the
+// closed ones are the audit trail, so they're included
+func (db *Conn) GetInvitations(ctx context.Context) ([]shared.Invitation, error) {
+	done, log := db.logging("GetInvitations", nil, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	rows, err := db.QueryContext(ctx, db.sqls["invitation"]["select-all"])
+	if err != nil {
+		return nil, done(err, log)
+	}
+	defer rows.Close()
+
+	result := []shared.Invitation{}
+	for rows.Next() {
+		row := shared.Invitation{}
+		if err = rows.Scan(
+			&row.UUID,
+			&row.Email,
+			&row.Cell,
+			&row.CreatedBy,
+			&row.Expires,
+			&row.Accepted,
+			&row.AcceptedBy,
+			&row.Revoked,
+			&row.RevokedBy,
+			&row.CTime,
+		); err != nil {
+			return nil, done(err, log)
+		}
+		result = append(result, row)
+	}
+
+	return result, done(rows.Err(), log)
+}
+
+// GetInvitation takes the token from an invitation link and returns the
+// invitation if it's still open; anything that doesn't match is
+// InvitationNotFoundError, same as CheckAPIKey
+func (db *Conn) GetInvitation(ctx context.Context, token string) (*shared.Invitation, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, shared.InvitationNotFoundError
+	}
+
+	done, log := db.logging("GetInvitation", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := checkInvitation(db.QueryRowContext(ctx, db.sqls["invitation"]["select"], id), id, secret)
+
+	return result, done(err, log)
+}
+
+// AddInvitation fills in the id and ctime and returns the token for the
+// link; CreatedBy and Expires are up to the caller
+func (db *Conn) AddInvitation(ctx context.Context, inv *shared.Invitation) (string, error) {
+	done, log := db.logging("AddInvitation", inv.CreatedBy, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	secret, err := oidc.NewSecret()
+	if err != nil {
+		return "", done(err, log)
+	}
+
+	inv.UUID = db.uuidgen()
+	inv.CTime = time.Now().UTC()
+
+	if _, err = db.ExecContext(ctx, db.sqls["invitation"]["insert"],
+		inv.UUID,
+		inv.Email,
+		inv.Cell,
+		hashAPIKey(secret), // same scheme, a random secret doesn't need anything slower
+		inv.CreatedBy,
+		inv.Expires,
+		inv.CTime,
+	); err != nil {
+		return "", done(err, log)
+	}
+
+	return string(inv.UUID) + "." + secret, done(nil, log)
+}
+
+// RevokeInvitation closes an open invitation on behalf of by; one that's
+// already closed is InvitationNotFoundError, same as one that never existed
+func (db *Conn) RevokeInvitation(ctx context.Context, id, by shared.UUID) error {
+	done, log := db.logging("RevokeInvitation", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["invitation"]["revoke"], time.Now().UTC(), by, id)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.InvitationNotFoundError
+		}
+	}
+
+	return done(err, log)
+}
+
+// AcceptInvitation is SignUp with the address taken from the invitation
+// instead of the caller; following the link proves the address, so it starts
+// out verified. The user, the verification and closing the invitation all
+// happen in one transaction
+func (db *Conn) AcceptInvitation(ctx context.Context, token string, u *shared.User, pass shared.Password) (shared.UUID, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" || secret == "" {
+		return "", shared.InvitationNotFoundError
+	}
+
+	done, log := db.logging("AcceptInvitation", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", done(err, log)
+	}
+	defer tx.Rollback() // nothing to undo once it's committed
+
+	inv, err := checkInvitation(tx.QueryRowContext(ctx, db.sqls["invitation"]["select-for-update"], id), id, secret)
+	if err != nil {
+		return "", done(err, log)
+	}
+
+	u.Email, u.Cell = inv.Email, inv.Cell
+	if err = db.signUp(ctx, tx, u, pass); err != nil {
+		return "", done(err, log)
+	}
+
+	now := time.Now().UTC()
+	verify, addr := "verify-email", any(inv.Email)
+	if !inv.Email.Valid() {
+		verify, addr = "verify-cell", inv.Cell
+	}
+
+	if err = db.execOne(ctx, tx, shared.UserNotUpdatedError, db.sqls["user"][verify], now, u.UUID, addr); err != nil {
+		return "", done(err, log)
+	} else if err = db.execOne(ctx, tx, shared.InvitationClosedError, db.sqls["invitation"]["accept"], now, u.UUID, id); err != nil {
+		return "", done(err, log)
+	}
+
+	return u.UUID, done(tx.Commit(), log)
+}
+
+// execOne runs stmt in tx and expects it to change exactly one row,
+// otherwise it's notOne
+func (db *Conn) execOne(ctx context.Context, tx *sql.Tx, notOne error, stmt string, args ...any) error {
+	result, err := tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	} else if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows != 1 {
+		return notOne
+	}
+	return nil
+}
+
+// checkInvitation scans a row from select or select-for-update and makes
+// sure secret belongs to it and it's still open
+func checkInvitation(row *sql.Row, id, secret string) (*shared.Invitation, error) {
+	var hash string
+	result := &shared.Invitation{UUID: shared.UUID(id)}
+	err := row.Scan(
+		&result.Email,
+		&result.Cell,
+		&hash,
+		&result.CreatedBy,
+		&result.Expires,
+		&result.Accepted,
+		&result.AcceptedBy,
+		&result.Revoked,
+		&result.RevokedBy,
+		&result.CTime)
+
+	if err == sql.ErrNoRows {
+		return nil, shared.InvitationNotFoundError
+	} else if err != nil {
+		return nil, err
+	} else if subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPIKey(secret))) != 1 {
+		return nil, shared.InvitationNotFoundError
+	} else if !result.Open(time.Now().UTC()) {
+		return nil, shared.InvitationClosedError
+	}
+
+	return result, nil
+}
@@ -0,0 +1,548 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+var (
+	invitationFields      = []string{"uuid", "email", "cell", "created_by", "expires", "accepted", "accepted_by", "revoked", "revoked_by", "ctime"}
+	invitationCheckFields = []string{"email", "cell", "hash", "created_by", "expires", "accepted", "accepted_by", "revoked", "revoked_by", "ctime"}
+)
+
+func Test_GetInvitations(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "invitation_test.go", "test": "Test_GetInvitations"})
+
+	addr, cell := shared.Email("someone@example.com"), shared.Cell("5551234")
+	uid, admin := shared.UUID("uid"), shared.UUID("admin")
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result []shared.Invitation
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(invitationFields).
+						AddRow("1", addr, nil, "admin", rightaboutnow, rightaboutnow, uid, nil, nil, rightaboutnow).
+						AddRow("0", nil, cell, "admin", rightaboutnow, nil, nil, rightaboutnow, "admin", rightaboutnow))
+				return db
+			},
+			result: []shared.Invitation{
+				{
+					UUID:       "1",
+					Email:      &addr,
+					CreatedBy:  "admin",
+					Expires:    rightaboutnow,
+					Accepted:   &rightaboutnow,
+					AcceptedBy: &uid,
+					CTime:      rightaboutnow,
+				},
+				{
+					UUID:      "0",
+					Cell:      &cell,
+					CreatedBy: "admin",
+					Expires:   rightaboutnow,
+					Revoked:   &rightaboutnow,
+					RevokedBy: &admin,
+					CTime:     rightaboutnow,
+				},
+			},
+		},
+		"no_invitations": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(invitationFields))
+				return db
+			},
+			result: []shared.Invitation{},
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"scan_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(invitationFields).
+						AddRow("0", addr, nil, "admin", rightaboutnow, nil, nil, nil, nil, "not a time"))
+				return db
+			},
+			err: fmt.Errorf(`sql: Scan error on column index 9, name "ctime": unsupported Scan, storing driver.Value type string into type *time.Time`),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetInvitations(mockContext(shared.CID("Test_GetInvitations-" + name)))
+
+			if tc.err != nil {
+				require.EqualError(t, err, tc.err.Error())
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_GetInvitation(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "invitation_test.go", "test": "Test_GetInvitation"})
+
+	addr, hash, later := shared.Email("someone@example.com"), hashAPIKey("snakeoil"), rightaboutnow.Add(time.Hour)
+
+	tcs := map[string]struct {
+		db     getMockDB
+		token  string
+		result *shared.Invitation
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs("inv").
+					WillReturnRows(sqlmock.
+						NewRows(invitationCheckFields).
+						AddRow(addr, nil, hash, "admin", later, nil, nil, nil, nil, rightaboutnow))
+				return db
+			},
+			token: "inv.snakeoil",
+			result: &shared.Invitation{
+				UUID:      "inv",
+				Email:     &addr,
+				CreatedBy: "admin",
+				Expires:   later,
+				CTime:     rightaboutnow,
+			},
+		},
+		"wrong_secret": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(invitationCheckFields).
+						AddRow(addr, nil, hash, "admin", later, nil, nil, nil, nil, rightaboutnow))
+				return db
+			},
+			token: "inv.snakeoyl",
+			err:   shared.InvitationNotFoundError,
+		},
+		"expired": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(invitationCheckFields).
+						AddRow(addr, nil, hash, "admin", rightaboutnow, nil, nil, nil, nil, rightaboutnow))
+				return db
+			},
+			token: "inv.snakeoil",
+			err:   shared.InvitationClosedError,
+		},
+		"accepted": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(invitationCheckFields).
+						AddRow(addr, nil, hash, "admin", later, rightaboutnow, "uid", nil, nil, rightaboutnow))
+				return db
+			},
+			token: "inv.snakeoil",
+			err:   shared.InvitationClosedError,
+		},
+		"revoked": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(invitationCheckFields).
+						AddRow(addr, nil, hash, "admin", later, nil, nil, rightaboutnow, "admin", rightaboutnow))
+				return db
+			},
+			token: "inv.snakeoil",
+			err:   shared.InvitationClosedError,
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(invitationCheckFields))
+				return db
+			},
+			token: "inv.snakeoil",
+			err:   shared.InvitationNotFoundError,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			token: "inv.snakeoil",
+			err:   fmt.Errorf("some error"),
+		},
+		"malformed": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				return db
+			},
+			token: "snakeoil",
+			err:   shared.InvitationNotFoundError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			result, err := (&Conn{
+				tc.db(db, mock, err),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetInvitation(mockContext(shared.CID("Test_GetInvitation-"+name)), tc.token)
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+			require.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_AddInvitation(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "invitation_test.go", "test": "Test_AddInvitation"})
+
+	addr, later := shared.Email("someone@example.com"), rightaboutnow.Add(time.Hour)
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), "someone@example.com", nil, sqlmock.AnyArg(), shared.UUID("admin"), later, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"insert_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inv := &shared.Invitation{Email: &addr, CreatedBy: "admin", Expires: later}
+			token, err := (&Conn{
+				tc.db(sqlmock.New()),
+				mockUUIDGen,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).AddInvitation(mockContext(shared.CID("Test_AddInvitation-"+name)), inv)
+
+			require.Equal(t, tc.err, err)
+			if err != nil {
+				require.Empty(t, token)
+				return
+			}
+			require.Equal(t, mockUUIDGen(), inv.UUID)
+			require.True(t, strings.HasPrefix(token, string(mockUUIDGen())+"."))
+		})
+	}
+}
+
+func Test_RevokeInvitation(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "invitation_test.go", "test": "Test_RevokeInvitation"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), shared.UUID("admin"), shared.UUID("inv")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.InvitationNotFoundError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).RevokeInvitation(mockContext(shared.CID("Test_RevokeInvitation-"+name)), "inv", "admin")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_AcceptInvitation(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "invitation_test.go", "test": "Test_AcceptInvitation"})
+
+	addr, cell := shared.Email("someone@example.com"), shared.Cell("5551234")
+	hash, later := hashAPIKey("snakeoil"), rightaboutnow.Add(time.Hour)
+	open := func(e, c any) *sqlmock.Rows {
+		return sqlmock.
+			NewRows(invitationCheckFields).
+			AddRow(e, c, hash, "admin", later, nil, nil, nil, nil, rightaboutnow)
+	}
+
+	tcs := map[string]struct {
+		db     getMockDB
+		token  string
+		pass   shared.Password
+		result shared.UUID
+		email  *shared.Email
+		cell   *shared.Cell
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WithArgs("inv").WillReturnRows(open(addr, nil))
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), "username", "someone@example.com", nil, sqlmock.AnyArg(), "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), mockUUIDGen(), "someone@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), mockUUIDGen(), "inv").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			token:  "inv.snakeoil",
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			email:  &addr,
+		},
+		"cell": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(open(nil, cell))
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), "username", nil, "5551234", sqlmock.AnyArg(), "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), mockUUIDGen(), "5551234").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			token:  "inv.snakeoil",
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			cell:   &cell,
+		},
+		"malformed": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				return db
+			},
+			token: "snakeoil",
+			err:   shared.InvitationNotFoundError,
+		},
+		"begin_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			token: "inv.snakeoil",
+			err:   fmt.Errorf("some error"),
+		},
+		"wrong_secret": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(open(addr, nil))
+				mock.ExpectRollback()
+				return db
+			},
+			token: "inv.snakeoyl",
+			err:   shared.InvitationNotFoundError,
+		},
+		"policy_violation": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(open(addr, nil))
+				mock.ExpectRollback()
+				return db
+			},
+			token: "inv.snakeoil",
+			pass:  "short",
+			email: &addr,
+			err:   testpolicy.Check("short"),
+		},
+		"verify_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(open(addr, nil))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				return db
+			},
+			token: "inv.snakeoil",
+			pass:  "correct horse",
+			email: &addr,
+			err:   shared.UserNotUpdatedError,
+		},
+		"accept_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(open(addr, nil))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			token: "inv.snakeoil",
+			pass:  "correct horse",
+			email: &addr,
+			err:   fmt.Errorf("some error"),
+		},
+		"already_accepted": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(open(addr, nil))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				return db
+			},
+			token: "inv.snakeoil",
+			pass:  "correct horse",
+			email: &addr,
+			err:   shared.InvitationClosedError,
+		},
+		"commit_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(open(addr, nil))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			token:  "inv.snakeoil",
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			email:  &addr,
+			err:    fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// whatever the caller says about addresses is ignored
+			other := shared.Email("other@example.com")
+			u := &shared.User{Name: "username", Email: &other}
+
+			db, mock, err := sqlmock.New()
+			result, err := (&Conn{
+				tc.db(db, mock, err),
+				mockUUIDGen,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).AcceptInvitation(mockContext(shared.CID("Test_AcceptInvitation-"+name)), tc.token, u, tc.pass)
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+			if tc.email != nil || tc.cell != nil {
+				require.Equal(t, tc.email, u.Email)
+				require.Equal(t, tc.cell, u.Cell)
+			}
+			require.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// GetMagicLink fails with MagicLinkNotEnabledError when the user never opted
+// in
+func (db *Conn) GetMagicLink(ctx context.Context, uid shared.UUID) (*shared.MagicLink, error) {
+	done, log := db.logging("GetMagicLink", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result := &shared.MagicLink{}
+	err := db.
+		QueryRowContext(ctx, db.sqls["magic-link"]["select"], uid).
+		Scan(
+			&result.Channel,
+			&result.MTime,
+			&result.CTime)
+
+	if err == sql.ErrNoRows {
+		return nil, done(shared.MagicLinkNotEnabledError, log)
+	} else if err != nil {
+		return nil, done(err, log)
+	}
+
+	return result, done(err, log)
+}
+
+// EnableMagicLink opts in, or changes where links go if already opted in
+func (db *Conn) EnableMagicLink(ctx context.Context, uid shared.UUID, ch shared.Channel) error {
+	done, log := db.logging("EnableMagicLink", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, db.sqls["magic-link"]["insert"], uid, ch, now, now)
+
+	return done(err, log)
+}
+
+func (db *Conn) DisableMagicLink(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("DisableMagicLink", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["magic-link"]["delete"], uid)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.MagicLinkNotEnabledError
+		}
+	}
+
+	return done(err, log)
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_GetMagicLink(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "magic_test.go", "test": "Test_GetMagicLink"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result *shared.MagicLink
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs("0").
+					WillReturnRows(sqlmock.
+						NewRows([]string{"channel", "mtime", "ctime"}).
+						AddRow("sms", rightaboutnow, rightaboutnow))
+				return db
+			},
+			result: &shared.MagicLink{
+				Channel: shared.SMSChannel,
+				MTime:   rightaboutnow,
+				CTime:   rightaboutnow,
+			},
+		},
+		"not_enabled": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.NewRows([]string{"channel", "mtime", "ctime"}))
+				return db
+			},
+			err: shared.MagicLinkNotEnabledError,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetMagicLink(mockContext(shared.CID("Test_GetMagicLink-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_EnableMagicLink(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "magic_test.go", "test": "Test_EnableMagicLink"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs("0", shared.EmailChannel, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).EnableMagicLink(mockContext(shared.CID("Test_EnableMagicLink-"+name)), "0", shared.EmailChannel)
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_DisableMagicLink(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "magic_test.go", "test": "Test_DisableMagicLink"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs("0").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_enabled": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.MagicLinkNotEnabledError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).DisableMagicLink(mockContext(shared.CID("Test_DisableMagicLink-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
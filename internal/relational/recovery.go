@@ -0,0 +1,72 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jsmit257/userservice/internal/mfa"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// ReplaceRecoveryCodes throws away whatever codes the user had, used or not,
+// and stores the hashes of the new set
+func (db *Conn) ReplaceRecoveryCodes(ctx context.Context, uid shared.UUID, codes []string) error {
+	done, log := db.logging("ReplaceRecoveryCodes", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return done(err, log)
+	}
+	defer func() { _ = tx.Rollback() }() // a no-op once it's committed
+
+	if _, err = tx.ExecContext(ctx, db.sqls["recovery-code"]["delete"], uid); err != nil {
+		return done(err, log)
+	}
+
+	now := time.Now().UTC()
+	for _, code := range codes {
+		if _, err = tx.ExecContext(ctx, db.sqls["recovery-code"]["insert"], uid, mfa.HashRecoveryCode(code), now); err != nil {
+			return done(err, log)
+		}
+	}
+
+	return done(tx.Commit(), log)
+}
+
+// UseRecoveryCode burns a code, failing with BadMFACodeError if it doesn't
+// exist or was already used
+func (db *Conn) UseRecoveryCode(ctx context.Context, uid shared.UUID, code string) error {
+	done, log := db.logging("UseRecoveryCode", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["recovery-code"]["update"],
+		time.Now().UTC(),
+		uid,
+		mfa.HashRecoveryCode(code))
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.BadMFACodeError
+		}
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) CountRecoveryCodes(ctx context.Context, uid shared.UUID) (int, error) {
+	done, log := db.logging("CountRecoveryCodes", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	var result int
+	err := db.QueryRowContext(ctx, db.sqls["recovery-code"]["select"], uid).Scan(&result)
+
+	return result, done(err, log)
+}
+
+// pruneRecoveryCodes drops the user's codes once there's no confirmed second
+// factor left for them to stand in for
+func (db *Conn) pruneRecoveryCodes(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("pruneRecoveryCodes", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	_, err := db.ExecContext(ctx, db.sqls["recovery-code"]["prune"], uid, uid, uid)
+
+	return done(err, log)
+}
@@ -0,0 +1,206 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/mfa"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_ReplaceRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "recovery_test.go", "test": "Test_ReplaceRecoveryCodes"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WithArgs("0").WillReturnResult(sqlmock.NewResult(0, 10))
+				mock.ExpectExec("").
+					WithArgs("0", mfa.HashRecoveryCode("abcde-fghij"), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("").
+					WithArgs("0", mfa.HashRecoveryCode("klmno-pqrst"), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		"begin_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"delete_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"insert_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 10))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"commit_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 10))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).ReplaceRecoveryCodes(
+				mockContext(shared.CID("Test_ReplaceRecoveryCodes-"+name)),
+				"0",
+				[]string{"abcde-fghij", "klmno-pqrst"})
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_UseRecoveryCode(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "recovery_test.go", "test": "Test_UseRecoveryCode"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), "0", mfa.HashRecoveryCode("abcdefghij")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"used_or_missing": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.BadMFACodeError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).UseRecoveryCode(mockContext(shared.CID("Test_UseRecoveryCode-"+name)), "0", "ABCDE-FGHIJ")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_CountRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "recovery_test.go", "test": "Test_CountRecoveryCodes"})
+
+	tcs := map[string]struct {
+		db    getMockDB
+		count int
+		err   error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+				return db
+			},
+			count: 7,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			count, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).CountRecoveryCodes(mockContext(shared.CID("Test_CountRecoveryCodes-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.count, count)
+		})
+	}
+}
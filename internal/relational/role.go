@@ -0,0 +1,242 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// mysql's 'Cannot add or update a child row: a foreign key constraint fails'
+const fkViolation = 1452
+
+type scanner interface {
+	Scan(...any) error
+}
+
+func (db *Conn) GetRoles(ctx context.Context) ([]shared.Role, error) {
+	done, log := db.logging("GetRoles", nil, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	rows, err := db.QueryContext(ctx, db.sqls["role"]["select-all"])
+	if err != nil {
+		return nil, done(err, log)
+	}
+	defer rows.Close()
+
+	result, err := scanRoles(rows)
+
+	return result, done(err, log)
+}
+
+func (db *Conn) GetRole(ctx context.Context, id shared.UUID) (*shared.Role, error) {
+	done, log := db.logging("GetRole", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := scanRole(db.QueryRowContext(ctx, db.sqls["role"]["select"], id))
+	if err == sql.ErrNoRows {
+		return nil, done(shared.RoleNotFoundError, log)
+	} else if err != nil {
+		return nil, done(err, log)
+	}
+
+	return result, done(nil, log)
+}
+
+// AddRole creates the role and grants whatever permissions it came with, all
+// or nothing
+func (db *Conn) AddRole(ctx context.Context, r *shared.Role) (shared.UUID, error) {
+	done, log := db.logging("AddRole", r.Name, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", done(err, log)
+	}
+	defer func() { _ = tx.Rollback() }() // a no-op once it's committed
+
+	now := time.Now().UTC()
+	r.UUID = db.uuidgen()
+	r.MTime = now
+	r.CTime = now
+
+	if _, err = tx.ExecContext(ctx, db.sqls["role"]["insert"],
+		r.UUID,
+		r.Name,
+		r.Description,
+		now,
+		now,
+	); err != nil {
+		return "", done(roleError(err), log)
+	}
+
+	for _, p := range r.Permissions {
+		if _, err = tx.ExecContext(ctx, db.sqls["permission"]["insert"], r.UUID, p, now); err != nil {
+			return "", done(err, log)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", done(err, log)
+	}
+
+	return r.UUID, done(nil, log)
+}
+
+// UpdateRole only changes the name and description; permissions have their
+// own calls
+func (db *Conn) UpdateRole(ctx context.Context, r *shared.Role) error {
+	done, log := db.logging("UpdateRole", r.UUID, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	r.MTime = time.Now().UTC()
+	result, err := db.ExecContext(ctx, db.sqls["role"]["update"],
+		r.Name,
+		r.Description,
+		r.MTime,
+		r.UUID)
+	if err != nil {
+		err = roleError(err)
+	} else {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.RoleNotFoundError
+		}
+	}
+
+	return done(err, log)
+}
+
+// DeleteRole takes the role away from everyone who had it
+func (db *Conn) DeleteRole(ctx context.Context, id shared.UUID) error {
+	done, log := db.logging("DeleteRole", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["role"]["delete"], id)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.RoleNotFoundError
+		}
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) GrantPermission(ctx context.Context, id shared.UUID, perm string) error {
+	done, log := db.logging("GrantPermission", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	_, err := db.ExecContext(ctx, db.sqls["permission"]["insert"], id, perm, time.Now().UTC())
+	if isFKViolation(err) {
+		err = shared.RoleNotFoundError
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) RevokePermission(ctx context.Context, id shared.UUID, perm string) error {
+	done, log := db.logging("RevokePermission", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["permission"]["delete"], id, perm)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.PermissionNotFoundError
+		}
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) GetUserRoles(ctx context.Context, uid shared.UUID) ([]shared.Role, error) {
+	done, log := db.logging("GetUserRoles", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	rows, err := db.QueryContext(ctx, db.sqls["user-role"]["select"], uid)
+	if err != nil {
+		return nil, done(err, log)
+	}
+	defer rows.Close()
+
+	result, err := scanRoles(rows)
+
+	return result, done(err, log)
+}
+
+// AssignRole fails with RoleNotFoundError if the role doesn't exist; an
+// unknown user is just an error, same as anywhere else
+func (db *Conn) AssignRole(ctx context.Context, uid, id shared.UUID) error {
+	done, log := db.logging("AssignRole", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	_, err := db.ExecContext(ctx, db.sqls["user-role"]["insert"], uid, id, time.Now().UTC())
+	if isFKViolation(err) && strings.Contains(err.(*mysql.MySQLError).Message, "`roles`") {
+		err = shared.RoleNotFoundError
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) UnassignRole(ctx context.Context, uid, id shared.UUID) error {
+	done, log := db.logging("UnassignRole", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["user-role"]["delete"], uid, id)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.RoleNotAssignedError
+		}
+	}
+
+	return done(err, log)
+}
+
+// HasPermission is true if any of the user's roles grants perm
+func (db *Conn) HasPermission(ctx context.Context, uid shared.UUID, perm string) (bool, error) {
+	done, log := db.logging("HasPermission", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	var count int
+	err := db.QueryRowContext(ctx, db.sqls["user-role"]["check"], uid, perm).Scan(&count)
+
+	return count > 0, done(err, log)
+}
+
+func scanRoles(rows *sql.Rows) ([]shared.Role, error) {
+	result := []shared.Role{}
+	for rows.Next() {
+		row, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *row)
+	}
+	return result, rows.Err()
+}
+
+func scanRole(s scanner) (*shared.Role, error) {
+	var perms string
+
+	result := &shared.Role{}
+	if err := s.Scan(
+		&result.UUID,
+		&result.Name,
+		&result.Description,
+		&perms,
+		&result.MTime,
+		&result.CTime,
+	); err != nil {
+		return nil, err
+	}
+
+	result.Permissions = strings.Fields(perms)
+
+	return result, nil
+}
+
+func roleError(err error) error {
+	if v, ok := err.(*mysql.MySQLError); ok && strings.Contains(v.Message, "roles.name") {
+		return shared.RoleExistsError
+	}
+	return err
+}
+
+func isFKViolation(err error) bool {
+	v, ok := err.(*mysql.MySQLError)
+	return ok && v.Number == fkViolation
+}
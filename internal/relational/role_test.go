@@ -0,0 +1,736 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+var roleFields = []string{"uuid", "name", "description", "permissions", "mtime", "ctime"}
+
+func Test_GetRoles(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_GetRoles"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result []shared.Role
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(roleFields).
+						AddRow("0", "admin", "everything", "roles:write users:write", rightaboutnow, rightaboutnow).
+						AddRow("1", "nobody", "", "", rightaboutnow, rightaboutnow))
+				return db
+			},
+			result: []shared.Role{
+				{UUID: "0", Name: "admin", Description: "everything", Permissions: []string{"roles:write", "users:write"}, MTime: rightaboutnow, CTime: rightaboutnow},
+				{UUID: "1", Name: "nobody", Permissions: []string{}, MTime: rightaboutnow, CTime: rightaboutnow},
+			},
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"scan_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WillReturnRows(sqlmock.
+						NewRows(roleFields).
+						AddRow("0", "admin", "", "", rightaboutnow, "not a time"))
+				return db
+			},
+			err: fmt.Errorf(`sql: Scan error on column index 5, name "ctime": unsupported Scan, storing driver.Value type string into type *time.Time`),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetRoles(mockContext(shared.CID("Test_GetRoles-" + name)))
+
+			if tc.err != nil {
+				require.EqualError(t, err, tc.err.Error())
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_GetRole(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_GetRole"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result *shared.Role
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs(shared.UUID("0")).
+					WillReturnRows(sqlmock.
+						NewRows(roleFields).
+						AddRow("0", "admin", "everything", "roles:write", rightaboutnow, rightaboutnow))
+				return db
+			},
+			result: &shared.Role{
+				UUID:        "0",
+				Name:        "admin",
+				Description: "everything",
+				Permissions: []string{"roles:write"},
+				MTime:       rightaboutnow,
+				CTime:       rightaboutnow,
+			},
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(roleFields))
+				return db
+			},
+			err: shared.RoleNotFoundError,
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetRole(mockContext(shared.CID("Test_GetRole-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_AddRole(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_AddRole"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		id  shared.UUID
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), "admin", "everything", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), "roles:write", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), "users:write", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			id: mockUUIDGen(),
+		},
+		"begin_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"duplicate_name": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnError(&mysql.MySQLError{
+					Number:  1062,
+					Message: "Duplicate entry 'admin' for key 'roles.name'",
+				})
+				mock.ExpectRollback()
+				return db
+			},
+			err: shared.RoleExistsError,
+		},
+		"permission_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"commit_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			id, err := (&Conn{
+				tc.db(sqlmock.New()),
+				mockUUIDGen,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).AddRole(mockContext(shared.CID("Test_AddRole-"+name)), &shared.Role{
+				Name:        "admin",
+				Description: "everything",
+				Permissions: []string{"roles:write", "users:write"},
+			})
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.id, id)
+		})
+	}
+}
+
+func Test_UpdateRole(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_UpdateRole"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs("admin", "everything", sqlmock.AnyArg(), shared.UUID("0")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.RoleNotFoundError,
+		},
+		"duplicate_name": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(&mysql.MySQLError{
+					Number:  1062,
+					Message: "Duplicate entry 'admin' for key 'roles.name'",
+				})
+				return db
+			},
+			err: shared.RoleExistsError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).UpdateRole(mockContext(shared.CID("Test_UpdateRole-"+name)), &shared.Role{
+				UUID:        "0",
+				Name:        "admin",
+				Description: "everything",
+			})
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_DeleteRole(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_DeleteRole"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WithArgs(shared.UUID("0")).WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_found": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.RoleNotFoundError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).DeleteRole(mockContext(shared.CID("Test_DeleteRole-"+name)), "0")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_GrantPermission(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_GrantPermission"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(shared.UUID("0"), "roles:write", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"already_granted": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+		},
+		"no_such_role": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(&mysql.MySQLError{Number: fkViolation})
+				return db
+			},
+			err: shared.RoleNotFoundError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GrantPermission(mockContext(shared.CID("Test_GrantPermission-"+name)), "0", "roles:write")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_RevokePermission(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_RevokePermission"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(shared.UUID("0"), "roles:write").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_granted": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.PermissionNotFoundError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).RevokePermission(mockContext(shared.CID("Test_RevokePermission-"+name)), "0", "roles:write")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_GetUserRoles(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_GetUserRoles"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result []shared.Role
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs(shared.UUID("uid")).
+					WillReturnRows(sqlmock.
+						NewRows(roleFields).
+						AddRow("0", "admin", "", "roles:write", rightaboutnow, rightaboutnow))
+				return db
+			},
+			result: []shared.Role{
+				{UUID: "0", Name: "admin", Permissions: []string{"roles:write"}, MTime: rightaboutnow, CTime: rightaboutnow},
+			},
+		},
+		"no_roles": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows(roleFields))
+				return db
+			},
+			result: []shared.Role{},
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).GetUserRoles(mockContext(shared.CID("Test_GetUserRoles-"+name)), "uid")
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_AssignRole(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_AssignRole"})
+
+	tcs := map[string]struct {
+		db      getMockDB
+		err     error
+		someErr bool
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(shared.UUID("uid"), shared.UUID("0"), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"no_such_role": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(&mysql.MySQLError{
+					Number:  fkViolation,
+					Message: "Cannot add or update a child row: a foreign key constraint fails (... REFERENCES `roles` (`uuid`) ON DELETE CASCADE)",
+				})
+				return db
+			},
+			err: shared.RoleNotFoundError,
+		},
+		"no_such_user": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(&mysql.MySQLError{
+					Number:  fkViolation,
+					Message: "Cannot add or update a child row: a foreign key constraint fails (... REFERENCES `users` (`uuid`))",
+				})
+				return db
+			},
+			someErr: true,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).AssignRole(mockContext(shared.CID("Test_AssignRole-"+name)), "uid", "0")
+
+			if tc.someErr {
+				require.NotNil(t, err)
+				require.NotEqual(t, shared.RoleNotFoundError, err)
+			} else {
+				require.Equal(t, tc.err, err)
+			}
+		})
+	}
+}
+
+func Test_UnassignRole(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_UnassignRole"})
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(shared.UUID("uid"), shared.UUID("0")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"not_assigned": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.RoleNotAssignedError,
+		},
+		"exec_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).UnassignRole(mockContext(shared.CID("Test_UnassignRole-"+name)), "uid", "0")
+
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_HasPermission(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "role_test.go", "test": "Test_HasPermission"})
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result bool
+		err    error
+	}{
+		"granted": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").
+					WithArgs(shared.UUID("uid"), "roles:write").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				return db
+			},
+			result: true,
+		},
+		"denied": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				return db
+			},
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := (&Conn{
+				tc.db(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).HasPermission(mockContext(shared.CID("Test_HasPermission-"+name)), "uid", "roles:write")
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
@@ -0,0 +1,465 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/jsmit257/userservice/internal/config"
+	valid "github.com/jsmit257/userservice/internal/validation"
+)
+
+// the kinds of thing a session key can hold
+const (
+	hashKey   = "hash"
+	setKey    = "set"
+	stringKey = "string"
+)
+
+type (
+	// SessionStore is a valid.Store in mysql, for deployments without redis;
+	// every command is its own transaction and Atomic is one transaction for
+	// the lot, which unlike redis rolls back if any of them fail
+	SessionStore struct {
+		db   *sql.DB
+		sqls map[string]string
+		now  func() time.Time
+	}
+
+	// sessionTx runs commands in a transaction until one of them fails, then
+	// hands err back from everything after it
+	sessionTx struct {
+		tx   *sql.Tx
+		sqls map[string]string
+		now  time.Time
+		err  error
+	}
+)
+
+func NewSessionStore(db *sql.DB, sqls config.Sqls) *SessionStore {
+	return &SessionStore{
+		db:   db,
+		sqls: sqls["session-store"],
+		now:  func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (s *SessionStore) Atomic(ctx context.Context, fn func(valid.Cmds)) error {
+	tx := s.begin(ctx)
+	fn(tx)
+	return tx.end()
+}
+
+func (s *SessionStore) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	tx := s.begin(ctx)
+	return one(tx.Del(ctx, keys...), tx)
+}
+
+func (s *SessionStore) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	tx := s.begin(ctx)
+	return one(tx.Exists(ctx, keys...), tx)
+}
+
+func (s *SessionStore) Expire(ctx context.Context, key string, d time.Duration) *redis.BoolCmd {
+	tx := s.begin(ctx)
+	return one(tx.Expire(ctx, key, d), tx)
+}
+
+func (s *SessionStore) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	tx := s.begin(ctx)
+	return one(tx.HDel(ctx, key, fields...), tx)
+}
+
+func (s *SessionStore) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	tx := s.begin(ctx)
+	return one(tx.HGet(ctx, key, field), tx)
+}
+
+func (s *SessionStore) HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd {
+	tx := s.begin(ctx)
+	return one(tx.HGetAll(ctx, key), tx)
+}
+
+func (s *SessionStore) HIncrBy(ctx context.Context, key, field string, n int64) *redis.IntCmd {
+	tx := s.begin(ctx)
+	return one(tx.HIncrBy(ctx, key, field, n), tx)
+}
+
+func (s *SessionStore) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	tx := s.begin(ctx)
+	return one(tx.HMGet(ctx, key, fields...), tx)
+}
+
+func (s *SessionStore) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	tx := s.begin(ctx)
+	return one(tx.HSet(ctx, key, values...), tx)
+}
+
+func (s *SessionStore) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	tx := s.begin(ctx)
+	return one(tx.SAdd(ctx, key, members...), tx)
+}
+
+func (s *SessionStore) SetNX(ctx context.Context, key string, value interface{}, d time.Duration) *redis.BoolCmd {
+	tx := s.begin(ctx)
+	return one(tx.SetNX(ctx, key, value, d), tx)
+}
+
+func (s *SessionStore) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	tx := s.begin(ctx)
+	return one(tx.SMembers(ctx, key), tx)
+}
+
+func (s *SessionStore) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	tx := s.begin(ctx)
+	return one(tx.SRem(ctx, key, members...), tx)
+}
+
+func (s *SessionStore) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	tx := s.begin(ctx)
+	return one(tx.TTL(ctx, key), tx)
+}
+
+func (s *SessionStore) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	tx := s.begin(ctx)
+	return one(tx.Scan(ctx, cursor, match, count), tx)
+}
+
+func (s *SessionStore) begin(ctx context.Context) *sessionTx {
+	tx, err := s.db.BeginTx(ctx, nil)
+	return &sessionTx{tx: tx, sqls: s.sqls, now: s.now(), err: err}
+}
+
+// one finishes the transaction a single command ran in; a commit that fails
+// fails the command
+func one[C redis.Cmder](cmd C, tx *sessionTx) C {
+	if err := tx.end(); err != nil && cmd.Err() == nil {
+		cmd.SetErr(err)
+	}
+	return cmd
+}
+
+func (tx *sessionTx) end() error {
+	if tx.tx == nil {
+		return tx.err
+	} else if tx.err != nil {
+		_ = tx.tx.Rollback()
+		return tx.err
+	}
+	return tx.tx.Commit()
+}
+
+func (tx *sessionTx) fail(err error) {
+	if tx.err == nil {
+		tx.err = err
+	}
+}
+
+func (tx *sessionTx) exec(ctx context.Context, stmt string, args ...any) int64 {
+	if tx.err != nil {
+		return 0
+	}
+
+	result, err := tx.tx.ExecContext(ctx, tx.sqls[stmt], args...)
+	if err != nil {
+		tx.fail(err)
+		return 0
+	}
+
+	n, err := result.RowsAffected()
+	tx.fail(err)
+	return n
+}
+
+// kind locks key and says what it holds, "" for nothing; a key that expired
+// is deleted on the way past
+func (tx *sessionTx) kind(ctx context.Context, key string) (string, sql.NullTime) {
+	var kind string
+	var expires sql.NullTime
+	if tx.err != nil {
+		return "", expires
+	} else if err := tx.tx.
+		QueryRowContext(ctx, tx.sqls["select-key"], key).
+		Scan(&kind, &expires); err == sql.ErrNoRows {
+		return "", expires
+	} else if err != nil {
+		tx.fail(err)
+		return "", expires
+	} else if expires.Valid && !tx.now.Before(expires.Time) {
+		tx.exec(ctx, "delete-key", key)
+		return "", sql.NullTime{}
+	}
+	return kind, expires
+}
+
+// claim makes sure key holds want, creating it if it isn't there
+func (tx *sessionTx) claim(ctx context.Context, key, want string) {
+	if kind, _ := tx.kind(ctx, key); kind == "" {
+		tx.exec(ctx, "insert-key", key, want, nil)
+	} else if kind != want {
+		tx.fail(valid.WrongTypeError)
+	}
+}
+
+// is says whether key holds want; when it holds something else the
+// transaction fails
+func (tx *sessionTx) is(ctx context.Context, key, want string) bool {
+	kind, _ := tx.kind(ctx, key)
+	if kind != "" && kind != want {
+		tx.fail(valid.WrongTypeError)
+	}
+	return kind == want && tx.err == nil
+}
+
+func (tx *sessionTx) fields(ctx context.Context, key string) map[string]string {
+	result := map[string]string{}
+	if tx.err != nil {
+		return result
+	}
+
+	rows, err := tx.tx.QueryContext(ctx, tx.sqls["select-fields"], key)
+	if err != nil {
+		tx.fail(err)
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var field, value string
+		if err = rows.Scan(&field, &value); err != nil {
+			tx.fail(err)
+			return result
+		}
+		result[field] = value
+	}
+	tx.fail(rows.Err())
+
+	return result
+}
+
+// gc drops key once the last of its fields is gone, same as redis
+func (tx *sessionTx) gc(ctx context.Context, key string) {
+	var n int64
+	if tx.err != nil {
+		return
+	} else if err := tx.tx.QueryRowContext(ctx, tx.sqls["count-fields"], key).Scan(&n); err != nil {
+		tx.fail(err)
+	} else if n == 0 {
+		tx.exec(ctx, "delete-key", key)
+	}
+}
+
+func (tx *sessionTx) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	var n int64
+	for _, key := range keys {
+		if kind, _ := tx.kind(ctx, key); kind != "" {
+			n += tx.exec(ctx, "delete-key", key)
+		}
+	}
+	return redis.NewIntResult(n, tx.err)
+}
+
+func (tx *sessionTx) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	var n int64
+	for _, key := range keys {
+		if kind, _ := tx.kind(ctx, key); kind != "" {
+			n++
+		}
+	}
+	return redis.NewIntResult(n, tx.err)
+}
+
+func (tx *sessionTx) Expire(ctx context.Context, key string, d time.Duration) *redis.BoolCmd {
+	if kind, _ := tx.kind(ctx, key); kind == "" {
+		return redis.NewBoolResult(false, tx.err)
+	} else if d <= 0 {
+		tx.exec(ctx, "delete-key", key)
+	} else {
+		tx.exec(ctx, "expire", tx.now.Add(d), key)
+	}
+	return redis.NewBoolResult(tx.err == nil, tx.err)
+}
+
+func (tx *sessionTx) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	var n int64
+	if tx.is(ctx, key, hashKey) {
+		for _, f := range fields {
+			n += tx.exec(ctx, "delete-field", key, f)
+		}
+		tx.gc(ctx, key)
+	}
+	return redis.NewIntResult(n, tx.err)
+}
+
+func (tx *sessionTx) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	var value string
+	if !tx.is(ctx, key, hashKey) {
+		if tx.err != nil {
+			return redis.NewStringResult("", tx.err)
+		}
+		return redis.NewStringResult("", redis.Nil)
+	} else if err := tx.tx.QueryRowContext(ctx, tx.sqls["select-field"], key, field).Scan(&value); err == sql.ErrNoRows {
+		return redis.NewStringResult("", redis.Nil)
+	} else if err != nil {
+		tx.fail(err)
+	}
+	return redis.NewStringResult(value, tx.err)
+}
+
+func (tx *sessionTx) HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd {
+	if !tx.is(ctx, key, hashKey) {
+		return redis.NewMapStringStringResult(map[string]string{}, tx.err)
+	}
+	return redis.NewMapStringStringResult(tx.fields(ctx, key), tx.err)
+}
+
+func (tx *sessionTx) HIncrBy(ctx context.Context, key, field string, n int64) *redis.IntCmd {
+	tx.claim(ctx, key, hashKey) // the key's row stays locked, so increments don't race
+
+	var was int64
+	if v, ok := tx.fields(ctx, key)[field]; ok {
+		var err error
+		if was, err = strconv.ParseInt(v, 10, 64); err != nil {
+			tx.fail(fmt.Errorf("ERR hash value is not an integer"))
+		}
+	}
+	tx.exec(ctx, "upsert-field", key, field, strconv.FormatInt(was+n, 10))
+
+	return redis.NewIntResult(was+n, tx.err)
+}
+
+func (tx *sessionTx) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	result := make([]interface{}, len(fields))
+	if tx.is(ctx, key, hashKey) {
+		have := tx.fields(ctx, key)
+		for i, f := range fields {
+			if v, ok := have[f]; ok {
+				result[i] = v
+			}
+		}
+	}
+	return redis.NewSliceResult(result, tx.err)
+}
+
+func (tx *sessionTx) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	pairs, err := valid.FieldValues(values)
+	if err != nil {
+		tx.fail(err)
+		return redis.NewIntResult(0, tx.err)
+	}
+
+	tx.claim(ctx, key, hashKey)
+
+	var n int64
+	for i := 0; i < len(pairs); i += 2 {
+		if tx.exec(ctx, "upsert-field", key, pairs[i], pairs[i+1]) == 1 { // 2 is an update
+			n++
+		}
+	}
+	return redis.NewIntResult(n, tx.err)
+}
+
+func (tx *sessionTx) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	tx.claim(ctx, key, setKey)
+
+	var n int64
+	for _, m := range members {
+		n += tx.exec(ctx, "insert-member", key, fmt.Sprint(m))
+	}
+	return redis.NewIntResult(n, tx.err)
+}
+
+func (tx *sessionTx) SetNX(ctx context.Context, key string, value interface{}, d time.Duration) *redis.BoolCmd {
+	if kind, _ := tx.kind(ctx, key); kind != "" || tx.err != nil {
+		return redis.NewBoolResult(false, tx.err)
+	}
+
+	var expires interface{}
+	if d > 0 {
+		expires = tx.now.Add(d)
+	}
+	tx.exec(ctx, "insert-key", key, stringKey, expires)
+	tx.exec(ctx, "upsert-field", key, "", fmt.Sprint(value))
+
+	return redis.NewBoolResult(tx.err == nil, tx.err)
+}
+
+func (tx *sessionTx) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	result := []string{}
+	if tx.is(ctx, key, setKey) {
+		for m := range tx.fields(ctx, key) {
+			result = append(result, m)
+		}
+		sort.Strings(result)
+	}
+	return redis.NewStringSliceResult(result, tx.err)
+}
+
+func (tx *sessionTx) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	var n int64
+	if tx.is(ctx, key, setKey) {
+		for _, m := range members {
+			n += tx.exec(ctx, "delete-field", key, fmt.Sprint(m))
+		}
+		tx.gc(ctx, key)
+	}
+	return redis.NewIntResult(n, tx.err)
+}
+
+// TTL follows redis: -2 for no key, -1 for a key that never expires
+func (tx *sessionTx) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	if kind, expires := tx.kind(ctx, key); kind == "" {
+		return redis.NewDurationResult(-2, tx.err)
+	} else if !expires.Valid {
+		return redis.NewDurationResult(-1, tx.err)
+	} else {
+		return redis.NewDurationResult(expires.Time.Sub(tx.now).Round(time.Second), tx.err)
+	}
+}
+
+// Scan does it all in one go, so the cursor always comes back 0; it clears
+// out expired keys that match first, since nothing else would
+func (tx *sessionTx) Scan(ctx context.Context, _ uint64, match string, _ int64) *redis.ScanCmd {
+	result := []string{}
+	like := likePattern(match)
+	tx.exec(ctx, "prune", like, tx.now)
+	if tx.err != nil {
+		return redis.NewScanCmdResult(result, 0, tx.err)
+	}
+
+	rows, err := tx.tx.QueryContext(ctx, tx.sqls["select-keys"], like)
+	if err != nil {
+		tx.fail(err)
+		return redis.NewScanCmdResult(result, 0, tx.err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			tx.fail(err)
+			break
+		}
+		result = append(result, key)
+	}
+	tx.fail(rows.Err())
+
+	return redis.NewScanCmdResult(result, 0, tx.err)
+}
+
+// likePattern turns the glob Scan takes into something for like
+func likePattern(glob string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`%`, `\%`,
+		`_`, `\_`,
+		`*`, `%`,
+		`?`, `_`,
+	).Replace(glob)
+}
@@ -0,0 +1,366 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	valid "github.com/jsmit257/userservice/internal/validation"
+)
+
+var sessionNow = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func mockSessionStore(db *sql.DB) *SessionStore {
+	return &SessionStore{
+		db:   db,
+		sqls: map[string]string{},
+		now:  func() time.Time { return sessionNow },
+	}
+}
+
+func Test_SessionStoreHGet(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result string
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(hashKey, sessionNow.Add(time.Minute)))
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"value"}).
+					AddRow("value"))
+				mock.ExpectCommit()
+				return db
+			},
+			result: "value",
+		},
+		"missing_key": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnError(sql.ErrNoRows)
+				mock.ExpectCommit()
+				return db
+			},
+			err: redis.Nil,
+		},
+		"missing_field": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(hashKey, nil))
+				mock.ExpectQuery("").WillReturnError(sql.ErrNoRows)
+				mock.ExpectCommit()
+				return db
+			},
+			err: redis.Nil,
+		},
+		"expired_key": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(hashKey, sessionNow))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			err: redis.Nil,
+		},
+		"wrong_type": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(setKey, nil))
+				mock.ExpectRollback()
+				return db
+			},
+			err: valid.WrongTypeError,
+		},
+		"begin_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"query_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+		"commit_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(hashKey, nil))
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"value"}).
+					AddRow("value"))
+				mock.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			result: "value",
+			err:    fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			result, err := mockSessionStore(tc.db(sqlmock.New())).
+				HGet(context.Background(), "token:1", "userid").
+				Result()
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_SessionStoreHSet(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		db     getMockDB
+		values []interface{}
+		result int64
+		err    error
+	}{
+		"new_key": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec("").
+					WithArgs("token:1", hashKey, nil).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs("token:1", "a", "1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			values: []interface{}{"a", 1},
+			result: 1,
+		},
+		"existing_key": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(hashKey, nil))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 2)) // updated
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1)) // added
+				mock.ExpectCommit()
+				return db
+			},
+			values: []interface{}{"a", 1, "b", 2},
+			result: 1,
+		},
+		"wrong_type": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(stringKey, nil))
+				mock.ExpectRollback()
+				return db
+			},
+			values: []interface{}{"a", 1},
+			err:    valid.WrongTypeError,
+		},
+		"bad_args": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+				return db
+			},
+			values: []interface{}{"a"},
+			err:    fmt.Errorf("ERR wrong number of arguments for 'hset' command"),
+		},
+		"upsert_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(hashKey, nil))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			values: []interface{}{"a", 1, "b", 2},
+			err:    fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			result, err := mockSessionStore(tc.db(sqlmock.New())).
+				HSet(context.Background(), "token:1", tc.values...).
+				Result()
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_SessionStoreTTL(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		db     getMockDB
+		result time.Duration
+	}{
+		"expires": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(hashKey, sessionNow.Add(time.Minute)))
+				mock.ExpectCommit()
+				return db
+			},
+			result: time.Minute,
+		},
+		"never_expires": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(setKey, nil))
+				mock.ExpectCommit()
+				return db
+			},
+			result: -1,
+		},
+		"missing": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnError(sql.ErrNoRows)
+				mock.ExpectCommit()
+				return db
+			},
+			result: -2,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			result, err := mockSessionStore(tc.db(sqlmock.New())).
+				TTL(context.Background(), "token:1").
+				Result()
+			require.Nil(t, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func Test_SessionStoreScan(t *testing.T) {
+	t.Parallel()
+
+	db, mock, _ := sqlmock.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("").
+		WithArgs(`token\_%`, sessionNow).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery("").
+		WithArgs(`token\_%`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("token_1").AddRow("token_2"))
+	mock.ExpectCommit()
+
+	keys, cursor, err := mockSessionStore(db).Scan(context.Background(), 0, "token_*", 100).Result()
+	require.Nil(t, err)
+	require.Equal(t, []string{"token_1", "token_2"}, keys)
+	require.Equal(t, uint64(0), cursor)
+	require.Nil(t, mock.ExpectationsWereMet())
+}
+
+func Test_SessionStoreAtomic(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		db  getMockDB
+		err error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnError(sql.ErrNoRows)           // SAdd: select-key
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1)) // SAdd: insert-key
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1)) // SAdd: insert-member
+				// Expire: select-key
+				mock.ExpectQuery("").WillReturnRows(sqlmock.
+					NewRows([]string{"kind", "expires"}).
+					AddRow(setKey, nil))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1)) // Expire: expire
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		"rolls_back": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectQuery("").WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback() // and nothing for Expire
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			err := mockSessionStore(tc.db(sqlmock.New())).Atomic(ctx, func(tx valid.Cmds) {
+				tx.SAdd(ctx, "logins:1", "token:1")
+				tx.Expire(ctx, "logins:1", time.Minute)
+			})
+			require.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func Test_likePattern(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]string{
+		"token:*":    "token:%",
+		"a?c":        "a_c",
+		"100%_done*": `100\%\_done%`,
+		`back\slash`: `back\\slash`,
+	}
+
+	for glob, like := range tcs {
+		glob, like := glob, like
+		t.Run(glob, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, like, likePattern(glob))
+		})
+	}
+}
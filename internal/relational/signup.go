@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// SignUp is AddUser with a real password; the user and their credential go
+// in together or not at all
+func (db *Conn) SignUp(ctx context.Context, u *shared.User, pass shared.Password) (shared.UUID, error) {
+	done, log := db.logging("SignUp", u, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", done(err, log)
+	}
+	defer tx.Rollback() // nothing to undo once it's committed
+
+	if err = db.signUp(ctx, tx, u, pass); err == nil {
+		err = tx.Commit()
+	}
+
+	return u.UUID, done(err, log)
+}
+
+// signUp does the work for SignUp inside someone else's transaction
+func (db *Conn) signUp(ctx context.Context, tx *sql.Tx, u *shared.User, pass shared.Password) error {
+	if !u.Email.Valid() && !u.Cell.Valid() {
+		return shared.Undeliverable
+	} else if v := db.policy.Check(pass, u.Name, u.Email.LocalPart()); len(v) != 0 {
+		return v
+	}
+
+	hash, err := db.hasher.Hash(pass)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	u.UUID = db.uuidgen()
+	u.MTime = now
+	u.CTime = now
+
+	result, err := tx.ExecContext(ctx, db.sqls["user"]["insert"],
+		u.UUID,
+		u.Name,
+		u.Email,
+		u.Cell,
+		hash,
+		"", // the salt lives in the encoded hash
+		now,
+		now)
+	if v, ok := err.(*mysql.MySQLError); ok && strings.Contains(v.Message, "users.name") {
+		return shared.UserExistsError
+	} else if err != nil {
+		return err
+	} else if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows != 1 {
+		return shared.UserNotAddedError
+	} else if db.policy.History == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, db.sqls["password-history"]["insert"], u.UUID, hash, "", now)
+
+	return err
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_SignUp(t *testing.T) {
+	t.Parallel()
+
+	l := testLogger(t, log.Fields{"app": "signup_test.go", "test": "Test_SignUp"})
+
+	addr := shared.Email("someone@example.com")
+	historic := &shared.PasswordPolicy{MinLength: 8, History: 3}
+
+	tcs := map[string]struct {
+		db     getMockDB
+		policy *shared.PasswordPolicy
+		user   *shared.User
+		pass   shared.Password
+		result shared.UUID
+		err    error
+	}{
+		"happy_path": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), "username", "someone@example.com", nil, sqlmock.AnyArg(), "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			user:   &shared.User{Name: "username", Email: &addr},
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+		},
+		"keeps_history": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").
+					WithArgs(mockUUIDGen(), sqlmock.AnyArg(), "", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			policy: historic,
+			user:   &shared.User{Name: "username", Email: &addr},
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+		},
+		"undeliverable": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+				return db
+			},
+			user: &shared.User{Name: "username"},
+			pass: "correct horse",
+			err:  shared.Undeliverable,
+		},
+		"policy_violation": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+				return db
+			},
+			user: &shared.User{Name: "username", Email: &addr},
+			pass: "short",
+			err:  testpolicy.Check("short"),
+		},
+		"begin_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			user: &shared.User{Name: "username", Email: &addr},
+			pass: "correct horse",
+			err:  fmt.Errorf("some error"),
+		},
+		"user_exists": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry for key 'users.name'"})
+				mock.ExpectRollback()
+				return db
+			},
+			user:   &shared.User{Name: "username", Email: &addr},
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			err:    shared.UserExistsError,
+		},
+		"insert_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			user:   &shared.User{Name: "username", Email: &addr},
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			err:    fmt.Errorf("some error"),
+		},
+		"not_added": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				return db
+			},
+			user:   &shared.User{Name: "username", Email: &addr},
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			err:    shared.UserNotAddedError,
+		},
+		"history_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+				return db
+			},
+			policy: historic,
+			user:   &shared.User{Name: "username", Email: &addr},
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			err:    fmt.Errorf("some error"),
+		},
+		"commit_fails": {
+			db: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectBegin()
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			user:   &shared.User{Name: "username", Email: &addr},
+			pass:   "correct horse",
+			result: mockUUIDGen(),
+			err:    fmt.Errorf("some error"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := testpolicy
+			if tc.policy != nil {
+				policy = tc.policy
+			}
+
+			db, mock, err := sqlmock.New()
+			result, err := (&Conn{
+				tc.db(db, mock, err),
+				mockUUIDGen,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				policy,
+				testlockout,
+			}).SignUp(mockContext(shared.CID("Test_SignUp-"+name)), tc.user, tc.pass)
+
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+			require.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
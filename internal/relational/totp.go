@@ -0,0 +1,93 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// GetTOTP fails with MFANotEnrolledError when the user never started an
+// enrollment; an unconfirmed one comes back with a nil Confirmed
+func (db *Conn) GetTOTP(ctx context.Context, uid shared.UUID) (*shared.TOTP, error) {
+	done, log := db.logging("GetTOTP", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result := &shared.TOTP{}
+	err := db.
+		QueryRowContext(ctx, db.sqls["totp"]["select"], uid).
+		Scan(
+			&result.Secret,
+			&result.Confirmed,
+			&result.LastStep,
+			&result.MTime,
+			&result.CTime)
+
+	if err == sql.ErrNoRows {
+		return nil, done(shared.MFANotEnrolledError, log)
+	} else if err != nil {
+		return nil, done(err, log)
+	}
+
+	return result, done(err, log)
+}
+
+// EnrollTOTP starts a new enrollment, replacing any existing one; the old
+// secret keeps working for nothing once this succeeds, so callers should
+// make sure the user is allowed to throw it away
+func (db *Conn) EnrollTOTP(ctx context.Context, uid shared.UUID, secret string) error {
+	done, log := db.logging("EnrollTOTP", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, db.sqls["totp"]["insert"], uid, secret, now, now)
+
+	return done(err, log)
+}
+
+func (db *Conn) ConfirmTOTP(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("ConfirmTOTP", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, db.sqls["totp"]["update"], now, now, uid)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.MFANotEnrolledError
+		}
+	}
+
+	return done(err, log)
+}
+
+// UseTOTP spends every period up to step; it's BadMFACodeError if step, or
+// one after it, was already spent, which is how two requests racing with the
+// same code get told apart
+func (db *Conn) UseTOTP(ctx context.Context, uid shared.UUID, step int64) error {
+	done, log := db.logging("UseTOTP", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["totp"]["use"], step, uid, step)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.BadMFACodeError
+		}
+	}
+
+	return done(err, log)
+}
+
+func (db *Conn) DeleteTOTP(ctx context.Context, uid shared.UUID) error {
+	done, log := db.logging("DeleteTOTP", uid, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["totp"]["delete"], uid)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			err = shared.MFANotEnrolledError
+		} else if err == nil {
+			err = db.pruneRecoveryCodes(ctx, uid)
+		}
+	}
+
+	return done(err, log)
+}
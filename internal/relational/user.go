@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/go-sql-driver/mysql"
+
+	"github.com/jsmit257/userservice/internal/passwd"
 	"github.com/jsmit257/userservice/shared/v1"
 )
 
@@ -46,6 +48,8 @@ func (db *Conn) GetUser(ctx context.Context, id shared.UUID) (*shared.User, erro
 			&result.Name,
 			&result.Email,
 			&result.Cell,
+			&result.EmailVerified,
+			&result.CellVerified,
 			&result.MTime,
 			&result.CTime,
 			&result.DTime)
@@ -77,8 +81,8 @@ func (db *Conn) AddUser(ctx context.Context, u *shared.User) (shared.UUID, error
 		u.Name,
 		u.Email,
 		u.Cell,
-		"", //hash("password", salt),
-		"", //salt,
+		passwd.Unusable, // until somebody resets it
+		"",
 		now,
 		now)
 	if err != nil {
@@ -102,8 +106,10 @@ func (db *Conn) UpdateUser(ctx context.Context, u *shared.User) error {
 	u.MTime = time.Now().UTC()
 	result, err := db.ExecContext(ctx, db.sqls["user"]["update"],
 		u.Name,
+		u.Email, // unchanged addresses stay verified
 		u.Email,
 		u.Cell,
+		u.Cell,
 		u.MTime,
 		u.UUID)
 
@@ -117,6 +123,37 @@ func (db *Conn) UpdateUser(ctx context.Context, u *shared.User) error {
 	return done(err, log)
 }
 
+// VerifyEmail only marks e verified if it's still the user's address; a
+// change since the link went out means UserNotUpdatedError
+func (db *Conn) VerifyEmail(ctx context.Context, id shared.UUID, e shared.Email) error {
+	done, log := db.logging("VerifyEmail", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["user"]["verify-email"], time.Now().UTC(), id, e)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			return shared.UserNotUpdatedError
+		}
+	}
+
+	return done(err, log)
+}
+
+// VerifyCell is VerifyEmail for the cell number
+func (db *Conn) VerifyCell(ctx context.Context, id shared.UUID, c shared.Cell) error {
+	done, log := db.logging("VerifyCell", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
+
+	result, err := db.ExecContext(ctx, db.sqls["user"]["verify-cell"], time.Now().UTC(), id, c)
+	if err == nil {
+		var rows int64
+		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
+			return shared.UserNotUpdatedError
+		}
+	}
+
+	return done(err, log)
+}
+
 func (db *Conn) DeleteUser(ctx context.Context, id shared.UUID) error {
 	done, log := db.logging("DeleteUser", id, ctx.Value(shared.CTXKey("cid")).(shared.CID))
 
@@ -125,6 +162,8 @@ func (db *Conn) DeleteUser(ctx context.Context, id shared.UUID) error {
 		var rows int64
 		if rows, err = result.RowsAffected(); err == nil && rows != 1 {
 			return shared.UserNotDeletedError
+		} else if err == nil {
+			err = db.deleteHistory(ctx, id)
 		}
 	}
 
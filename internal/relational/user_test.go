@@ -17,18 +17,21 @@ import (
 
 var (
 	_user = shared.User{
-		UUID:  "uuid",
-		Name:  "username",
-		Email: func(s shared.Email) *shared.Email { return &s }("example@example.com"),
-		MTime: rightaboutnow,
-		CTime: rightaboutnow,
+		UUID:          "uuid",
+		Name:          "username",
+		Email:         func(s shared.Email) *shared.Email { return &s }("example@example.com"),
+		EmailVerified: &rightaboutnow,
+		MTime:         rightaboutnow,
+		CTime:         rightaboutnow,
 	}
-	userFields = row{"uuid", "name", "email", "cell", "mtime", "ctime", "dtime"}
+	userFields = row{"uuid", "name", "email", "cell", "email_verified", "cell_verified", "mtime", "ctime", "dtime"}
 	userValues = values{
 		_user.UUID,
 		_user.Name,
 		_user.Email,
 		_user.Cell,
+		_user.EmailVerified,
+		_user.CellVerified,
 		_user.MTime,
 		_user.CTime,
 		_user.DTime,
@@ -40,8 +43,8 @@ func TestGetAllUsers(t *testing.T) {
 
 	l := testLogger(t, log.Fields{"app": "user_test.go", "test": "TestGetAllUsers"})
 
-	fields := append(append(make(row, 0, len(userFields)-2), userFields[:2]...), userFields[4:]...)
-	values := append(append(make(values, 0, len(userValues)-2), userValues[:2]...), userValues[4:]...)
+	fields := append(append(make(row, 0, len(userFields)-4), userFields[:2]...), userFields[6:]...)
+	values := append(append(make(values, 0, len(userValues)-4), userValues[:2]...), userValues[6:]...)
 
 	tcs := map[string]struct {
 		mockDB getMockDB
@@ -60,6 +63,7 @@ func TestGetAllUsers(t *testing.T) {
 			result: func(u shared.User) []shared.User {
 				u.Email = nil
 				u.Cell = nil
+				u.EmailVerified = nil
 				return []shared.User{u, u}
 			}(_user),
 		},
@@ -83,6 +87,9 @@ func TestGetAllUsers(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).GetAllUsers(mockContext(cid))
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.result, result)
@@ -166,6 +173,9 @@ func TestGetUser(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).GetUser(mockContext(shared.CID("TestGetUser-"+name)), "1")
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.result, user)
@@ -288,6 +298,9 @@ func TestAddUser(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).AddUser(mockContext(shared.CID("TestAddUser-"+name)), tc.user)
 			require.Equal(t, tc.err, err)
 			require.Equal(t, tc.result, result)
@@ -305,7 +318,9 @@ func TestUpdateUser(t *testing.T) {
 	}{
 		"happy_path": {
 			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
-				mock.ExpectExec(".*").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(".*").
+					WithArgs("new username", nil, nil, nil, nil, sqlmock.AnyArg(), "1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				return db
 			},
 			user: &shared.User{UUID: "1", Name: "new username", MTime: rightaboutnow},
@@ -337,11 +352,110 @@ func TestUpdateUser(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).UpdateUser(mockContext(shared.CID("TestUpdateUser-"+name)), tc.user))
 		})
 	}
 }
 
+func TestVerifyEmail(t *testing.T) {
+	t.Parallel()
+	l := testLogger(t, log.Fields{"app": "user_test.go", "test": "TestVerifyEmail"})
+	tcs := map[string]struct {
+		mockDB getMockDB
+		err    error
+	}{
+		"happy_path": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), "1", "example@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"address_changed": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.UserNotUpdatedError,
+		},
+		"exec_fails": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.err, (&Conn{
+				tc.mockDB(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).VerifyEmail(mockContext(shared.CID("TestVerifyEmail-"+name)), "1", "example@example.com"))
+		})
+	}
+}
+
+func TestVerifyCell(t *testing.T) {
+	t.Parallel()
+	l := testLogger(t, log.Fields{"app": "user_test.go", "test": "TestVerifyCell"})
+	tcs := map[string]struct {
+		mockDB getMockDB
+		err    error
+	}{
+		"happy_path": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").
+					WithArgs(sqlmock.AnyArg(), "1", "cell").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		"address_changed": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			err: shared.UserNotUpdatedError,
+		},
+		"exec_fails": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
+				return db
+			},
+			err: fmt.Errorf("some error"),
+		},
+	}
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.err, (&Conn{
+				tc.mockDB(sqlmock.New()),
+				nil,
+				mockSqls(),
+				l,
+				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
+			}).VerifyCell(mockContext(shared.CID("TestVerifyCell-"+name)), "1", "cell"))
+		})
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	t.Parallel()
 	l := testLogger(t, log.Fields{"app": "user_test.go", "test": "TestDeleteUser"})
@@ -352,8 +466,17 @@ func TestDeleteUser(t *testing.T) {
 		"happy_path": {
 			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
 				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 3))
+				return db
+			},
+		},
+		"history_fails": {
+			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
+				mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("").WillReturnError(fmt.Errorf("some error"))
 				return db
 			},
+			err: fmt.Errorf("some error"),
 		},
 		"exec_fails": {
 			mockDB: func(db *sql.DB, mock sqlmock.Sqlmock, err error) *sql.DB {
@@ -381,6 +504,9 @@ func TestDeleteUser(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).DeleteUser(mockContext(shared.CID("TestDeleteUser-"+name)), "1"))
 		})
 	}
@@ -428,6 +554,9 @@ func TestCreateContact(t *testing.T) {
 				mockSqls(),
 				l,
 				testmetrics.MustCurryWith(prometheus.Labels{"db": "test db"}),
+				testhasher,
+				testpolicy,
+				testlockout,
 			}).CreateContact(mockContext(shared.CID("TestCreateContact-"+name)), &tc.user, tc.contact)
 
 			if result != nil {
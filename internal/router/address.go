@@ -83,10 +83,19 @@ func (us *UserService) PatchAddress(w http.ResponseWriter, r *http.Request) {
 	} else if err = json.Unmarshal(body, &address); err != nil {
 		sc(http.StatusBadRequest).send(ctx, w, err)
 		_, _ = w.Write([]byte(fmt.Sprintf("couldn't unmarshal: '%s'", html.EscapeString(string(body)))))
-	} else if err = us.Addresser.UpdateAddress(ctx, &address); err != nil {
+	} else if err = us.Addresser.UpdateAddress(ctx, urlAddress(r, &address)); err != nil {
 		sc(http.StatusInternalServerError).send(ctx, w, err)
 		_, _ = w.Write([]byte(err.Error()))
 	} else {
 		sc(http.StatusNoContent).success(ctx, w)
 	}
 }
+
+// urlAddress makes the id in the url win over one in the body, since the url
+// is what the ownership check looked at
+func urlAddress(r *http.Request, a *shared.Address) *shared.Address {
+	if id := chi.URLParam(r, "address_id"); id != "" {
+		a.UUID = shared.UUID(id)
+	}
+	return a
+}
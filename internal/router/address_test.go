@@ -24,6 +24,8 @@ type mockAddresser struct {
 	addResp shared.UUID
 	addErr  error
 	updErr  error
+	owns    bool
+	ownsErr error
 }
 
 func Test_GetAllGetAllAddresses(t *testing.T) {
@@ -299,3 +301,6 @@ func (ma *mockAddresser) AddAddress(context.Context, *shared.Address) (shared.UU
 func (ma *mockAddresser) UpdateAddress(context.Context, *shared.Address) error {
 	return ma.updErr
 }
+func (ma *mockAddresser) OwnsAddress(context.Context, shared.UUID, shared.UUID) (bool, error) {
+	return ma.owns, ma.ownsErr
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func (us UserService) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if keys, err := us.APIKeyer.GetAPIKeys(ctx, id); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(keys))
+	}
+}
+
+// PostAPIKey only wants a name, and optionally scopes and an expiry; the
+// response is the only place the token ever shows up
+func (us UserService) PostAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	key := &shared.APIKey{}
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if body, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(body, key); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if err = checkAPIKey(key); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else if _, err = us.APIKeyer.AddAPIKey(ctx, id, key); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusCreated).success(ctx, w, mustJSON(key))
+	}
+}
+
+func (us UserService) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if kid := chi.URLParam(r, "key_id"); kid == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing key_id")
+	} else if err := us.APIKeyer.DeleteAPIKey(ctx, id, kid); errors.Is(err, shared.APIKeyNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// checkAPIKey throws away anything the client isn't allowed to choose
+func checkAPIKey(k *shared.APIKey) error {
+	k.ID, k.UserID, k.Secret, k.LastUsed = "", "", "", nil
+
+	if k.Name == "" {
+		return fmt.Errorf("name is required")
+	} else if k.Expires != nil && !k.Expires.After(time.Now()) {
+		return fmt.Errorf("expires is in the past")
+	}
+	for _, s := range k.Scopes {
+		if s == "" || strings.ContainsAny(s, " \t\r\n") { // they're stored space separated
+			return fmt.Errorf("bad scope: %q", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type mockAPIKeyer struct {
+	keys      []shared.APIKey
+	keysErr   error
+	addErr    error
+	check     *shared.APIKey
+	checkErr  error
+	deleteErr error
+}
+
+func Test_GetAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		k  *mockAPIKeyer
+		id shared.UUID
+		sc int
+	}{
+		"happy_path": {
+			k:  &mockAPIKeyer{keys: []shared.APIKey{{ID: "key", Name: "ci"}}},
+			id: "uid",
+			sc: http.StatusOK,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"lookup_fails": {
+			k:  &mockAPIKeyer{keysErr: fmt.Errorf("some error")},
+			id: "uid",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{APIKeyer: tc.k}
+
+			w := httptest.NewRecorder()
+			us.GetAPIKeys(w, totpRequest(http.MethodGet, tc.id, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_PostAPIKey(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		k    *mockAPIKeyer
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			k:    &mockAPIKeyer{},
+			id:   "uid",
+			body: fmt.Sprintf(`{"name":"ci","scopes":["read"],"expires":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339)),
+			sc:   http.StatusCreated,
+		},
+		"client_picks_token": {
+			k:    &mockAPIKeyer{},
+			id:   "uid",
+			body: `{"name":"ci","token":"usk_mine.mine","id":"mine"}`,
+			sc:   http.StatusCreated,
+		},
+		"missing_id": {
+			body: `{"name":"ci"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_body": {
+			id:   "uid",
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_name": {
+			id:   "uid",
+			body: `{"scopes":["read"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"expired": {
+			id:   "uid",
+			body: `{"name":"ci","expires":"2001-01-01T00:00:00Z"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_scope": {
+			id:   "uid",
+			body: `{"name":"ci","scopes":["read write"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"empty_scope": {
+			id:   "uid",
+			body: `{"name":"ci","scopes":[""]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"add_fails": {
+			k:    &mockAPIKeyer{addErr: fmt.Errorf("some error")},
+			id:   "uid",
+			body: `{"name":"ci"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{APIKeyer: tc.k}
+
+			w := httptest.NewRecorder()
+			us.PostAPIKey(w, totpRequest(http.MethodPost, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			if tc.sc == http.StatusCreated {
+				key := shared.APIKey{}
+				require.Nil(t, json.Unmarshal(w.Body.Bytes(), &key))
+				require.Equal(t, "key", key.ID)
+				require.Equal(t, tc.id, key.UserID)
+				require.Equal(t, "usk_key.secret", key.Secret)
+			}
+		})
+	}
+}
+
+func Test_DeleteAPIKey(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		k   *mockAPIKeyer
+		id  shared.UUID
+		kid string
+		sc  int
+	}{
+		"happy_path": {
+			k:   &mockAPIKeyer{},
+			id:  "uid",
+			kid: "key",
+			sc:  http.StatusNoContent,
+		},
+		"missing_id": {
+			kid: "key",
+			sc:  http.StatusBadRequest,
+		},
+		"missing_key_id": {
+			id: "uid",
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			k:   &mockAPIKeyer{deleteErr: shared.APIKeyNotFoundError},
+			id:  "uid",
+			kid: "key",
+			sc:  http.StatusNotFound,
+		},
+		"delete_fails": {
+			k:   &mockAPIKeyer{deleteErr: fmt.Errorf("some error")},
+			id:  "uid",
+			kid: "key",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{APIKeyer: tc.k}
+
+			w := httptest.NewRecorder()
+			us.DeleteAPIKey(w, keyRequest(http.MethodDelete, tc.id, tc.kid))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func keyRequest(method string, id shared.UUID, kid string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams = chi.RouteParams{Keys: []string{"user_id", "key_id"}, Values: []string{string(id), kid}}
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(
+			mockContext(),
+			chi.RouteCtxKey,
+			rctx),
+		method,
+		"tc.url",
+		io.Reader(bytes.NewReader(nil)),
+	)
+	return r
+}
+
+func (mk *mockAPIKeyer) GetAPIKeys(context.Context, shared.UUID) ([]shared.APIKey, error) {
+	return mk.keys, mk.keysErr
+}
+
+func (mk *mockAPIKeyer) AddAPIKey(_ context.Context, uid shared.UUID, k *shared.APIKey) (string, error) {
+	if mk.addErr != nil {
+		return "", mk.addErr
+	}
+	k.ID, k.UserID, k.Secret = "key", uid, "usk_key.secret"
+	return k.ID, nil
+}
+
+func (mk *mockAPIKeyer) CheckAPIKey(context.Context, string) (*shared.APIKey, error) {
+	return mk.check, mk.checkErr
+}
+
+func (mk *mockAPIKeyer) DeleteAPIKey(context.Context, shared.UUID, string) error {
+	return mk.deleteErr
+}
@@ -0,0 +1,124 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// authenticate turns away any request without a live session; everything
+// downstream can find out who's calling with caller(ctx)
+func (us UserService) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		token, err := r.Cookie("us-authn")
+		if err != nil {
+			sc(http.StatusUnauthorized).send(ctx, w, shared.MissingAuthToken)
+			return
+		}
+
+		cookie, code := us.Validator.Valid(ctx, token.Value, us.client(r))
+		http.SetCookie(w, cookie)
+		if code == http.StatusInternalServerError {
+			sc(code).send(ctx, w, shared.RedisTokenFail)
+			return
+		} else if code != http.StatusNoContent {
+			sc(http.StatusUnauthorized).send(ctx, w, shared.MissingAuthToken)
+			return
+		}
+
+		uid, code := us.Validator.Session(ctx, token.Value)
+		if code == http.StatusInternalServerError {
+			sc(code).send(ctx, w, shared.RedisTokenFail)
+			return
+		} else if code != http.StatusOK {
+			sc(http.StatusUnauthorized).send(ctx, w, shared.MissingAuthToken)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, shared.CTXKey("uid"), uid)))
+	})
+}
+
+// adminOnly is for routes that aren't about any one user, like listing all
+// of them
+func (us UserService) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if ok, err := us.isAdmin(ctx); err != nil {
+			sc(http.StatusInternalServerError).send(ctx, w, err)
+		} else if !ok {
+			sc(http.StatusForbidden).send(ctx, w, shared.PermissionDeniedError)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// selfOrAdmin only lets callers at their own {user_id}, unless they're an
+// admin
+func (us UserService) selfOrAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if uid := caller(ctx); uid != "" && uid == shared.UUID(chi.URLParam(r, "user_id")) {
+			next.ServeHTTP(w, r)
+		} else if ok, err := us.isAdmin(ctx); err != nil {
+			sc(http.StatusInternalServerError).send(ctx, w, err)
+		} else if !ok {
+			sc(http.StatusForbidden).send(ctx, w, shared.PermissionDeniedError)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// addressOwner is selfOrAdmin for {address_id}; an address belongs to
+// whoever's contact points at it
+func (us UserService) addressOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if ok, err := us.Addresser.OwnsAddress(ctx, caller(ctx), shared.UUID(chi.URLParam(r, "address_id"))); err != nil {
+			sc(http.StatusInternalServerError).send(ctx, w, err)
+		} else if ok {
+			next.ServeHTTP(w, r)
+		} else if ok, err = us.isAdmin(ctx); err != nil {
+			sc(http.StatusInternalServerError).send(ctx, w, err)
+		} else if !ok {
+			sc(http.StatusForbidden).send(ctx, w, shared.PermissionDeniedError)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// resetOrSelf lets a password reset through on the strength of its one-time
+// pad, which PatchLogin checks against {user_id} before anything else;
+// without one it's the same as authenticate plus selfOrAdmin
+func (us UserService) resetOrSelf(next http.Handler) http.Handler {
+	session := us.authenticate(us.selfOrAdmin(next))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("authn-pad"); err == nil {
+			next.ServeHTTP(w, r)
+		} else {
+			session.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (us UserService) isAdmin(ctx context.Context) (bool, error) {
+	return us.Authorizer.HasPermission(ctx, caller(ctx), us.adminPermission)
+}
+
+// caller is whoever authenticate let through; it's empty on routes that
+// don't authenticate
+func caller(ctx context.Context) shared.UUID {
+	uid, _ := ctx.Value(shared.CTXKey("uid")).(shared.UUID)
+	return uid
+}
@@ -0,0 +1,270 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// authnNext stands in for the real handler; it only answers if it was reached
+var authnNext = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Caller", string(caller(r.Context())))
+	w.WriteHeader(http.StatusTeapot)
+})
+
+func Test_authenticate(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		v      *mockValidator
+		cookie *http.Cookie
+		uid    shared.UUID
+		sc     int
+	}{
+		"happy_path": {
+			v:      &mockValidator{validsc: http.StatusNoContent, session: "1", sessionsc: http.StatusOK},
+			cookie: &http.Cookie{Name: "us-authn", Value: "token"},
+			uid:    "1",
+			sc:     http.StatusTeapot,
+		},
+		"missing_cookie": {
+			v:  &mockValidator{},
+			sc: http.StatusUnauthorized,
+		},
+		"invalid_session": {
+			v:      &mockValidator{validsc: http.StatusForbidden},
+			cookie: &http.Cookie{Name: "us-authn", Value: "token"},
+			sc:     http.StatusUnauthorized,
+		},
+		"valid_fails": {
+			v:      &mockValidator{validsc: http.StatusInternalServerError},
+			cookie: &http.Cookie{Name: "us-authn", Value: "token"},
+			sc:     http.StatusInternalServerError,
+		},
+		"session_gone": {
+			v:      &mockValidator{validsc: http.StatusNoContent, sessionsc: http.StatusBadRequest},
+			cookie: &http.Cookie{Name: "us-authn", Value: "token"},
+			sc:     http.StatusUnauthorized,
+		},
+		"session_fails": {
+			v:      &mockValidator{validsc: http.StatusNoContent, sessionsc: http.StatusInternalServerError},
+			cookie: &http.Cookie{Name: "us-authn", Value: "token"},
+			sc:     http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Validator: tc.v}
+
+			r := roleRequest(http.MethodGet, nil, "")
+			if tc.cookie != nil {
+				r.AddCookie(tc.cookie)
+			}
+
+			w := httptest.NewRecorder()
+			us.authenticate(authnNext).ServeHTTP(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, string(tc.uid), w.Header().Get("X-Caller"))
+		})
+	}
+}
+
+func Test_adminOnly(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a  *mockAuthorizer
+		sc int
+	}{
+		"happy_path": {
+			a:  &mockAuthorizer{has: true},
+			sc: http.StatusTeapot,
+		},
+		"not_admin": {
+			a:  &mockAuthorizer{},
+			sc: http.StatusForbidden,
+		},
+		"lookup_fails": {
+			a:  &mockAuthorizer{hasErr: fmt.Errorf("some error")},
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.adminOnly(authnNext).ServeHTTP(w, callerRequest("1", nil))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_selfOrAdmin(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a   *mockAuthorizer
+		uid shared.UUID
+		sc  int
+	}{
+		"self": {
+			a:   &mockAuthorizer{hasErr: fmt.Errorf("shouldn't be called")},
+			uid: "1",
+			sc:  http.StatusTeapot,
+		},
+		"admin": {
+			a:   &mockAuthorizer{has: true},
+			uid: "2",
+			sc:  http.StatusTeapot,
+		},
+		"someone_else": {
+			a:   &mockAuthorizer{},
+			uid: "2",
+			sc:  http.StatusForbidden,
+		},
+		"anonymous": {
+			a:  &mockAuthorizer{},
+			sc: http.StatusForbidden,
+		},
+		"lookup_fails": {
+			a:   &mockAuthorizer{hasErr: fmt.Errorf("some error")},
+			uid: "2",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.selfOrAdmin(authnNext).ServeHTTP(w, callerRequest(tc.uid, map[string]string{"user_id": "1"}))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_addressOwner(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		addr *mockAddresser
+		a    *mockAuthorizer
+		sc   int
+	}{
+		"owner": {
+			addr: &mockAddresser{owns: true},
+			a:    &mockAuthorizer{hasErr: fmt.Errorf("shouldn't be called")},
+			sc:   http.StatusTeapot,
+		},
+		"admin": {
+			addr: &mockAddresser{},
+			a:    &mockAuthorizer{has: true},
+			sc:   http.StatusTeapot,
+		},
+		"someone_else": {
+			addr: &mockAddresser{},
+			a:    &mockAuthorizer{},
+			sc:   http.StatusForbidden,
+		},
+		"owner_fails": {
+			addr: &mockAddresser{ownsErr: fmt.Errorf("some error")},
+			a:    &mockAuthorizer{},
+			sc:   http.StatusInternalServerError,
+		},
+		"admin_fails": {
+			addr: &mockAddresser{},
+			a:    &mockAuthorizer{hasErr: fmt.Errorf("some error")},
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Addresser: tc.addr, Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.addressOwner(authnNext).ServeHTTP(w, callerRequest("1", map[string]string{"address_id": "0"}))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_resetOrSelf(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		v      *mockValidator
+		cookie *http.Cookie
+		sc     int
+	}{
+		"reset": {
+			v:      &mockValidator{},
+			cookie: &http.Cookie{Name: "authn-pad", Value: "pad"},
+			sc:     http.StatusTeapot,
+		},
+		"session": {
+			v:      &mockValidator{validsc: http.StatusNoContent, session: "1", sessionsc: http.StatusOK},
+			cookie: &http.Cookie{Name: "us-authn", Value: "token"},
+			sc:     http.StatusTeapot,
+		},
+		"neither": {
+			v:  &mockValidator{},
+			sc: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Validator: tc.v, Authorizer: &mockAuthorizer{}}
+
+			r := roleRequest(http.MethodPatch, map[string]string{"user_id": "1"}, "")
+			if tc.cookie != nil {
+				r.AddCookie(tc.cookie)
+			}
+
+			w := httptest.NewRecorder()
+			us.resetOrSelf(authnNext).ServeHTTP(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func callerRequest(uid shared.UUID, params map[string]string) *http.Request {
+	r := roleRequest(http.MethodGet, params, "")
+	return r.WithContext(context.WithValue(r.Context(), shared.CTXKey("uid"), uid))
+}
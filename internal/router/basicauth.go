@@ -1,15 +1,19 @@
 package router
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 
+	valid "github.com/jsmit257/userservice/internal/validation"
 	"github.com/jsmit257/userservice/shared/v1"
 )
 
@@ -36,16 +40,58 @@ func (us UserService) PostLogin(w http.ResponseWriter, r *http.Request) {
 	} else if err = json.Unmarshal(body, &login); err != nil {
 		sc(http.StatusBadRequest).send(ctx, w, err, err.Error(), string(body))
 	} else if auth, err := us.Auther.Login(ctx, &login); err != nil {
-		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
-	} else if cookie, code := us.Validator.Login(ctx, auth.UUID, r.RemoteAddr); code != http.StatusOK {
+		sc(loginStatus(err)).send(ctx, w, err, err.Error())
+	} else if methods, err := us.mfaMethods(ctx, auth.UUID); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if len(methods) != 0 {
+		us.beginMFA(w, r, auth.UUID, methods)
+	} else if cookie, code := us.Validator.Login(ctx, auth.UUID, auth.Name, valid.PasswordLogin, us.client(r)); code != http.StatusOK {
 		sc(code).send(ctx, w, fmt.Errorf("failed redis login"))
 	} else {
 		http.SetCookie(w, cookie)
-		w.Header().Set("Location", us.success)
+		us.setRefresh(ctx, w, auth.UUID, auth.Name)
+		w.Header().Set("Location", us.landing(r))
 		sc(http.StatusMovedPermanently).success(ctx, w)
 	}
 }
 
+// landing is where a fresh login goes next: back where it came from when
+// that's somewhere on this site (e.g. /oauth/authorize), otherwise home
+func (us UserService) landing(r *http.Request) string {
+	loc := r.URL.Query().Get("redirect")
+	if !strings.HasPrefix(loc, "/") || strings.HasPrefix(loc, "//") || strings.HasPrefix(loc, "/\\") {
+		return us.success
+	}
+	return loc
+}
+
+// loginStatus tells a locked or throttled client apart from a bad password
+func loginStatus(err error) int {
+	switch err {
+	case shared.MaxFailedLoginError:
+		return http.StatusLocked
+	case shared.LoginBackoffError:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadRequest
+}
+
+// checkPad makes sure a reset's pad is good and is for id without using it
+// up, so nobody gets to find out anything about a user's passwords without
+// one; no pad at all is fine, resetOrSelf only lets that through with a
+// session
+func (us UserService) checkPad(r *http.Request, id shared.UUID) (int, error) {
+	otp, err := r.Cookie("authn-pad")
+	if err == http.ErrNoCookie {
+		return http.StatusOK, nil
+	} else if padID, code := us.Validator.CheckOTP(r.Context(), otp.Value); code != http.StatusOK {
+		return code, fmt.Errorf("bad reset pad")
+	} else if padID != id {
+		return http.StatusForbidden, shared.PermissionDeniedError
+	}
+	return http.StatusOK, nil
+}
+
 func authnPad(us UserService, w http.ResponseWriter, r *http.Request, id shared.UUID, old shared.Password) (shared.Password, int) {
 	ctx := r.Context()
 
@@ -97,19 +143,44 @@ func (us UserService) PatchLogin(w http.ResponseWriter, r *http.Request) {
 		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
 	} else if err = json.Unmarshal(body, &pair); err != nil {
 		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal form")
+	} else if code, err = us.checkPad(r, id); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if err = us.Auther.CheckPassword(ctx, id, pair.New); err != nil {
+		rejectPassword(ctx, w, err) // before the pad is gone, or a bad password locks them out
 	} else if pair.Old, code = authnPad(us, w, r, id, pair.Old); code != http.StatusOK {
 		sc(code).send(ctx, w, err)
 	} else if err := us.Auther.ChangePassword(ctx, id, pair.Old, pair.New); err != nil {
+		rejectPassword(ctx, w, err)
+	} else if user, err := us.Userer.GetUser(ctx, id); err != nil {
 		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
-	} else if cookie, code := us.Validator.Login(r.Context(), id, r.RemoteAddr); code != http.StatusOK {
+	} else if methods, err := us.mfaMethods(ctx, id); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if len(methods) != 0 {
+		us.beginMFA(w, r, id, methods) // a reset link is only ever the first factor
+	} else if cookie, code := us.Validator.Login(r.Context(), id, user.Name, valid.ResetLogin, us.client(r)); code != http.StatusOK {
 		sc(code).send(ctx, w, fmt.Errorf("failed redis login"), "failed redis login")
 	} else {
 		http.SetCookie(w, cookie)
+		us.setRefresh(ctx, w, id, user.Name)
 		w.Header().Set("Location", us.success)
 		sc(http.StatusNoContent).success(ctx, w)
 	}
 }
 
+// rejectPassword sends whatever CheckPassword or ChangePassword didn't like
+// about a new password; policy violations go back as json so a form can show
+// them
+func rejectPassword(ctx context.Context, w http.ResponseWriter, err error) {
+	var violations shared.PolicyViolations
+	if errors.As(err, &violations) {
+		sc(http.StatusBadRequest).success(ctx, w, mustJSON(violations))
+	} else if errors.Is(err, shared.PasswordsMatch) {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	}
+}
+
 func (us UserService) DeleteLogin(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -132,7 +203,9 @@ func (us UserService) DeleteLogin(w http.ResponseWriter, r *http.Request) {
 		sc(http.StatusBadRequest).send(ctx, w, fmt.Errorf("email doesn't match records"))
 	} else if login.Cell != nil && user.Cell != nil && *login.Cell != *user.Cell {
 		sc(http.StatusBadRequest).send(ctx, w, fmt.Errorf("cell number doesn't match records"))
-	} else if pad, code := us.OTP(ctx, user.UUID, r.RemoteAddr, location["redirect"]); pad == "" {
+	} else if user = user.Verified(); user.Undeliverable() {
+		sc(http.StatusBadRequest).send(ctx, w, shared.Undeliverable, "nowhere verified to send a reset")
+	} else if pad, code := us.OTP(ctx, user.UUID, us.client(r).Remote, location["redirect"]); pad == "" {
 		sc(code).send(ctx, w, fmt.Errorf("couldn't generate token"), "couldn't generate token")
 	} else if err = us.MailSender.Send(user.PasswordResetEmail(r.Host, pad)); err != nil {
 		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
@@ -142,3 +215,19 @@ func (us UserService) DeleteLogin(w http.ResponseWriter, r *http.Request) {
 		sc(http.StatusNoContent).success(ctx, w)
 	}
 }
+
+// DeleteLock is the admin escape hatch for a locked account; it clears the
+// lock and the failure count but leaves the password alone
+func (us UserService) DeleteLock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if err := us.Auther.Unlock(ctx, id); errors.Is(err, shared.UserNotUpdatedError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
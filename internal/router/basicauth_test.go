@@ -25,9 +25,14 @@ type mockAuther struct {
 	loginErr error
 
 	change error
+	check  error
 
 	reset    shared.Password
 	resetErr error
+
+	unlock error
+
+	active error
 }
 
 func Test_GetAuth(t *testing.T) {
@@ -102,6 +107,7 @@ func Test_PostLogin(t *testing.T) {
 
 	tcs := map[string]struct {
 		a     *mockAuther
+		m     *mockMFAer
 		v     *mockValidator
 		login shared.BasicAuth
 		sc    int
@@ -114,6 +120,80 @@ func Test_PostLogin(t *testing.T) {
 			},
 			sc: http.StatusMovedPermanently,
 		},
+		"with_refresh": {
+			a: &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			v: &mockValidator{
+				login:        &testCookie,
+				loginsc:      http.StatusOK,
+				newrefresh:   &http.Cookie{Name: "us-refresh", Value: "refresh", Raw: "us-refresh=refresh"},
+				newrefreshsc: http.StatusOK,
+			},
+			sc: http.StatusMovedPermanently,
+		},
+		"refresh_fails": {
+			a: &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			v: &mockValidator{
+				login:        &testCookie,
+				loginsc:      http.StatusOK,
+				newrefreshsc: http.StatusInternalServerError,
+			},
+			sc: http.StatusMovedPermanently,
+		},
+		"mfa_unconfirmed": {
+			a: &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			m: &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret}},
+			v: &mockValidator{
+				login:   &testCookie,
+				loginsc: http.StatusOK,
+			},
+			sc: http.StatusMovedPermanently,
+		},
+		"mfa_required": {
+			a:  &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			m:  &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			v:  &mockValidator{beginmfa: "pending", beginmfasc: http.StatusOK},
+			sc: http.StatusAccepted,
+		},
+		"begin_mfa_fails": {
+			a:  &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			m:  &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			v:  &mockValidator{beginmfasc: http.StatusInternalServerError},
+			sc: http.StatusInternalServerError,
+		},
+		"mfa_lookup_fails": {
+			a:  &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			m:  &mockMFAer{totpErr: fmt.Errorf("some error")},
+			sc: http.StatusInternalServerError,
+		},
+		"channel_required": {
+			a: &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			m: &mockMFAer{
+				totpErr: shared.MFANotEnrolledError,
+				channel: &shared.MFAChannel{Channel: shared.EmailChannel, Confirmed: &confirmed},
+			},
+			v:  &mockValidator{beginmfa: "pending", beginmfasc: http.StatusOK},
+			sc: http.StatusAccepted,
+		},
+		"channel_unconfirmed": {
+			a: &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			m: &mockMFAer{
+				totpErr: shared.MFANotEnrolledError,
+				channel: &shared.MFAChannel{Channel: shared.EmailChannel},
+			},
+			v: &mockValidator{
+				login:   &testCookie,
+				loginsc: http.StatusOK,
+			},
+			sc: http.StatusMovedPermanently,
+		},
+		"channel_lookup_fails": {
+			a: &mockAuther{login: &shared.BasicAuth{UUID: uid}},
+			m: &mockMFAer{
+				totpErr:    shared.MFANotEnrolledError,
+				channelErr: fmt.Errorf("some error"),
+			},
+			sc: http.StatusInternalServerError,
+		},
 		"read_fails": {
 			a:  &mockAuther{},
 			sc: http.StatusBadRequest,
@@ -126,6 +206,14 @@ func Test_PostLogin(t *testing.T) {
 			a:  &mockAuther{loginErr: fmt.Errorf("auth_login_fails")},
 			sc: http.StatusBadRequest,
 		},
+		"locked": {
+			a:  &mockAuther{loginErr: shared.MaxFailedLoginError},
+			sc: http.StatusLocked,
+		},
+		"backoff": {
+			a:  &mockAuther{loginErr: shared.LoginBackoffError},
+			sc: http.StatusTooManyRequests,
+		},
 		"valid_login_fails": {
 			a: &mockAuther{login: &shared.BasicAuth{UUID: uid}},
 			v: &mockValidator{
@@ -141,8 +229,13 @@ func Test_PostLogin(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			if tc.m == nil {
+				tc.m = &mockMFAer{totpErr: shared.MFANotEnrolledError}
+			}
+
 			us := &UserService{
 				Auther:    tc.a,
+				MFAer:     tc.m,
 				Validator: tc.v,
 			}
 
@@ -174,6 +267,50 @@ func Test_PostLogin(t *testing.T) {
 			} else {
 				require.NotSubset(t, w.Result().Cookies(), []*http.Cookie{&testCookie})
 			}
+			if tc.v != nil && tc.v.newrefresh != nil {
+				require.Subset(t, w.Result().Cookies(), []*http.Cookie{tc.v.newrefresh})
+			}
+		})
+	}
+}
+
+func Test_landing(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		url string
+		loc string
+	}{
+		"no_redirect": {
+			url: "/auth",
+			loc: "/home",
+		},
+		"local_redirect": {
+			url: "/auth?redirect=%2Foauth%2Fauthorize%3Fclient_id%3Drp",
+			loc: "/oauth/authorize?client_id=rp",
+		},
+		"absolute_redirect": {
+			url: "/auth?redirect=https%3A%2F%2Fevil.example.com",
+			loc: "/home",
+		},
+		"scheme_relative_redirect": {
+			url: "/auth?redirect=%2F%2Fevil.example.com",
+			loc: "/home",
+		},
+		"backslash_redirect": {
+			url: "/auth?redirect=%2F%5Cevil.example.com",
+			loc: "/home",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, _ := http.NewRequestWithContext(mockContext(), http.MethodPost, tc.url, nil)
+			require.Equal(t, tc.loc, UserService{success: "/home"}.landing(r))
 		})
 	}
 }
@@ -185,15 +322,20 @@ func Test_PatchLogin(t *testing.T) {
 
 	tcs := map[string]struct {
 		a        *mockAuther
+		u        *mockUserer
+		m        *mockMFAer
 		v        *mockValidator
 		id       shared.UUID
 		cookie   *http.Cookie
 		new, old *shared.Password
 		sc       int
+		response string
 	}{
 		"happy_path": {
 			a: &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
 			v: &mockValidator{
+				checkotp:      "uuid",
+				checkotpsc:    http.StatusOK,
 				completeotp:   "uuid",
 				completeotpsc: http.StatusOK,
 				login:         &http.Cookie{},
@@ -205,6 +347,31 @@ func Test_PatchLogin(t *testing.T) {
 			new:    &pass,
 			sc:     http.StatusNoContent,
 		},
+		"reset_needs_mfa": {
+			a: &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m: &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			v: &mockValidator{
+				checkotp:      "uuid",
+				checkotpsc:    http.StatusOK,
+				completeotp:   "uuid",
+				completeotpsc: http.StatusOK,
+				beginmfa:      "pending",
+				beginmfasc:    http.StatusOK,
+			},
+			id:       "uuid",
+			cookie:   &http.Cookie{Name: "authn-pad"},
+			new:      &pass,
+			sc:       http.StatusAccepted,
+			response: `{"methods":["totp"]}`,
+		},
+		"mfa_lookup_fails": {
+			a:   &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:   &mockMFAer{totpErr: fmt.Errorf("some error")},
+			id:  "uuid",
+			old: &pass,
+			new: &pass,
+			sc:  http.StatusInternalServerError,
+		},
 		"param_missing": {
 			sc: http.StatusBadRequest,
 		},
@@ -218,9 +385,46 @@ func Test_PatchLogin(t *testing.T) {
 			old: &pass,
 			sc:  http.StatusBadRequest,
 		},
+		"bad_pad": {
+			// no auther: reaching CheckPassword would panic
+			v:      &mockValidator{checkotpsc: http.StatusForbidden},
+			id:     "uuid",
+			cookie: &http.Cookie{Name: "authn-pad", Value: "junk"},
+			new:    &pass,
+			sc:     http.StatusForbidden,
+		},
+		"bad_pad_current_password": {
+			a:      &mockAuther{check: shared.PasswordsMatch},
+			v:      &mockValidator{checkotpsc: http.StatusForbidden},
+			id:     "uuid",
+			cookie: &http.Cookie{Name: "authn-pad", Value: "junk"},
+			new:    &pass,
+			sc:     http.StatusForbidden,
+		},
+		"check_pad_fails": {
+			v:      &mockValidator{checkotpsc: http.StatusInternalServerError},
+			id:     "uuid",
+			cookie: &http.Cookie{Name: "authn-pad"},
+			new:    &pass,
+			sc:     http.StatusInternalServerError,
+		},
+		"pad_for_someone_else": {
+			v: &mockValidator{
+				checkotp:   "wrong_id",
+				checkotpsc: http.StatusOK,
+			},
+			id:     "uuid",
+			cookie: &http.Cookie{Name: "authn-pad"},
+			new:    &pass,
+			sc:     http.StatusForbidden,
+		},
 		"complete_fails": {
-			a:      &mockAuther{resetErr: fmt.Errorf("some error")},
-			v:      &mockValidator{completeotpsc: http.StatusBadRequest},
+			a: &mockAuther{resetErr: fmt.Errorf("some error")},
+			v: &mockValidator{
+				checkotp:      "uuid",
+				checkotpsc:    http.StatusOK,
+				completeotpsc: http.StatusBadRequest,
+			},
 			id:     "uuid",
 			cookie: &http.Cookie{Name: "authn-pad"},
 			sc:     http.StatusBadRequest,
@@ -228,6 +432,8 @@ func Test_PatchLogin(t *testing.T) {
 		"wrong_id": {
 			a: &mockAuther{resetErr: fmt.Errorf("some error")},
 			v: &mockValidator{
+				checkotp:      "uuid",
+				checkotpsc:    http.StatusOK,
 				completeotp:   "wrong_id",
 				completeotpsc: http.StatusOK,
 			},
@@ -238,6 +444,8 @@ func Test_PatchLogin(t *testing.T) {
 		"reset_fails": {
 			a: &mockAuther{resetErr: fmt.Errorf("some error")},
 			v: &mockValidator{
+				checkotp:      "uuid",
+				checkotpsc:    http.StatusOK,
 				completeotp:   "uuid",
 				completeotpsc: http.StatusOK,
 			},
@@ -245,6 +453,53 @@ func Test_PatchLogin(t *testing.T) {
 			cookie: &http.Cookie{Name: "authn-pad"},
 			sc:     http.StatusInternalServerError,
 		},
+		"policy_violations": {
+			a: &mockAuther{change: shared.PolicyViolations{{
+				Rule:   shared.MinLengthRule,
+				Detail: "must be at least 8 characters",
+			}}},
+			id:       "uuid",
+			old:      &pass,
+			new:      &pass,
+			sc:       http.StatusBadRequest,
+			response: `[{"rule":"min_length","detail":"must be at least 8 characters"}]`,
+		},
+		"passwords_match": {
+			a:   &mockAuther{change: shared.PasswordsMatch},
+			id:  "uuid",
+			old: &pass,
+			new: &pass,
+			sc:  http.StatusBadRequest,
+		},
+		"reset_weak_password": {
+			// the pad is good but CompleteOTP would fail: it can't be used up
+			a: &mockAuther{check: shared.PolicyViolations{{
+				Rule:   shared.MinLengthRule,
+				Detail: "must be at least 8 characters",
+			}}},
+			v:        &mockValidator{checkotp: "uuid", checkotpsc: http.StatusOK},
+			id:       "uuid",
+			cookie:   &http.Cookie{Name: "authn-pad"},
+			new:      &pass,
+			sc:       http.StatusBadRequest,
+			response: `[{"rule":"min_length","detail":"must be at least 8 characters"}]`,
+		},
+		"reset_reused_password": {
+			a:      &mockAuther{check: shared.PasswordsMatch},
+			v:      &mockValidator{checkotp: "uuid", checkotpsc: http.StatusOK},
+			id:     "uuid",
+			cookie: &http.Cookie{Name: "authn-pad"},
+			new:    &pass,
+			sc:     http.StatusBadRequest,
+		},
+		"check_fails": {
+			a:      &mockAuther{check: fmt.Errorf("some error")},
+			v:      &mockValidator{checkotp: "uuid", checkotpsc: http.StatusOK},
+			id:     "uuid",
+			cookie: &http.Cookie{Name: "authn-pad"},
+			new:    &pass,
+			sc:     http.StatusInternalServerError,
+		},
 		"change_fails": {
 			a:   &mockAuther{change: fmt.Errorf("some error")},
 			id:  "uuid",
@@ -260,6 +515,14 @@ func Test_PatchLogin(t *testing.T) {
 			new: &pass,
 			sc:  http.StatusForbidden,
 		},
+		"user_lookup_fails": {
+			a:   &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			u:   &mockUserer{userErr: fmt.Errorf("some error")},
+			id:  "uuid",
+			old: &pass,
+			new: &pass,
+			sc:  http.StatusInternalServerError,
+		},
 	}
 	for name, tc := range tcs {
 		name, tc := name, tc
@@ -267,8 +530,17 @@ func Test_PatchLogin(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			if tc.u == nil {
+				tc.u = &mockUserer{user: &shared.User{UUID: "uuid", Name: "name"}}
+			}
+			if tc.m == nil {
+				tc.m = &mockMFAer{totpErr: shared.MFANotEnrolledError}
+			}
+
 			us := &UserService{
 				Auther:    tc.a,
+				Userer:    tc.u,
+				MFAer:     tc.m,
 				Validator: tc.v,
 			}
 
@@ -306,6 +578,9 @@ func Test_PatchLogin(t *testing.T) {
 			us.PatchLogin(w, r)
 
 			require.Equal(t, tc.sc, w.Code)
+			if tc.response != "" {
+				require.Equal(t, tc.response, w.Body.String())
+			}
 		})
 	}
 }
@@ -338,9 +613,11 @@ func Test_DeleteLogin(t *testing.T) {
 			ms: mockMailSender{},
 			u: mockUserer{
 				user: &shared.User{
-					UUID:  "uuid",
-					Email: &addr,
-					Cell:  &cell,
+					UUID:          "uuid",
+					Email:         &addr,
+					EmailVerified: &confirmed,
+					Cell:          &cell,
+					CellVerified:  &confirmed,
 				}},
 			login: shared.User{
 				UUID:  "uuid",
@@ -356,8 +633,9 @@ func Test_DeleteLogin(t *testing.T) {
 			ms: mockMailSender{},
 			u: mockUserer{
 				user: &shared.User{
-					UUID: "uuid",
-					Cell: &cell,
+					UUID:         "uuid",
+					Cell:         &cell,
+					CellVerified: &confirmed,
 				}},
 			login: shared.User{
 				UUID: "uuid",
@@ -392,6 +670,30 @@ func Test_DeleteLogin(t *testing.T) {
 			sc:    http.StatusBadRequest,
 			loc:   "redirect",
 		},
+		"email_unverified": {
+			u: mockUserer{user: &shared.User{
+				UUID:  "uuid",
+				Email: &addr,
+			}},
+			login: shared.User{
+				UUID:  "uuid",
+				Email: &addr,
+			},
+			sc:  http.StatusBadRequest,
+			loc: "redirect",
+		},
+		"cell_unverified": {
+			u: mockUserer{user: &shared.User{
+				UUID: "uuid",
+				Cell: &cell,
+			}},
+			login: shared.User{
+				UUID: "uuid",
+				Cell: &cell,
+			},
+			sc:  http.StatusBadRequest,
+			loc: "redirect",
+		},
 		"email_mismatch": {
 			u: mockUserer{user: &shared.User{
 				UUID:  "uuid",
@@ -420,9 +722,10 @@ func Test_DeleteLogin(t *testing.T) {
 		},
 		"gen_token_fails": {
 			u: mockUserer{user: &shared.User{
-				UUID:  "uuid",
-				Email: &addr,
-				Cell:  &cell,
+				UUID:         "uuid",
+				Email:        &addr,
+				Cell:         &cell,
+				CellVerified: &confirmed,
 			}},
 			v: mockValidator{tokensc: http.StatusConflict},
 			login: shared.User{
@@ -434,9 +737,10 @@ func Test_DeleteLogin(t *testing.T) {
 		},
 		"send_email_fails": {
 			u: mockUserer{user: &shared.User{
-				UUID:  "uuid",
-				Email: &addr,
-				Cell:  &cell,
+				UUID:          "uuid",
+				Email:         &addr,
+				EmailVerified: &confirmed,
+				Cell:          &cell,
 			}},
 			v:  mockValidator{token: "token"},
 			ms: mockMailSender{err: fmt.Errorf("some error")},
@@ -449,8 +753,9 @@ func Test_DeleteLogin(t *testing.T) {
 		},
 		"send_sms_fails": {
 			u: mockUserer{user: &shared.User{
-				UUID:  "uuid",
-				Email: &addr,
+				UUID:          "uuid",
+				Email:         &addr,
+				EmailVerified: &confirmed,
 			}},
 			v:  mockValidator{token: "token"},
 			ss: mockSmsSender{err: fmt.Errorf("some error")},
@@ -506,6 +811,61 @@ func Test_DeleteLogin(t *testing.T) {
 	}
 }
 
+func Test_DeleteLock(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a  *mockAuther
+		id shared.UUID
+		sc int
+	}{
+		"happy_path": {
+			a:  &mockAuther{},
+			id: "uuid",
+			sc: http.StatusNoContent,
+		},
+		"missing_id": {
+			a:  &mockAuther{},
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			a:  &mockAuther{unlock: shared.UserNotUpdatedError},
+			id: "uuid",
+			sc: http.StatusNotFound,
+		},
+		"unlock_fails": {
+			a:  &mockAuther{unlock: fmt.Errorf("some error")},
+			id: "uuid",
+			sc: http.StatusInternalServerError,
+		},
+	}
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Auther: tc.a}
+			rctx := chi.NewRouteContext()
+			rctx.URLParams = chi.RouteParams{Keys: []string{"user_id"}, Values: []string{string(tc.id)}}
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(
+					mockContext(),
+					chi.RouteCtxKey,
+					rctx),
+				http.MethodDelete,
+				"tc.url",
+				nil,
+			)
+
+			us.DeleteLock(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
 func authToBody(a *shared.BasicAuth) string {
 	result, _ := json.Marshal(a)
 	return string(result)
@@ -517,9 +877,18 @@ func (ma *mockAuther) GetAuthByAttrs(context.Context, *shared.UUID, *string) (*s
 func (ma *mockAuther) ChangePassword(context.Context, shared.UUID, shared.Password, shared.Password) error {
 	return ma.change
 }
+func (ma *mockAuther) CheckPassword(context.Context, shared.UUID, shared.Password) error {
+	return ma.check
+}
 func (ma *mockAuther) Login(context.Context, *shared.BasicAuth) (*shared.BasicAuth, error) {
 	return ma.login, ma.loginErr
 }
 func (ma *mockAuther) ResetPassword(context.Context, *shared.UUID) (shared.Password, error) {
 	return ma.reset, ma.resetErr
 }
+func (ma *mockAuther) Unlock(context.Context, shared.UUID) error {
+	return ma.unlock
+}
+func (ma *mockAuther) Active(context.Context, shared.UUID) error {
+	return ma.active
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	valid "github.com/jsmit257/userservice/internal/validation"
+)
+
+// client is what the validator gets to know about whoever's asking
+func (us UserService) client(r *http.Request) valid.Client {
+	return valid.Client{Remote: us.remote(r), UserAgent: r.UserAgent()}
+}
+
+// remote is the caller's address: the peer, unless the peer is one of our
+// own proxies, in which case it's the last X-Forwarded-For hop that wasn't
+// added by one of them; anything before that is whatever the client said
+func (us UserService) remote(r *http.Request) string {
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	result := addr.Addr().Unmap()
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0 && us.trusted(result); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		result = hop.Unmap()
+	}
+
+	return result.String()
+}
+
+func (us UserService) trusted(addr netip.Addr) bool {
+	for _, p := range us.proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxies panics on a bad CIDR, same as any other bad config
+func parseProxies(cidrs []string) []netip.Prefix {
+	result := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		p, err := netip.ParsePrefix(strings.TrimSpace(c))
+		if err != nil {
+			panic(fmt.Errorf("bad trusted proxy %q: %w", c, err))
+		}
+		result = append(result, p.Masked())
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_remote(t *testing.T) {
+	t.Parallel()
+
+	us := UserService{proxies: parseProxies([]string{"10.0.0.0/8", "fd00::/8"})}
+
+	tcs := map[string]struct {
+		peer string
+		xff  []string
+		addr string
+	}{
+		"no_proxy": {
+			peer: "192.0.2.1:1234",
+			addr: "192.0.2.1",
+		},
+		"untrusted_peer_is_ignored": {
+			peer: "192.0.2.1:1234",
+			xff:  []string{"198.51.100.1"},
+			addr: "192.0.2.1",
+		},
+		"trusted_peer": {
+			peer: "10.0.0.1:1234",
+			xff:  []string{"198.51.100.1"},
+			addr: "198.51.100.1",
+		},
+		"spoofed_hops_are_skipped": {
+			peer: "10.0.0.1:1234",
+			xff:  []string{"203.0.113.1, 198.51.100.1"},
+			addr: "198.51.100.1",
+		},
+		"chained_proxies": {
+			peer: "10.0.0.1:1234",
+			xff:  []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"},
+			addr: "198.51.100.1",
+		},
+		"ipv6": {
+			peer: "[fd00::1]:1234",
+			xff:  []string{"2001:db8::1"},
+			addr: "2001:db8::1",
+		},
+		"garbage_stops_the_walk": {
+			peer: "10.0.0.1:1234",
+			xff:  []string{"198.51.100.1, garbage, 10.0.0.2"},
+			addr: "10.0.0.2",
+		},
+		"all_proxies": {
+			peer: "10.0.0.1:1234",
+			xff:  []string{"10.0.0.2"},
+			addr: "10.0.0.2",
+		},
+		"unparseable_peer": {
+			peer: "pipe",
+			addr: "pipe",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, _ := http.NewRequest(http.MethodGet, "/valid", nil)
+			r.RemoteAddr = tc.peer
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			r.Header.Set("User-Agent", "agent")
+
+			c := us.client(r)
+			require.Equal(t, tc.addr, c.Remote)
+			require.Equal(t, "agent", c.UserAgent)
+		})
+	}
+}
+
+func Test_parseProxies(t *testing.T) {
+	t.Parallel()
+
+	require.Panics(t, func() { parseProxies([]string{"10.0.0.1"}) })
+	require.Len(t, parseProxies([]string{" 10.0.0.1/8 "}), 1)
+}
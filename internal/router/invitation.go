@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+
+	valid "github.com/jsmit257/userservice/internal/validation"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// GetInvitations lists open and closed invitations alike, it's how admins
+// find out who invited whom and what became of it
+func (us UserService) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if invitations, err := us.Inviter.GetInvitations(ctx); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(invitations))
+	}
+}
+
+// PostInvitation invites whoever is at an email or a cell, only one of them,
+// and sends them the link; the token is never in the response, so the only
+// way to the account is through the address
+func (us UserService) PostInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var body struct {
+		Email *shared.Email `json:"email"`
+		Cell  *shared.Cell  `json:"cell"`
+	}
+	if b, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(b, &body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if body.Email.Valid() == body.Cell.Valid() {
+		sc(http.StatusBadRequest).send(ctx, w, fmt.Errorf("need exactly one of email or cell"), "exactly one of email or cell is required")
+	} else if inv, token, err := us.addInvitation(ctx, body.Email, body.Cell); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if err = us.sendInvitation(r, inv, token); err != nil {
+		us.revokeUnsent(r, inv)
+		sc(http.StatusInternalServerError).send(ctx, w, err, "couldn't send invitation")
+	} else {
+		sc(http.StatusCreated).success(ctx, w, mustJSON(inv))
+	}
+}
+
+// DeleteInvitation revokes an invitation nobody has accepted yet; the record
+// stays, with who revoked it and when
+func (us UserService) DeleteInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "invitation_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing invitation_id")
+	} else if err := us.Inviter.RevokeInvitation(ctx, id, caller(ctx)); errors.Is(err, shared.InvitationNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, "no open invitation with that id")
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// GetInvite is for whatever page the invitation link lands on; it says where
+// the invitation went and until when it's good, nothing about who sent it
+func (us UserService) GetInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if token := chi.URLParam(r, "token"); token == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing token")
+	} else if inv, err := us.Inviter.GetInvitation(ctx, token); errors.Is(err, shared.InvitationNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if errors.Is(err, shared.InvitationClosedError) {
+		sc(http.StatusGone).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(struct {
+			Email   *shared.Email `json:"email,omitempty"`
+			Cell    *shared.Cell  `json:"cell,omitempty"`
+			Expires time.Time     `json:"expires"`
+		}{inv.Email, inv.Cell, inv.Expires}))
+	}
+}
+
+// PostInvite accepts an invitation: the invitee picks a username and
+// password, gets the address the invitation went to, already verified, and
+// is logged in, same as PostSignup
+func (us UserService) PostInvite(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	ctx := r.Context()
+
+	var body struct {
+		Name     string          `json:"username"`
+		Password shared.Password `json:"password"`
+	}
+	var violations shared.PolicyViolations
+	if token := chi.URLParam(r, "token"); token == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing token")
+	} else if b, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(b, &body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if body.Name == "" || body.Password == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "username and password are required")
+	} else if id, err := us.Inviter.AcceptInvitation(ctx, token, &shared.User{Name: body.Name}, body.Password); errors.Is(err, shared.InvitationNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if errors.Is(err, shared.InvitationClosedError) {
+		sc(http.StatusGone).send(ctx, w, err, err.Error())
+	} else if errors.As(err, &violations) {
+		sc(http.StatusBadRequest).success(ctx, w, mustJSON(violations))
+	} else if errors.Is(err, shared.UserExistsError) {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if cookie, code := us.Validator.Login(ctx, id, body.Name, valid.PasswordLogin, us.client(r)); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("failed redis login"), "account was created, but couldn't log in")
+	} else {
+		http.SetCookie(w, cookie)
+		us.setRefresh(ctx, w, id, body.Name)
+		sc(http.StatusCreated).success(ctx, w, html.EscapeString(string(id)))
+	}
+}
+
+// addInvitation stores the invitation as from the caller, with an empty
+// address left as null rather than stored empty
+func (us UserService) addInvitation(ctx context.Context, e *shared.Email, c *shared.Cell) (*shared.Invitation, string, error) {
+	inv := &shared.Invitation{
+		CreatedBy: caller(ctx),
+		Expires:   time.Now().UTC().Add(us.inviteTimeout),
+	}
+	if e.Valid() {
+		inv.Email = e
+	} else {
+		inv.Cell = c
+	}
+	token, err := us.Inviter.AddInvitation(ctx, inv)
+	return inv, token, err
+}
+
+func (us UserService) sendInvitation(r *http.Request, inv *shared.Invitation, token string) error {
+	if inv.Email.Valid() {
+		return us.MailSender.Send(inv.InvitationEmail(r.Host, token))
+	}
+	return us.SmsSender.Send(inv.InvitationSMS(r.Host, token))
+}
+
+// revokeUnsent closes an invitation whose link never went out, so it doesn't
+// sit in the list looking like it's waiting on somebody
+func (us UserService) revokeUnsent(r *http.Request, inv *shared.Invitation) {
+	ctx := r.Context()
+
+	if err := us.Inviter.RevokeInvitation(ctx, inv.UUID, inv.CreatedBy); err != nil {
+		ctx.Value(shared.CTXKey("log")).(*logrus.Entry).
+			WithError(err).
+			WithField("invitation", inv.UUID).
+			Error("couldn't revoke unsent invitation")
+	}
+}
@@ -0,0 +1,367 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type mockInviter struct {
+	invitations    []shared.Invitation
+	invitationsErr error
+
+	invitation    *shared.Invitation
+	invitationErr error
+
+	token  string
+	addErr error
+
+	revoked   int
+	revokeErr error
+
+	accept    shared.UUID
+	acceptErr error
+}
+
+func Test_GetInvitations(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		i  *mockInviter
+		sc int
+	}{
+		"happy_path": {
+			i:  &mockInviter{invitations: []shared.Invitation{{UUID: "inv"}}},
+			sc: http.StatusOK,
+		},
+		"list_fails": {
+			i:  &mockInviter{invitationsErr: fmt.Errorf("some error")},
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Inviter: tc.i}
+
+			w := httptest.NewRecorder()
+			us.GetInvitations(w, magicRequest(http.MethodGet, "", "", ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_PostInvitation(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		i       *mockInviter
+		ms      mockMailSender
+		ss      mockSmsSender
+		body    string
+		sc      int
+		emails  int
+		texts   int
+		revoked int
+	}{
+		"happy_email": {
+			i:      &mockInviter{token: "inv.secret"},
+			body:   `{"email":"someone@example.com"}`,
+			sc:     http.StatusCreated,
+			emails: 1,
+		},
+		"happy_sms": {
+			i:     &mockInviter{token: "inv.secret"},
+			body:  `{"cell":"5551234","email":""}`,
+			sc:    http.StatusCreated,
+			texts: 1,
+		},
+		"bad_body": {
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"no_address": {
+			body: `{}`,
+			sc:   http.StatusBadRequest,
+		},
+		"both_addresses": {
+			body: `{"email":"someone@example.com","cell":"5551234"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"add_fails": {
+			i:    &mockInviter{addErr: fmt.Errorf("some error")},
+			body: `{"email":"someone@example.com"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"send_fails": {
+			i:       &mockInviter{token: "inv.secret"},
+			ms:      mockMailSender{err: fmt.Errorf("some error")},
+			body:    `{"email":"someone@example.com"}`,
+			sc:      http.StatusInternalServerError,
+			emails:  1,
+			revoked: 1,
+		},
+		"send_and_revoke_fail": {
+			i:       &mockInviter{token: "inv.secret", revokeErr: fmt.Errorf("some error")},
+			ss:      mockSmsSender{err: fmt.Errorf("some error")},
+			body:    `{"cell":"5551234"}`,
+			sc:      http.StatusInternalServerError,
+			texts:   1,
+			revoked: 1,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Inviter: tc.i, MailSender: &tc.ms, SmsSender: &tc.ss}
+
+			w := httptest.NewRecorder()
+			us.PostInvitation(w, magicRequest(http.MethodPost, "", "", tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.emails, tc.ms.msgs)
+			require.Equal(t, tc.texts, tc.ss.msgs)
+			if tc.i != nil {
+				require.Equal(t, tc.revoked, tc.i.revoked)
+			}
+		})
+	}
+}
+
+func Test_DeleteInvitation(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		i  *mockInviter
+		id string
+		sc int
+	}{
+		"happy_path": {
+			i:  &mockInviter{},
+			id: "inv",
+			sc: http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			i:  &mockInviter{revokeErr: shared.InvitationNotFoundError},
+			id: "inv",
+			sc: http.StatusNotFound,
+		},
+		"revoke_fails": {
+			i:  &mockInviter{revokeErr: fmt.Errorf("some error")},
+			id: "inv",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Inviter: tc.i}
+
+			w := httptest.NewRecorder()
+			us.DeleteInvitation(w, magicRequest(http.MethodDelete, "invitation_id", tc.id, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_GetInvite(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		i      *mockInviter
+		token  string
+		sc     int
+		result string
+	}{
+		"happy_path": {
+			i: &mockInviter{invitation: &shared.Invitation{
+				UUID:      "inv",
+				Email:     &testEmail,
+				CreatedBy: "admin",
+				Expires:   time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			}},
+			token:  "inv.secret",
+			sc:     http.StatusOK,
+			result: `{"email":"` + string(testEmail) + `","expires":"2030-01-01T00:00:00Z"}`,
+		},
+		"missing_token": {
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			i:     &mockInviter{invitationErr: shared.InvitationNotFoundError},
+			token: "inv.secret",
+			sc:    http.StatusNotFound,
+		},
+		"closed": {
+			i:     &mockInviter{invitationErr: shared.InvitationClosedError},
+			token: "inv.secret",
+			sc:    http.StatusGone,
+		},
+		"get_fails": {
+			i:     &mockInviter{invitationErr: fmt.Errorf("some error")},
+			token: "inv.secret",
+			sc:    http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Inviter: tc.i}
+
+			w := httptest.NewRecorder()
+			us.GetInvite(w, magicRequest(http.MethodGet, "token", tc.token, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+			if tc.result != "" {
+				require.Equal(t, tc.result, w.Body.String())
+			}
+		})
+	}
+}
+
+func Test_PostInvite(t *testing.T) {
+	t.Parallel()
+
+	loggedIn := &mockValidator{
+		login:   &http.Cookie{Name: "us-authn", Value: "token"},
+		loginsc: http.StatusOK,
+	}
+
+	tcs := map[string]struct {
+		i      *mockInviter
+		v      *mockValidator
+		token  string
+		body   string
+		sc     int
+		cookie bool
+	}{
+		"happy_path": {
+			i:      &mockInviter{accept: "uuid"},
+			v:      loggedIn,
+			token:  "inv.secret",
+			body:   `{"username":"name","password":"correct horse"}`,
+			sc:     http.StatusCreated,
+			cookie: true,
+		},
+		"missing_token": {
+			body: `{"username":"name","password":"correct horse"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_body": {
+			token: "inv.secret",
+			body:  `{`,
+			sc:    http.StatusBadRequest,
+		},
+		"missing_password": {
+			token: "inv.secret",
+			body:  `{"username":"name"}`,
+			sc:    http.StatusBadRequest,
+		},
+		"not_found": {
+			i:     &mockInviter{acceptErr: shared.InvitationNotFoundError},
+			token: "inv.secret",
+			body:  `{"username":"name","password":"correct horse"}`,
+			sc:    http.StatusNotFound,
+		},
+		"closed": {
+			i:     &mockInviter{acceptErr: shared.InvitationClosedError},
+			token: "inv.secret",
+			body:  `{"username":"name","password":"correct horse"}`,
+			sc:    http.StatusGone,
+		},
+		"policy_violation": {
+			i:     &mockInviter{acceptErr: shared.DefaultPasswordPolicy.Check("short")},
+			token: "inv.secret",
+			body:  `{"username":"name","password":"short"}`,
+			sc:    http.StatusBadRequest,
+		},
+		"user_exists": {
+			i:     &mockInviter{acceptErr: shared.UserExistsError},
+			token: "inv.secret",
+			body:  `{"username":"name","password":"correct horse"}`,
+			sc:    http.StatusBadRequest,
+		},
+		"accept_fails": {
+			i:     &mockInviter{acceptErr: fmt.Errorf("some error")},
+			token: "inv.secret",
+			body:  `{"username":"name","password":"correct horse"}`,
+			sc:    http.StatusInternalServerError,
+		},
+		"login_fails": {
+			i:     &mockInviter{accept: "uuid"},
+			v:     &mockValidator{loginsc: http.StatusInternalServerError},
+			token: "inv.secret",
+			body:  `{"username":"name","password":"correct horse"}`,
+			sc:    http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Inviter: tc.i, Validator: tc.v}
+
+			w := httptest.NewRecorder()
+			us.PostInvite(w, magicRequest(http.MethodPost, "token", tc.token, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.cookie, w.Header().Get("Set-Cookie") != "")
+		})
+	}
+}
+
+func (mi *mockInviter) GetInvitations(context.Context) ([]shared.Invitation, error) {
+	return mi.invitations, mi.invitationsErr
+}
+
+func (mi *mockInviter) GetInvitation(context.Context, string) (*shared.Invitation, error) {
+	return mi.invitation, mi.invitationErr
+}
+
+func (mi *mockInviter) AddInvitation(_ context.Context, inv *shared.Invitation) (string, error) {
+	if mi.addErr != nil {
+		return "", mi.addErr
+	}
+	inv.UUID = "inv"
+	return mi.token, nil
+}
+
+func (mi *mockInviter) RevokeInvitation(context.Context, shared.UUID, shared.UUID) error {
+	mi.revoked++
+	return mi.revokeErr
+}
+
+func (mi *mockInviter) AcceptInvitation(context.Context, string, *shared.User, shared.Password) (shared.UUID, error) {
+	return mi.accept, mi.acceptErr
+}
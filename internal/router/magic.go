@@ -0,0 +1,142 @@
+package router
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	valid "github.com/jsmit257/userservice/internal/validation"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// PostMagic sends a login link to a user who opted in; it answers the same
+// whether or not there was anyone to send it to, or they were deleted or
+// locked out, so it can't be used to find out who has an account
+func (us UserService) PostMagic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var body struct{ Name string }
+	if b, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(b, &body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if body.Name == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "name is required")
+	} else if auth, err := us.Auther.GetAuthByAttrs(ctx, nil, &body.Name); errors.Is(err, sql.ErrNoRows) {
+		sc(http.StatusAccepted).send(ctx, w, err)
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if code := us.active(ctx, auth.UUID); code == http.StatusUnauthorized {
+		sc(http.StatusAccepted).send(ctx, w, fmt.Errorf("user isn't active"))
+	} else if code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("couldn't check user"), "couldn't check user")
+	} else if link, err := us.MagicLinker.GetMagicLink(ctx, auth.UUID); errors.Is(err, shared.MagicLinkNotEnabledError) {
+		sc(http.StatusAccepted).send(ctx, w, err)
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if user, code, err := us.reachable(r, auth.UUID, link.Channel); errors.Is(err, shared.Undeliverable) {
+		sc(http.StatusAccepted).send(ctx, w, err)
+	} else if err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if token, code := us.Validator.SendMagicLink(ctx, auth.UUID, us.client(r).Remote); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("couldn't generate link"), "couldn't generate link")
+	} else if err = us.sendMagicLink(r, user, link.Channel, token); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusAccepted).success(ctx, w)
+	}
+}
+
+// GetMagic is where a link lands: it's a login like any other, second factor
+// and all, as long as the user is still opted in and wasn't deleted or locked
+// out since the link went out
+func (us UserService) GetMagic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if token := chi.URLParam(r, "token"); token == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing token")
+	} else if uid, code := us.Validator.RedeemMagicLink(ctx, token); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("couldn't redeem link"))
+	} else if code = us.active(ctx, uid); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("user isn't active"))
+	} else if _, err := us.MagicLinker.GetMagicLink(ctx, uid); errors.Is(err, shared.MagicLinkNotEnabledError) {
+		sc(http.StatusForbidden).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if user, err := us.Userer.GetUser(ctx, uid); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if methods, err := us.mfaMethods(ctx, uid); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if len(methods) != 0 {
+		us.beginMFA(w, r, uid, methods)
+	} else if cookie, code := us.Validator.Login(ctx, uid, user.Name, valid.MagicLogin, us.client(r)); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("failed redis login"))
+	} else {
+		http.SetCookie(w, cookie)
+		us.setRefresh(ctx, w, uid, user.Name)
+		w.Header().Set("Location", us.landing(r))
+		sc(http.StatusFound).success(ctx, w)
+	}
+}
+
+func (us UserService) GetUserMagic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if link, err := us.MagicLinker.GetMagicLink(ctx, id); errors.Is(err, shared.MagicLinkNotEnabledError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(link))
+	}
+}
+
+// PostUserMagic opts in to magic links, or moves them to another channel
+func (us UserService) PostUserMagic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var body struct{ Channel shared.Channel }
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if b, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(b, &body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if !body.Channel.Valid() {
+		sc(http.StatusBadRequest).send(ctx, w, fmt.Errorf("unknown channel: %q", body.Channel), "channel must be email or sms")
+	} else if _, code, err := us.reachable(r, id, body.Channel); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if err = us.MagicLinker.EnableMagicLink(ctx, id, body.Channel); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func (us UserService) DeleteUserMagic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if err := us.MagicLinker.DisableMagicLink(ctx, id); errors.Is(err, shared.MagicLinkNotEnabledError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func (us UserService) sendMagicLink(r *http.Request, user *shared.User, ch shared.Channel, token string) error {
+	if ch == shared.SMSChannel {
+		return us.SmsSender.Send(user.MagicLinkSMS(r.Host, token))
+	}
+	return us.MailSender.Send(user.MagicLinkEmail(r.Host, token))
+}
@@ -0,0 +1,492 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type mockMagicLinker struct {
+	link    *shared.MagicLink
+	linkErr error
+
+	enableErr,
+	disableErr error
+}
+
+func Test_PostMagic(t *testing.T) {
+	t.Parallel()
+
+	smsLink := &mockMagicLinker{link: &shared.MagicLink{Channel: shared.SMSChannel}}
+	emailLink := &mockMagicLinker{link: &shared.MagicLink{Channel: shared.EmailChannel}}
+	found := &mockAuther{get: &shared.BasicAuth{UUID: "uuid"}}
+
+	tcs := map[string]struct {
+		a      *mockAuther
+		ml     *mockMagicLinker
+		u      *mockUserer
+		v      *mockValidator
+		ms     mockMailSender
+		ss     mockSmsSender
+		body   string
+		sc     int
+		emails int
+		texts  int
+	}{
+		"happy_email": {
+			a:      found,
+			ml:     emailLink,
+			u:      &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			v:      &mockValidator{magiclink: "token", magiclinksc: http.StatusOK},
+			body:   `{"name":"name"}`,
+			sc:     http.StatusAccepted,
+			emails: 1,
+		},
+		"happy_sms": {
+			a:     found,
+			ml:    smsLink,
+			u:     &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			v:     &mockValidator{magiclink: "token", magiclinksc: http.StatusOK},
+			body:  `{"name":"name"}`,
+			sc:    http.StatusAccepted,
+			texts: 1,
+		},
+		"bad_body": {
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_name": {
+			body: `{}`,
+			sc:   http.StatusBadRequest,
+		},
+		"no_such_user": {
+			a:    &mockAuther{getErr: sql.ErrNoRows},
+			body: `{"name":"name"}`,
+			sc:   http.StatusAccepted,
+		},
+		"auth_fails": {
+			a:    &mockAuther{getErr: fmt.Errorf("some error")},
+			body: `{"name":"name"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"inactive": {
+			a:    &mockAuther{get: &shared.BasicAuth{UUID: "uuid"}, active: shared.MaxFailedLoginError},
+			body: `{"name":"name"}`,
+			sc:   http.StatusAccepted,
+		},
+		"active_fails": {
+			a:    &mockAuther{get: &shared.BasicAuth{UUID: "uuid"}, active: fmt.Errorf("some error")},
+			body: `{"name":"name"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"not_enabled": {
+			a:    found,
+			ml:   &mockMagicLinker{linkErr: shared.MagicLinkNotEnabledError},
+			body: `{"name":"name"}`,
+			sc:   http.StatusAccepted,
+		},
+		"link_fails": {
+			a:    found,
+			ml:   &mockMagicLinker{linkErr: fmt.Errorf("some error")},
+			body: `{"name":"name"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"unreachable": {
+			a:    found,
+			ml:   smsLink,
+			u:    &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			body: `{"name":"name"}`,
+			sc:   http.StatusAccepted,
+		},
+		"unverified": {
+			a:    found,
+			ml:   emailLink,
+			u:    &mockUserer{user: &shared.User{Email: &testEmail}},
+			body: `{"name":"name"}`,
+			sc:   http.StatusAccepted,
+		},
+		"user_fails": {
+			a:    found,
+			ml:   smsLink,
+			u:    &mockUserer{userErr: fmt.Errorf("some error")},
+			body: `{"name":"name"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"too_many": {
+			a:    found,
+			ml:   smsLink,
+			u:    &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			v:    &mockValidator{magiclinksc: http.StatusTooManyRequests},
+			body: `{"name":"name"}`,
+			sc:   http.StatusTooManyRequests,
+		},
+		"send_fails": {
+			a:      found,
+			ml:     emailLink,
+			u:      &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			v:      &mockValidator{magiclink: "token", magiclinksc: http.StatusOK},
+			ms:     mockMailSender{err: fmt.Errorf("some error")},
+			body:   `{"name":"name"}`,
+			sc:     http.StatusInternalServerError,
+			emails: 1,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{
+				Auther:      tc.a,
+				MagicLinker: tc.ml,
+				Userer:      tc.u,
+				Validator:   tc.v,
+				MailSender:  &tc.ms,
+				SmsSender:   &tc.ss,
+			}
+
+			w := httptest.NewRecorder()
+			us.PostMagic(w, magicRequest(http.MethodPost, "token", "", tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.emails, tc.ms.msgs)
+			require.Equal(t, tc.texts, tc.ss.msgs)
+		})
+	}
+}
+
+func Test_GetMagic(t *testing.T) {
+	t.Parallel()
+
+	redeems := &mockValidator{
+		redeemmagic:   "uuid",
+		redeemmagicsc: http.StatusOK,
+		login:         &http.Cookie{Name: "us-authn", Value: "token"},
+		loginsc:       http.StatusOK,
+		beginmfa:      "mfa",
+		beginmfasc:    http.StatusOK,
+	}
+	enabled := &mockMagicLinker{link: &shared.MagicLink{Channel: shared.EmailChannel}}
+	nomfa := &mockMFAer{
+		totpErr:    shared.MFANotEnrolledError,
+		channelErr: shared.MFANotEnrolledError,
+	}
+
+	tcs := map[string]struct {
+		a     *mockAuther
+		ml    *mockMagicLinker
+		m     *mockMFAer
+		u     *mockUserer
+		v     *mockValidator
+		token string
+		sc    int
+	}{
+		"happy_path": {
+			ml:    enabled,
+			m:     nomfa,
+			u:     &mockUserer{user: &shared.User{Name: "name"}},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusFound,
+		},
+		"needs_mfa": {
+			ml: enabled,
+			m: &mockMFAer{
+				totp:       &shared.TOTP{Confirmed: &confirmed},
+				channelErr: shared.MFANotEnrolledError,
+			},
+			u:     &mockUserer{user: &shared.User{Name: "name"}},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusAccepted,
+		},
+		"missing_token": {
+			sc: http.StatusBadRequest,
+		},
+		"bad_token": {
+			v:     &mockValidator{redeemmagicsc: http.StatusForbidden},
+			token: "token",
+			sc:    http.StatusForbidden,
+		},
+		"deleted": {
+			a:     &mockAuther{active: shared.UserDeletedError},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusUnauthorized,
+		},
+		"locked_out": {
+			a:     &mockAuther{active: shared.MaxFailedLoginError},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusUnauthorized,
+		},
+		"active_fails": {
+			a:     &mockAuther{active: fmt.Errorf("some error")},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusInternalServerError,
+		},
+		"opted_out": {
+			ml:    &mockMagicLinker{linkErr: shared.MagicLinkNotEnabledError},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusForbidden,
+		},
+		"link_fails": {
+			ml:    &mockMagicLinker{linkErr: fmt.Errorf("some error")},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusInternalServerError,
+		},
+		"user_fails": {
+			ml:    enabled,
+			u:     &mockUserer{userErr: fmt.Errorf("some error")},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusInternalServerError,
+		},
+		"mfa_fails": {
+			ml:    enabled,
+			m:     &mockMFAer{totpErr: fmt.Errorf("some error")},
+			u:     &mockUserer{user: &shared.User{Name: "name"}},
+			v:     redeems,
+			token: "token",
+			sc:    http.StatusInternalServerError,
+		},
+		"login_fails": {
+			ml: enabled,
+			m:  nomfa,
+			u:  &mockUserer{user: &shared.User{Name: "name"}},
+			v: &mockValidator{
+				redeemmagic:   "uuid",
+				redeemmagicsc: http.StatusOK,
+				loginsc:       http.StatusInternalServerError,
+			},
+			token: "token",
+			sc:    http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.a == nil {
+				tc.a = &mockAuther{}
+			}
+
+			us := &UserService{
+				Auther:      tc.a,
+				MagicLinker: tc.ml,
+				MFAer:       tc.m,
+				Userer:      tc.u,
+				Validator:   tc.v,
+				success:     "/",
+			}
+
+			w := httptest.NewRecorder()
+			us.GetMagic(w, magicRequest(http.MethodGet, "token", tc.token, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+			if tc.sc == http.StatusFound {
+				require.Equal(t, "/", w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func Test_GetUserMagic(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		ml *mockMagicLinker
+		id shared.UUID
+		sc int
+	}{
+		"happy_path": {
+			ml: &mockMagicLinker{link: &shared.MagicLink{Channel: shared.EmailChannel}},
+			id: "uuid",
+			sc: http.StatusOK,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"not_enabled": {
+			ml: &mockMagicLinker{linkErr: shared.MagicLinkNotEnabledError},
+			id: "uuid",
+			sc: http.StatusNotFound,
+		},
+		"db_error": {
+			ml: &mockMagicLinker{linkErr: fmt.Errorf("some error")},
+			id: "uuid",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MagicLinker: tc.ml}
+
+			w := httptest.NewRecorder()
+			us.GetUserMagic(w, totpRequest(http.MethodGet, tc.id, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_PostUserMagic(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		ml   *mockMagicLinker
+		u    *mockUserer
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			ml:   &mockMagicLinker{},
+			u:    &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"bad_body": {
+			id:   "uuid",
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"unknown_channel": {
+			id:   "uuid",
+			body: `{"channel":"pigeon"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"unreachable": {
+			u:    &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"unverified": {
+			u:    &mockUserer{user: &shared.User{Cell: &testCell}},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"enable_fails": {
+			ml:   &mockMagicLinker{enableErr: fmt.Errorf("some error")},
+			u:    &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			id:   "uuid",
+			body: `{"channel":"email"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MagicLinker: tc.ml, Userer: tc.u}
+
+			w := httptest.NewRecorder()
+			us.PostUserMagic(w, totpRequest(http.MethodPost, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_DeleteUserMagic(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		ml *mockMagicLinker
+		id shared.UUID
+		sc int
+	}{
+		"happy_path": {
+			ml: &mockMagicLinker{},
+			id: "uuid",
+			sc: http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"not_enabled": {
+			ml: &mockMagicLinker{disableErr: shared.MagicLinkNotEnabledError},
+			id: "uuid",
+			sc: http.StatusNotFound,
+		},
+		"db_error": {
+			ml: &mockMagicLinker{disableErr: fmt.Errorf("some error")},
+			id: "uuid",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MagicLinker: tc.ml}
+
+			w := httptest.NewRecorder()
+			us.DeleteUserMagic(w, totpRequest(http.MethodDelete, tc.id, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func magicRequest(method, key, value, body string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams = chi.RouteParams{Keys: []string{key}, Values: []string{value}}
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(
+			mockContext(),
+			chi.RouteCtxKey,
+			rctx),
+		method,
+		"tc.url",
+		io.Reader(bytes.NewReader([]byte(body))),
+	)
+	return r
+}
+
+func (mml *mockMagicLinker) GetMagicLink(context.Context, shared.UUID) (*shared.MagicLink, error) {
+	return mml.link, mml.linkErr
+}
+
+func (mml *mockMagicLinker) EnableMagicLink(context.Context, shared.UUID, shared.Channel) error {
+	return mml.enableErr
+}
+
+func (mml *mockMagicLinker) DisableMagicLink(context.Context, shared.UUID) error {
+	return mml.disableErr
+}
@@ -0,0 +1,281 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/userservice/internal/mfa"
+	valid "github.com/jsmit257/userservice/internal/validation"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+const mfaCookie = "mfa-pending"
+
+// beginMFA is where PostLogin hands off when the password was right but the
+// user has a second factor; no authn cookie until PostMFA says so
+func (us UserService) beginMFA(w http.ResponseWriter, r *http.Request, uid shared.UUID, methods []string) {
+	ctx := r.Context()
+
+	if token, code := us.Validator.BeginMFA(ctx, uid, us.client(r).Remote); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("failed redis mfa"), "failed redis mfa")
+	} else {
+		http.SetCookie(w, &http.Cookie{
+			Name:     mfaCookie,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+		})
+		sc(http.StatusAccepted).success(ctx, w, mustJSON(map[string][]string{"methods": methods}))
+	}
+}
+
+func (us UserService) PostMFA(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var uid shared.UUID
+	var code int
+	if pending, err := r.Cookie(mfaCookie); err != nil {
+		sc(http.StatusUnauthorized).send(ctx, w, shared.MFARequiredError, "missing mfa token")
+	} else if body, err := readMFA(r); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read code")
+	} else if body.Code == "" && body.RecoveryCode == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MFARequiredError, shared.MFARequiredError.Error())
+	} else if uid, code = us.Validator.PendingMFA(ctx, pending.Value); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("no pending mfa"))
+	} else if code, err = us.checkFactor(ctx, pending.Value, uid, body); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if user, err := us.Userer.GetUser(ctx, uid); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if code = us.Validator.EndMFA(ctx, pending.Value); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("failed redis mfa"))
+	} else if cookie, code := us.Validator.Login(ctx, uid, user.Name, valid.MFALogin, us.client(r)); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("failed redis login"))
+	} else {
+		http.SetCookie(w, &http.Cookie{
+			Name:     mfaCookie,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+		})
+		http.SetCookie(w, cookie)
+		us.setRefresh(ctx, w, uid, user.Name)
+		w.Header().Set("Location", us.landing(r))
+		sc(http.StatusMovedPermanently).success(ctx, w)
+	}
+}
+
+// PostTOTP starts an enrollment and hands back the secret, once; replacing a
+// confirmed enrollment takes a code from the old one
+func (us UserService) PostTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	totp := &shared.TOTP{}
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if user, err := us.Userer.GetUser(ctx, id); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else if code, err := us.reauthTOTP(r, id); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if totp.Secret, err = us.totp.Secret(); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if err = us.MFAer.EnrollTOTP(ctx, id, totp.Secret); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		totp.URI = us.totp.URI(user.Name, totp.Secret)
+		sc(http.StatusCreated).success(ctx, w, mustJSON(totp))
+	}
+}
+
+// PatchTOTP confirms an enrollment with the first code the app generates and
+// hands back the first set of recovery codes
+func (us UserService) PatchTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if otp, err := readCode(r); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read code")
+	} else if code, err := us.checkTOTP(ctx, id, otp, false); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if err = us.MFAer.ConfirmTOTP(ctx, id); errors.Is(err, shared.MFANotEnrolledError) {
+		sc(http.StatusConflict).send(ctx, w, shared.MFAConfirmedError, shared.MFAConfirmedError.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		us.replaceRecoveryCodes(w, r, id, http.StatusOK)
+	}
+}
+
+// DeleteTOTP turns the second factor off; a confirmed enrollment takes a code
+func (us UserService) DeleteTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if code, err := us.reauthTOTP(r, id); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if err = us.MFAer.DeleteTOTP(ctx, id); errors.Is(err, shared.MFANotEnrolledError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func (us UserService) GetRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if n, err := us.MFAer.CountRecoveryCodes(ctx, id); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(shared.RecoveryCodes{Remaining: n}))
+	}
+}
+
+// PostRecoveryCodes replaces the whole set; it takes both the password and a
+// code from the authenticator, since a leaked session shouldn't be enough to
+// mint a way around the second factor
+func (us UserService) PostRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if body, err := readMFA(r); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if body.Password == "" || body.Code == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MFARequiredError, "password and code are required")
+	} else if _, err = us.Auther.Login(ctx, &shared.BasicAuth{UUID: id, Pass: body.Password}); err != nil {
+		sc(loginStatus(err)).send(ctx, w, err, err.Error())
+	} else if code, err := us.checkTOTP(ctx, id, body.Code, true); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else {
+		us.replaceRecoveryCodes(w, r, id, http.StatusCreated)
+	}
+}
+
+func (us UserService) replaceRecoveryCodes(w http.ResponseWriter, r *http.Request, uid shared.UUID, code int) {
+	ctx := r.Context()
+
+	if codes, err := mfa.RecoveryCodes(us.recoveryCodes); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if err = us.MFAer.ReplaceRecoveryCodes(ctx, uid, codes); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(code).success(ctx, w, mustJSON(shared.RecoveryCodes{Codes: codes, Remaining: len(codes)}))
+	}
+}
+
+// mfaMethods lists the confirmed second factors a user can finish logging in
+// with; an empty list means they don't have one
+func (us UserService) mfaMethods(ctx context.Context, uid shared.UUID) ([]string, error) {
+	var result []string
+
+	if totp, err := us.MFAer.GetTOTP(ctx, uid); err != nil && !errors.Is(err, shared.MFANotEnrolledError) {
+		return nil, err
+	} else if totp != nil && totp.Confirmed != nil {
+		result = append(result, "totp")
+	}
+
+	if ch, err := us.MFAer.GetMFAChannel(ctx, uid); err != nil && !errors.Is(err, shared.MFANotEnrolledError) {
+		return nil, err
+	} else if ch != nil && ch.Confirmed != nil {
+		result = append(result, string(ch.Channel))
+	}
+
+	return result, nil
+}
+
+// checkFactor takes a recovery code over anything else if the client sent
+// more than one; a code without a method is an authenticator code
+func (us UserService) checkFactor(ctx context.Context, token string, uid shared.UUID, body mfaBody) (int, error) {
+	if body.RecoveryCode == "" && shared.Channel(body.Method).Valid() {
+		return checkCode(us.Validator.CheckMFACode(ctx, token, body.Code))
+	} else if body.RecoveryCode == "" {
+		return us.checkTOTP(ctx, uid, body.Code, true)
+	} else if err := us.MFAer.UseRecoveryCode(ctx, uid, body.RecoveryCode); errors.Is(err, shared.BadMFACodeError) {
+		return http.StatusUnauthorized, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// reauthTOTP passes when there's nothing confirmed to protect, otherwise the
+// request has to carry a good code for the current secret
+func (us UserService) reauthTOTP(r *http.Request, uid shared.UUID) (int, error) {
+	ctx := r.Context()
+
+	if totp, err := us.MFAer.GetTOTP(ctx, uid); errors.Is(err, shared.MFANotEnrolledError) {
+		return http.StatusOK, nil
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	} else if totp.Confirmed == nil {
+		return http.StatusOK, nil
+	} else if otp, err := readCode(r); err != nil {
+		return http.StatusBadRequest, err
+	} else {
+		return us.checkTOTP(ctx, uid, otp, true)
+	}
+}
+
+// checkTOTP verifies otp against the user's secret and spends it, so it
+// can't be used again; `confirmed` says whether the enrollment has to be
+// confirmed already or has to not be
+func (us UserService) checkTOTP(ctx context.Context, uid shared.UUID, otp string, confirmed bool) (int, error) {
+	if totp, err := us.MFAer.GetTOTP(ctx, uid); errors.Is(err, shared.MFANotEnrolledError) {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	} else if totp.Confirmed == nil && confirmed {
+		return http.StatusConflict, shared.MFANotEnrolledError
+	} else if totp.Confirmed != nil && !confirmed {
+		return http.StatusConflict, shared.MFAConfirmedError
+	} else if step, ok, err := us.totp.Verify(totp.Secret, otp, totp.LastStep); err != nil {
+		return http.StatusInternalServerError, err
+	} else if !ok {
+		return http.StatusUnauthorized, shared.BadMFACodeError
+	} else if err = us.MFAer.UseTOTP(ctx, uid, step); errors.Is(err, shared.BadMFACodeError) {
+		return http.StatusUnauthorized, err
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+type mfaBody struct {
+	Method       string          `json:"method,omitempty"` // "totp" (the default), "email" or "sms"
+	Code         string          `json:"code"`
+	RecoveryCode string          `json:"recovery_code"`
+	Password     shared.Password `json:"password"`
+}
+
+func readMFA(r *http.Request) (mfaBody, error) {
+	var body mfaBody
+
+	b, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(b, &body)
+	}
+
+	return body, err
+}
+
+func readCode(r *http.Request) (string, error) {
+	if body, err := readMFA(r); err != nil {
+		return "", err
+	} else if body.Code == "" {
+		return "", shared.MFARequiredError
+	} else {
+		return body.Code, nil
+	}
+}
@@ -0,0 +1,702 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/mfa"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type mockMFAer struct {
+	totp    *shared.TOTP
+	totpErr error
+
+	enrollErr,
+	confirmErr,
+	useTOTPErr,
+	deleteErr error
+
+	channel    *shared.MFAChannel
+	channelErr error
+
+	enrollChannelErr,
+	confirmChannelErr,
+	deleteChannelErr error
+
+	replaceErr error
+	useErr     error
+	count      int
+	countErr   error
+}
+
+var (
+	// the RFC 6238 seed; "287082" is the good code at 59s past the epoch
+	rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	confirmed = time.Unix(0, 0).UTC()
+	testTOTP  = &mfa.TOTP{
+		Issuer: "test",
+		Digits: 6,
+		Period: 30 * time.Second,
+		Now:    func() time.Time { return time.Unix(59, 0).UTC() },
+	}
+)
+
+func Test_PostMFA(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		m       *mockMFAer
+		u       *mockUserer
+		v       *mockValidator
+		pending string
+		body    string
+		sc      int
+	}{
+		"happy_path": {
+			m: &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				endmfasc:     http.StatusOK,
+				login:        &testCookie,
+				loginsc:      http.StatusOK,
+			},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusMovedPermanently,
+		},
+		"replayed_code": {
+			// 287082 is period 1, which was already spent
+			m:       &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed, LastStep: 1}},
+			v:       &mockValidator{pendingmfa: "uuid", pendingmfasc: http.StatusOK},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusUnauthorized,
+		},
+		"raced_code": {
+			m: &mockMFAer{
+				totp:       &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed},
+				useTOTPErr: shared.BadMFACodeError,
+			},
+			v:       &mockValidator{pendingmfa: "uuid", pendingmfasc: http.StatusOK},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusUnauthorized,
+		},
+		"spending_code_fails": {
+			m: &mockMFAer{
+				totp:       &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed},
+				useTOTPErr: fmt.Errorf("some error"),
+			},
+			v:       &mockValidator{pendingmfa: "uuid", pendingmfasc: http.StatusOK},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusInternalServerError,
+		},
+		"recovery_code": {
+			m: &mockMFAer{},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				endmfasc:     http.StatusOK,
+				login:        &testCookie,
+				loginsc:      http.StatusOK,
+			},
+			pending: "pending",
+			body:    `{"recovery_code":"abcde-fghij"}`,
+			sc:      http.StatusMovedPermanently,
+		},
+		"used_recovery_code": {
+			m:       &mockMFAer{useErr: shared.BadMFACodeError},
+			v:       &mockValidator{pendingmfa: "uuid", pendingmfasc: http.StatusOK},
+			pending: "pending",
+			body:    `{"recovery_code":"abcde-fghij"}`,
+			sc:      http.StatusUnauthorized,
+		},
+		"recovery_code_fails": {
+			m:       &mockMFAer{useErr: fmt.Errorf("some error")},
+			v:       &mockValidator{pendingmfa: "uuid", pendingmfasc: http.StatusOK},
+			pending: "pending",
+			body:    `{"recovery_code":"abcde-fghij"}`,
+			sc:      http.StatusInternalServerError,
+		},
+		"emailed_code": {
+			m: &mockMFAer{},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				checkcodesc:  http.StatusOK,
+				endmfasc:     http.StatusOK,
+				login:        &testCookie,
+				loginsc:      http.StatusOK,
+			},
+			pending: "pending",
+			body:    `{"method":"email","code":"123456"}`,
+			sc:      http.StatusMovedPermanently,
+		},
+		"wrong_emailed_code": {
+			m: &mockMFAer{},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				checkcodesc:  http.StatusUnauthorized,
+			},
+			pending: "pending",
+			body:    `{"method":"sms","code":"123456"}`,
+			sc:      http.StatusUnauthorized,
+		},
+		"expired_emailed_code": {
+			m: &mockMFAer{},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				checkcodesc:  http.StatusForbidden,
+			},
+			pending: "pending",
+			body:    `{"method":"email","code":"123456"}`,
+			sc:      http.StatusForbidden,
+		},
+		"missing_cookie": {
+			body: `{"code":"287082"}`,
+			sc:   http.StatusUnauthorized,
+		},
+		"bad_body": {
+			pending: "pending",
+			body:    `{`,
+			sc:      http.StatusBadRequest,
+		},
+		"missing_code": {
+			pending: "pending",
+			body:    `{}`,
+			sc:      http.StatusBadRequest,
+		},
+		"no_pending_mfa": {
+			v:       &mockValidator{pendingmfasc: http.StatusForbidden},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusForbidden,
+		},
+		"bad_code": {
+			m:       &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			v:       &mockValidator{pendingmfa: "uuid", pendingmfasc: http.StatusOK},
+			pending: "pending",
+			body:    `{"code":"123456"}`,
+			sc:      http.StatusUnauthorized,
+		},
+		"not_confirmed": {
+			m:       &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret}},
+			v:       &mockValidator{pendingmfa: "uuid", pendingmfasc: http.StatusOK},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusConflict,
+		},
+		"end_mfa_fails": {
+			m: &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				endmfasc:     http.StatusInternalServerError,
+			},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusInternalServerError,
+		},
+		"login_fails": {
+			m: &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				endmfasc:     http.StatusOK,
+				loginsc:      http.StatusTooManyRequests,
+			},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusTooManyRequests,
+		},
+		"user_lookup_fails": {
+			m: &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			u: &mockUserer{userErr: fmt.Errorf("some error")},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+			},
+			pending: "pending",
+			body:    `{"code":"287082"}`,
+			sc:      http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.u == nil {
+				tc.u = &mockUserer{user: &shared.User{UUID: "uuid", Name: "name"}}
+			}
+
+			us := &UserService{MFAer: tc.m, Userer: tc.u, Validator: tc.v, totp: testTOTP}
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(
+					mockContext(),
+					chi.RouteCtxKey,
+					chi.NewRouteContext()),
+				http.MethodPost,
+				"tc.url",
+				bytes.NewReader([]byte(tc.body)),
+			)
+			if tc.pending != "" {
+				r.AddCookie(&http.Cookie{Name: mfaCookie, Value: tc.pending})
+			}
+
+			us.PostMFA(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			if w.Code == http.StatusMovedPermanently {
+				require.Subset(t, w.Result().Cookies(), []*http.Cookie{&testCookie})
+			}
+		})
+	}
+}
+
+func Test_PostTOTP(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		m    *mockMFAer
+		u    *mockUserer
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			m:  &mockMFAer{totpErr: shared.MFANotEnrolledError},
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Name: "jimbo"}},
+			id: "uuid",
+			sc: http.StatusCreated,
+		},
+		"reenroll_unconfirmed": {
+			m:  &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret}},
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Name: "jimbo"}},
+			id: "uuid",
+			sc: http.StatusCreated,
+		},
+		"reenroll_confirmed": {
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			u:    &mockUserer{user: &shared.User{UUID: "uuid", Name: "jimbo"}},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusCreated,
+		},
+		"reenroll_without_code": {
+			m:  &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Name: "jimbo"}},
+			id: "uuid",
+			sc: http.StatusBadRequest,
+		},
+		"reenroll_bad_code": {
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			u:    &mockUserer{user: &shared.User{UUID: "uuid", Name: "jimbo"}},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusUnauthorized,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"get_user_fails": {
+			u:  &mockUserer{userErr: fmt.Errorf("some error")},
+			id: "uuid",
+			sc: http.StatusBadRequest,
+		},
+		"get_totp_fails": {
+			m:  &mockMFAer{totpErr: fmt.Errorf("some error")},
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Name: "jimbo"}},
+			id: "uuid",
+			sc: http.StatusInternalServerError,
+		},
+		"enroll_fails": {
+			m: &mockMFAer{
+				totpErr:   shared.MFANotEnrolledError,
+				enrollErr: fmt.Errorf("some error"),
+			},
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Name: "jimbo"}},
+			id: "uuid",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MFAer: tc.m, Userer: tc.u, totp: testTOTP}
+
+			w := httptest.NewRecorder()
+			us.PostTOTP(w, totpRequest(http.MethodPost, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			if w.Code == http.StatusCreated {
+				totp := shared.TOTP{}
+				require.Nil(t, json.NewDecoder(w.Body).Decode(&totp))
+				require.Len(t, totp.Secret, 32)
+				require.Contains(t, totp.URI, "otpauth://totp/test:jimbo?")
+			}
+		})
+	}
+}
+
+func Test_PatchTOTP(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		m    *mockMFAer
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret}},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusOK,
+		},
+		"recovery_codes_fail": {
+			m: &mockMFAer{
+				totp:       &shared.TOTP{Secret: rfcSecret},
+				replaceErr: fmt.Errorf("some error"),
+			},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"missing_code": {
+			id:   "uuid",
+			body: `{}`,
+			sc:   http.StatusBadRequest,
+		},
+		"not_enrolled": {
+			m:    &mockMFAer{totpErr: shared.MFANotEnrolledError},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusNotFound,
+		},
+		"already_confirmed": {
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusConflict,
+		},
+		"bad_code": {
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret}},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusUnauthorized,
+		},
+		"confirm_raced": {
+			m: &mockMFAer{
+				totp:       &shared.TOTP{Secret: rfcSecret},
+				confirmErr: shared.MFANotEnrolledError,
+			},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusConflict,
+		},
+		"confirm_fails": {
+			m: &mockMFAer{
+				totp:       &shared.TOTP{Secret: rfcSecret},
+				confirmErr: fmt.Errorf("some error"),
+			},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MFAer: tc.m, totp: testTOTP, recoveryCodes: 10}
+
+			w := httptest.NewRecorder()
+			us.PatchTOTP(w, totpRequest(http.MethodPatch, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			if w.Code == http.StatusOK {
+				codes := shared.RecoveryCodes{}
+				require.Nil(t, json.NewDecoder(w.Body).Decode(&codes))
+				require.Len(t, codes.Codes, 10)
+				require.Equal(t, 10, codes.Remaining)
+			}
+		})
+	}
+}
+
+func Test_DeleteTOTP(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		m    *mockMFAer
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusNoContent,
+		},
+		"unconfirmed": {
+			m:  &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret}},
+			id: "uuid",
+			sc: http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"bad_code": {
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusUnauthorized,
+		},
+		"not_enrolled": {
+			m: &mockMFAer{
+				totpErr:   shared.MFANotEnrolledError,
+				deleteErr: shared.MFANotEnrolledError,
+			},
+			id: "uuid",
+			sc: http.StatusNotFound,
+		},
+		"delete_fails": {
+			m: &mockMFAer{
+				totp:      &shared.TOTP{Secret: rfcSecret},
+				deleteErr: fmt.Errorf("some error"),
+			},
+			id: "uuid",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MFAer: tc.m, totp: testTOTP}
+
+			w := httptest.NewRecorder()
+			us.DeleteTOTP(w, totpRequest(http.MethodDelete, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_GetRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		m        *mockMFAer
+		id       shared.UUID
+		sc       int
+		response string
+	}{
+		"happy_path": {
+			m:        &mockMFAer{count: 3},
+			id:       "uuid",
+			sc:       http.StatusOK,
+			response: `{"remaining":3}`,
+		},
+		"missing_id": {
+			sc:       http.StatusBadRequest,
+			response: "missing uid",
+		},
+		"count_fails": {
+			m:        &mockMFAer{countErr: fmt.Errorf("some error")},
+			id:       "uuid",
+			sc:       http.StatusInternalServerError,
+			response: "some error",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MFAer: tc.m}
+
+			w := httptest.NewRecorder()
+			us.GetRecoveryCodes(w, totpRequest(http.MethodGet, tc.id, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.response, w.Body.String())
+		})
+	}
+}
+
+func Test_PostRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	enrolled := &shared.TOTP{Secret: rfcSecret, Confirmed: &confirmed}
+
+	tcs := map[string]struct {
+		a    *mockAuther
+		m    *mockMFAer
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			a:    &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:    &mockMFAer{totp: enrolled},
+			id:   "uuid",
+			body: `{"password":"snakeoil","code":"287082"}`,
+			sc:   http.StatusCreated,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"bad_body": {
+			id:   "uuid",
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_password": {
+			id:   "uuid",
+			body: `{"code":"287082"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_code": {
+			id:   "uuid",
+			body: `{"password":"snakeoil"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_password": {
+			a:    &mockAuther{loginErr: shared.BadUserOrPassError},
+			id:   "uuid",
+			body: `{"password":"snakeoyl","code":"287082"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_code": {
+			a:    &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:    &mockMFAer{totp: enrolled},
+			id:   "uuid",
+			body: `{"password":"snakeoil","code":"123456"}`,
+			sc:   http.StatusUnauthorized,
+		},
+		"not_confirmed": {
+			a:    &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:    &mockMFAer{totp: &shared.TOTP{Secret: rfcSecret}},
+			id:   "uuid",
+			body: `{"password":"snakeoil","code":"287082"}`,
+			sc:   http.StatusConflict,
+		},
+		"replace_fails": {
+			a:    &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:    &mockMFAer{totp: enrolled, replaceErr: fmt.Errorf("some error")},
+			id:   "uuid",
+			body: `{"password":"snakeoil","code":"287082"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Auther: tc.a, MFAer: tc.m, totp: testTOTP, recoveryCodes: 4}
+
+			w := httptest.NewRecorder()
+			us.PostRecoveryCodes(w, totpRequest(http.MethodPost, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			if w.Code == http.StatusCreated {
+				codes := shared.RecoveryCodes{}
+				require.Nil(t, json.NewDecoder(w.Body).Decode(&codes))
+				require.Len(t, codes.Codes, 4)
+			}
+		})
+	}
+}
+
+func totpRequest(method string, id shared.UUID, body string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams = chi.RouteParams{Keys: []string{"user_id"}, Values: []string{string(id)}}
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(
+			mockContext(),
+			chi.RouteCtxKey,
+			rctx),
+		method,
+		"tc.url",
+		io.Reader(bytes.NewReader([]byte(body))),
+	)
+	return r
+}
+
+func (mm *mockMFAer) GetTOTP(context.Context, shared.UUID) (*shared.TOTP, error) {
+	return mm.totp, mm.totpErr
+}
+func (mm *mockMFAer) EnrollTOTP(context.Context, shared.UUID, string) error {
+	return mm.enrollErr
+}
+func (mm *mockMFAer) ConfirmTOTP(context.Context, shared.UUID) error {
+	return mm.confirmErr
+}
+func (mm *mockMFAer) DeleteTOTP(context.Context, shared.UUID) error {
+	return mm.deleteErr
+}
+func (mm *mockMFAer) UseTOTP(context.Context, shared.UUID, int64) error {
+	return mm.useTOTPErr
+}
+func (mm *mockMFAer) GetMFAChannel(context.Context, shared.UUID) (*shared.MFAChannel, error) {
+	return mm.channel, mm.channelErr
+}
+func (mm *mockMFAer) EnrollMFAChannel(context.Context, shared.UUID, shared.Channel) error {
+	return mm.enrollChannelErr
+}
+func (mm *mockMFAer) ConfirmMFAChannel(context.Context, shared.UUID) error {
+	return mm.confirmChannelErr
+}
+func (mm *mockMFAer) DeleteMFAChannel(context.Context, shared.UUID) error {
+	return mm.deleteChannelErr
+}
+func (mm *mockMFAer) ReplaceRecoveryCodes(context.Context, shared.UUID, []string) error {
+	return mm.replaceErr
+}
+func (mm *mockMFAer) UseRecoveryCode(context.Context, shared.UUID, string) error {
+	return mm.useErr
+}
+func (mm *mockMFAer) CountRecoveryCodes(context.Context, shared.UUID) (int, error) {
+	return mm.count, mm.countErr
+}
@@ -0,0 +1,164 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// PostMFACode sends a code to the user's confirmed channel for a pending
+// login; it counts against the pending login's attempts, same as guessing
+func (us UserService) PostMFACode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var uid shared.UUID
+	var code int
+	if pending, err := r.Cookie(mfaCookie); err != nil {
+		sc(http.StatusUnauthorized).send(ctx, w, shared.MFARequiredError, "missing mfa token")
+	} else if uid, code = us.Validator.PendingMFA(ctx, pending.Value); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("no pending mfa"))
+	} else if ch, err := us.MFAer.GetMFAChannel(ctx, uid); errors.Is(err, shared.MFANotEnrolledError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if ch.Confirmed == nil {
+		sc(http.StatusNotFound).send(ctx, w, shared.MFANotEnrolledError, shared.MFANotEnrolledError.Error())
+	} else if user, code, err := us.reachable(r, uid, ch.Channel); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if code, err = us.sendMFACode(r, pending.Value, user, ch.Channel); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// PostOTP picks the channel codes get sent to and sends the first one; a
+// confirmed channel has to be deleted before it can be replaced
+func (us UserService) PostOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var body struct{ Channel shared.Channel }
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if b, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(b, &body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if !body.Channel.Valid() {
+		sc(http.StatusBadRequest).send(ctx, w, fmt.Errorf("unknown channel: %q", body.Channel), "channel must be email or sms")
+	} else if ch, err := us.MFAer.GetMFAChannel(ctx, id); err != nil && !errors.Is(err, shared.MFANotEnrolledError) {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if ch != nil && ch.Confirmed != nil {
+		sc(http.StatusConflict).send(ctx, w, shared.MFAConfirmedError, shared.MFAConfirmedError.Error())
+	} else if user, code, err := us.reachable(r, id, body.Channel); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if err = us.MFAer.EnrollMFAChannel(ctx, id, body.Channel); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if code, err := us.sendMFACode(r, enrollKey(id), user, body.Channel); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusAccepted).success(ctx, w)
+	}
+}
+
+// PatchOTP confirms the channel with the code PostOTP sent; a user who didn't
+// already have recovery codes from an authenticator gets their first set
+func (us UserService) PatchOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if otp, err := readCode(r); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read code")
+	} else if code, err := checkCode(us.Validator.CheckMFACode(ctx, enrollKey(id), otp)); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if err = us.MFAer.ConfirmMFAChannel(ctx, id); errors.Is(err, shared.MFANotEnrolledError) {
+		sc(http.StatusConflict).send(ctx, w, shared.MFAConfirmedError, shared.MFAConfirmedError.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if n, err := us.MFAer.CountRecoveryCodes(ctx, id); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if n == 0 {
+		us.replaceRecoveryCodes(w, r, id, http.StatusOK)
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// DeleteOTP turns emailed/texted codes off; it takes the password, since a
+// session on its own shouldn't be enough to drop a second factor
+func (us UserService) DeleteOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if body, err := readMFA(r); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if body.Password == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "password is required")
+	} else if _, err = us.Auther.Login(ctx, &shared.BasicAuth{UUID: id, Pass: body.Password}); err != nil {
+		sc(loginStatus(err)).send(ctx, w, err, err.Error())
+	} else if err = us.MFAer.DeleteMFAChannel(ctx, id); errors.Is(err, shared.MFANotEnrolledError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// reachable looks the user up now, rather than trusting whatever address was
+// around at enrollment, so codes follow changes to their email or cell; only
+// verified addresses count, a code or a link is as good as a password to
+// whoever gets it
+func (us UserService) reachable(r *http.Request, uid shared.UUID, ch shared.Channel) (*shared.User, int, error) {
+	if user, err := us.Userer.GetUser(r.Context(), uid); err != nil {
+		return nil, http.StatusInternalServerError, err
+	} else if user = user.Verified(); !user.Reachable(ch) {
+		return nil, http.StatusBadRequest, shared.Undeliverable
+	} else {
+		return user, http.StatusOK, nil
+	}
+}
+
+// sendMFACode gets a fresh code from redis under key and sends it wherever ch
+// says
+func (us UserService) sendMFACode(r *http.Request, key string, user *shared.User, ch shared.Channel) (int, error) {
+	otp, code := us.Validator.SendMFACode(r.Context(), key)
+	if code != http.StatusOK {
+		return code, fmt.Errorf("couldn't generate code")
+	}
+
+	var err error
+	if ch == shared.SMSChannel {
+		err = us.SmsSender.Send(user.MFACodeSMS(otp))
+	} else {
+		err = us.MailSender.Send(user.MFACodeEmail(otp))
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// checkCode turns CheckMFACode's status into the error the handlers send
+func checkCode(code int) (int, error) {
+	switch code {
+	case http.StatusOK:
+		return code, nil
+	case http.StatusUnauthorized:
+		return code, shared.BadMFACodeError
+	}
+	return code, fmt.Errorf("couldn't check code")
+}
+
+func enrollKey(uid shared.UUID) string {
+	return "enroll:" + string(uid)
+}
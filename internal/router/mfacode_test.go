@@ -0,0 +1,434 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+var (
+	testEmail = shared.Email("user@example.com")
+	testCell  = shared.Cell("+15555555555")
+)
+
+func Test_PostMFACode(t *testing.T) {
+	t.Parallel()
+
+	emailed := &shared.MFAChannel{Channel: shared.EmailChannel, Confirmed: &confirmed}
+	texted := &shared.MFAChannel{Channel: shared.SMSChannel, Confirmed: &confirmed}
+	pending := &mockValidator{
+		pendingmfa:   "uuid",
+		pendingmfasc: http.StatusOK,
+		sendcode:     "123456",
+		sendcodesc:   http.StatusOK,
+	}
+
+	tcs := map[string]struct {
+		m       *mockMFAer
+		u       *mockUserer
+		v       *mockValidator
+		ms      mockMailSender
+		ss      mockSmsSender
+		pending string
+		sc      int
+		mails   int
+		texts   int
+	}{
+		"email": {
+			m:       &mockMFAer{channel: emailed},
+			u:       &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusNoContent,
+			mails:   1,
+		},
+		"sms": {
+			m:       &mockMFAer{channel: texted},
+			u:       &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusNoContent,
+			texts:   1,
+		},
+		"missing_cookie": {
+			sc: http.StatusUnauthorized,
+		},
+		"no_pending_mfa": {
+			v:       &mockValidator{pendingmfasc: http.StatusForbidden},
+			pending: "pending",
+			sc:      http.StatusForbidden,
+		},
+		"not_enrolled": {
+			m:       &mockMFAer{channelErr: shared.MFANotEnrolledError},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusNotFound,
+		},
+		"unconfirmed": {
+			m:       &mockMFAer{channel: &shared.MFAChannel{Channel: shared.EmailChannel}},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusNotFound,
+		},
+		"channel_lookup_fails": {
+			m:       &mockMFAer{channelErr: fmt.Errorf("some error")},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusInternalServerError,
+		},
+		"user_lookup_fails": {
+			m:       &mockMFAer{channel: emailed},
+			u:       &mockUserer{userErr: fmt.Errorf("some error")},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusInternalServerError,
+		},
+		"unreachable": {
+			m:       &mockMFAer{channel: texted},
+			u:       &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusBadRequest,
+		},
+		"unverified": {
+			m:       &mockMFAer{channel: emailed},
+			u:       &mockUserer{user: &shared.User{Email: &testEmail}},
+			v:       pending,
+			pending: "pending",
+			sc:      http.StatusBadRequest,
+		},
+		"too_soon": {
+			m: &mockMFAer{channel: emailed},
+			u: &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			v: &mockValidator{
+				pendingmfa:   "uuid",
+				pendingmfasc: http.StatusOK,
+				sendcodesc:   http.StatusTooManyRequests,
+			},
+			pending: "pending",
+			sc:      http.StatusTooManyRequests,
+		},
+		"send_fails": {
+			m:       &mockMFAer{channel: emailed},
+			u:       &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			v:       pending,
+			ms:      mockMailSender{err: fmt.Errorf("some error")},
+			pending: "pending",
+			sc:      http.StatusInternalServerError,
+			mails:   1,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{
+				MFAer:      tc.m,
+				Userer:     tc.u,
+				Validator:  tc.v,
+				MailSender: &tc.ms,
+				SmsSender:  &tc.ss,
+			}
+
+			w := httptest.NewRecorder()
+			r := totpRequest(http.MethodPost, "", "")
+			if tc.pending != "" {
+				r.AddCookie(&http.Cookie{Name: mfaCookie, Value: tc.pending})
+			}
+
+			us.PostMFACode(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.mails, tc.ms.msgs)
+			require.Equal(t, tc.texts, tc.ss.msgs)
+		})
+	}
+}
+
+func Test_PostOTP(t *testing.T) {
+	t.Parallel()
+
+	sends := &mockValidator{sendcode: "123456", sendcodesc: http.StatusOK}
+
+	tcs := map[string]struct {
+		m     *mockMFAer
+		u     *mockUserer
+		v     *mockValidator
+		ss    mockSmsSender
+		id    shared.UUID
+		body  string
+		sc    int
+		texts int
+	}{
+		"happy_path": {
+			m:     &mockMFAer{channelErr: shared.MFANotEnrolledError},
+			u:     &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			v:     sends,
+			id:    "uuid",
+			body:  `{"channel":"sms"}`,
+			sc:    http.StatusAccepted,
+			texts: 1,
+		},
+		"replaces_unconfirmed": {
+			m:     &mockMFAer{channel: &shared.MFAChannel{Channel: shared.EmailChannel}},
+			u:     &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			v:     sends,
+			id:    "uuid",
+			body:  `{"channel":"sms"}`,
+			sc:    http.StatusAccepted,
+			texts: 1,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"bad_body": {
+			id:   "uuid",
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"unknown_channel": {
+			id:   "uuid",
+			body: `{"channel":"pigeon"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"lookup_fails": {
+			m:    &mockMFAer{channelErr: fmt.Errorf("some error")},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"already_confirmed": {
+			m:    &mockMFAer{channel: &shared.MFAChannel{Channel: shared.EmailChannel, Confirmed: &confirmed}},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusConflict,
+		},
+		"unreachable": {
+			m:    &mockMFAer{channelErr: shared.MFANotEnrolledError},
+			u:    &mockUserer{user: &shared.User{Email: &testEmail, EmailVerified: &confirmed}},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"unverified": {
+			m:    &mockMFAer{channelErr: shared.MFANotEnrolledError},
+			u:    &mockUserer{user: &shared.User{Cell: &testCell}},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"enroll_fails": {
+			m: &mockMFAer{
+				channelErr:       shared.MFANotEnrolledError,
+				enrollChannelErr: fmt.Errorf("some error"),
+			},
+			u:    &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"too_soon": {
+			m:    &mockMFAer{channelErr: shared.MFANotEnrolledError},
+			u:    &mockUserer{user: &shared.User{Cell: &testCell, CellVerified: &confirmed}},
+			v:    &mockValidator{sendcodesc: http.StatusTooManyRequests},
+			id:   "uuid",
+			body: `{"channel":"sms"}`,
+			sc:   http.StatusTooManyRequests,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{
+				MFAer:      tc.m,
+				Userer:     tc.u,
+				Validator:  tc.v,
+				MailSender: &mockMailSender{},
+				SmsSender:  &tc.ss,
+			}
+
+			w := httptest.NewRecorder()
+			us.PostOTP(w, totpRequest(http.MethodPost, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.texts, tc.ss.msgs)
+		})
+	}
+}
+
+func Test_PatchOTP(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		m     *mockMFAer
+		v     *mockValidator
+		id    shared.UUID
+		body  string
+		sc    int
+		codes int
+	}{
+		"first_factor": {
+			m:     &mockMFAer{},
+			v:     &mockValidator{checkcodesc: http.StatusOK},
+			id:    "uuid",
+			body:  `{"code":"123456"}`,
+			sc:    http.StatusOK,
+			codes: 10,
+		},
+		"keeps_recovery_codes": {
+			m:    &mockMFAer{count: 7},
+			v:    &mockValidator{checkcodesc: http.StatusOK},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"missing_code": {
+			id:   "uuid",
+			body: `{}`,
+			sc:   http.StatusBadRequest,
+		},
+		"wrong_code": {
+			v:    &mockValidator{checkcodesc: http.StatusUnauthorized},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusUnauthorized,
+		},
+		"nothing_sent": {
+			v:    &mockValidator{checkcodesc: http.StatusForbidden},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusForbidden,
+		},
+		"already_confirmed": {
+			m:    &mockMFAer{confirmChannelErr: shared.MFANotEnrolledError},
+			v:    &mockValidator{checkcodesc: http.StatusOK},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusConflict,
+		},
+		"confirm_fails": {
+			m:    &mockMFAer{confirmChannelErr: fmt.Errorf("some error")},
+			v:    &mockValidator{checkcodesc: http.StatusOK},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"count_fails": {
+			m:    &mockMFAer{countErr: fmt.Errorf("some error")},
+			v:    &mockValidator{checkcodesc: http.StatusOK},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{MFAer: tc.m, Validator: tc.v, recoveryCodes: 10}
+
+			w := httptest.NewRecorder()
+			us.PatchOTP(w, totpRequest(http.MethodPatch, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			if w.Code == http.StatusOK {
+				codes := shared.RecoveryCodes{}
+				require.Nil(t, json.NewDecoder(w.Body).Decode(&codes))
+				require.Len(t, codes.Codes, tc.codes)
+			}
+		})
+	}
+}
+
+func Test_DeleteOTP(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a    *mockAuther
+		m    *mockMFAer
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			a:    &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:    &mockMFAer{},
+			id:   "uuid",
+			body: `{"password":"snakeoil"}`,
+			sc:   http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"bad_body": {
+			id:   "uuid",
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_password": {
+			id:   "uuid",
+			body: `{}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_password": {
+			a:    &mockAuther{loginErr: shared.BadUserOrPassError},
+			id:   "uuid",
+			body: `{"password":"snakeoyl"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"locked": {
+			a:    &mockAuther{loginErr: shared.MaxFailedLoginError},
+			id:   "uuid",
+			body: `{"password":"snakeoil"}`,
+			sc:   http.StatusLocked,
+		},
+		"not_enrolled": {
+			a:    &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:    &mockMFAer{deleteChannelErr: shared.MFANotEnrolledError},
+			id:   "uuid",
+			body: `{"password":"snakeoil"}`,
+			sc:   http.StatusNotFound,
+		},
+		"delete_fails": {
+			a:    &mockAuther{login: &shared.BasicAuth{UUID: "uuid"}},
+			m:    &mockMFAer{deleteChannelErr: fmt.Errorf("some error")},
+			id:   "uuid",
+			body: `{"password":"snakeoil"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Auther: tc.a, MFAer: tc.m}
+
+			w := httptest.NewRecorder()
+			us.DeleteOTP(w, totpRequest(http.MethodDelete, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
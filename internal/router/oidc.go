@@ -0,0 +1,310 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/userservice/internal/oidc"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func (us UserService) GetDiscovery(w http.ResponseWriter, r *http.Request) {
+	sc(http.StatusOK).success(r.Context(), w, mustJSON(us.discovery))
+}
+
+func (us UserService) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	sc(http.StatusOK).success(r.Context(), w, mustJSON(us.Keys.JWKS()))
+}
+
+// GetAuthorize is the front door for relying parties; anyone without a
+// session gets sent to the login page, which sends them back here when
+// they're done. There's no consent screen: every client is one of ours
+func (us UserService) GetAuthorize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	redirect := q.Get("redirect_uri")
+	if client, err := us.Clienter.GetClient(ctx, q.Get("client_id")); errors.Is(err, shared.ClientNotFoundError) {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if !client.AllowsRedirect(redirect) {
+		// anything wrong before here can't go back to the client
+		sc(http.StatusBadRequest).send(ctx, w, fmt.Errorf("unregistered redirect_uri: %q", redirect), "unregistered redirect_uri")
+	} else if q.Get("response_type") != "code" {
+		authzError(ctx, w, r, redirect, "unsupported_response_type", "only the code flow is supported")
+	} else if !oidc.HasScope(q.Get("scope"), "openid") {
+		authzError(ctx, w, r, redirect, "invalid_scope", "scope has to include openid")
+	} else if q.Get("code_challenge_method") != "S256" || !oidc.ValidChallenge(q.Get("code_challenge")) {
+		authzError(ctx, w, r, redirect, "invalid_request", "an S256 code_challenge is required")
+	} else if cookie, err := r.Cookie("us-authn"); err != nil {
+		us.toLogin(w, r)
+	} else if refreshed, code := us.Validator.Valid(ctx, cookie.Value, us.client(r)); code == http.StatusInternalServerError {
+		authzError(ctx, w, r, redirect, "server_error", "couldn't look up the session")
+	} else if http.SetCookie(w, refreshed); code != http.StatusNoContent {
+		us.toLogin(w, r) // expired, too old or bound to somebody else, same as authenticate
+	} else if uid, code := us.Validator.Session(ctx, cookie.Value); code == http.StatusUnauthorized {
+		us.toLogin(w, r)
+	} else if code != http.StatusOK {
+		authzError(ctx, w, r, redirect, "server_error", "couldn't look up the session")
+	} else if authz, code := us.Validator.BeginAuthCode(ctx, &shared.AuthCode{
+		ClientID:    client.ID,
+		UserID:      uid,
+		RedirectURI: redirect,
+		Scope:       q.Get("scope"),
+		Nonce:       q.Get("nonce"),
+		Challenge:   q.Get("code_challenge"),
+	}); code != http.StatusOK {
+		authzError(ctx, w, r, redirect, "server_error", "couldn't issue a code")
+	} else {
+		authzRedirect(ctx, w, r, redirect, url.Values{"code": {authz}})
+	}
+}
+
+// PostToken trades an authorization code for an id token and an access token
+func (us UserService) PostToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	var ac *shared.AuthCode
+	if err := r.ParseForm(); err != nil {
+		oauthError(ctx, w, http.StatusBadRequest, err, "invalid_request")
+	} else if gt := r.PostForm.Get("grant_type"); gt != "authorization_code" {
+		oauthError(ctx, w, http.StatusBadRequest, fmt.Errorf("unsupported grant_type: %q", gt), "unsupported_grant_type")
+	} else if client, code, err := us.tokenClient(r); err != nil && code == http.StatusUnauthorized {
+		oauthError(ctx, w, code, err, "invalid_client")
+	} else if err != nil {
+		oauthError(ctx, w, code, err, "server_error")
+	} else if ac, code = us.Validator.RedeemAuthCode(ctx, r.PostForm.Get("code")); code == http.StatusInternalServerError {
+		oauthError(ctx, w, code, fmt.Errorf("couldn't redeem code"), "server_error")
+	} else if code != http.StatusOK {
+		oauthError(ctx, w, http.StatusBadRequest, fmt.Errorf("unknown, expired or used code"), "invalid_grant")
+	} else if ac.ClientID != client.ID || ac.RedirectURI != r.PostForm.Get("redirect_uri") {
+		oauthError(ctx, w, http.StatusBadRequest, fmt.Errorf("code was issued for another client or redirect_uri"), "invalid_grant")
+	} else if !oidc.VerifyPKCE(ac.Challenge, r.PostForm.Get("code_verifier")) {
+		oauthError(ctx, w, http.StatusBadRequest, fmt.Errorf("code_verifier doesn't match"), "invalid_grant")
+	} else if user, err := us.Userer.GetUser(ctx, ac.UserID); err != nil {
+		oauthError(ctx, w, http.StatusInternalServerError, err, "server_error")
+	} else if tokens, err := us.issueTokens(client, ac, user); err != nil {
+		oauthError(ctx, w, http.StatusInternalServerError, err, "server_error")
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(tokens))
+	}
+}
+
+func (us UserService) GetUserInfo(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	claims := &oidc.AccessClaims{}
+	if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "Bearer ") {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sc(http.StatusUnauthorized).send(ctx, w, shared.MissingAuthToken)
+	} else if err := us.Keys.Parse(strings.TrimPrefix(auth, "Bearer "), claims); err != nil {
+		invalidToken(ctx, w, err)
+	} else if claims.TokenUse != oidc.AccessTokenUse || !claims.VerifyAudience(us.discovery.Issuer, true) || claims.ClientID == "" {
+		invalidToken(ctx, w, oidc.BadTokenError) // an id token or a verification link, most likely
+	} else if _, err = us.Clienter.GetClient(ctx, claims.ClientID); errors.Is(err, shared.ClientNotFoundError) {
+		invalidToken(ctx, w, err) // the client was deleted since
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if user, err := us.Userer.GetUser(ctx, shared.UUID(claims.Subject)); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(oidc.UserInfo{
+			Subject: user.UUID,
+			Profile: oidc.NewProfile(user, claims.Scope),
+		}))
+	}
+}
+
+// PostClient registers a relying party; a confidential client's secret is in
+// the response and nowhere else, ever
+func (us UserService) PostClient(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	client := &shared.OAuthClient{}
+	if body, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(body, client); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if err = checkClient(client); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else if client.Secret = ""; !client.Public && func() bool {
+		client.Secret, err = oidc.NewSecret()
+		return err != nil
+	}() {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if client.ID, err = us.Clienter.AddClient(ctx, client); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		w.Header().Set("Location", fmt.Sprintf("/oauth/client/%s", client.ID))
+		sc(http.StatusCreated).success(ctx, w, mustJSON(client))
+	}
+}
+
+func (us UserService) GetClient(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := chi.URLParam(r, "client_id"); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing client_id")
+	} else if client, err := us.Clienter.GetClient(ctx, id); errors.Is(err, shared.ClientNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(client))
+	}
+}
+
+func (us UserService) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := chi.URLParam(r, "client_id"); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing client_id")
+	} else if err := us.Clienter.DeleteClient(ctx, id); errors.Is(err, shared.ClientNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// tokenClient authenticates the client with either basic auth or form fields;
+// a public client only has to say who it is, PKCE does the rest
+func (us UserService) tokenClient(r *http.Request) (*shared.OAuthClient, int, error) {
+	ctx := r.Context()
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	var client *shared.OAuthClient
+	var err error
+	if secret != "" {
+		client, err = us.Clienter.CheckClient(ctx, id, secret)
+	} else if client, err = us.Clienter.GetClient(ctx, id); err == nil && !client.Public {
+		err = shared.BadClientError
+	}
+
+	if errors.Is(err, shared.ClientNotFoundError) || errors.Is(err, shared.BadClientError) {
+		return nil, http.StatusUnauthorized, err
+	} else if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return client, http.StatusOK, nil
+}
+
+func (us UserService) issueTokens(client *shared.OAuthClient, ac *shared.AuthCode, user *shared.User) (*oidc.Tokens, error) {
+	now := time.Now().UTC()
+	std := jwt.StandardClaims{
+		Issuer:    us.discovery.Issuer,
+		Subject:   string(user.UUID),
+		Audience:  client.ID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(us.tokenTimeout).Unix(),
+	}
+
+	id, err := us.Keys.Sign(&oidc.IDClaims{
+		StandardClaims: std,
+		Nonce:          ac.Nonce,
+		Profile:        oidc.NewProfile(user, ac.Scope),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	std.Audience = us.discovery.Issuer // only userinfo takes these, for now
+	std.Id = uuid.NewString()
+	access, err := us.Keys.Sign(&oidc.AccessClaims{
+		StandardClaims: std,
+		TokenUse:       oidc.AccessTokenUse,
+		Scope:          ac.Scope,
+		ClientID:       client.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidc.Tokens{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(us.tokenTimeout.Seconds()),
+		IDToken:     id,
+		Scope:       ac.Scope,
+	}, nil
+}
+
+func invalidToken(ctx context.Context, w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	sc(http.StatusUnauthorized).send(ctx, w, err)
+}
+
+// toLogin sends the browser to the login page with a way back here
+func (us UserService) toLogin(w http.ResponseWriter, r *http.Request) {
+	u, _ := url.Parse(us.logon) // it's config, it parses
+	q := u.Query()
+	q.Set("redirect", r.URL.RequestURI())
+	u.RawQuery = q.Encode()
+
+	w.Header().Set("Location", u.String())
+	sc(http.StatusFound).success(r.Context(), w)
+}
+
+func checkClient(c *shared.OAuthClient) error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	} else if len(c.RedirectURIs) == 0 {
+		return fmt.Errorf("at least one redirect_uri is required")
+	}
+	for _, uri := range c.RedirectURIs {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(uri, " \t\n") {
+			return fmt.Errorf("bad redirect_uri: %q", uri)
+		}
+	}
+	return nil
+}
+
+// authzRedirect sends the browser back to the client with whatever params,
+// plus the client's state
+func authzRedirect(ctx context.Context, w http.ResponseWriter, r *http.Request, redirect string, params url.Values) {
+	u, _ := url.Parse(redirect) // already matched a registered uri
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state := r.URL.Query().Get("state"); state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+
+	w.Header().Set("Location", u.String())
+	sc(http.StatusFound).success(ctx, w)
+}
+
+func authzError(ctx context.Context, w http.ResponseWriter, r *http.Request, redirect, kind, desc string) {
+	authzRedirect(ctx, w, r, redirect, url.Values{"error": {kind}, "error_description": {desc}})
+}
+
+func oauthError(ctx context.Context, w http.ResponseWriter, code int, err error, kind string) {
+	w.Header().Set("Content-Type", "application/json")
+	sc(code).send(ctx, w, err, mustJSON(map[string]string{
+		"error":             kind,
+		"error_description": err.Error(),
+	}))
+}
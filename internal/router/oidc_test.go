@@ -0,0 +1,798 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/internal/oidc"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type mockClienter struct {
+	client    *shared.OAuthClient
+	clientErr error
+	addID     string
+	addErr    error
+	check     *shared.OAuthClient
+	checkErr  error
+	deleteErr error
+}
+
+const (
+	testIssuer    = "https://id.example.com"
+	testRedirect  = "https://rp.example.com/cb"
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk" // rfc 7636, appendix b
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+var (
+	testKeys = func() *oidc.Keys {
+		k, err := oidc.NewKeys(&config.Config{OIDCIssuer: testIssuer}, logrus.WithField("app", "test"))
+		if err != nil {
+			panic(err)
+		}
+		return k
+	}()
+
+	testVerifyKey = func() *oidc.VerifyKey {
+		f, err := os.CreateTemp("", "verify")
+		if err != nil {
+			panic(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err = f.WriteString("0123456789abcdef0123456789abcdef"); err != nil {
+			panic(err)
+		}
+		f.Close()
+
+		k, err := oidc.NewVerifyKey(
+			&config.Config{OIDCIssuer: testIssuer, VerifyKeyFile: f.Name()},
+			logrus.WithField("app", "test"))
+		if err != nil {
+			panic(err)
+		}
+		return k
+	}()
+
+	confidential = &shared.OAuthClient{ID: "rp", Name: "rp", RedirectURIs: []string{testRedirect}}
+	public       = &shared.OAuthClient{ID: "spa", Name: "spa", RedirectURIs: []string{testRedirect}, Public: true}
+)
+
+func Test_GetDiscovery(t *testing.T) {
+	t.Parallel()
+
+	us := &UserService{discovery: oidc.NewDiscovery(testIssuer + "/")}
+
+	w := httptest.NewRecorder()
+	us.GetDiscovery(w, totpRequest(http.MethodGet, "", ""))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	d := oidc.Discovery{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &d))
+	require.Equal(t, testIssuer, d.Issuer)
+	require.Equal(t, testIssuer+"/oauth/token", d.TokenEndpoint)
+}
+
+func Test_GetJWKS(t *testing.T) {
+	t.Parallel()
+
+	us := &UserService{Keys: testKeys}
+
+	w := httptest.NewRecorder()
+	us.GetJWKS(w, totpRequest(http.MethodGet, "", ""))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	jwks := oidc.JWKS{}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	require.Len(t, jwks.Keys, 1)
+	require.Equal(t, testKeys.ID, jwks.Keys[0].Kid)
+}
+
+func Test_GetAuthorize(t *testing.T) {
+	t.Parallel()
+
+	good := url.Values{
+		"client_id":             {"rp"},
+		"redirect_uri":          {testRedirect},
+		"response_type":         {"code"},
+		"scope":                 {"openid profile"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6"},
+		"code_challenge":        {testChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	with := func(k, v string) url.Values {
+		result := url.Values{}
+		for key, vals := range good {
+			result[key] = vals
+		}
+		result.Set(k, v)
+		return result
+	}
+	signedIn := &mockValidator{
+		validsc:    http.StatusNoContent,
+		session:    "uuid",
+		sessionsc:  http.StatusOK,
+		authcode:   "code",
+		authcodesc: http.StatusOK,
+	}
+
+	tcs := map[string]struct {
+		c      *mockClienter
+		v      *mockValidator
+		q      url.Values
+		cookie bool
+		sc     int
+		loc    string
+	}{
+		"happy_path": {
+			c:      &mockClienter{client: confidential},
+			v:      signedIn,
+			q:      good,
+			cookie: true,
+			sc:     http.StatusFound,
+			loc:    testRedirect + "?code=code&state=xyz",
+		},
+		"unknown_client": {
+			c:  &mockClienter{clientErr: shared.ClientNotFoundError},
+			q:  good,
+			sc: http.StatusBadRequest,
+		},
+		"client_lookup_fails": {
+			c:  &mockClienter{clientErr: fmt.Errorf("some error")},
+			q:  good,
+			sc: http.StatusInternalServerError,
+		},
+		"unregistered_redirect": {
+			c:  &mockClienter{client: confidential},
+			q:  with("redirect_uri", "https://evil.example.com/cb"),
+			sc: http.StatusBadRequest,
+		},
+		"implicit_flow": {
+			c:   &mockClienter{client: confidential},
+			q:   with("response_type", "token"),
+			sc:  http.StatusFound,
+			loc: testRedirect + "?error=unsupported_response_type&error_description=only+the+code+flow+is+supported&state=xyz",
+		},
+		"no_openid_scope": {
+			c:   &mockClienter{client: confidential},
+			q:   with("scope", "profile"),
+			sc:  http.StatusFound,
+			loc: testRedirect + "?error=invalid_scope&error_description=scope+has+to+include+openid&state=xyz",
+		},
+		"plain_challenge": {
+			c:   &mockClienter{client: confidential},
+			q:   with("code_challenge_method", "plain"),
+			sc:  http.StatusFound,
+			loc: testRedirect + "?error=invalid_request&error_description=an+S256+code_challenge+is+required&state=xyz",
+		},
+		"missing_challenge": {
+			c:   &mockClienter{client: confidential},
+			q:   with("code_challenge", ""),
+			sc:  http.StatusFound,
+			loc: testRedirect + "?error=invalid_request&error_description=an+S256+code_challenge+is+required&state=xyz",
+		},
+		"not_logged_in": {
+			c:   &mockClienter{client: confidential},
+			q:   good,
+			sc:  http.StatusFound,
+			loc: "/login?redirect=%2Foauth%2Fauthorize%3F" + url.QueryEscape(good.Encode()),
+		},
+		"invalid_session": {
+			c:      &mockClienter{client: confidential},
+			v:      &mockValidator{validsc: http.StatusTemporaryRedirect},
+			q:      good,
+			cookie: true,
+			sc:     http.StatusFound,
+			loc:    "/login?redirect=%2Foauth%2Fauthorize%3F" + url.QueryEscape(good.Encode()),
+		},
+		"valid_fails": {
+			c:      &mockClienter{client: confidential},
+			v:      &mockValidator{validsc: http.StatusInternalServerError},
+			q:      good,
+			cookie: true,
+			sc:     http.StatusFound,
+			loc:    testRedirect + "?error=server_error&error_description=couldn%27t+look+up+the+session&state=xyz",
+		},
+		"stale_session": {
+			c:      &mockClienter{client: confidential},
+			v:      &mockValidator{validsc: http.StatusNoContent, sessionsc: http.StatusUnauthorized},
+			q:      good,
+			cookie: true,
+			sc:     http.StatusFound,
+			loc:    "/login?redirect=%2Foauth%2Fauthorize%3F" + url.QueryEscape(good.Encode()),
+		},
+		"session_lookup_fails": {
+			c:      &mockClienter{client: confidential},
+			v:      &mockValidator{validsc: http.StatusNoContent, sessionsc: http.StatusInternalServerError},
+			q:      good,
+			cookie: true,
+			sc:     http.StatusFound,
+			loc:    testRedirect + "?error=server_error&error_description=couldn%27t+look+up+the+session&state=xyz",
+		},
+		"code_fails": {
+			c: &mockClienter{client: confidential},
+			v: &mockValidator{
+				validsc:    http.StatusNoContent,
+				session:    "uuid",
+				sessionsc:  http.StatusOK,
+				authcodesc: http.StatusInternalServerError,
+			},
+			q:      good,
+			cookie: true,
+			sc:     http.StatusFound,
+			loc:    testRedirect + "?error=server_error&error_description=couldn%27t+issue+a+code&state=xyz",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{
+				Clienter:  tc.c,
+				Validator: tc.v,
+				logon:     "/login",
+			}
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequestWithContext(
+				mockContext(),
+				http.MethodGet,
+				"/oauth/authorize?"+tc.q.Encode(),
+				nil,
+			)
+			if tc.cookie {
+				r.AddCookie(&http.Cookie{Name: "us-authn", Value: "token"})
+			}
+
+			us.GetAuthorize(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.loc, w.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_PostToken(t *testing.T) {
+	t.Parallel()
+
+	code := &shared.AuthCode{
+		ClientID:    "rp",
+		UserID:      "uuid",
+		RedirectURI: testRedirect,
+		Scope:       "openid email",
+		Nonce:       "n-0S6",
+		Challenge:   testChallenge,
+	}
+	redeems := &mockValidator{redeem: code, redeemsc: http.StatusOK}
+	good := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"code"},
+		"redirect_uri":  {testRedirect},
+		"code_verifier": {testVerifier},
+		"client_id":     {"rp"},
+		"client_secret": {"secret"},
+	}
+	with := func(k, v string) url.Values {
+		result := url.Values{}
+		for key, vals := range good {
+			result[key] = vals
+		}
+		result.Set(k, v)
+		return result
+	}
+
+	tcs := map[string]struct {
+		c     *mockClienter
+		v     *mockValidator
+		u     *mockUserer
+		form  url.Values
+		basic bool
+		sc    int
+		err   string
+	}{
+		"happy_path": {
+			c:    &mockClienter{check: confidential},
+			v:    redeems,
+			u:    &mockUserer{user: &shared.User{UUID: "uuid", Name: "joe", Email: &testEmail}},
+			form: good,
+			sc:   http.StatusOK,
+		},
+		"basic_auth": {
+			c:     &mockClienter{check: confidential},
+			v:     redeems,
+			u:     &mockUserer{user: &shared.User{UUID: "uuid", Name: "joe", Email: &testEmail}},
+			form:  with("client_secret", ""),
+			basic: true,
+			sc:    http.StatusOK,
+		},
+		"public_client": {
+			c: &mockClienter{client: public},
+			v: &mockValidator{
+				redeem:   &shared.AuthCode{ClientID: "spa", UserID: "uuid", RedirectURI: testRedirect, Challenge: testChallenge},
+				redeemsc: http.StatusOK,
+			},
+			u:    &mockUserer{user: &shared.User{UUID: "uuid"}},
+			form: with("client_secret", ""),
+			sc:   http.StatusOK,
+		},
+		"wrong_grant": {
+			form: with("grant_type", "password"),
+			sc:   http.StatusBadRequest,
+			err:  "unsupported_grant_type",
+		},
+		"bad_secret": {
+			c:    &mockClienter{checkErr: shared.BadClientError},
+			form: good,
+			sc:   http.StatusUnauthorized,
+			err:  "invalid_client",
+		},
+		"confidential_without_secret": {
+			c:    &mockClienter{client: confidential},
+			form: with("client_secret", ""),
+			sc:   http.StatusUnauthorized,
+			err:  "invalid_client",
+		},
+		"client_lookup_fails": {
+			c:    &mockClienter{checkErr: fmt.Errorf("some error")},
+			form: good,
+			sc:   http.StatusInternalServerError,
+			err:  "server_error",
+		},
+		"used_code": {
+			c:    &mockClienter{check: confidential},
+			v:    &mockValidator{redeemsc: http.StatusBadRequest},
+			form: good,
+			sc:   http.StatusBadRequest,
+			err:  "invalid_grant",
+		},
+		"redeem_fails": {
+			c:    &mockClienter{check: confidential},
+			v:    &mockValidator{redeemsc: http.StatusInternalServerError},
+			form: good,
+			sc:   http.StatusInternalServerError,
+			err:  "server_error",
+		},
+		"other_client": {
+			c:    &mockClienter{check: &shared.OAuthClient{ID: "other"}},
+			v:    redeems,
+			form: good,
+			sc:   http.StatusBadRequest,
+			err:  "invalid_grant",
+		},
+		"other_redirect": {
+			c:    &mockClienter{check: confidential},
+			v:    redeems,
+			form: with("redirect_uri", "https://rp.example.com/other"),
+			sc:   http.StatusBadRequest,
+			err:  "invalid_grant",
+		},
+		"bad_verifier": {
+			c:    &mockClienter{check: confidential},
+			v:    redeems,
+			form: with("code_verifier", strings.Repeat("a", 43)),
+			sc:   http.StatusBadRequest,
+			err:  "invalid_grant",
+		},
+		"user_lookup_fails": {
+			c:    &mockClienter{check: confidential},
+			v:    redeems,
+			u:    &mockUserer{userErr: fmt.Errorf("some error")},
+			form: good,
+			sc:   http.StatusInternalServerError,
+			err:  "server_error",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{
+				Clienter:     tc.c,
+				Userer:       tc.u,
+				Validator:    tc.v,
+				Keys:         testKeys,
+				discovery:    oidc.NewDiscovery(testIssuer),
+				tokenTimeout: time.Minute,
+			}
+
+			form := tc.form
+			if tc.basic {
+				form = url.Values{}
+				for k, v := range tc.form {
+					if k != "client_id" {
+						form[k] = v
+					}
+				}
+			}
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequestWithContext(
+				mockContext(),
+				http.MethodPost,
+				"/oauth/token",
+				strings.NewReader(form.Encode()),
+			)
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basic {
+				r.SetBasicAuth("rp", "secret")
+			}
+
+			us.PostToken(w, r)
+
+			require.Equal(t, tc.sc, w.Code, w.Body.String())
+			require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			if tc.sc != http.StatusOK {
+				oerr := map[string]string{}
+				require.Nil(t, json.Unmarshal(w.Body.Bytes(), &oerr))
+				require.Equal(t, tc.err, oerr["error"])
+				return
+			}
+
+			tokens := oidc.Tokens{}
+			require.Nil(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+			require.Equal(t, "Bearer", tokens.TokenType)
+			require.Equal(t, int64(60), tokens.ExpiresIn)
+
+			id := &oidc.IDClaims{}
+			require.Nil(t, testKeys.Parse(tokens.IDToken, id))
+			require.Equal(t, "uuid", id.Subject)
+			require.True(t, id.VerifyAudience(tc.v.redeem.ClientID, true))
+			require.Equal(t, tc.v.redeem.Nonce, id.Nonce)
+
+			access := &oidc.AccessClaims{}
+			require.Nil(t, testKeys.Parse(tokens.AccessToken, access))
+			require.True(t, access.VerifyAudience(testIssuer, true))
+			require.Equal(t, tc.v.redeem.ClientID, access.ClientID)
+			require.Equal(t, oidc.AccessTokenUse, access.TokenUse)
+			require.NotEmpty(t, access.Id)
+		})
+	}
+}
+
+func Test_GetUserInfo(t *testing.T) {
+	t.Parallel()
+
+	bearer := func(claims jwt.Claims) string {
+		token, err := testKeys.Sign(claims)
+		if err != nil {
+			panic(err)
+		}
+		return "Bearer " + token
+	}
+	std := func(aud string, exp time.Duration) jwt.StandardClaims {
+		return jwt.StandardClaims{
+			Issuer:    testIssuer,
+			Subject:   "uuid",
+			Audience:  aud,
+			ExpiresAt: time.Now().Add(exp).Unix(),
+		}
+	}
+	sign := func(scope string, exp time.Duration) string {
+		return bearer(&oidc.AccessClaims{
+			StandardClaims: std(testIssuer, exp),
+			TokenUse:       oidc.AccessTokenUse,
+			Scope:          scope,
+			ClientID:       "rp",
+		})
+	}
+
+	tcs := map[string]struct {
+		c     *mockClienter
+		u     *mockUserer
+		auth  string
+		sc    int
+		email bool
+	}{
+		"happy_path": {
+			c:     &mockClienter{client: confidential},
+			u:     &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail}},
+			auth:  sign("openid email", time.Minute),
+			sc:    http.StatusOK,
+			email: true,
+		},
+		"narrow_scope": {
+			c:    &mockClienter{client: confidential},
+			u:    &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail}},
+			auth: sign("openid", time.Minute),
+			sc:   http.StatusOK,
+		},
+		"missing_token": {
+			sc: http.StatusUnauthorized,
+		},
+		"expired_token": {
+			auth: sign("openid", -time.Minute),
+			sc:   http.StatusUnauthorized,
+		},
+		"garbage_token": {
+			auth: "Bearer garbage",
+			sc:   http.StatusUnauthorized,
+		},
+		"id_token": {
+			auth: bearer(&oidc.IDClaims{StandardClaims: std("rp", time.Minute)}),
+			sc:   http.StatusUnauthorized,
+		},
+		"verification_token": {
+			auth: bearer(&oidc.VerifyClaims{
+				StandardClaims: std("", time.Minute),
+				Purpose:        oidc.VerifyEmailPurpose,
+				Email:          &testEmail,
+			}),
+			sc: http.StatusUnauthorized,
+		},
+		"wrong_audience": {
+			auth: bearer(&oidc.AccessClaims{
+				StandardClaims: std("rp", time.Minute),
+				TokenUse:       oidc.AccessTokenUse,
+				Scope:          "openid",
+				ClientID:       "rp",
+			}),
+			sc: http.StatusUnauthorized,
+		},
+		"no_client": {
+			auth: bearer(&oidc.AccessClaims{
+				StandardClaims: std(testIssuer, time.Minute),
+				TokenUse:       oidc.AccessTokenUse,
+				Scope:          "openid",
+			}),
+			sc: http.StatusUnauthorized,
+		},
+		"deleted_client": {
+			c:    &mockClienter{clientErr: shared.ClientNotFoundError},
+			auth: sign("openid", time.Minute),
+			sc:   http.StatusUnauthorized,
+		},
+		"client_lookup_fails": {
+			c:    &mockClienter{clientErr: fmt.Errorf("some error")},
+			auth: sign("openid", time.Minute),
+			sc:   http.StatusInternalServerError,
+		},
+		"user_lookup_fails": {
+			c:    &mockClienter{client: confidential},
+			u:    &mockUserer{userErr: fmt.Errorf("some error")},
+			auth: sign("openid", time.Minute),
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{
+				Clienter:  tc.c,
+				Userer:    tc.u,
+				Keys:      testKeys,
+				discovery: oidc.NewDiscovery(testIssuer),
+			}
+
+			w := httptest.NewRecorder()
+			r := totpRequest(http.MethodGet, "", "")
+			if tc.auth != "" {
+				r.Header.Set("Authorization", tc.auth)
+			}
+
+			us.GetUserInfo(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			if tc.sc == http.StatusUnauthorized {
+				require.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			} else if tc.sc == http.StatusOK {
+				info := oidc.UserInfo{}
+				require.Nil(t, json.Unmarshal(w.Body.Bytes(), &info))
+				require.Equal(t, shared.UUID("uuid"), info.Subject)
+				require.Equal(t, tc.email, info.Email != nil)
+			}
+		})
+	}
+}
+
+func Test_PostClient(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		c      *mockClienter
+		body   string
+		sc     int
+		secret bool
+	}{
+		"happy_path": {
+			c:      &mockClienter{addID: "rp"},
+			body:   `{"name":"rp","redirect_uris":["https://rp.example.com/cb"]}`,
+			sc:     http.StatusCreated,
+			secret: true,
+		},
+		"public": {
+			c:    &mockClienter{addID: "spa"},
+			body: `{"name":"spa","redirect_uris":["http://localhost:8080/cb"],"public":true,"client_secret":"ignored"}`,
+			sc:   http.StatusCreated,
+		},
+		"bad_body": {
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_name": {
+			body: `{"redirect_uris":["https://rp.example.com/cb"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_redirect": {
+			body: `{"name":"rp"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"relative_redirect": {
+			body: `{"name":"rp","redirect_uris":["/cb"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"fragment_redirect": {
+			body: `{"name":"rp","redirect_uris":["https://rp.example.com/cb#frag"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"add_fails": {
+			c:    &mockClienter{addErr: fmt.Errorf("some error")},
+			body: `{"name":"rp","redirect_uris":["https://rp.example.com/cb"]}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Clienter: tc.c}
+
+			w := httptest.NewRecorder()
+			us.PostClient(w, totpRequest(http.MethodPost, "", tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			if tc.sc == http.StatusCreated {
+				client := shared.OAuthClient{}
+				require.Nil(t, json.Unmarshal(w.Body.Bytes(), &client))
+				require.Equal(t, tc.c.addID, client.ID)
+				require.Equal(t, tc.secret, client.Secret != "")
+				require.Equal(t, "/oauth/client/"+tc.c.addID, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func Test_GetClient(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		c  *mockClienter
+		id string
+		sc int
+	}{
+		"happy_path": {
+			c:  &mockClienter{client: confidential},
+			id: "rp",
+			sc: http.StatusOK,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			c:  &mockClienter{clientErr: shared.ClientNotFoundError},
+			id: "rp",
+			sc: http.StatusNotFound,
+		},
+		"lookup_fails": {
+			c:  &mockClienter{clientErr: fmt.Errorf("some error")},
+			id: "rp",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Clienter: tc.c}
+
+			w := httptest.NewRecorder()
+			us.GetClient(w, clientRequest(http.MethodGet, tc.id))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_DeleteClient(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		c  *mockClienter
+		id string
+		sc int
+	}{
+		"happy_path": {
+			c:  &mockClienter{},
+			id: "rp",
+			sc: http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			c:  &mockClienter{deleteErr: shared.ClientNotFoundError},
+			id: "rp",
+			sc: http.StatusNotFound,
+		},
+		"delete_fails": {
+			c:  &mockClienter{deleteErr: fmt.Errorf("some error")},
+			id: "rp",
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Clienter: tc.c}
+
+			w := httptest.NewRecorder()
+			us.DeleteClient(w, clientRequest(http.MethodDelete, tc.id))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func clientRequest(method, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams = chi.RouteParams{Keys: []string{"client_id"}, Values: []string{id}}
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(
+			mockContext(),
+			chi.RouteCtxKey,
+			rctx),
+		method,
+		"tc.url",
+		io.Reader(bytes.NewReader(nil)),
+	)
+	return r
+}
+
+func (mc *mockClienter) GetClient(context.Context, string) (*shared.OAuthClient, error) {
+	return mc.client, mc.clientErr
+}
+func (mc *mockClienter) AddClient(context.Context, *shared.OAuthClient) (string, error) {
+	return mc.addID, mc.addErr
+}
+func (mc *mockClienter) CheckClient(context.Context, string, string) (*shared.OAuthClient, error) {
+	return mc.check, mc.checkErr
+}
+func (mc *mockClienter) DeleteClient(context.Context, string) error {
+	return mc.deleteErr
+}
@@ -0,0 +1,78 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// PostRefresh trades the refresh cookie for a new session and the next
+// refresh token; a stale or reused one means logging in again
+func (us UserService) PostRefresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if token, err := r.Cookie(us.refreshCookie); err != nil {
+		w.Header().Set("Location", us.logon)
+		sc(http.StatusUnauthorized).send(ctx, w, shared.MissingAuthToken)
+	} else if session, refresh, code := us.Validator.Refresh(ctx, token.Value, us.client(r), us.active); code != http.StatusOK {
+		if code == http.StatusUnauthorized {
+			http.SetCookie(w, us.clearRefresh())
+			w.Header().Set("Location", us.logon)
+		}
+		sc(code).send(ctx, w, fmt.Errorf("failed refresh"))
+	} else {
+		http.SetCookie(w, session)
+		http.SetCookie(w, refresh)
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// DeleteRefresh revokes the refresh token's whole family without touching the
+// current session
+func (us UserService) DeleteRefresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if token, err := r.Cookie(us.refreshCookie); err != nil {
+		sc(http.StatusNoContent).success(ctx, w)
+	} else if cookie, code := us.Validator.RevokeRefresh(ctx, token.Value); code != http.StatusNoContent {
+		sc(code).send(ctx, w, fmt.Errorf("failed revoking refresh token"))
+	} else {
+		http.SetCookie(w, cookie)
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// active is what Refresh asks before trading a refresh token in: deleted and
+// locked out users don't get new sessions
+func (us UserService) active(ctx context.Context, uid shared.UUID) int {
+	if err := us.Auther.Active(ctx, uid); err == nil {
+		return http.StatusOK
+	} else if errors.Is(err, shared.UserDeletedError) || errors.Is(err, shared.MaxFailedLoginError) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// setRefresh goes along with every new session; a login without a refresh
+// token still works, it just can't outlive the session, so failing to make one
+// isn't worth failing the login over
+func (us UserService) setRefresh(ctx context.Context, w http.ResponseWriter, uid shared.UUID, name string) {
+	if cookie, code := us.Validator.NewRefresh(ctx, uid, name); code == http.StatusOK {
+		http.SetCookie(w, cookie)
+	}
+}
+
+func (us UserService) clearRefresh() *http.Cookie {
+	return &http.Cookie{
+		Name:     us.refreshCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	}
+}
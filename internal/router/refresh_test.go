@@ -0,0 +1,171 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+var refreshCookie = http.Cookie{
+	Name:     "us-refresh",
+	Path:     "/",
+	Expires:  time.Time{},
+	MaxAge:   -1,
+	HttpOnly: true,
+	Raw:      "us-refresh=; Path=/; Max-Age=0; HttpOnly",
+}
+
+func Test_PostRefresh(t *testing.T) {
+	t.Parallel()
+
+	session := &http.Cookie{Name: "us-authn", Value: "session", Raw: "us-authn=session"}
+	next := &http.Cookie{Name: "us-refresh", Value: "next", Raw: "us-refresh=next"}
+
+	tcs := map[string]struct {
+		a       *mockAuther
+		v       *mockValidator
+		refresh string
+		sc      int
+		cookies []*http.Cookie
+		loc     string
+	}{
+		"happy_path": {
+			v: &mockValidator{
+				refreshsession: session,
+				refresh:        next,
+				refreshsc:      http.StatusOK,
+			},
+			refresh: "token",
+			sc:      http.StatusNoContent,
+			cookies: []*http.Cookie{session, next},
+		},
+		"missing_cookie": {
+			sc:  http.StatusUnauthorized,
+			loc: "/login",
+		},
+		"stale_or_reused": {
+			v:       &mockValidator{refreshsc: http.StatusUnauthorized},
+			refresh: "token",
+			sc:      http.StatusUnauthorized,
+			cookies: []*http.Cookie{&refreshCookie},
+			loc:     "/login",
+		},
+		"deleted_user": {
+			a:       &mockAuther{active: shared.UserDeletedError},
+			v:       &mockValidator{},
+			refresh: "token",
+			sc:      http.StatusUnauthorized,
+			cookies: []*http.Cookie{&refreshCookie},
+			loc:     "/login",
+		},
+		"locked_user": {
+			a:       &mockAuther{active: shared.MaxFailedLoginError},
+			v:       &mockValidator{},
+			refresh: "token",
+			sc:      http.StatusUnauthorized,
+			cookies: []*http.Cookie{&refreshCookie},
+			loc:     "/login",
+		},
+		"active_fails": {
+			a:       &mockAuther{active: fmt.Errorf("some error")},
+			v:       &mockValidator{},
+			refresh: "token",
+			sc:      http.StatusInternalServerError,
+		},
+		"too_many_logins": {
+			v:       &mockValidator{refreshsc: http.StatusTooManyRequests},
+			refresh: "token",
+			sc:      http.StatusTooManyRequests,
+		},
+		"refresh_fails": {
+			v:       &mockValidator{refreshsc: http.StatusInternalServerError},
+			refresh: "token",
+			sc:      http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.a == nil {
+				tc.a = &mockAuther{}
+			}
+
+			us := &UserService{Auther: tc.a, Validator: tc.v, refreshCookie: "us-refresh", logon: "/login"}
+
+			w := httptest.NewRecorder()
+			r := totpRequest(http.MethodPost, "", "")
+			if tc.refresh != "" {
+				r.AddCookie(&http.Cookie{Name: "us-refresh", Value: tc.refresh})
+			}
+
+			us.PostRefresh(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			require.Equal(t, tc.loc, w.Header().Get("Location"))
+			require.Equal(t, len(tc.cookies), len(w.Result().Cookies()))
+			require.Subset(t, w.Result().Cookies(), tc.cookies)
+		})
+	}
+}
+
+func Test_DeleteRefresh(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		v       *mockValidator
+		refresh string
+		sc      int
+		cleared bool
+	}{
+		"happy_path": {
+			v:       &mockValidator{revokerefreshsc: http.StatusNoContent},
+			refresh: "token",
+			sc:      http.StatusNoContent,
+			cleared: true,
+		},
+		"missing_cookie": {
+			sc: http.StatusNoContent,
+		},
+		"revoke_fails": {
+			v:       &mockValidator{revokerefreshsc: http.StatusInternalServerError},
+			refresh: "token",
+			sc:      http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Validator: tc.v, refreshCookie: "us-refresh"}
+
+			w := httptest.NewRecorder()
+			r := totpRequest(http.MethodDelete, "", "")
+			if tc.refresh != "" {
+				r.AddCookie(&http.Cookie{Name: "us-refresh", Value: tc.refresh})
+			}
+
+			us.DeleteRefresh(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			if tc.cleared {
+				require.Subset(t, w.Result().Cookies(), []*http.Cookie{&refreshCookie})
+			} else {
+				require.Empty(t, w.Result().Cookies())
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// rolePatch tells a field that's missing from one that's being cleared
+type rolePatch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+func (us UserService) GetRoles(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if roles, err := us.Authorizer.GetRoles(ctx); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(roles))
+	}
+}
+
+func (us UserService) GetRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "role_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing role_id")
+	} else if role, err := us.Authorizer.GetRole(ctx, id); errors.Is(err, shared.RoleNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(role))
+	}
+}
+
+// PostRole takes a name, and optionally a description and the permissions
+// to start out with
+func (us UserService) PostRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	role := &shared.Role{}
+	if body, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(body, role); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if err = checkRole(role); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else if role.UUID, err = us.Authorizer.AddRole(ctx, role); errors.Is(err, shared.RoleExistsError) {
+		sc(http.StatusConflict).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		w.Header().Set("Location", fmt.Sprintf("/role/%s", role.UUID))
+		sc(http.StatusCreated).success(ctx, w, mustJSON(role))
+	}
+}
+
+// PatchRole changes the name and/or description; anything else in the body
+// is ignored
+func (us UserService) PatchRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	patch := rolePatch{}
+
+	if id := shared.UUID(chi.URLParam(r, "role_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing role_id")
+	} else if body, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(body, &patch); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if patch.Name != nil && *patch.Name == "" {
+		sc(http.StatusBadRequest).send(ctx, w, fmt.Errorf("name can't be empty"), "name can't be empty")
+	} else if role, err := us.Authorizer.GetRole(ctx, id); errors.Is(err, shared.RoleNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if err = us.Authorizer.UpdateRole(ctx, patch.apply(role)); errors.Is(err, shared.RoleNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if errors.Is(err, shared.RoleExistsError) {
+		sc(http.StatusConflict).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(role))
+	}
+}
+
+func (us UserService) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "role_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing role_id")
+	} else if err := us.Authorizer.DeleteRole(ctx, id); errors.Is(err, shared.RoleNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func (us UserService) PostPermission(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "role_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing role_id")
+	} else if perm := chi.URLParam(r, "permission"); !validPermission(perm) {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing or bad permission")
+	} else if err := us.Authorizer.GrantPermission(ctx, id, perm); errors.Is(err, shared.RoleNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func (us UserService) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "role_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing role_id")
+	} else if perm := chi.URLParam(r, "permission"); perm == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing permission")
+	} else if err := us.Authorizer.RevokePermission(ctx, id, perm); errors.Is(err, shared.PermissionNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func (us UserService) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if roles, err := us.Authorizer.GetUserRoles(ctx, id); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(roles))
+	}
+}
+
+func (us UserService) PostUserRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if rid := shared.UUID(chi.URLParam(r, "role_id")); rid == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing role_id")
+	} else if err := us.Authorizer.AssignRole(ctx, id, rid); errors.Is(err, shared.RoleNotFoundError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func (us UserService) DeleteUserRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if rid := shared.UUID(chi.URLParam(r, "role_id")); rid == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing role_id")
+	} else if err := us.Authorizer.UnassignRole(ctx, id, rid); errors.Is(err, shared.RoleNotAssignedError) {
+		sc(http.StatusNotFound).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+func checkRole(role *shared.Role) error {
+	role.UUID = ""
+
+	if role.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	for _, p := range role.Permissions {
+		if !validPermission(p) {
+			return fmt.Errorf("bad permission: %q", p)
+		}
+	}
+	return nil
+}
+
+// permissions get stored and reported space separated
+func validPermission(p string) bool {
+	return p != "" && !strings.ContainsAny(p, " \t\r\n")
+}
+
+func (p rolePatch) apply(role *shared.Role) *shared.Role {
+	if p.Name != nil {
+		role.Name = *p.Name
+	}
+	if p.Description != nil {
+		role.Description = *p.Description
+	}
+	return role
+}
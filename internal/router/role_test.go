@@ -0,0 +1,619 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+type mockAuthorizer struct {
+	roles     []shared.Role
+	rolesErr  error
+	role      *shared.Role
+	roleErr   error
+	addErr    error
+	updateErr error
+	deleteErr error
+	grantErr  error
+	revokeErr error
+	assignErr error
+	has       bool
+	hasErr    error
+}
+
+var adminRole = &shared.Role{UUID: "0", Name: "admin", Permissions: []string{"roles:write"}}
+
+func Test_GetRoles(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a  *mockAuthorizer
+		sc int
+	}{
+		"happy_path": {
+			a:  &mockAuthorizer{roles: []shared.Role{*adminRole}},
+			sc: http.StatusOK,
+		},
+		"lookup_fails": {
+			a:  &mockAuthorizer{rolesErr: fmt.Errorf("some error")},
+			sc: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.GetRoles(w, roleRequest(http.MethodGet, nil, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_GetRole(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a   *mockAuthorizer
+		rid string
+		sc  int
+	}{
+		"happy_path": {
+			a:   &mockAuthorizer{role: adminRole},
+			rid: "0",
+			sc:  http.StatusOK,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			a:   &mockAuthorizer{roleErr: shared.RoleNotFoundError},
+			rid: "0",
+			sc:  http.StatusNotFound,
+		},
+		"lookup_fails": {
+			a:   &mockAuthorizer{roleErr: fmt.Errorf("some error")},
+			rid: "0",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.GetRole(w, roleRequest(http.MethodGet, map[string]string{"role_id": tc.rid}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_PostRole(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a    *mockAuthorizer
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			a:    &mockAuthorizer{},
+			body: `{"name":"admin","permissions":["roles:write"]}`,
+			sc:   http.StatusCreated,
+		},
+		"bad_body": {
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_name": {
+			body: `{"permissions":["roles:write"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_permission": {
+			body: `{"name":"admin","permissions":["roles write"]}`,
+			sc:   http.StatusBadRequest,
+		},
+		"exists": {
+			a:    &mockAuthorizer{addErr: shared.RoleExistsError},
+			body: `{"name":"admin"}`,
+			sc:   http.StatusConflict,
+		},
+		"add_fails": {
+			a:    &mockAuthorizer{addErr: fmt.Errorf("some error")},
+			body: `{"name":"admin"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.PostRole(w, roleRequest(http.MethodPost, nil, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+			if tc.sc == http.StatusCreated {
+				require.Equal(t, "/role/0", w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func Test_PatchRole(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a    *mockAuthorizer
+		rid  string
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			a:    &mockAuthorizer{role: &shared.Role{UUID: "0", Name: "admin"}},
+			rid:  "0",
+			body: `{"description":"everything"}`,
+			sc:   http.StatusOK,
+		},
+		"missing_id": {
+			body: `{"name":"root"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"bad_body": {
+			rid:  "0",
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"empty_name": {
+			rid:  "0",
+			body: `{"name":""}`,
+			sc:   http.StatusBadRequest,
+		},
+		"not_found": {
+			a:    &mockAuthorizer{roleErr: shared.RoleNotFoundError},
+			rid:  "0",
+			body: `{"name":"root"}`,
+			sc:   http.StatusNotFound,
+		},
+		"lookup_fails": {
+			a:    &mockAuthorizer{roleErr: fmt.Errorf("some error")},
+			rid:  "0",
+			body: `{"name":"root"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"deleted_meanwhile": {
+			a:    &mockAuthorizer{role: &shared.Role{UUID: "0"}, updateErr: shared.RoleNotFoundError},
+			rid:  "0",
+			body: `{"name":"root"}`,
+			sc:   http.StatusNotFound,
+		},
+		"name_taken": {
+			a:    &mockAuthorizer{role: &shared.Role{UUID: "0"}, updateErr: shared.RoleExistsError},
+			rid:  "0",
+			body: `{"name":"root"}`,
+			sc:   http.StatusConflict,
+		},
+		"update_fails": {
+			a:    &mockAuthorizer{role: &shared.Role{UUID: "0"}, updateErr: fmt.Errorf("some error")},
+			rid:  "0",
+			body: `{"name":"root"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.PatchRole(w, roleRequest(http.MethodPatch, map[string]string{"role_id": tc.rid}, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_rolePatch(t *testing.T) {
+	t.Parallel()
+
+	name, empty := "root", ""
+
+	require.Equal(t,
+		&shared.Role{Name: "root", Description: "everything"},
+		rolePatch{Name: &name}.apply(&shared.Role{Name: "admin", Description: "everything"}))
+	require.Equal(t,
+		&shared.Role{Name: "admin"},
+		rolePatch{Description: &empty}.apply(&shared.Role{Name: "admin", Description: "everything"}))
+}
+
+func Test_DeleteRole(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a   *mockAuthorizer
+		rid string
+		sc  int
+	}{
+		"happy_path": {
+			a:   &mockAuthorizer{},
+			rid: "0",
+			sc:  http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"not_found": {
+			a:   &mockAuthorizer{deleteErr: shared.RoleNotFoundError},
+			rid: "0",
+			sc:  http.StatusNotFound,
+		},
+		"delete_fails": {
+			a:   &mockAuthorizer{deleteErr: fmt.Errorf("some error")},
+			rid: "0",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.DeleteRole(w, roleRequest(http.MethodDelete, map[string]string{"role_id": tc.rid}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_PostPermission(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a    *mockAuthorizer
+		rid  string
+		perm string
+		sc   int
+	}{
+		"happy_path": {
+			a:    &mockAuthorizer{},
+			rid:  "0",
+			perm: "roles:write",
+			sc:   http.StatusNoContent,
+		},
+		"missing_id": {
+			perm: "roles:write",
+			sc:   http.StatusBadRequest,
+		},
+		"missing_permission": {
+			rid: "0",
+			sc:  http.StatusBadRequest,
+		},
+		"bad_permission": {
+			rid:  "0",
+			perm: "roles write",
+			sc:   http.StatusBadRequest,
+		},
+		"not_found": {
+			a:    &mockAuthorizer{grantErr: shared.RoleNotFoundError},
+			rid:  "0",
+			perm: "roles:write",
+			sc:   http.StatusNotFound,
+		},
+		"grant_fails": {
+			a:    &mockAuthorizer{grantErr: fmt.Errorf("some error")},
+			rid:  "0",
+			perm: "roles:write",
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.PostPermission(w, roleRequest(http.MethodPost, map[string]string{"role_id": tc.rid, "permission": tc.perm}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_DeletePermission(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a    *mockAuthorizer
+		rid  string
+		perm string
+		sc   int
+	}{
+		"happy_path": {
+			a:    &mockAuthorizer{},
+			rid:  "0",
+			perm: "roles:write",
+			sc:   http.StatusNoContent,
+		},
+		"missing_id": {
+			perm: "roles:write",
+			sc:   http.StatusBadRequest,
+		},
+		"missing_permission": {
+			rid: "0",
+			sc:  http.StatusBadRequest,
+		},
+		"not_granted": {
+			a:    &mockAuthorizer{revokeErr: shared.PermissionNotFoundError},
+			rid:  "0",
+			perm: "roles:write",
+			sc:   http.StatusNotFound,
+		},
+		"revoke_fails": {
+			a:    &mockAuthorizer{revokeErr: fmt.Errorf("some error")},
+			rid:  "0",
+			perm: "roles:write",
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.DeletePermission(w, roleRequest(http.MethodDelete, map[string]string{"role_id": tc.rid, "permission": tc.perm}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_GetUserRoles(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a   *mockAuthorizer
+		uid string
+		sc  int
+	}{
+		"happy_path": {
+			a:   &mockAuthorizer{roles: []shared.Role{*adminRole}},
+			uid: "uid",
+			sc:  http.StatusOK,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"lookup_fails": {
+			a:   &mockAuthorizer{rolesErr: fmt.Errorf("some error")},
+			uid: "uid",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.GetUserRoles(w, roleRequest(http.MethodGet, map[string]string{"user_id": tc.uid}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_PostUserRole(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a        *mockAuthorizer
+		uid, rid string
+		sc       int
+	}{
+		"happy_path": {
+			a:   &mockAuthorizer{},
+			uid: "uid",
+			rid: "0",
+			sc:  http.StatusNoContent,
+		},
+		"missing_uid": {
+			rid: "0",
+			sc:  http.StatusBadRequest,
+		},
+		"missing_role_id": {
+			uid: "uid",
+			sc:  http.StatusBadRequest,
+		},
+		"no_such_role": {
+			a:   &mockAuthorizer{assignErr: shared.RoleNotFoundError},
+			uid: "uid",
+			rid: "0",
+			sc:  http.StatusNotFound,
+		},
+		"assign_fails": {
+			a:   &mockAuthorizer{assignErr: fmt.Errorf("some error")},
+			uid: "uid",
+			rid: "0",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.PostUserRole(w, roleRequest(http.MethodPost, map[string]string{"user_id": tc.uid, "role_id": tc.rid}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_DeleteUserRole(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		a        *mockAuthorizer
+		uid, rid string
+		sc       int
+	}{
+		"happy_path": {
+			a:   &mockAuthorizer{},
+			uid: "uid",
+			rid: "0",
+			sc:  http.StatusNoContent,
+		},
+		"missing_uid": {
+			rid: "0",
+			sc:  http.StatusBadRequest,
+		},
+		"missing_role_id": {
+			uid: "uid",
+			sc:  http.StatusBadRequest,
+		},
+		"not_assigned": {
+			a:   &mockAuthorizer{assignErr: shared.RoleNotAssignedError},
+			uid: "uid",
+			rid: "0",
+			sc:  http.StatusNotFound,
+		},
+		"unassign_fails": {
+			a:   &mockAuthorizer{assignErr: fmt.Errorf("some error")},
+			uid: "uid",
+			rid: "0",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Authorizer: tc.a}
+
+			w := httptest.NewRecorder()
+			us.DeleteUserRole(w, roleRequest(http.MethodDelete, map[string]string{"user_id": tc.uid, "role_id": tc.rid}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func roleRequest(method string, params map[string]string, body string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(
+			mockContext(),
+			chi.RouteCtxKey,
+			rctx),
+		method,
+		"tc.url",
+		io.Reader(bytes.NewReader([]byte(body))),
+	)
+	return r
+}
+
+func (ma *mockAuthorizer) GetRoles(context.Context) ([]shared.Role, error) {
+	return ma.roles, ma.rolesErr
+}
+
+func (ma *mockAuthorizer) GetRole(context.Context, shared.UUID) (*shared.Role, error) {
+	return ma.role, ma.roleErr
+}
+
+func (ma *mockAuthorizer) AddRole(_ context.Context, r *shared.Role) (shared.UUID, error) {
+	if ma.addErr != nil {
+		return "", ma.addErr
+	}
+	r.UUID = "0"
+	return r.UUID, nil
+}
+
+func (ma *mockAuthorizer) UpdateRole(context.Context, *shared.Role) error {
+	return ma.updateErr
+}
+
+func (ma *mockAuthorizer) DeleteRole(context.Context, shared.UUID) error {
+	return ma.deleteErr
+}
+
+func (ma *mockAuthorizer) GrantPermission(context.Context, shared.UUID, string) error {
+	return ma.grantErr
+}
+
+func (ma *mockAuthorizer) RevokePermission(context.Context, shared.UUID, string) error {
+	return ma.revokeErr
+}
+
+func (ma *mockAuthorizer) GetUserRoles(context.Context, shared.UUID) ([]shared.Role, error) {
+	return ma.roles, ma.rolesErr
+}
+
+func (ma *mockAuthorizer) AssignRole(context.Context, shared.UUID, shared.UUID) error {
+	return ma.assignErr
+}
+
+func (ma *mockAuthorizer) UnassignRole(context.Context, shared.UUID, shared.UUID) error {
+	return ma.assignErr
+}
+
+func (ma *mockAuthorizer) HasPermission(context.Context, shared.UUID, string) (bool, error) {
+	return ma.has, ma.hasErr
+}
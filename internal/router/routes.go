@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"time"
 
@@ -17,6 +18,8 @@ import (
 	"github.com/jsmit257/userservice/internal/messaging/maild"
 	"github.com/jsmit257/userservice/internal/messaging/smsd"
 	"github.com/jsmit257/userservice/internal/metrics"
+	"github.com/jsmit257/userservice/internal/mfa"
+	"github.com/jsmit257/userservice/internal/oidc"
 	valid "github.com/jsmit257/userservice/internal/validation"
 	"github.com/jsmit257/userservice/shared/v1"
 )
@@ -25,11 +28,28 @@ type (
 	UserService struct {
 		MailSender maild.Sender
 		SmsSender  smsd.Sender
+		shared.APIKeyer
 		shared.Addresser
 		shared.Auther
+		shared.Authorizer
+		shared.Clienter
 		shared.Contacter
+		shared.Inviter
+		shared.MagicLinker
+		shared.MFAer
 		shared.Userer
 		valid.Validator
+		Keys            *oidc.Keys
+		VerifyKey       *oidc.VerifyKey
+		totp            *mfa.TOTP
+		recoveryCodes   int
+		discovery       oidc.Discovery
+		tokenTimeout    time.Duration
+		verifyTimeout   time.Duration
+		inviteTimeout   time.Duration
+		refreshCookie   string
+		adminPermission string
+		proxies         []netip.Prefix
 		success,
 		logon,
 		redirect string
@@ -42,33 +62,112 @@ func NewInstance(us *UserService, cfg *config.Config, log *logrus.Entry) *http.S
 	us.success = cfg.SuccessURL
 	us.logon = cfg.LogonURL
 	us.redirect = cfg.ResetURL
+	us.refreshCookie = cfg.RefreshCookie
+	us.totp = mfa.NewTOTP(cfg)
+	us.recoveryCodes = cfg.RecoveryCodes
+	us.discovery = oidc.NewDiscovery(cfg.OIDCIssuer)
+	us.tokenTimeout = cfg.OIDCTokenTimeout
+	us.verifyTimeout = cfg.VerifyTimeout
+	us.inviteTimeout = cfg.InviteTimeout
+	us.adminPermission = cfg.AdminPermission
+	us.proxies = parseProxies(cfg.TrustedProxies)
 
 	r := chi.NewRouter()
 
 	r.Use(wrapContext(log))
 
-	r.Get("/users", us.GetAllUsers)
-	r.Get("/user/{user_id}", us.GetUser)
 	r.Post("/user", us.PostUser)
-	r.Patch("/user/{user_id}", us.PatchUser)
-	r.Delete("/user/{user_id}", us.DeleteUser)
-	r.Post("/user/{user_id}/contact", us.CreateContact)
+	r.Post("/signup", us.PostSignup)
+	r.Get("/invite/{token}", us.GetInvite)
+	r.Post("/invite/{token}", us.PostInvite)
 
-	r.Patch("/contact/{user_id}", us.PatchContact)
-
-	r.Get("/addresses", us.GetAllAddresses)
-	r.Get("/address/{address_id}", us.GetAddress)
-	r.Post("/address", us.PostAddress)
-	r.Patch("/address/{address_id}", us.PatchAddress)
-
-	r.Get("/auth/{username}", us.GetAuth)
 	r.Post("/auth", us.PostLogin)
-	r.Patch("/auth/{user_id}", us.PatchLogin)
+	r.With(us.resetOrSelf).Patch("/auth/{user_id}", us.PatchLogin)
 	r.Delete("/auth", us.DeleteLogin)
+	r.Post("/auth/mfa", us.PostMFA)
+	r.Post("/auth/mfa/code", us.PostMFACode)
+	r.Post("/auth/magic", us.PostMagic)
+	r.Get("/auth/magic/{token}", us.GetMagic)
+
+	r.Post("/token/refresh", us.PostRefresh)
+	r.Delete("/token/refresh", us.DeleteRefresh)
 
 	r.Post("/logout", us.PostLogout)
 	r.Get("/valid", us.GetValid)
 	r.Get("/otp/{pad}", us.GetLoginOTP)
+	r.Get("/user/{user_id}/email/verify", us.GetEmailVerify)
+
+	r.Get("/.well-known/openid-configuration", us.GetDiscovery)
+	r.Get("/oauth/jwks", us.GetJWKS)
+	r.Get("/oauth/authorize", us.GetAuthorize)
+	r.Post("/oauth/token", us.PostToken)
+	r.Get("/oauth/userinfo", us.GetUserInfo)
+
+	// everything else needs a session, and most of it only for the caller's
+	// own user unless they're an admin
+	r.Group(func(r chi.Router) {
+		r.Use(us.authenticate)
+
+		r.With(us.selfOrAdmin).Get("/user/{user_id}", us.GetUser)
+		r.With(us.selfOrAdmin).Patch("/user/{user_id}", us.PatchUser)
+		r.With(us.selfOrAdmin).Delete("/user/{user_id}", us.DeleteUser)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/contact", us.CreateContact)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/email/verify", us.PostEmailVerify)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/cell/verify", us.PostCellVerify)
+		r.With(us.selfOrAdmin).Patch("/user/{user_id}/cell/verify", us.PatchCellVerify)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/mfa/totp", us.PostTOTP)
+		r.With(us.selfOrAdmin).Patch("/user/{user_id}/mfa/totp", us.PatchTOTP)
+		r.With(us.selfOrAdmin).Delete("/user/{user_id}/mfa/totp", us.DeleteTOTP)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/mfa/otp", us.PostOTP)
+		r.With(us.selfOrAdmin).Patch("/user/{user_id}/mfa/otp", us.PatchOTP)
+		r.With(us.selfOrAdmin).Delete("/user/{user_id}/mfa/otp", us.DeleteOTP)
+		r.With(us.selfOrAdmin).Get("/user/{user_id}/magic", us.GetUserMagic)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/magic", us.PostUserMagic)
+		r.With(us.selfOrAdmin).Delete("/user/{user_id}/magic", us.DeleteUserMagic)
+		r.With(us.selfOrAdmin).Get("/user/{user_id}/mfa/recovery", us.GetRecoveryCodes)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/mfa/recovery", us.PostRecoveryCodes)
+		r.With(us.selfOrAdmin).Get("/user/{user_id}/apikeys", us.GetAPIKeys)
+		r.With(us.selfOrAdmin).Post("/user/{user_id}/apikey", us.PostAPIKey)
+		r.With(us.selfOrAdmin).Delete("/user/{user_id}/apikey/{key_id}", us.DeleteAPIKey)
+		r.With(us.selfOrAdmin).Get("/user/{user_id}/roles", us.GetUserRoles)
+		r.With(us.selfOrAdmin).Get("/user/{user_id}/sessions", us.GetSessions)
+		r.With(us.selfOrAdmin).Delete("/user/{user_id}/sessions", us.DeleteSessions)
+		r.With(us.selfOrAdmin).Delete("/user/{user_id}/sessions/{session_id}", us.DeleteSession)
+
+		r.With(us.selfOrAdmin).Patch("/contact/{user_id}", us.PatchContact)
+
+		r.Post("/address", us.PostAddress)
+		r.With(us.addressOwner).Get("/address/{address_id}", us.GetAddress)
+		r.With(us.addressOwner).Patch("/address/{address_id}", us.PatchAddress)
+
+		r.Group(func(r chi.Router) {
+			r.Use(us.adminOnly)
+
+			r.Get("/users", us.GetAllUsers)
+			r.Get("/addresses", us.GetAllAddresses)
+			r.Get("/auth/{username}", us.GetAuth)
+			r.Delete("/auth/{user_id}/lock", us.DeleteLock)
+
+			r.Post("/user/{user_id}/role/{role_id}", us.PostUserRole)
+			r.Delete("/user/{user_id}/role/{role_id}", us.DeleteUserRole)
+
+			r.Get("/roles", us.GetRoles)
+			r.Get("/role/{role_id}", us.GetRole)
+			r.Post("/role", us.PostRole)
+			r.Patch("/role/{role_id}", us.PatchRole)
+			r.Delete("/role/{role_id}", us.DeleteRole)
+			r.Post("/role/{role_id}/permission/{permission}", us.PostPermission)
+			r.Delete("/role/{role_id}/permission/{permission}", us.DeletePermission)
+
+			r.Get("/invitations", us.GetInvitations)
+			r.Post("/invitation", us.PostInvitation)
+			r.Delete("/invitation/{invitation_id}", us.DeleteInvitation)
+
+			r.Post("/oauth/client", us.PostClient)
+			r.Get("/oauth/client/{client_id}", us.GetClient)
+			r.Delete("/oauth/client/{client_id}", us.DeleteClient)
+		})
+	})
 
 	r.Get("/hc", hc)
 
@@ -56,11 +56,17 @@ func Test_NewInstance(t *testing.T) {
 	os.Setenv("MYSQL_PASSWORD", "snakeoil")
 
 	_ = NewInstance(&UserService{
-		Addresser: &mockAddresser{},
-		Auther:    &mockAuther{},
-		Contacter: &mockContacter{},
-		Userer:    &mockUserer{},
-		Validator: &mockValidator{},
+		APIKeyer:    &mockAPIKeyer{},
+		Addresser:   &mockAddresser{},
+		Auther:      &mockAuther{},
+		Authorizer:  &mockAuthorizer{},
+		Clienter:    &mockClienter{},
+		Contacter:   &mockContacter{},
+		Inviter:     &mockInviter{},
+		MagicLinker: &mockMagicLinker{},
+		MFAer:       &mockMFAer{},
+		Userer:      &mockUserer{},
+		Validator:   &mockValidator{},
 	}, config.NewConfig(), nil)
 }
 
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func (us UserService) GetSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if sessions, code := us.Validator.Sessions(ctx, id); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("failed listing sessions"))
+	} else {
+		sc(http.StatusOK).success(ctx, w, mustJSON(sessions))
+	}
+}
+
+// DeleteSession logs out one session, which might be the caller's own
+func (us UserService) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if sid := chi.URLParam(r, "session_id"); sid == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing session_id")
+	} else if code := us.Validator.EndSession(ctx, id, sid); code == http.StatusNotFound {
+		sc(code).send(ctx, w, shared.SessionNotFoundError, shared.SessionNotFoundError.Error())
+	} else if code != http.StatusNoContent {
+		sc(code).send(ctx, w, fmt.Errorf("failed ending session"))
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// DeleteSessions is logging out everywhere
+func (us UserService) DeleteSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if code := us.Validator.EndSessions(ctx, id); code != http.StatusNoContent {
+		sc(code).send(ctx, w, fmt.Errorf("failed ending sessions"))
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
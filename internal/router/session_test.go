@@ -0,0 +1,153 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_GetSessions(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		v   *mockValidator
+		uid string
+		sc  int
+	}{
+		"happy_path": {
+			v: &mockValidator{
+				sessions:   []shared.Session{{ID: "0", Remote: "remote", Created: time.Now()}},
+				sessionssc: http.StatusOK,
+			},
+			uid: "1",
+			sc:  http.StatusOK,
+		},
+		"missing_id": {
+			v:  &mockValidator{},
+			sc: http.StatusBadRequest,
+		},
+		"lookup_fails": {
+			v:   &mockValidator{sessionssc: http.StatusInternalServerError},
+			uid: "1",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Validator: tc.v}
+
+			w := httptest.NewRecorder()
+			us.GetSessions(w, roleRequest(http.MethodGet, map[string]string{"user_id": tc.uid}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_DeleteSession(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		v   *mockValidator
+		uid string
+		sid string
+		sc  int
+	}{
+		"happy_path": {
+			v:   &mockValidator{endsessionsc: http.StatusNoContent},
+			uid: "1",
+			sid: "0",
+			sc:  http.StatusNoContent,
+		},
+		"missing_id": {
+			v:   &mockValidator{},
+			sid: "0",
+			sc:  http.StatusBadRequest,
+		},
+		"missing_session_id": {
+			v:   &mockValidator{},
+			uid: "1",
+			sc:  http.StatusBadRequest,
+		},
+		"not_found": {
+			v:   &mockValidator{endsessionsc: http.StatusNotFound},
+			uid: "1",
+			sid: "0",
+			sc:  http.StatusNotFound,
+		},
+		"end_fails": {
+			v:   &mockValidator{endsessionsc: http.StatusInternalServerError},
+			uid: "1",
+			sid: "0",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Validator: tc.v}
+
+			w := httptest.NewRecorder()
+			us.DeleteSession(w, roleRequest(http.MethodDelete, map[string]string{
+				"user_id":    tc.uid,
+				"session_id": tc.sid,
+			}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_DeleteSessions(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		v   *mockValidator
+		uid string
+		sc  int
+	}{
+		"happy_path": {
+			v:   &mockValidator{endsessionssc: http.StatusNoContent},
+			uid: "1",
+			sc:  http.StatusNoContent,
+		},
+		"missing_id": {
+			v:  &mockValidator{},
+			sc: http.StatusBadRequest,
+		},
+		"end_fails": {
+			v:   &mockValidator{endsessionssc: http.StatusInternalServerError},
+			uid: "1",
+			sc:  http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Validator: tc.v}
+
+			w := httptest.NewRecorder()
+			us.DeleteSessions(w, roleRequest(http.MethodDelete, map[string]string{"user_id": tc.uid}, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
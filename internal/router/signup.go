@@ -0,0 +1,51 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+
+	valid "github.com/jsmit257/userservice/internal/validation"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// PostSignup is self-registration: it makes the user with the password they
+// picked and logs them straight in, where POST /user leaves an account that
+// can't be used until somebody resets its password
+func (us UserService) PostSignup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	ctx := r.Context()
+
+	var body struct {
+		shared.User
+		Password shared.Password `json:"password"`
+	}
+	var violations shared.PolicyViolations
+	if b, err := io.ReadAll(r.Body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read body")
+	} else if err = json.Unmarshal(b, &body); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't unmarshal body")
+	} else if body.Name == "" || body.Password == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "username and password are required")
+	} else if !body.Email.Valid() && !body.Cell.Valid() {
+		sc(http.StatusBadRequest).send(ctx, w, shared.Undeliverable, "no valid email or SMS provided")
+	} else if id, err := us.Userer.SignUp(ctx, &body.User, body.Password); errors.As(err, &violations) {
+		sc(http.StatusBadRequest).success(ctx, w, mustJSON(violations))
+	} else if errors.Is(err, shared.UserExistsError) {
+		sc(http.StatusBadRequest).send(ctx, w, err, err.Error())
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if cookie, code := us.Validator.Login(ctx, id, body.Name, valid.PasswordLogin, us.client(r)); code != http.StatusOK {
+		sc(code).send(ctx, w, fmt.Errorf("failed redis login"), "account was created, but couldn't log in")
+	} else {
+		body.UUID = id
+		us.emailChanged(r, &shared.User{}, &body.User) // from nothing
+		http.SetCookie(w, cookie)
+		us.setRefresh(ctx, w, id, body.Name)
+		sc(http.StatusCreated).success(ctx, w, html.EscapeString(string(id)))
+	}
+}
@@ -0,0 +1,116 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_PostSignup(t *testing.T) {
+	t.Parallel()
+
+	loggedIn := &mockValidator{
+		login:   &http.Cookie{Name: "us-authn", Value: "token"},
+		loginsc: http.StatusOK,
+	}
+
+	tcs := map[string]struct {
+		u      *mockUserer
+		v      *mockValidator
+		body   string
+		sc     int
+		cookie bool
+		emails int
+	}{
+		"happy_path": {
+			u:      &mockUserer{signup: "uuid"},
+			v:      loggedIn,
+			body:   `{"username":"name","email":"someone@example.com","password":"correct horse"}`,
+			sc:     http.StatusCreated,
+			cookie: true,
+			emails: 1,
+		},
+		"cell_only": {
+			u:      &mockUserer{signup: "uuid"},
+			v:      loggedIn,
+			body:   `{"username":"name","cell":"5551234","password":"correct horse"}`,
+			sc:     http.StatusCreated,
+			cookie: true,
+		},
+		"bad_body": {
+			body: `{`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_name": {
+			body: `{"email":"someone@example.com","password":"correct horse"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"missing_password": {
+			body: `{"username":"name","email":"someone@example.com"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"undeliverable": {
+			body: `{"username":"name","password":"correct horse"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"policy_violation": {
+			u:    &mockUserer{signupErr: shared.DefaultPasswordPolicy.Check("short")},
+			body: `{"username":"name","email":"someone@example.com","password":"short"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"user_exists": {
+			u:    &mockUserer{signupErr: shared.UserExistsError},
+			body: `{"username":"name","email":"someone@example.com","password":"correct horse"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"signup_fails": {
+			u:    &mockUserer{signupErr: fmt.Errorf("some error")},
+			body: `{"username":"name","email":"someone@example.com","password":"correct horse"}`,
+			sc:   http.StatusInternalServerError,
+		},
+		"login_fails": {
+			u:    &mockUserer{signup: "uuid"},
+			v:    &mockValidator{loginsc: http.StatusInternalServerError},
+			body: `{"username":"name","email":"someone@example.com","password":"correct horse"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ms := &mockMailSender{}
+			us := &UserService{
+				Userer:     tc.u,
+				Validator:  tc.v,
+				MailSender: ms,
+				VerifyKey:  testVerifyKey,
+			}
+
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(mockContext(), chi.RouteCtxKey, chi.NewRouteContext()),
+				http.MethodPost,
+				"tc.url",
+				io.Reader(bytes.NewReader([]byte(tc.body))))
+
+			w := httptest.NewRecorder()
+			us.PostSignup(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.cookie, w.Header().Get("Set-Cookie") != "")
+			require.Equal(t, tc.emails, ms.msgs)
+		})
+	}
+}
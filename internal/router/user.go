@@ -57,6 +57,8 @@ func (us *UserService) PostUser(w http.ResponseWriter, r *http.Request) {
 	} else if id == "" {
 		sc(http.StatusInternalServerError).send(ctx, w, fmt.Errorf("userid_nil"))
 	} else {
+		user.UUID = id
+		us.emailChanged(r, &shared.User{}, &user) // from nothing
 		sc(http.StatusCreated).success(ctx, w, html.EscapeString(string(id)))
 	}
 }
@@ -76,9 +78,12 @@ func (us *UserService) PatchUser(w http.ResponseWriter, r *http.Request) {
 		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing user id")
 	} else if !user.Email.Valid() && !user.Cell.Valid() {
 		sc(http.StatusBadRequest).send(ctx, w, shared.Undeliverable, "no valid email or SMS provided")
+	} else if old, err := us.Userer.GetUser(ctx, user.UUID); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
 	} else if err = us.Userer.UpdateUser(ctx, &user); err != nil {
 		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
 	} else {
+		us.emailChanged(r, old, &user)
 		sc(http.StatusNoContent).success(ctx, w)
 	}
 }
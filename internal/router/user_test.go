@@ -27,6 +27,9 @@ type mockUserer struct {
 	createContactResp *shared.Contact
 	createContactErr  error
 	rmUserErr         error
+	verifyErr         error
+	signup            shared.UUID
+	signupErr         error
 }
 
 func Test_GetAllUsers(t *testing.T) {
@@ -150,8 +153,10 @@ func Test_PostUser(t *testing.T) {
 	tcs := map[string]struct {
 		u        *mockUserer
 		r        *shared.User
+		ms       mockMailSender
 		sc       int
 		response string
+		emails   int
 	}{
 		"happy_path": {
 			u: &mockUserer{
@@ -163,6 +168,31 @@ func Test_PostUser(t *testing.T) {
 			},
 			sc:       http.StatusCreated,
 			response: "1",
+			emails:   1,
+		},
+		"cell_only": {
+			u: &mockUserer{
+				postUserResp: &shared.User{UUID: "1"},
+			},
+			r: &shared.User{
+				UUID: "1",
+				Cell: &testCell,
+			},
+			sc:       http.StatusCreated,
+			response: "1",
+		},
+		"verification_fails": {
+			u: &mockUserer{
+				postUserResp: &shared.User{UUID: "1"},
+			},
+			r: &shared.User{
+				UUID:  "1",
+				Email: &addr,
+			},
+			ms:       mockMailSender{err: fmt.Errorf("some error")},
+			sc:       http.StatusCreated,
+			response: "1",
+			emails:   1,
 		},
 		"unmarshal_fails": {
 			u:  &mockUserer{},
@@ -226,7 +256,9 @@ func Test_PostUser(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			us := &UserService{
-				Userer: tc.u,
+				Userer:     tc.u,
+				MailSender: &tc.ms,
+				VerifyKey:  testVerifyKey,
 			}
 			w := httptest.NewRecorder()
 			body := userToBody(tc.r)
@@ -251,6 +283,7 @@ func Test_PostUser(t *testing.T) {
 			resp, _ := io.ReadAll(w.Body)
 			require.Equal(t, tc.sc, w.Code)
 			require.Equal(t, tc.response, string(resp))
+			require.Equal(t, tc.emails, tc.ms.msgs)
 		})
 	}
 }
@@ -258,7 +291,8 @@ func Test_PostUser(t *testing.T) {
 func Test_PatchUser(t *testing.T) {
 	t.Parallel()
 
-	addr := shared.Email("addr")
+	addr, other := shared.Email("addr"), shared.Email("other")
+	unchanged := &mockUserer{user: &shared.User{UUID: "1", Email: &addr, EmailVerified: &confirmed}}
 
 	tcs := map[string]struct {
 		u       *mockUserer
@@ -266,9 +300,10 @@ func Test_PatchUser(t *testing.T) {
 		user    *shared.User
 		userIDs []string
 		sc      int
+		emails  int
 	}{
 		"happy_path": {
-			u:       &mockUserer{},
+			u:       unchanged,
 			userIDs: []string{"1"},
 			r: &shared.User{
 				UUID:  "1",
@@ -276,6 +311,45 @@ func Test_PatchUser(t *testing.T) {
 			},
 			sc: http.StatusNoContent,
 		},
+		"verified_email_changed": {
+			u:       &mockUserer{user: &shared.User{UUID: "1", Email: &other, EmailVerified: &confirmed}},
+			userIDs: []string{"1"},
+			r: &shared.User{
+				UUID:  "1",
+				Email: &addr,
+			},
+			sc:     http.StatusNoContent,
+			emails: 2,
+		},
+		"unverified_email_changed": {
+			u:       &mockUserer{user: &shared.User{UUID: "1", Email: &other}},
+			userIDs: []string{"1"},
+			r: &shared.User{
+				UUID:  "1",
+				Email: &addr,
+			},
+			sc:     http.StatusNoContent,
+			emails: 1,
+		},
+		"email_removed": {
+			u:       unchanged,
+			userIDs: []string{"1"},
+			r: &shared.User{
+				UUID: "1",
+				Cell: &testCell,
+			},
+			sc:     http.StatusNoContent,
+			emails: 1,
+		},
+		"get_fails": {
+			u:       &mockUserer{userErr: fmt.Errorf("some error")},
+			userIDs: []string{"1"},
+			r: &shared.User{
+				UUID:  "1",
+				Email: &addr,
+			},
+			sc: http.StatusInternalServerError,
+		},
 		"missing_param": {
 			u:       &mockUserer{},
 			userIDs: []string{""},
@@ -300,7 +374,7 @@ func Test_PatchUser(t *testing.T) {
 			sc:      http.StatusBadRequest,
 		},
 		"update_fails": {
-			u:       &mockUserer{patchUserErr: fmt.Errorf("some error")},
+			u:       &mockUserer{user: unchanged.user, patchUserErr: fmt.Errorf("some error")},
 			userIDs: []string{"1"},
 			r: &shared.User{
 				UUID:  "1",
@@ -313,7 +387,8 @@ func Test_PatchUser(t *testing.T) {
 		name, tc := name, tc
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
-			us := &UserService{Userer: tc.u}
+			ms := &mockMailSender{}
+			us := &UserService{Userer: tc.u, MailSender: ms, VerifyKey: testVerifyKey}
 
 			body := userToBody(tc.r)
 			if name == "unmarshal_fails" {
@@ -339,6 +414,7 @@ func Test_PatchUser(t *testing.T) {
 			us.PatchUser(w, r)
 
 			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.emails, ms.msgs)
 		})
 	}
 }
@@ -495,6 +571,15 @@ func (mu *mockUserer) UpdateUser(context.Context, *shared.User) error {
 func (mu *mockUserer) CreateContact(context.Context, *shared.User, shared.Contact) (*shared.Contact, error) {
 	return mu.createContactResp, mu.createContactErr
 }
+func (mu *mockUserer) SignUp(context.Context, *shared.User, shared.Password) (shared.UUID, error) {
+	return mu.signup, mu.signupErr
+}
+func (mu *mockUserer) VerifyEmail(context.Context, shared.UUID, shared.Email) error {
+	return mu.verifyErr
+}
+func (mu *mockUserer) VerifyCell(context.Context, shared.UUID, shared.Cell) error {
+	return mu.verifyErr
+}
 func (mu *mockUserer) DeleteUser(context.Context, shared.UUID) error { // unused
 	return mu.rmUserErr
 }
@@ -1,7 +1,9 @@
 package router
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -19,16 +21,30 @@ func (us UserService) PostLogout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie, code := us.Validator.Logout(ctx, token.Value)
+	cookie, code := us.Validator.Logout(ctx, token.Value, us.client(r))
 
 	http.SetCookie(w, cookie)
+	if refresh, err := r.Cookie(us.refreshCookie); err == nil {
+		// logging out means the refresh token can't bring the session back
+		if cookie, code := us.Validator.RevokeRefresh(ctx, refresh.Value); code == http.StatusNoContent {
+			http.SetCookie(w, cookie)
+		}
+	}
 
 	sc(code).success(ctx, w)
 }
 
+// GetValid takes either a session cookie or, for machine clients, an api key
+// in the Authorization header; a key can also be asked whether it has a
+// particular ?scope=, and either one whether its user has a ?permission=
 func (us UserService) GetValid(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		us.validKey(w, r, strings.TrimPrefix(auth, "Bearer "))
+		return
+	}
+
 	token, err := r.Cookie("us-authn")
 	if err != nil {
 		w.Header().Set("Location", us.logon)
@@ -36,16 +52,54 @@ func (us UserService) GetValid(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie, code := us.Validator.Valid(ctx, token.Value)
+	cookie, code := us.Validator.Valid(ctx, token.Value, us.client(r))
 
 	http.SetCookie(w, cookie)
 	if code == http.StatusTemporaryRedirect {
 		w.Header().Set("Location", us.logon)
+	} else if perm := r.URL.Query().Get("permission"); code == http.StatusNoContent && perm != "" {
+		if uid, sessionsc := us.Validator.Session(ctx, token.Value); sessionsc != http.StatusOK {
+			code = sessionsc
+		} else {
+			us.permitted(w, r, uid, perm)
+			return
+		}
 	}
 
 	sc(code).success(ctx, w)
 }
 
+func (us UserService) validKey(w http.ResponseWriter, r *http.Request, token string) {
+	ctx := r.Context()
+
+	if key, err := us.APIKeyer.CheckAPIKey(ctx, token); errors.Is(err, shared.BadAPIKeyError) || errors.Is(err, shared.APIKeyExpiredError) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		sc(http.StatusUnauthorized).send(ctx, w, err)
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err)
+	} else if scope := r.URL.Query().Get("scope"); !key.HasScope(scope) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+		sc(http.StatusForbidden).send(ctx, w, shared.BadAPIKeyError, "key doesn't have scope: "+scope)
+	} else {
+		us.permitted(w, r, key.UserID, r.URL.Query().Get("permission"))
+	}
+}
+
+// permitted finishes off a request to /valid that's already authenticated
+func (us UserService) permitted(w http.ResponseWriter, r *http.Request, uid shared.UUID, perm string) {
+	ctx := r.Context()
+
+	if perm == "" {
+		sc(http.StatusNoContent).success(ctx, w)
+	} else if ok, err := us.Authorizer.HasPermission(ctx, uid, perm); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err)
+	} else if !ok {
+		sc(http.StatusForbidden).send(ctx, w, shared.PermissionDeniedError, "missing permission: "+perm)
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
 func (us UserService) GetLoginOTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -2,12 +2,15 @@ package router
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	valid "github.com/jsmit257/userservice/internal/validation"
 	"github.com/jsmit257/userservice/shared/v1"
 	"github.com/stretchr/testify/require"
 )
@@ -25,8 +28,54 @@ type mockValidator struct {
 	loginloc   string
 	loginotpsc int
 
+	checkotp   shared.UUID
+	checkotpsc int
+
 	completeotp   shared.UUID
 	completeotpsc int
+
+	beginmfa   string
+	beginmfasc int
+
+	pendingmfa   shared.UUID
+	pendingmfasc int
+
+	endmfasc int
+
+	sendcode   string
+	sendcodesc int
+
+	checkcodesc int
+
+	session   shared.UUID
+	sessionsc int
+
+	authcode   string
+	authcodesc int
+
+	redeem   *shared.AuthCode
+	redeemsc int
+
+	newrefresh   *http.Cookie
+	newrefreshsc int
+
+	refreshsession,
+	refresh *http.Cookie
+	refreshsc int
+
+	revokerefreshsc int
+
+	sessions   []shared.Session
+	sessionssc int
+
+	endsessionsc,
+	endsessionssc int
+
+	magiclink   string
+	magiclinksc int
+
+	redeemmagic   shared.UUID
+	redeemmagicsc int
 }
 
 var testCookie = http.Cookie{
@@ -42,15 +91,36 @@ func Test_PostLogout(t *testing.T) {
 	t.Parallel()
 
 	tcs := map[string]struct {
-		token string
-		mv    *mockValidator
-		sc    int
+		token   string
+		refresh string
+		mv      *mockValidator
+		sc      int
+		revoked bool
 	}{
 		"pass_through": {
 			token: "foobar",
 			mv:    &mockValidator{logoutsc: http.StatusFound},
 			sc:    http.StatusFound,
 		},
+		"revokes_refresh": {
+			token:   "foobar",
+			refresh: "refresh",
+			mv: &mockValidator{
+				logoutsc:        http.StatusFound,
+				revokerefreshsc: http.StatusNoContent,
+			},
+			sc:      http.StatusFound,
+			revoked: true,
+		},
+		"revoke_refresh_fails": {
+			token:   "foobar",
+			refresh: "refresh",
+			mv: &mockValidator{
+				logoutsc:        http.StatusFound,
+				revokerefreshsc: http.StatusInternalServerError,
+			},
+			sc: http.StatusFound,
+		},
 		"missing_token": {
 			sc: http.StatusMovedPermanently,
 		},
@@ -62,7 +132,7 @@ func Test_PostLogout(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			us := &UserService{Validator: tc.mv}
+			us := &UserService{Validator: tc.mv, refreshCookie: "us-refresh"}
 			w := httptest.NewRecorder()
 			r, _ := http.NewRequestWithContext(
 				context.WithValue(
@@ -80,6 +150,9 @@ func Test_PostLogout(t *testing.T) {
 					Expires: time.Now().UTC().Add(time.Hour),
 				})
 			}
+			if tc.refresh != "" {
+				r.AddCookie(&http.Cookie{Name: "us-refresh", Value: tc.refresh})
+			}
 
 			us.PostLogout(w, r)
 
@@ -89,6 +162,11 @@ func Test_PostLogout(t *testing.T) {
 			} else {
 				require.NotSubset(t, w.Result().Cookies(), []*http.Cookie{&testCookie})
 			}
+			if tc.revoked {
+				require.Subset(t, w.Result().Cookies(), []*http.Cookie{&refreshCookie})
+			} else {
+				require.NotSubset(t, w.Result().Cookies(), []*http.Cookie{&refreshCookie})
+			}
 		})
 	}
 }
@@ -97,10 +175,95 @@ func Test_GetValid(t *testing.T) {
 	t.Parallel()
 
 	tcs := map[string]struct {
-		token string
-		mv    *mockValidator
-		sc    int
+		token  string
+		bearer string
+		scope  string
+		perm   string
+		mv     *mockValidator
+		mk     *mockAPIKeyer
+		ma     *mockAuthorizer
+		sc     int
 	}{
+		"permission": {
+			token: "permission",
+			perm:  "roles:write",
+			mv:    &mockValidator{validsc: http.StatusNoContent, session: "uid", sessionsc: http.StatusOK},
+			ma:    &mockAuthorizer{has: true},
+			sc:    http.StatusNoContent,
+		},
+		"permission_denied": {
+			token: "permission",
+			perm:  "roles:write",
+			mv:    &mockValidator{validsc: http.StatusNoContent, session: "uid", sessionsc: http.StatusOK},
+			ma:    &mockAuthorizer{},
+			sc:    http.StatusForbidden,
+		},
+		"permission_check_fails": {
+			token: "permission",
+			perm:  "roles:write",
+			mv:    &mockValidator{validsc: http.StatusNoContent, session: "uid", sessionsc: http.StatusOK},
+			ma:    &mockAuthorizer{hasErr: fmt.Errorf("some error")},
+			sc:    http.StatusInternalServerError,
+		},
+		"permission_session_fails": {
+			token: "permission",
+			perm:  "roles:write",
+			mv:    &mockValidator{validsc: http.StatusNoContent, sessionsc: http.StatusInternalServerError},
+			sc:    http.StatusInternalServerError,
+		},
+		"permission_invalid_session": {
+			token: "permission",
+			perm:  "roles:write",
+			mv:    &mockValidator{validsc: http.StatusTemporaryRedirect},
+			sc:    http.StatusTemporaryRedirect,
+		},
+		"api_key_permission": {
+			bearer: "usk_key.secret",
+			perm:   "roles:write",
+			mk:     &mockAPIKeyer{check: &shared.APIKey{UserID: "uid"}},
+			ma:     &mockAuthorizer{has: true},
+			sc:     http.StatusNoContent,
+		},
+		"api_key_permission_denied": {
+			bearer: "usk_key.secret",
+			perm:   "roles:write",
+			mk:     &mockAPIKeyer{check: &shared.APIKey{UserID: "uid"}},
+			ma:     &mockAuthorizer{},
+			sc:     http.StatusForbidden,
+		},
+		"api_key": {
+			bearer: "usk_key.secret",
+			mk:     &mockAPIKeyer{check: &shared.APIKey{Scopes: []string{"read"}}},
+			sc:     http.StatusNoContent,
+		},
+		"api_key_scope": {
+			bearer: "usk_key.secret",
+			scope:  "read",
+			mk:     &mockAPIKeyer{check: &shared.APIKey{Scopes: []string{"read"}}},
+			sc:     http.StatusNoContent,
+		},
+		"api_key_missing_scope": {
+			bearer: "usk_key.secret",
+			scope:  "write",
+			mk:     &mockAPIKeyer{check: &shared.APIKey{Scopes: []string{"read"}}},
+			sc:     http.StatusForbidden,
+		},
+		"api_key_bad": {
+			token:  "ignored",
+			bearer: "usk_key.secret",
+			mk:     &mockAPIKeyer{checkErr: shared.BadAPIKeyError},
+			sc:     http.StatusUnauthorized,
+		},
+		"api_key_expired": {
+			bearer: "usk_key.secret",
+			mk:     &mockAPIKeyer{checkErr: shared.APIKeyExpiredError},
+			sc:     http.StatusUnauthorized,
+		},
+		"api_key_check_fails": {
+			bearer: "usk_key.secret",
+			mk:     &mockAPIKeyer{checkErr: fmt.Errorf("some error")},
+			sc:     http.StatusInternalServerError,
+		},
 		"pass_through": {
 			token: "pass_through",
 			mv:    &mockValidator{validsc: http.StatusFound},
@@ -122,7 +285,7 @@ func Test_GetValid(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			us := &UserService{Validator: tc.mv}
+			us := &UserService{Validator: tc.mv, APIKeyer: tc.mk, Authorizer: tc.ma}
 			w := httptest.NewRecorder()
 			r, _ := http.NewRequestWithContext(
 				context.WithValue(
@@ -130,9 +293,12 @@ func Test_GetValid(t *testing.T) {
 					chi.RouteCtxKey,
 					chi.NewRouteContext()),
 				http.MethodPost,
-				"tc.url",
+				"tc.url?"+url.Values{"scope": {tc.scope}, "permission": {tc.perm}}.Encode(),
 				nil,
 			)
+			if tc.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.bearer)
+			}
 			if tc.token != "" {
 				r.AddCookie(&http.Cookie{
 					Name:    "us-authn",
@@ -214,13 +380,13 @@ func Test_GetLoginOTP(t *testing.T) {
 	}
 }
 
-func (mv *mockValidator) Login(context.Context, shared.UUID, string) (*http.Cookie, int) {
+func (mv *mockValidator) Login(context.Context, shared.UUID, string, string, valid.Client) (*http.Cookie, int) {
 	return mv.login, mv.loginsc
 }
-func (mv *mockValidator) Logout(context.Context, string) (*http.Cookie, int) {
+func (mv *mockValidator) Logout(context.Context, string, valid.Client) (*http.Cookie, int) {
 	return &testCookie, mv.logoutsc
 }
-func (mv *mockValidator) Valid(context.Context, string) (*http.Cookie, int) {
+func (mv *mockValidator) Valid(context.Context, string, valid.Client) (*http.Cookie, int) {
 	return &testCookie, mv.validsc
 }
 func (mv *mockValidator) OTP(context.Context, shared.UUID, string, string) (string, int) {
@@ -229,6 +395,65 @@ func (mv *mockValidator) OTP(context.Context, shared.UUID, string, string) (stri
 func (mv *mockValidator) LoginOTP(context.Context, string) (string, int) {
 	return mv.loginloc, mv.loginotpsc
 }
+func (mv *mockValidator) CheckOTP(context.Context, string) (shared.UUID, int) {
+	return mv.checkotp, mv.checkotpsc
+}
 func (mv *mockValidator) CompleteOTP(context.Context, string) (shared.UUID, int) {
 	return mv.completeotp, mv.completeotpsc
 }
+func (mv *mockValidator) BeginMFA(context.Context, shared.UUID, string) (string, int) {
+	return mv.beginmfa, mv.beginmfasc
+}
+func (mv *mockValidator) PendingMFA(context.Context, string) (shared.UUID, int) {
+	return mv.pendingmfa, mv.pendingmfasc
+}
+func (mv *mockValidator) EndMFA(context.Context, string) int {
+	return mv.endmfasc
+}
+func (mv *mockValidator) SendMFACode(context.Context, string) (string, int) {
+	return mv.sendcode, mv.sendcodesc
+}
+func (mv *mockValidator) CheckMFACode(context.Context, string, string) int {
+	return mv.checkcodesc
+}
+func (mv *mockValidator) Session(context.Context, string) (shared.UUID, int) {
+	return mv.session, mv.sessionsc
+}
+func (mv *mockValidator) BeginAuthCode(context.Context, *shared.AuthCode) (string, int) {
+	return mv.authcode, mv.authcodesc
+}
+func (mv *mockValidator) RedeemAuthCode(context.Context, string) (*shared.AuthCode, int) {
+	return mv.redeem, mv.redeemsc
+}
+func (mv *mockValidator) NewRefresh(context.Context, shared.UUID, string) (*http.Cookie, int) {
+	return mv.newrefresh, mv.newrefreshsc
+}
+func (mv *mockValidator) Refresh(ctx context.Context, _ string, _ valid.Client, active valid.Active) (*http.Cookie, *http.Cookie, int) {
+	if code := active(ctx, "uuid"); code != http.StatusOK {
+		return nil, nil, code
+	}
+	return mv.refreshsession, mv.refresh, mv.refreshsc
+}
+func (mv *mockValidator) RevokeRefresh(context.Context, string) (*http.Cookie, int) {
+	return &refreshCookie, mv.revokerefreshsc
+}
+func (mv *mockValidator) Sessions(context.Context, shared.UUID) ([]shared.Session, int) {
+	return mv.sessions, mv.sessionssc
+}
+func (mv *mockValidator) EndSession(context.Context, shared.UUID, string) int {
+	return mv.endsessionsc
+}
+func (mv *mockValidator) EndSessions(context.Context, shared.UUID) int {
+	return mv.endsessionssc
+}
+
+func (mv *mockValidator) SendMagicLink(context.Context, shared.UUID, string) (string, int) {
+	return mv.magiclink, mv.magiclinksc
+}
+func (mv *mockValidator) RedeemMagicLink(context.Context, string) (shared.UUID, int) {
+	return mv.redeemmagic, mv.redeemmagicsc
+}
+
+func (mv *mockValidator) Sweep(context.Context) int {
+	return http.StatusOK
+}
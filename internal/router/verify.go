@@ -0,0 +1,169 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/userservice/internal/oidc"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// GetEmailVerify is where a verification link lands; the link is signed, so
+// it doesn't need a session, but it's no good once the address has changed
+func (us UserService) GetEmailVerify(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	claims := &oidc.VerifyClaims{}
+	if us.VerifyKey == nil {
+		sc(http.StatusServiceUnavailable).send(ctx, w, oidc.NoVerifyKeyError, "email verification is off")
+	} else if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if token := r.URL.Query().Get("token"); token == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing token")
+	} else if err := us.VerifyKey.Parse(token, claims); err != nil {
+		sc(http.StatusForbidden).send(ctx, w, err, "bad or expired link")
+	} else if claims.Purpose != oidc.VerifyEmailPurpose || claims.Subject != string(id) || !claims.Email.Valid() {
+		sc(http.StatusForbidden).send(ctx, w, fmt.Errorf("not an email verification for %s", id), "bad or expired link")
+	} else if err = us.Userer.VerifyEmail(ctx, id, *claims.Email); errors.Is(err, shared.UserNotUpdatedError) {
+		sc(http.StatusGone).send(ctx, w, err, "the address changed since the link was sent")
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// PostEmailVerify sends another link, for when the first one expired or got
+// lost
+func (us UserService) PostEmailVerify(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if us.VerifyKey == nil {
+		sc(http.StatusServiceUnavailable).send(ctx, w, oidc.NoVerifyKeyError, "email verification is off")
+	} else if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if user, err := us.Userer.GetUser(ctx, id); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else if !user.Email.Valid() {
+		sc(http.StatusBadRequest).send(ctx, w, shared.Undeliverable, "no email to verify")
+	} else if user.EmailVerified != nil {
+		sc(http.StatusConflict).send(ctx, w, fmt.Errorf("email is already verified"), "email is already verified")
+	} else if err = us.sendEmailVerification(r, user); err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusAccepted).success(ctx, w)
+	}
+}
+
+// PostCellVerify texts a code to the user's cell; the usual code limits
+// apply, so it can't be used to spam a number
+func (us UserService) PostCellVerify(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if user, code, err := us.cellOnFile(r, id); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if user.CellVerified != nil {
+		sc(http.StatusConflict).send(ctx, w, fmt.Errorf("cell is already verified"), "cell is already verified")
+	} else if code, err = us.sendMFACode(r, cellKey(id, *user.Cell), user, shared.SMSChannel); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusAccepted).success(ctx, w)
+	}
+}
+
+// PatchCellVerify takes the code PostCellVerify sent; the code only works for
+// the number it went to, so changing the number in between means starting
+// over
+func (us UserService) PatchCellVerify(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if id := shared.UUID(chi.URLParam(r, "user_id")); id == "" {
+		sc(http.StatusBadRequest).send(ctx, w, shared.MissingParams, "missing uid")
+	} else if otp, err := readCode(r); err != nil {
+		sc(http.StatusBadRequest).send(ctx, w, err, "couldn't read code")
+	} else if user, code, err := us.cellOnFile(r, id); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if code, err = checkCode(us.Validator.CheckMFACode(ctx, cellKey(id, *user.Cell), otp)); err != nil {
+		sc(code).send(ctx, w, err, err.Error())
+	} else if err = us.Userer.VerifyCell(ctx, id, *user.Cell); errors.Is(err, shared.UserNotUpdatedError) {
+		sc(http.StatusGone).send(ctx, w, err, "the number changed since the code was sent")
+	} else if err != nil {
+		sc(http.StatusInternalServerError).send(ctx, w, err, err.Error())
+	} else {
+		sc(http.StatusNoContent).success(ctx, w)
+	}
+}
+
+// sendEmailVerification mails the user a signed link back to GetEmailVerify;
+// without a VerifyKey nothing is sent
+func (us UserService) sendEmailVerification(r *http.Request, user *shared.User) error {
+	now := time.Now().UTC()
+	token, err := us.VerifyKey.Sign(&oidc.VerifyClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    us.discovery.Issuer,
+			Subject:   string(user.UUID),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(us.verifyTimeout).Unix(),
+		},
+		Purpose: oidc.VerifyEmailPurpose,
+		Email:   user.Email,
+	})
+	if err != nil {
+		return err
+	}
+	return us.MailSender.Send(user.VerificationEmail(r.Host, token))
+}
+
+// emailChanged tells the old address it's been replaced, if it was ever
+// verified, and asks the new one to verify itself when verification is on;
+// the change is already saved by now, so failures only get logged
+func (us UserService) emailChanged(r *http.Request, old, user *shared.User) {
+	l := r.Context().Value(shared.CTXKey("log")).(*logrus.Entry)
+
+	if sameEmail(old.Email, user.Email) {
+		return
+	}
+
+	if old.Email.Valid() && old.EmailVerified != nil {
+		if err := us.MailSender.Send(user.EmailChangedEmail(*old.Email)); err != nil {
+			l.WithError(err).Error("couldn't notify the old email address")
+		}
+	}
+
+	if user.Email.Valid() && us.VerifyKey != nil {
+		if err := us.sendEmailVerification(r, user); err != nil {
+			l.WithError(err).Error("couldn't send email verification")
+		}
+	}
+}
+
+// cellOnFile is reachable for the cell verification endpoints, which are
+// how a cell gets verified in the first place, so it doesn't have to be yet
+func (us UserService) cellOnFile(r *http.Request, uid shared.UUID) (*shared.User, int, error) {
+	if user, err := us.Userer.GetUser(r.Context(), uid); err != nil {
+		return nil, http.StatusInternalServerError, err
+	} else if !user.Reachable(shared.SMSChannel) {
+		return nil, http.StatusBadRequest, shared.Undeliverable
+	} else {
+		return user, http.StatusOK, nil
+	}
+}
+
+func cellKey(uid shared.UUID, c shared.Cell) string {
+	return "cell:" + string(uid) + ":" + string(c)
+}
+
+func sameEmail(a, b *shared.Email) bool {
+	if !a.Valid() || !b.Valid() {
+		return a.Valid() == b.Valid()
+	}
+	return *a == *b
+}
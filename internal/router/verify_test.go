@@ -0,0 +1,402 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/oidc"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_GetEmailVerify(t *testing.T) {
+	t.Parallel()
+
+	addr := shared.Email("addr")
+	sign := func(purpose string, sub shared.UUID, e *shared.Email, expires time.Duration) string {
+		token, err := testVerifyKey.Sign(&oidc.VerifyClaims{
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    testIssuer,
+				Subject:   string(sub),
+				ExpiresAt: time.Now().Add(expires).Unix(),
+			},
+			Purpose: purpose,
+			Email:   e,
+		})
+		require.Nil(t, err)
+		return token
+	}
+	good := sign(oidc.VerifyEmailPurpose, "uuid", &addr, time.Hour)
+
+	// the provider's keys can be thrown away on restart, so they don't get
+	// to sign links that are good for days
+	oidcSigned, err := testKeys.Sign(&oidc.VerifyClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    testIssuer,
+			Subject:   "uuid",
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		Purpose: oidc.VerifyEmailPurpose,
+		Email:   &addr,
+	})
+	require.Nil(t, err)
+
+	tcs := map[string]struct {
+		u     *mockUserer
+		key   *oidc.VerifyKey
+		id    shared.UUID
+		token string
+		sc    int
+	}{
+		"happy_path": {
+			u:     &mockUserer{},
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: good,
+			sc:    http.StatusNoContent,
+		},
+		"no_verify_key": {
+			u:     &mockUserer{},
+			id:    "uuid",
+			token: good,
+			sc:    http.StatusServiceUnavailable,
+		},
+		"oidc_token": {
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: oidcSigned,
+			sc:    http.StatusForbidden,
+		},
+		"missing_id": {
+			key:   testVerifyKey,
+			token: good,
+			sc:    http.StatusBadRequest,
+		},
+		"missing_token": {
+			key: testVerifyKey,
+			id:  "uuid",
+			sc:  http.StatusBadRequest,
+		},
+		"garbage": {
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: "garbage",
+			sc:    http.StatusForbidden,
+		},
+		"expired": {
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: sign(oidc.VerifyEmailPurpose, "uuid", &addr, -time.Hour),
+			sc:    http.StatusForbidden,
+		},
+		"wrong_purpose": {
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: sign("", "uuid", &addr, time.Hour),
+			sc:    http.StatusForbidden,
+		},
+		"wrong_user": {
+			key:   testVerifyKey,
+			id:    "other",
+			token: good,
+			sc:    http.StatusForbidden,
+		},
+		"no_email": {
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: sign(oidc.VerifyEmailPurpose, "uuid", nil, time.Hour),
+			sc:    http.StatusForbidden,
+		},
+		"address_changed": {
+			u:     &mockUserer{verifyErr: shared.UserNotUpdatedError},
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: good,
+			sc:    http.StatusGone,
+		},
+		"verify_fails": {
+			u:     &mockUserer{verifyErr: fmt.Errorf("some error")},
+			key:   testVerifyKey,
+			id:    "uuid",
+			token: good,
+			sc:    http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Userer: tc.u, VerifyKey: tc.key}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams = chi.RouteParams{Keys: []string{"user_id"}, Values: []string{string(tc.id)}}
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(mockContext(), chi.RouteCtxKey, rctx),
+				http.MethodGet,
+				"/user/"+string(tc.id)+"/email/verify?token="+tc.token,
+				nil)
+
+			w := httptest.NewRecorder()
+			us.GetEmailVerify(w, r)
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_PostEmailVerify(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		u      *mockUserer
+		ms     mockMailSender
+		key    *oidc.VerifyKey
+		id     shared.UUID
+		sc     int
+		emails int
+	}{
+		"happy_path": {
+			u:      &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail}},
+			key:    testVerifyKey,
+			id:     "uuid",
+			sc:     http.StatusAccepted,
+			emails: 1,
+		},
+		"no_verify_key": {
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail}},
+			id: "uuid",
+			sc: http.StatusServiceUnavailable,
+		},
+		"missing_id": {
+			key: testVerifyKey,
+			sc:  http.StatusBadRequest,
+		},
+		"user_fails": {
+			u:   &mockUserer{userErr: fmt.Errorf("some error")},
+			key: testVerifyKey,
+			id:  "uuid",
+			sc:  http.StatusInternalServerError,
+		},
+		"no_email": {
+			u:   &mockUserer{user: &shared.User{UUID: "uuid", Cell: &testCell}},
+			key: testVerifyKey,
+			id:  "uuid",
+			sc:  http.StatusBadRequest,
+		},
+		"already_verified": {
+			u:   &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail, EmailVerified: &confirmed}},
+			key: testVerifyKey,
+			id:  "uuid",
+			sc:  http.StatusConflict,
+		},
+		"send_fails": {
+			u:      &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail}},
+			ms:     mockMailSender{err: fmt.Errorf("some error")},
+			key:    testVerifyKey,
+			id:     "uuid",
+			sc:     http.StatusInternalServerError,
+			emails: 1,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Userer: tc.u, MailSender: &tc.ms, VerifyKey: tc.key}
+
+			w := httptest.NewRecorder()
+			us.PostEmailVerify(w, totpRequest(http.MethodPost, tc.id, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.emails, tc.ms.msgs)
+		})
+	}
+}
+
+func Test_SameEmail(t *testing.T) {
+	t.Parallel()
+
+	a, b, empty := shared.Email("a"), shared.Email("b"), shared.Email("")
+
+	require.True(t, sameEmail(nil, nil))
+	require.True(t, sameEmail(nil, &empty))
+	require.True(t, sameEmail(&a, &a))
+	require.False(t, sameEmail(&a, &b))
+	require.False(t, sameEmail(&a, nil))
+	require.False(t, sameEmail(nil, &b))
+}
+
+func Test_PostCellVerify(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		u     *mockUserer
+		v     *mockValidator
+		ss    mockSmsSender
+		id    shared.UUID
+		sc    int
+		texts int
+	}{
+		"happy_path": {
+			u:     &mockUserer{user: &shared.User{UUID: "uuid", Cell: &testCell}},
+			v:     &mockValidator{sendcode: "123456", sendcodesc: http.StatusOK},
+			id:    "uuid",
+			sc:    http.StatusAccepted,
+			texts: 1,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"user_fails": {
+			u:  &mockUserer{userErr: fmt.Errorf("some error")},
+			id: "uuid",
+			sc: http.StatusInternalServerError,
+		},
+		"no_cell": {
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail}},
+			id: "uuid",
+			sc: http.StatusBadRequest,
+		},
+		"already_verified": {
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Cell: &testCell, CellVerified: &confirmed}},
+			id: "uuid",
+			sc: http.StatusConflict,
+		},
+		"too_soon": {
+			u:  &mockUserer{user: &shared.User{UUID: "uuid", Cell: &testCell}},
+			v:  &mockValidator{sendcodesc: http.StatusTooManyRequests},
+			id: "uuid",
+			sc: http.StatusTooManyRequests,
+		},
+		"send_fails": {
+			u:     &mockUserer{user: &shared.User{UUID: "uuid", Cell: &testCell}},
+			v:     &mockValidator{sendcode: "123456", sendcodesc: http.StatusOK},
+			ss:    mockSmsSender{err: fmt.Errorf("some error")},
+			id:    "uuid",
+			sc:    http.StatusInternalServerError,
+			texts: 1,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Userer: tc.u, Validator: tc.v, SmsSender: &tc.ss}
+
+			w := httptest.NewRecorder()
+			us.PostCellVerify(w, totpRequest(http.MethodPost, tc.id, ""))
+
+			require.Equal(t, tc.sc, w.Code)
+			require.Equal(t, tc.texts, tc.ss.msgs)
+		})
+	}
+}
+
+func Test_PatchCellVerify(t *testing.T) {
+	t.Parallel()
+
+	unverified := &mockUserer{user: &shared.User{UUID: "uuid", Cell: &testCell}}
+
+	tcs := map[string]struct {
+		u    *mockUserer
+		v    *mockValidator
+		id   shared.UUID
+		body string
+		sc   int
+	}{
+		"happy_path": {
+			u:    unverified,
+			v:    &mockValidator{checkcodesc: http.StatusOK},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusNoContent,
+		},
+		"missing_id": {
+			sc: http.StatusBadRequest,
+		},
+		"missing_code": {
+			id:   "uuid",
+			body: `{}`,
+			sc:   http.StatusBadRequest,
+		},
+		"no_cell": {
+			u:    &mockUserer{user: &shared.User{UUID: "uuid", Email: &testEmail}},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusBadRequest,
+		},
+		"wrong_code": {
+			u:    unverified,
+			v:    &mockValidator{checkcodesc: http.StatusUnauthorized},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusUnauthorized,
+		},
+		"too_many_attempts": {
+			u:    unverified,
+			v:    &mockValidator{checkcodesc: http.StatusTooManyRequests},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusTooManyRequests,
+		},
+		"number_changed": {
+			u: &mockUserer{
+				user:      &shared.User{UUID: "uuid", Cell: &testCell},
+				verifyErr: shared.UserNotUpdatedError,
+			},
+			v:    &mockValidator{checkcodesc: http.StatusOK},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusGone,
+		},
+		"verify_fails": {
+			u: &mockUserer{
+				user:      &shared.User{UUID: "uuid", Cell: &testCell},
+				verifyErr: fmt.Errorf("some error"),
+			},
+			v:    &mockValidator{checkcodesc: http.StatusOK},
+			id:   "uuid",
+			body: `{"code":"123456"}`,
+			sc:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			us := &UserService{Userer: tc.u, Validator: tc.v}
+
+			w := httptest.NewRecorder()
+			us.PatchCellVerify(w, totpRequest(http.MethodPatch, tc.id, tc.body))
+
+			require.Equal(t, tc.sc, w.Code)
+		})
+	}
+}
+
+func Test_CellKey(t *testing.T) {
+	t.Parallel()
+
+	// a code sent to one number is no good for another
+	require.NotEqual(t, cellKey("uuid", "1"), cellKey("uuid", "2"))
+	require.NotEqual(t, cellKey("uuid", "1"), enrollKey("uuid"))
+}
@@ -0,0 +1,81 @@
+package valid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// Session says who a login cookie belongs to, without sliding its expiry the
+// way Valid does
+func (v *core) Session(ctx context.Context, token string) (shared.UUID, int) {
+	t := v.tracker(ctx, "Session")
+
+	if key, _, err := v.session(token); err != nil {
+		return "", t.sc(http.StatusUnauthorized).err(err).done("unreadable token").sc()
+	} else if uid, err := v.authn.HGet(ctx, key, userid).Result(); err == redis.Nil {
+		return "", t.sc(http.StatusUnauthorized).err(NotAuthorized).done("no such session").sc()
+	} else if err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("looking up session").sc()
+	} else {
+		return shared.UUID(uid), t.sc(http.StatusOK).ok().sc()
+	}
+}
+
+// BeginAuthCode parks everything the token endpoint will need to check and
+// returns the code that stands for it
+func (v *core) BeginAuthCode(ctx context.Context, ac *shared.AuthCode) (string, int) {
+	t := v.tracker(ctx, "BeginAuthCode")
+
+	code := uuid.NewString()
+	key := "authz:" + code
+
+	if err := v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, key, map[string]interface{}{
+			clientid: ac.ClientID,
+			userid:   string(ac.UserID),
+			redirect: ac.RedirectURI,
+			scope:    ac.Scope,
+			nonce:    ac.Nonce,
+			pkce:     ac.Challenge,
+		})
+		tx.Expire(ctx, key, v.authzTimeout)
+	}); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("storing auth code").sc()
+	}
+
+	return code, t.sc(http.StatusOK).ok().sc()
+}
+
+// RedeemAuthCode hands back what a code stands for, exactly once
+func (v *core) RedeemAuthCode(ctx context.Context, code string) (*shared.AuthCode, int) {
+	t := v.tracker(ctx, "RedeemAuthCode")
+
+	key := "authz:" + code
+	if result, err := v.authn.HGetAll(ctx, key).Result(); err != nil {
+		return nil, t.sc(http.StatusInternalServerError).err(err).done(err.Error()).sc()
+	} else if result[userid] == "" {
+		return nil, t.sc(http.StatusBadRequest).err(NotAuthorized).done("no such auth code").sc()
+	} else if n, err := v.authn.Del(ctx, key).Result(); err != nil {
+		return nil, t.sc(http.StatusInternalServerError).err(err).done("clearing auth code").sc()
+	} else if n == 0 {
+		return nil, t.sc(http.StatusBadRequest).
+			err(fmt.Errorf("auth code was already redeemed")).
+			done("lost the race to redeem").
+			sc()
+	} else {
+		return &shared.AuthCode{
+			ClientID:    result[clientid],
+			UserID:      shared.UUID(result[userid]),
+			RedirectURI: result[redirect],
+			Scope:       result[scope],
+			Nonce:       result[nonce],
+			Challenge:   result[pkce],
+		}, t.sc(http.StatusOK).ok().sc()
+	}
+}
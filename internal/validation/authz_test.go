@@ -0,0 +1,146 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_Session(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_Session")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("lookup fails")
+	s.fail("HGet", "token:1", broken)
+	uid, sc := v.Session(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, shared.UUID(""), uid)
+
+	ctx = setcid("no session")
+	uid, sc = v.Session(ctx, "1")
+	require.Equal(t, http.StatusUnauthorized, sc)
+	require.Equal(t, shared.UUID(""), uid)
+
+	ctx = setcid("happy path")
+	seed(s, "token:1", loginFields(time.Now()))
+	uid, sc = v.Session(ctx, "1")
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, shared.UUID(userid), uid)
+	require.Equal(t, expireme, s.TTL(ctx, "token:1").Val(), "session doesn't slide the expiry")
+
+	require.Empty(t, s.faults)
+}
+
+func Test_BeginAuthCode(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_BeginAuthCode")
+	v := NewValidator(s, cfg, l)
+
+	ac := &shared.AuthCode{
+		ClientID:    "client",
+		UserID:      userid,
+		RedirectURI: "https://rp/cb",
+		Scope:       "openid",
+		Nonce:       "n",
+		Challenge:   "c",
+	}
+
+	ctx := setcid("store fails")
+	s.fail("HSet", "authz:*", broken)
+	code, sc := v.BeginAuthCode(ctx, ac)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, code)
+
+	ctx = setcid("expire fails")
+	s.fail("Expire", "authz:*", broken)
+	code, sc = v.BeginAuthCode(ctx, ac)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, code)
+
+	ctx = setcid("happy path")
+	code, sc = v.BeginAuthCode(ctx, ac)
+	require.Equal(t, http.StatusOK, sc)
+	require.NotEmpty(t, code)
+	require.Equal(t, map[string]string{
+		clientid: "client",
+		userid:   userid,
+		redirect: "https://rp/cb",
+		scope:    "openid",
+		nonce:    "n",
+		pkce:     "c",
+	}, s.HGetAll(ctx, "authz:"+code).Val())
+	require.Equal(t, time.Minute, s.TTL(ctx, "authz:"+code).Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_RedeemAuthCode(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_RedeemAuthCode")
+	v := NewValidator(s, cfg, l)
+
+	stored := map[string]interface{}{
+		clientid: "client",
+		userid:   userid,
+		redirect: "https://rp/cb",
+		scope:    "openid",
+		nonce:    "n",
+		pkce:     "c",
+	}
+
+	ctx := setcid("lookup fails")
+	s.fail("HGetAll", "authz:1", broken)
+	ac, sc := v.RedeemAuthCode(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Nil(t, ac)
+
+	ctx = setcid("no such code")
+	ac, sc = v.RedeemAuthCode(ctx, "1")
+	require.Equal(t, http.StatusBadRequest, sc)
+	require.Nil(t, ac)
+
+	s.HSet(ctx, "authz:1", stored)
+
+	ctx = setcid("clear fails")
+	s.fail("Del", "authz:1", broken)
+	ac, sc = v.RedeemAuthCode(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Nil(t, ac)
+
+	ctx = setcid("lost the race")
+	s.fail("Del", "authz:1", nil)
+	ac, sc = v.RedeemAuthCode(ctx, "1")
+	require.Equal(t, http.StatusBadRequest, sc)
+	require.Nil(t, ac)
+
+	ctx = setcid("happy path")
+	ac, sc = v.RedeemAuthCode(ctx, "1")
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, &shared.AuthCode{
+		ClientID:    "client",
+		UserID:      userid,
+		RedirectURI: "https://rp/cb",
+		Scope:       "openid",
+		Nonce:       "n",
+		Challenge:   "c",
+	}, ac)
+
+	ctx = setcid("a code is good once")
+	ac, sc = v.RedeemAuthCode(ctx, "1")
+	require.Equal(t, http.StatusBadRequest, sc)
+	require.Nil(t, ac)
+
+	require.Empty(t, s.faults)
+}
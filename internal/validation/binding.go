@@ -0,0 +1,78 @@
+package valid
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// what SESSION_BINDING can hold, comma separated; ip is stricter than subnet
+// so there's no point in having both
+const (
+	BindNone   = "none"
+	BindIP     = "ip"
+	BindSubnet = "subnet" // a /24 for ipv4 and a /64 for ipv6
+	BindAgent  = "agent"
+)
+
+// binding ties a session to the client that logged in; a request that
+// doesn't match gets treated like it has no session at all
+type binding struct {
+	ip, subnet, agent bool
+}
+
+func newBinding(policy []string) (binding, error) {
+	var result binding
+	for _, p := range policy {
+		switch strings.TrimSpace(p) {
+		case "", BindNone:
+		case BindIP:
+			result.ip = true
+		case BindSubnet:
+			result.subnet = true
+		case BindAgent:
+			result.agent = true
+		default:
+			return result, fmt.Errorf("unknown session binding: %q", p)
+		}
+	}
+	return result, nil
+}
+
+// allows says whether c may use a session that login started
+func (b binding) allows(login, c Client) bool {
+	if b.agent && login.UserAgent != c.UserAgent {
+		return false
+	} else if !b.ip && !b.subnet {
+		return true
+	}
+
+	then, err := parseAddr(login.Remote)
+	if err != nil {
+		return false
+	}
+	now, err := parseAddr(c.Remote)
+	if err != nil {
+		return false
+	} else if b.ip || then.Is4() != now.Is4() {
+		return then == now
+	}
+
+	bits := 64
+	if then.Is4() {
+		bits = 24
+	}
+	was, _ := then.Prefix(bits) // bits always fits the address family
+	is, _ := now.Prefix(bits)
+	return was == is
+}
+
+// parseAddr takes an address with or without a port; sessions from before
+// the port got dropped still have one
+func parseAddr(s string) (netip.Addr, error) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	return a.Unmap(), err
+}
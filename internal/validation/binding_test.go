@@ -0,0 +1,145 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newBinding(t *testing.T) {
+	t.Parallel()
+
+	b, err := newBinding([]string{"none"})
+	require.Nil(t, err)
+	require.Equal(t, binding{}, b)
+
+	b, err = newBinding([]string{"subnet", " agent"})
+	require.Nil(t, err)
+	require.Equal(t, binding{subnet: true, agent: true}, b)
+
+	_, err = newBinding([]string{"ip", "country"})
+	require.NotNil(t, err)
+}
+
+func Test_allows(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		b        binding
+		login, c Client
+		allowed  bool
+	}{
+		"none": {
+			login:   Client{Remote: "192.0.2.1", UserAgent: "agent"},
+			c:       Client{Remote: "198.51.100.1", UserAgent: "other"},
+			allowed: true,
+		},
+		"same_ip": {
+			b:       binding{ip: true},
+			login:   Client{Remote: "192.0.2.1"},
+			c:       Client{Remote: "192.0.2.1"},
+			allowed: true,
+		},
+		"old_sessions_kept_the_port": {
+			b:       binding{ip: true},
+			login:   Client{Remote: "192.0.2.1:1234"},
+			c:       Client{Remote: "192.0.2.1"},
+			allowed: true,
+		},
+		"different_ip": {
+			b:     binding{ip: true},
+			login: Client{Remote: "192.0.2.1"},
+			c:     Client{Remote: "192.0.2.2"},
+		},
+		"unparseable_ip": {
+			b:     binding{ip: true},
+			login: Client{Remote: "192.0.2.1"},
+			c:     Client{Remote: "pipe"},
+		},
+		"same_v4_subnet": {
+			b:       binding{subnet: true},
+			login:   Client{Remote: "192.0.2.1"},
+			c:       Client{Remote: "192.0.2.200"},
+			allowed: true,
+		},
+		"different_v4_subnet": {
+			b:     binding{subnet: true},
+			login: Client{Remote: "192.0.2.1"},
+			c:     Client{Remote: "192.0.3.1"},
+		},
+		"same_v6_subnet": {
+			b:       binding{subnet: true},
+			login:   Client{Remote: "2001:db8:0:1::1"},
+			c:       Client{Remote: "2001:db8:0:1:ffff::1"},
+			allowed: true,
+		},
+		"different_v6_subnet": {
+			b:     binding{subnet: true},
+			login: Client{Remote: "2001:db8:0:1::1"},
+			c:     Client{Remote: "2001:db8:0:2::1"},
+		},
+		"mixed_families": {
+			b:     binding{subnet: true},
+			login: Client{Remote: "192.0.2.1"},
+			c:     Client{Remote: "2001:db8::1"},
+		},
+		"mapped_v4": {
+			b:       binding{subnet: true},
+			login:   Client{Remote: "192.0.2.1"},
+			c:       Client{Remote: "::ffff:192.0.2.9"},
+			allowed: true,
+		},
+		"same_agent": {
+			b:       binding{agent: true},
+			login:   Client{Remote: "192.0.2.1", UserAgent: "agent"},
+			c:       Client{Remote: "198.51.100.1", UserAgent: "agent"},
+			allowed: true,
+		},
+		"different_agent": {
+			b:     binding{ip: true, agent: true},
+			login: Client{Remote: "192.0.2.1", UserAgent: "agent"},
+			c:     Client{Remote: "192.0.2.1", UserAgent: "other"},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.allowed, tc.b.allows(tc.login, tc.c))
+		})
+	}
+}
+
+func Test_ValidBinding(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	bound := *cfg
+	bound.SessionBinding = []string{BindIP}
+	v := NewValidator(s, &bound, logrus.WithField("test", "Test_ValidBinding"))
+
+	fields := loginFields(time.Now())
+	fields[remote] = "192.0.2.1"
+	seed(s, "token:token", fields)
+
+	ctx := setcid("someone else's cookie")
+	_, sc := v.Valid(ctx, "token", Client{Remote: "198.51.100.1", UserAgent: "agent"})
+	require.Equal(t, http.StatusTemporaryRedirect, sc)
+
+	ctx = setcid("same client")
+	_, sc = v.Valid(ctx, "token", Client{Remote: "192.0.2.1", UserAgent: "other"})
+	require.Equal(t, http.StatusNoContent, sc)
+
+	require.Empty(t, s.faults)
+
+	bound.SessionBinding = []string{"everything"}
+	require.Panics(t, func() {
+		_ = NewValidator(s, &bound, logrus.WithField("test", "Test_ValidBinding"))
+	})
+}
@@ -0,0 +1,68 @@
+package valid
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/jsmit257/userservice/internal/mfa"
+)
+
+// SendMFACode makes a new numeric code for whatever key identifies the
+// exchange (a pending mfa token, an enrollment) and hands it back for the
+// caller to deliver; a new code replaces the old one, but not more often
+// than the resend delay allows
+func (v *core) SendMFACode(ctx context.Context, key string) (string, int) {
+	t := v.tracker(ctx, "SendMFACode")
+
+	if ok, err := v.authn.SetNX(ctx, "mfaresend:"+key, 1, v.codeResend).Result(); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("throttling resend").sc()
+	} else if !ok {
+		return "", t.sc(http.StatusTooManyRequests).
+			err(fmt.Errorf("too soon to resend")).
+			done("too soon to resend").
+			sc()
+	} else if code, err := mfa.NumericCode(v.codeDigits); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("generating code").sc()
+	} else if err = v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, "mfacode:"+key, map[string]interface{}{
+			mfacode:  code,
+			attempts: 0,
+		})
+		tx.Expire(ctx, "mfacode:"+key, v.codeTimeout)
+	}); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("storing code").sc()
+	} else {
+		return code, t.sc(http.StatusOK).ok().sc()
+	}
+}
+
+// CheckMFACode compares a code against the last one sent for key; the code is
+// good for one match or a few misses, whichever comes first
+func (v *core) CheckMFACode(ctx context.Context, key, code string) int {
+	t := v.tracker(ctx, "CheckMFACode")
+
+	k := "mfacode:" + key
+	if result, err := v.authn.HGetAll(ctx, k).Result(); err != nil {
+		return t.sc(http.StatusInternalServerError).err(err).done(err.Error()).sc()
+	} else if want := result[mfacode]; want == "" {
+		return t.sc(http.StatusForbidden).err(NotAuthorized).done("no code was sent or it expired").sc()
+	} else if n, err := v.authn.HIncrBy(ctx, k, attempts, 1).Result(); err != nil {
+		return t.sc(http.StatusInternalServerError).err(err).done("counting attempts").sc()
+	} else if n > v.codeAttempts {
+		if err = v.authn.Del(ctx, k).Err(); err != nil {
+			return t.sc(http.StatusInternalServerError).err(err).done("clearing code").sc()
+		}
+		return t.sc(http.StatusTooManyRequests).
+			err(fmt.Errorf("too many attempts")).
+			done("too many attempts").
+			sc()
+	} else if subtle.ConstantTimeCompare([]byte(want), []byte(code)) != 1 {
+		return t.sc(http.StatusUnauthorized).err(NotAuthorized).done("wrong code").sc()
+	} else if err = v.authn.Del(ctx, k).Err(); err != nil {
+		return t.sc(http.StatusInternalServerError).err(err).done("clearing code").sc()
+	}
+
+	return t.sc(http.StatusOK).ok().sc()
+}
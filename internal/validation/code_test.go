@@ -0,0 +1,105 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SendMFACode(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_SendMFACode")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("throttle fails")
+	s.fail("SetNX", "mfaresend:1", broken)
+	code, sc := v.SendMFACode(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, code)
+
+	ctx = setcid("too soon")
+	s.SetNX(ctx, "mfaresend:1", 1, 30*time.Second)
+	code, sc = v.SendMFACode(ctx, "1")
+	require.Equal(t, http.StatusTooManyRequests, sc)
+	require.Empty(t, code)
+
+	ctx = setcid("store fails")
+	s.Del(ctx, "mfaresend:1")
+	s.fail("HSet", "mfacode:1", broken)
+	code, sc = v.SendMFACode(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, code)
+
+	ctx = setcid("expire fails")
+	s.Del(ctx, "mfaresend:1")
+	s.fail("Expire", "mfacode:1", broken)
+	code, sc = v.SendMFACode(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, code)
+
+	ctx = setcid("happy path")
+	s.Del(ctx, "mfaresend:1")
+	code, sc = v.SendMFACode(ctx, "1")
+	require.Equal(t, http.StatusOK, sc)
+	require.Regexp(t, "^[0-9]{6}$", code)
+	require.Equal(t, map[string]string{mfacode: code, attempts: "0"}, s.HGetAll(ctx, "mfacode:1").Val())
+	require.Equal(t, 10*time.Minute, s.TTL(ctx, "mfacode:1").Val())
+	require.Equal(t, 30*time.Second, s.TTL(ctx, "mfaresend:1").Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_CheckMFACode(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_CheckMFACode")
+	v := NewValidator(s, cfg, l)
+
+	// sent is a code that's been tried n times already
+	sent := func(n int) {
+		s.HSet(ctx, "mfacode:1", mfacode, "123456", attempts, n)
+	}
+
+	ctx := setcid("lookup fails")
+	s.fail("HGetAll", "mfacode:1", broken)
+	require.Equal(t, http.StatusInternalServerError, v.CheckMFACode(ctx, "1", "123456"))
+
+	ctx = setcid("nothing sent")
+	require.Equal(t, http.StatusForbidden, v.CheckMFACode(ctx, "1", "123456"))
+
+	ctx = setcid("counting fails")
+	sent(0)
+	s.fail("HIncrBy", "mfacode:1", broken)
+	require.Equal(t, http.StatusInternalServerError, v.CheckMFACode(ctx, "1", "123456"))
+
+	ctx = setcid("too many attempts")
+	sent(3)
+	require.Equal(t, http.StatusTooManyRequests, v.CheckMFACode(ctx, "1", "123456"))
+	require.Equal(t, int64(0), s.Exists(ctx, "mfacode:1").Val())
+
+	ctx = setcid("too many attempts, clear fails")
+	sent(3)
+	s.fail("Del", "mfacode:1", broken)
+	require.Equal(t, http.StatusInternalServerError, v.CheckMFACode(ctx, "1", "123456"))
+
+	ctx = setcid("wrong code")
+	sent(0)
+	require.Equal(t, http.StatusUnauthorized, v.CheckMFACode(ctx, "1", "654321"))
+	require.Equal(t, "1", s.HGet(ctx, "mfacode:1", attempts).Val())
+
+	ctx = setcid("clear fails")
+	s.fail("Del", "mfacode:1", broken)
+	require.Equal(t, http.StatusInternalServerError, v.CheckMFACode(ctx, "1", "123456"))
+
+	ctx = setcid("happy path")
+	require.Equal(t, http.StatusOK, v.CheckMFACode(ctx, "1", "123456"))
+	require.Equal(t, int64(0), s.Exists(ctx, "mfacode:1").Val())
+
+	require.Empty(t, s.faults)
+}
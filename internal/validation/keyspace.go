@@ -0,0 +1,133 @@
+package valid
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyVersion is the layout of everything the validator keeps: what keys are
+// called, what they hold and how they point at each other. Change it along
+// with the layout and a new version starts out empty beside the old one;
+// sessions in the old one can be carried over by hand, or left to expire.
+// The first layout is older than versions and doesn't have one, so it's
+// empty: keys stay where they always were and nobody gets logged out
+const KeyVersion = ""
+
+type (
+	// keys puts every key the validator names under a namespace, so it never
+	// sees anyone else's; set members that name keys stay as the validator
+	// wrote them, and get the namespace when they're used as keys
+	keys struct {
+		Cmds
+		ns string
+	}
+
+	keyspace struct {
+		keys
+		store Store
+	}
+)
+
+// NewKeyspace keeps store's keys under prefix and KeyVersion, so several
+// environments and several layouts can share one redis
+func NewKeyspace(store Store, prefix string) Store {
+	return keyspace{keys{store, Namespace(prefix)}, store}
+}
+
+// Namespace is what goes in front of every key: prefix, then KeyVersion,
+// whichever of them aren't empty
+func Namespace(prefix string) string {
+	var result string
+	for _, part := range []string{prefix, KeyVersion} {
+		if part != "" {
+			result += part + ":"
+		}
+	}
+	return result
+}
+
+func (s keyspace) Atomic(ctx context.Context, fn func(Cmds)) error {
+	return s.store.Atomic(ctx, func(tx Cmds) {
+		fn(keys{tx, s.ns})
+	})
+}
+
+func (k keys) name(key string) string {
+	return k.ns + key
+}
+
+func (k keys) names(keys []string) []string {
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = k.name(key)
+	}
+	return result
+}
+
+func (k keys) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	return k.Cmds.Del(ctx, k.names(keys)...)
+}
+
+func (k keys) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	return k.Cmds.Exists(ctx, k.names(keys)...)
+}
+
+func (k keys) Expire(ctx context.Context, key string, d time.Duration) *redis.BoolCmd {
+	return k.Cmds.Expire(ctx, k.name(key), d)
+}
+
+func (k keys) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	return k.Cmds.HDel(ctx, k.name(key), fields...)
+}
+
+func (k keys) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	return k.Cmds.HGet(ctx, k.name(key), field)
+}
+
+func (k keys) HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd {
+	return k.Cmds.HGetAll(ctx, k.name(key))
+}
+
+func (k keys) HIncrBy(ctx context.Context, key, field string, n int64) *redis.IntCmd {
+	return k.Cmds.HIncrBy(ctx, k.name(key), field, n)
+}
+
+func (k keys) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	return k.Cmds.HMGet(ctx, k.name(key), fields...)
+}
+
+func (k keys) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	return k.Cmds.HSet(ctx, k.name(key), values...)
+}
+
+func (k keys) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return k.Cmds.SAdd(ctx, k.name(key), members...)
+}
+
+func (k keys) SetNX(ctx context.Context, key string, value interface{}, d time.Duration) *redis.BoolCmd {
+	return k.Cmds.SetNX(ctx, k.name(key), value, d)
+}
+
+func (k keys) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	return k.Cmds.SMembers(ctx, k.name(key))
+}
+
+func (k keys) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return k.Cmds.SRem(ctx, k.name(key), members...)
+}
+
+func (k keys) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	return k.Cmds.TTL(ctx, k.name(key))
+}
+
+// Scan only finds keys in the namespace, and hands them back without it
+func (k keys) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	found, next, err := k.Cmds.Scan(ctx, cursor, k.name(match), count).Result()
+	for i := range found {
+		found[i] = strings.TrimPrefix(found[i], k.ns)
+	}
+	return redis.NewScanCmdResult(found, next, err)
+}
@@ -0,0 +1,86 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_Namespace(t *testing.T) {
+	t.Parallel()
+
+	// the first layout has no version, so keys from before keyspaces are
+	// still found
+	require.Equal(t, "", Namespace(""))
+	require.Equal(t, "staging:", Namespace("staging"))
+}
+
+func Test_Keyspace(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	s := NewKeyspace(store, "env")
+
+	require.Equal(t, int64(1), s.HSet(ctx, "token:1", userid, "uid").Val())
+	require.Equal(t, "uid", store.HGet(ctx, "env:token:1", userid).Val())
+	require.Equal(t, "uid", s.HGet(ctx, "token:1", userid).Val())
+
+	require.Equal(t, int64(0), store.Exists(ctx, "token:1").Val())
+	require.Equal(t, int64(1), s.Del(ctx, "token:1", "pad:2").Val())
+
+	// members are left alone
+	require.Nil(t, s.Atomic(ctx, func(tx Cmds) {
+		tx.SAdd(ctx, "logins:uid", "token:1")
+		tx.Expire(ctx, "logins:uid", time.Minute)
+	}))
+	require.Equal(t, []string{"token:1"}, store.SMembers(ctx, "env:logins:uid").Val())
+	require.Equal(t, []string{"token:1"}, s.SMembers(ctx, "logins:uid").Val())
+	require.Equal(t, time.Minute, store.TTL(ctx, "env:logins:uid").Val())
+
+	store.HSet(ctx, "env:token:2", userid, "uid")
+	store.HSet(ctx, "token:3", userid, "uid") // somebody else's
+	keys, _ := s.Scan(ctx, 0, "token:*", sweepBatch).Val()
+	require.Equal(t, []string{"token:2"}, keys)
+}
+
+// Test_KeyspaceUnprefixed is a deploy without a prefix picking up where the
+// one before keyspaces left off
+func Test_KeyspaceUnprefixed(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	require.Nil(t, store.HSet(ctx, "token:old", userid, "uid").Err())
+
+	s := NewKeyspace(store, "")
+	require.Equal(t, "uid", s.HGet(ctx, "token:old", userid).Val())
+}
+
+// Test_KeyspaceValidator is a session under a namespace: everything the
+// validator left behind is in it, and Sweep finds it there
+func Test_KeyspaceValidator(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore()
+	v := NewValidator(NewKeyspace(store, "env"), cfg, logrus.WithField("test", "Test_KeyspaceValidator"))
+	c := Client{Remote: "remote"}
+
+	ctx := setcid("keyspace validator")
+	cookie, sc := v.Login(ctx, shared.UUID("keyspace"), "name", PasswordLogin, c)
+	require.Equal(t, http.StatusOK, sc)
+
+	keys, _ := store.Scan(ctx, 0, "*", sweepBatch).Val()
+	require.Len(t, keys, 2)
+	for _, key := range keys {
+		require.Regexp(t, "^env:(token|logins):", key)
+	}
+
+	require.Equal(t, http.StatusOK, v.Sweep(ctx))
+
+	_, sc = v.Valid(ctx, cookie.Value, c)
+	require.Equal(t, http.StatusNoContent, sc)
+}
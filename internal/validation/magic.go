@@ -0,0 +1,77 @@
+package valid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// SendMagicLink makes a single use login token for uid and hands it back for
+// the caller to deliver; sends to one user are spaced out by the resend
+// delay and capped per window, so nobody can flood an inbox
+func (v *core) SendMagicLink(ctx context.Context, uid shared.UUID, rmt string) (string, int) {
+	t := v.tracker(ctx, "SendMagicLink")
+
+	token := uuid.NewString()
+	key := "magic:" + token
+	sent := "magicsent:" + string(uid)
+
+	if ok, err := v.authn.SetNX(ctx, "magicresend:"+string(uid), 1, v.magicResend).Result(); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("throttling resend").sc()
+	} else if !ok {
+		return "", t.sc(http.StatusTooManyRequests).
+			err(fmt.Errorf("too soon to resend")).
+			done("too soon to resend").
+			sc()
+	} else if n, err := v.count(ctx, sent, v.magicWindow); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("counting sends").sc()
+	} else if v.magicMaxSends > 0 && n > v.magicMaxSends {
+		return "", t.sc(http.StatusTooManyRequests).
+			err(fmt.Errorf("too many sends")).
+			done("too many sends").
+			sc()
+	} else if err = v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, key, map[string]interface{}{
+			userid: string(uid),
+			remote: rmt,
+		})
+		tx.Expire(ctx, key, v.magicTimeout)
+	}); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("storing link").sc()
+	}
+
+	return token, t.sc(http.StatusOK).ok().sc()
+}
+
+// RedeemMagicLink is good once: whoever deletes the token first gets the
+// user, anyone after that gets turned away
+func (v *core) RedeemMagicLink(ctx context.Context, token string) (shared.UUID, int) {
+	t := v.tracker(ctx, "RedeemMagicLink")
+
+	key := "magic:" + token
+	if result, err := v.authn.HGetAll(ctx, key).Result(); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("reading link").sc()
+	} else if uid := result[userid]; uid == "" {
+		return "", t.sc(http.StatusForbidden).err(NotAuthorized).done("no such link or it expired").sc()
+	} else if n, err := v.authn.Del(ctx, key).Result(); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("using link").sc()
+	} else if n != 1 {
+		return "", t.sc(http.StatusForbidden).err(NotAuthorized).done("link was already used").sc()
+	} else {
+		return shared.UUID(uid), t.sc(http.StatusOK).ok().sc()
+	}
+}
+
+// count bumps the counter in key, and starts its window on the first one
+func (v *core) count(ctx context.Context, key string, window time.Duration) (int64, error) {
+	n, err := v.authn.HIncrBy(ctx, key, attempts, 1).Result()
+	if err == nil && n == 1 {
+		err = v.authn.Expire(ctx, key, window).Err()
+	}
+	return n, err
+}
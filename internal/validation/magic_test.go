@@ -0,0 +1,131 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SendMagicLink(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_SendMagicLink")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("throttle fails")
+	s.fail("SetNX", "magicresend:1", broken)
+	token, sc := v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("too soon")
+	s.SetNX(ctx, "magicresend:1", 1, time.Minute)
+	token, sc = v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusTooManyRequests, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("count fails")
+	s.Del(ctx, "magicresend:1")
+	s.fail("HIncrBy", "magicsent:1", broken)
+	token, sc = v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("window fails")
+	s.Del(ctx, "magicresend:1")
+	s.fail("Expire", "magicsent:1", broken)
+	token, sc = v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("store fails")
+	s.Del(ctx, "magicresend:1")
+	s.fail("HSet", "magic:*", broken)
+	token, sc = v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("too many")
+	s.Del(ctx, "magicresend:1")
+	s.HSet(ctx, "magicsent:1", attempts, 3)
+	token, sc = v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusTooManyRequests, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("happy path")
+	s.Del(ctx, "magicresend:1", "magicsent:1")
+	token, sc = v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusOK, sc)
+	require.NotEmpty(t, token)
+	require.Equal(t, map[string]string{userid: "1", remote: remote}, s.HGetAll(ctx, "magic:"+token).Val())
+	require.Equal(t, 10*time.Minute, s.TTL(ctx, "magic:"+token).Val())
+	require.Equal(t, time.Hour, s.TTL(ctx, "magicsent:1").Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_RedeemMagicLink(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_RedeemMagicLink")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("lookup fails")
+	s.fail("HGetAll", "magic:token", broken)
+	uid, sc := v.RedeemMagicLink(ctx, "token")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, uid)
+
+	ctx = setcid("expired")
+	uid, sc = v.RedeemMagicLink(ctx, "token")
+	require.Equal(t, http.StatusForbidden, sc)
+	require.Empty(t, uid)
+
+	s.HSet(ctx, "magic:token", userid, "1", remote, remote)
+
+	ctx = setcid("delete fails")
+	s.fail("Del", "magic:token", broken)
+	uid, sc = v.RedeemMagicLink(ctx, "token")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, uid)
+
+	ctx = setcid("someone else got there first")
+	s.fail("Del", "magic:token", nil)
+	uid, sc = v.RedeemMagicLink(ctx, "token")
+	require.Equal(t, http.StatusForbidden, sc)
+	require.Empty(t, uid)
+
+	ctx = setcid("happy path")
+	uid, sc = v.RedeemMagicLink(ctx, "token")
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, "1", string(uid))
+	require.Equal(t, int64(0), s.Exists(ctx, "magic:token").Val())
+
+	require.Empty(t, s.faults)
+}
+
+// Test_MagicLinkOnce is a link used twice
+func Test_MagicLinkOnce(t *testing.T) {
+	t.Parallel()
+
+	v := NewValidator(NewMemoryStore(), cfg, logrus.WithField("test", "Test_MagicLinkOnce"))
+
+	ctx := setcid("magic link once")
+	token, sc := v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusOK, sc)
+
+	_, sc = v.SendMagicLink(ctx, "1", remote)
+	require.Equal(t, http.StatusTooManyRequests, sc, "resend is throttled")
+
+	uid, sc := v.RedeemMagicLink(ctx, token)
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, "1", string(uid))
+
+	_, sc = v.RedeemMagicLink(ctx, token)
+	require.Equal(t, http.StatusForbidden, sc)
+}
@@ -0,0 +1,526 @@
+package valid
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// memStore keeps everything in a map behind one lock, so Atomic is just
+	// holding the lock; like redis, a command that fails in the middle of
+	// Atomic doesn't undo the ones before it
+	memStore struct {
+		mu sync.Mutex
+		m  *memory
+	}
+
+	// memory is the unlocked half of memStore, what Atomic hands to fn
+	memory struct {
+		keys map[string]*entry
+		now  func() time.Time
+	}
+
+	// entry is one key: exactly one of str, hash and set is in use
+	entry struct {
+		str     *string
+		hash    map[string]string
+		set     map[string]struct{}
+		expires time.Time // zero never expires
+	}
+)
+
+// NewMemoryStore is a Store for a single process: nothing is shared with
+// other instances and nothing survives a restart
+func NewMemoryStore() Store {
+	return &memStore{m: &memory{
+		keys: map[string]*entry{},
+		now:  func() time.Time { return time.Now().UTC() },
+	}}
+}
+
+func (s *memStore) Atomic(_ context.Context, fn func(Cmds)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := &recorder{Cmds: s.m}
+	fn(rec)
+	return rec.err
+}
+
+func (s *memStore) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Del(ctx, keys...)
+}
+
+func (s *memStore) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Exists(ctx, keys...)
+}
+
+func (s *memStore) Expire(ctx context.Context, key string, d time.Duration) *redis.BoolCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Expire(ctx, key, d)
+}
+
+func (s *memStore) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.HDel(ctx, key, fields...)
+}
+
+func (s *memStore) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.HGet(ctx, key, field)
+}
+
+func (s *memStore) HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.HGetAll(ctx, key)
+}
+
+func (s *memStore) HIncrBy(ctx context.Context, key, field string, n int64) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.HIncrBy(ctx, key, field, n)
+}
+
+func (s *memStore) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.HMGet(ctx, key, fields...)
+}
+
+func (s *memStore) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.HSet(ctx, key, values...)
+}
+
+func (s *memStore) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.SAdd(ctx, key, members...)
+}
+
+func (s *memStore) SetNX(ctx context.Context, key string, value interface{}, d time.Duration) *redis.BoolCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.SetNX(ctx, key, value, d)
+}
+
+func (s *memStore) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.SMembers(ctx, key)
+}
+
+func (s *memStore) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.SRem(ctx, key, members...)
+}
+
+func (s *memStore) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.TTL(ctx, key)
+}
+
+func (s *memStore) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Scan(ctx, cursor, match, count)
+}
+
+// get is nil for a key that isn't there, expired keys included
+func (m *memory) get(key string) *entry {
+	e, ok := m.keys[key]
+	if !ok {
+		return nil
+	} else if !e.expires.IsZero() && !m.now().Before(e.expires) {
+		delete(m.keys, key)
+		return nil
+	}
+	return e
+}
+
+func (m *memory) hash(key string, create bool) (*entry, error) {
+	if e := m.get(key); e == nil && create {
+		e = &entry{hash: map[string]string{}}
+		m.keys[key] = e
+		return e, nil
+	} else if e != nil && e.hash == nil {
+		return nil, WrongTypeError
+	} else {
+		return e, nil
+	}
+}
+
+func (m *memory) set(key string, create bool) (*entry, error) {
+	if e := m.get(key); e == nil && create {
+		e = &entry{set: map[string]struct{}{}}
+		m.keys[key] = e
+		return e, nil
+	} else if e != nil && e.set == nil {
+		return nil, WrongTypeError
+	} else {
+		return e, nil
+	}
+}
+
+func (m *memory) Del(_ context.Context, keys ...string) *redis.IntCmd {
+	var n int64
+	for _, key := range keys {
+		if m.get(key) != nil {
+			delete(m.keys, key)
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *memory) Exists(_ context.Context, keys ...string) *redis.IntCmd {
+	var n int64
+	for _, key := range keys {
+		if m.get(key) != nil {
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *memory) Expire(_ context.Context, key string, d time.Duration) *redis.BoolCmd {
+	e := m.get(key)
+	if e == nil {
+		return redis.NewBoolResult(false, nil)
+	} else if d <= 0 {
+		delete(m.keys, key)
+	} else {
+		e.expires = m.now().Add(d)
+	}
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *memory) HDel(_ context.Context, key string, fields ...string) *redis.IntCmd {
+	e, err := m.hash(key, false)
+	if e == nil {
+		return redis.NewIntResult(0, err)
+	}
+
+	var n int64
+	for _, f := range fields {
+		if _, ok := e.hash[f]; ok {
+			delete(e.hash, f)
+			n++
+		}
+	}
+	if len(e.hash) == 0 {
+		delete(m.keys, key)
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *memory) HGet(_ context.Context, key, field string) *redis.StringCmd {
+	if e, err := m.hash(key, false); e == nil {
+		if err == nil {
+			err = redis.Nil
+		}
+		return redis.NewStringResult("", err)
+	} else if v, ok := e.hash[field]; !ok {
+		return redis.NewStringResult("", redis.Nil)
+	} else {
+		return redis.NewStringResult(v, nil)
+	}
+}
+
+func (m *memory) HGetAll(_ context.Context, key string) *redis.MapStringStringCmd {
+	result := map[string]string{}
+	e, err := m.hash(key, false)
+	if e != nil {
+		for f, v := range e.hash {
+			result[f] = v
+		}
+	}
+	return redis.NewMapStringStringResult(result, err)
+}
+
+func (m *memory) HIncrBy(_ context.Context, key, field string, n int64) *redis.IntCmd {
+	e, err := m.hash(key, true)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+
+	var was int64
+	if v, ok := e.hash[field]; ok {
+		if was, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return redis.NewIntResult(0, fmt.Errorf("ERR hash value is not an integer"))
+		}
+	}
+	e.hash[field] = strconv.FormatInt(was+n, 10)
+	return redis.NewIntResult(was+n, nil)
+}
+
+func (m *memory) HMGet(_ context.Context, key string, fields ...string) *redis.SliceCmd {
+	result := make([]interface{}, len(fields))
+	e, err := m.hash(key, false)
+	if e != nil {
+		for i, f := range fields {
+			if v, ok := e.hash[f]; ok {
+				result[i] = v
+			}
+		}
+	}
+	return redis.NewSliceResult(result, err)
+}
+
+func (m *memory) HSet(_ context.Context, key string, values ...interface{}) *redis.IntCmd {
+	pairs, err := FieldValues(values)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+
+	e, err := m.hash(key, true)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+
+	var n int64
+	for i := 0; i < len(pairs); i += 2 {
+		if _, ok := e.hash[pairs[i]]; !ok {
+			n++
+		}
+		e.hash[pairs[i]] = pairs[i+1]
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *memory) SAdd(_ context.Context, key string, members ...interface{}) *redis.IntCmd {
+	e, err := m.set(key, true)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+
+	var n int64
+	for _, member := range members {
+		k := fmt.Sprint(member)
+		if _, ok := e.set[k]; !ok {
+			e.set[k] = struct{}{}
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *memory) SetNX(_ context.Context, key string, value interface{}, d time.Duration) *redis.BoolCmd {
+	if m.get(key) != nil {
+		return redis.NewBoolResult(false, nil)
+	}
+
+	v := fmt.Sprint(value)
+	e := &entry{str: &v}
+	if d > 0 {
+		e.expires = m.now().Add(d)
+	}
+	m.keys[key] = e
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *memory) SMembers(_ context.Context, key string) *redis.StringSliceCmd {
+	result := []string{}
+	e, err := m.set(key, false)
+	if e != nil {
+		for member := range e.set {
+			result = append(result, member)
+		}
+		sort.Strings(result)
+	}
+	return redis.NewStringSliceResult(result, err)
+}
+
+func (m *memory) SRem(_ context.Context, key string, members ...interface{}) *redis.IntCmd {
+	e, err := m.set(key, false)
+	if e == nil {
+		return redis.NewIntResult(0, err)
+	}
+
+	var n int64
+	for _, member := range members {
+		k := fmt.Sprint(member)
+		if _, ok := e.set[k]; ok {
+			delete(e.set, k)
+			n++
+		}
+	}
+	if len(e.set) == 0 {
+		delete(m.keys, key)
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+// TTL follows redis: -2 for no key, -1 for a key that never expires
+func (m *memory) TTL(_ context.Context, key string) *redis.DurationCmd {
+	if e := m.get(key); e == nil {
+		return redis.NewDurationResult(-2, nil)
+	} else if e.expires.IsZero() {
+		return redis.NewDurationResult(-1, nil)
+	} else {
+		return redis.NewDurationResult(e.expires.Sub(m.now()).Round(time.Second), nil)
+	}
+}
+
+// Scan does it all in one go, so the cursor always comes back 0; expired
+// keys it passes over are dropped for good
+func (m *memory) Scan(_ context.Context, _ uint64, match string, _ int64) *redis.ScanCmd {
+	result := []string{}
+	for key := range m.keys {
+		if m.get(key) == nil {
+			continue
+		} else if ok, err := path.Match(match, key); err != nil {
+			return redis.NewScanCmdResult(nil, 0, err)
+		} else if ok {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return redis.NewScanCmdResult(result, 0, nil)
+}
+
+// FieldValues flattens HSet's arguments into field, value, field, value the
+// way go-redis does, for the shapes the validator uses; it's for stores that
+// aren't redis
+func FieldValues(values []interface{}) ([]string, error) {
+	if len(values) == 1 {
+		if m, ok := values[0].(map[string]interface{}); ok {
+			result := make([]string, 0, 2*len(m))
+			for f, v := range m {
+				result = append(result, f, fmt.Sprint(v))
+			}
+			return result, nil
+		}
+	}
+
+	if len(values) == 0 || len(values)%2 != 0 {
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'hset' command")
+	}
+
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = fmt.Sprint(v)
+	}
+	return result, nil
+}
+
+// recorder keeps the first error out of everything run through it, which is
+// what Atomic returns for stores that run commands as they come
+type recorder struct {
+	Cmds
+	err error
+}
+
+func (r *recorder) keep(err error) {
+	if r.err == nil && err != nil && err != redis.Nil {
+		r.err = err
+	}
+}
+
+func (r *recorder) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	result := r.Cmds.Del(ctx, keys...)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	result := r.Cmds.Exists(ctx, keys...)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) Expire(ctx context.Context, key string, d time.Duration) *redis.BoolCmd {
+	result := r.Cmds.Expire(ctx, key, d)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	result := r.Cmds.HDel(ctx, key, fields...)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	result := r.Cmds.HGet(ctx, key, field)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd {
+	result := r.Cmds.HGetAll(ctx, key)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) HIncrBy(ctx context.Context, key, field string, n int64) *redis.IntCmd {
+	result := r.Cmds.HIncrBy(ctx, key, field, n)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	result := r.Cmds.HMGet(ctx, key, fields...)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	result := r.Cmds.HSet(ctx, key, values...)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	result := r.Cmds.SAdd(ctx, key, members...)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) SetNX(ctx context.Context, key string, value interface{}, d time.Duration) *redis.BoolCmd {
+	result := r.Cmds.SetNX(ctx, key, value, d)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	result := r.Cmds.SMembers(ctx, key)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	result := r.Cmds.SRem(ctx, key, members...)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	result := r.Cmds.TTL(ctx, key)
+	r.keep(result.Err())
+	return result
+}
+
+func (r *recorder) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	result := r.Cmds.Scan(ctx, cursor, match, count)
+	r.keep(result.Err())
+	return result
+}
@@ -0,0 +1,151 @@
+package valid
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	s := NewMemoryStore().(*memStore)
+	s.m.now = func() time.Time { return now }
+
+	// hashes
+	require.Equal(t, int64(2), s.HSet(ctx, "h", "a", 1, "b", "two").Val())
+	require.Equal(t, int64(1), s.HSet(ctx, "h", map[string]interface{}{"b": 2, "c": 3}).Val())
+	require.Equal(t, "2", s.HGet(ctx, "h", "b").Val())
+	require.Equal(t, redis.Nil, s.HGet(ctx, "h", "z").Err())
+	require.Equal(t, redis.Nil, s.HGet(ctx, "nope", "a").Err())
+	require.Equal(t, []interface{}{"1", nil, "3"}, s.HMGet(ctx, "h", "a", "z", "c").Val())
+	require.Equal(t, []interface{}{nil}, s.HMGet(ctx, "nope", "a").Val())
+	require.Equal(t, map[string]string{"a": "1", "b": "2", "c": "3"}, s.HGetAll(ctx, "h").Val())
+	require.Equal(t, int64(5), s.HIncrBy(ctx, "h", "a", 4).Val())
+	require.Equal(t, int64(-1), s.HIncrBy(ctx, "h", "n", -1).Val())
+	require.Equal(t, int64(2), s.HDel(ctx, "h", "a", "n", "z").Val())
+	require.NotNil(t, s.HSet(ctx, "h", "odd").Err())
+
+	// sets
+	require.Equal(t, int64(2), s.SAdd(ctx, "s", "x", "y", "x").Val())
+	require.Equal(t, []string{"x", "y"}, s.SMembers(ctx, "s").Val())
+	require.Equal(t, []string{}, s.SMembers(ctx, "nope").Val())
+	require.Equal(t, int64(1), s.SRem(ctx, "s", "x", "z").Val())
+	require.Equal(t, int64(1), s.SRem(ctx, "s", "y").Val())
+	require.Equal(t, int64(0), s.Exists(ctx, "s").Val(), "an empty set is gone")
+
+	// strings
+	require.True(t, s.SetNX(ctx, "str", "v", time.Minute).Val())
+	require.False(t, s.SetNX(ctx, "str", "w", time.Minute).Val())
+
+	// wrong types
+	require.Equal(t, WrongTypeError, s.HGet(ctx, "str", "a").Err())
+	require.Equal(t, WrongTypeError, s.HSet(ctx, "str", "a", 1).Err())
+	require.Equal(t, WrongTypeError, s.SAdd(ctx, "h", "x").Err())
+	require.Equal(t, WrongTypeError, s.SMembers(ctx, "h").Err())
+	s.HSet(ctx, "h", "word", "two")
+	require.NotNil(t, s.HIncrBy(ctx, "h", "word", 1).Err())
+
+	// expiry
+	require.Equal(t, time.Duration(-2), s.TTL(ctx, "nope").Val())
+	require.Equal(t, time.Duration(-1), s.TTL(ctx, "h").Val())
+	require.Equal(t, time.Minute, s.TTL(ctx, "str").Val())
+	require.True(t, s.Expire(ctx, "h", 30*time.Second).Val())
+	require.False(t, s.Expire(ctx, "nope", time.Second).Val())
+	keys, cursor := s.Scan(ctx, 0, "*", sweepBatch).Val()
+	require.Equal(t, []string{"h", "str"}, keys)
+	require.Equal(t, uint64(0), cursor)
+	now = now.Add(45 * time.Second)
+	require.Equal(t, int64(0), s.Exists(ctx, "h").Val())
+	keys, _ = s.Scan(ctx, 0, "*", sweepBatch).Val()
+	require.Equal(t, []string{"str"}, keys)
+	require.Equal(t, int64(1), s.Del(ctx, "str", "h").Val())
+
+	// all or nothing, as far as errors go
+	require.Nil(t, s.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, "t", "a", 1)
+		tx.Expire(ctx, "t", time.Minute)
+		tx.HGet(ctx, "t", "missing") // Nil isn't an error here either
+	}))
+	require.Equal(t, WrongTypeError, s.Atomic(ctx, func(tx Cmds) {
+		tx.SAdd(ctx, "t", "x")
+		tx.HSet(ctx, "u", "a", 1)
+	}))
+	require.Equal(t, int64(1), s.Exists(ctx, "u").Val(), "the rest ran anyway, same as redis")
+}
+
+func Test_FieldValues(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		values []interface{}
+		result []string
+		err    error
+	}{
+		"pairs": {
+			values: []interface{}{"a", 1, "b", true},
+			result: []string{"a", "1", "b", "true"},
+		},
+		"map": {
+			values: []interface{}{map[string]interface{}{"a": 1}},
+			result: []string{"a", "1"},
+		},
+		"odd": {
+			values: []interface{}{"a", 1, "b"},
+			err:    fmt.Errorf("ERR wrong number of arguments for 'hset' command"),
+		},
+		"empty": {
+			err: fmt.Errorf("ERR wrong number of arguments for 'hset' command"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			result, err := FieldValues(tc.values)
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.result, result)
+		})
+	}
+}
+
+// Test_MemoryValidator is a whole session against a store that isn't redis,
+// with no mock to say what it ought to do
+func Test_MemoryValidator(t *testing.T) {
+	t.Parallel()
+
+	l := logrus.WithField("test", "Test_MemoryValidator")
+	v := NewValidator(NewMemoryStore(), cfg, l)
+	c := Client{Remote: "remote", UserAgent: "agent"}
+	uid := shared.UUID("memory")
+
+	ctx := setcid("memory validator")
+	cookie, sc := v.Login(ctx, uid, "name", PasswordLogin, c)
+	require.Equal(t, http.StatusOK, sc)
+
+	_, sc = v.Valid(ctx, cookie.Value, c)
+	require.Equal(t, http.StatusNoContent, sc)
+
+	sessions, sc := v.Sessions(ctx, uid)
+	require.Equal(t, http.StatusOK, sc)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "agent", sessions[0].UserAgent)
+	require.Equal(t, PasswordLogin, sessions[0].Method)
+
+	require.Equal(t, http.StatusOK, v.Sweep(ctx))
+
+	_, sc = v.Logout(ctx, cookie.Value, c)
+	require.Equal(t, http.StatusNoContent, sc)
+
+	_, sc = v.Valid(ctx, cookie.Value, c)
+	require.Equal(t, http.StatusTemporaryRedirect, sc)
+}
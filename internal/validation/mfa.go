@@ -0,0 +1,71 @@
+package valid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// BeginMFA parks a user who got their password right until they prove the
+// second factor; the token goes back to the client in place of an authn cookie
+func (v *core) BeginMFA(ctx context.Context, uid shared.UUID, rmt string) (string, int) {
+	t := v.tracker(ctx, "BeginMFA")
+
+	token := uuid.NewString()
+	key := "mfa:" + token
+
+	if err := v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, key, map[string]interface{}{
+			userid: string(uid),
+			remote: rmt,
+		})
+		tx.Expire(ctx, key, v.mfaTimeout)
+	}); err != nil {
+		return "", t.sc(http.StatusInternalServerError).
+			err(err).
+			done("creating pending mfa").
+			sc()
+	}
+
+	return token, t.sc(http.StatusOK).ok().sc()
+}
+
+// PendingMFA finds who a pending token belongs to and counts it as an attempt;
+// once the attempts run out the token is thrown away and the user has to start
+// over with their password
+func (v *core) PendingMFA(ctx context.Context, token string) (shared.UUID, int) {
+	t := v.tracker(ctx, "PendingMFA")
+
+	key := "mfa:" + token
+	if result, err := v.authn.HGetAll(ctx, key).Result(); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done(err.Error()).sc()
+	} else if uid := result[userid]; uid == "" {
+		return "", t.sc(http.StatusForbidden).err(NotAuthorized).done("no pending mfa").sc()
+	} else if n, err := v.authn.HIncrBy(ctx, key, attempts, 1).Result(); err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done("counting attempts").sc()
+	} else if n > v.mfaAttempts {
+		if code := v.EndMFA(ctx, token); code != http.StatusOK {
+			return "", t.sc(code).done("clearing pending mfa").sc()
+		}
+		return "", t.sc(http.StatusTooManyRequests).
+			err(fmt.Errorf("too many attempts")).
+			done("too many attempts").
+			sc()
+	} else {
+		return shared.UUID(uid), t.sc(http.StatusOK).ok().sc()
+	}
+}
+
+func (v *core) EndMFA(ctx context.Context, token string) int {
+	t := v.tracker(ctx, "EndMFA")
+
+	if err := v.authn.HDel(ctx, "mfa:"+token, userid, remote, attempts).Err(); err != nil {
+		return t.sc(http.StatusInternalServerError).err(err).done("clearing pending mfa").sc()
+	}
+
+	return t.sc(http.StatusOK).ok().sc()
+}
@@ -0,0 +1,115 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_BeginMFA(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_BeginMFA")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("create fails")
+	s.fail("HSet", "mfa:*", broken)
+	token, sc := v.BeginMFA(ctx, userid, remote)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("expire fails")
+	s.fail("Expire", "mfa:*", broken)
+	token, sc = v.BeginMFA(ctx, userid, remote)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Empty(t, token)
+
+	ctx = setcid("happy path")
+	token, sc = v.BeginMFA(ctx, userid, remote)
+	require.Equal(t, http.StatusOK, sc)
+	require.NotEmpty(t, token)
+	require.Equal(t, map[string]string{userid: userid, remote: remote}, s.HGetAll(ctx, "mfa:"+token).Val())
+	require.Equal(t, 5*time.Minute, s.TTL(ctx, "mfa:"+token).Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_PendingMFA(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_PendingMFA")
+	v := NewValidator(s, cfg, l)
+
+	// pending is a token that's been tried n times already
+	pending := func(n int) {
+		s.HSet(ctx, "mfa:1", userid, userid, remote, remote, attempts, n)
+	}
+
+	ctx := setcid("lookup fails")
+	s.fail("HGetAll", "mfa:1", broken)
+	uid, sc := v.PendingMFA(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, shared.UUID(""), uid)
+
+	ctx = setcid("no pending mfa")
+	uid, sc = v.PendingMFA(ctx, "1")
+	require.Equal(t, http.StatusForbidden, sc)
+	require.Equal(t, shared.UUID(""), uid)
+
+	ctx = setcid("counting fails")
+	pending(0)
+	s.fail("HIncrBy", "mfa:1", broken)
+	uid, sc = v.PendingMFA(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, shared.UUID(""), uid)
+
+	ctx = setcid("too many attempts")
+	pending(5)
+	uid, sc = v.PendingMFA(ctx, "1")
+	require.Equal(t, http.StatusTooManyRequests, sc)
+	require.Equal(t, shared.UUID(""), uid)
+	require.Equal(t, int64(0), s.Exists(ctx, "mfa:1").Val())
+
+	ctx = setcid("too many attempts, clear fails")
+	pending(5)
+	s.fail("HDel", "mfa:1", broken)
+	uid, sc = v.PendingMFA(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, shared.UUID(""), uid)
+
+	ctx = setcid("happy path")
+	pending(0)
+	uid, sc = v.PendingMFA(ctx, "1")
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, shared.UUID(userid), uid)
+	require.Equal(t, "1", s.HGet(ctx, "mfa:1", attempts).Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_EndMFA(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_EndMFA")
+	v := NewValidator(s, cfg, l)
+
+	s.HSet(ctx, "mfa:1", userid, userid, remote, remote, attempts, 1)
+
+	ctx := setcid("clear fails")
+	s.fail("HDel", "mfa:1", broken)
+	require.Equal(t, http.StatusInternalServerError, v.EndMFA(ctx, "1"))
+
+	ctx = setcid("happy path")
+	require.Equal(t, http.StatusOK, v.EndMFA(ctx, "1"))
+	require.Equal(t, int64(0), s.Exists(ctx, "mfa:1").Val())
+
+	require.Empty(t, s.faults)
+}
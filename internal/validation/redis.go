@@ -0,0 +1,125 @@
+package valid
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/jsmit257/userservice/internal/config"
+)
+
+// what REDIS_MODE can be
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
+
+// clusterStore is a redisStore on a cluster, where SCAN only sees whichever
+// node it lands on
+type clusterStore struct {
+	redisStore
+	cluster *redis.ClusterClient
+}
+
+// NewRedisClient connects to redis the way REDIS_MODE says; it doesn't
+// check that anyone's there
+func NewRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	opts, err := redisOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.RedisMode {
+	case RedisStandalone:
+		return redis.NewClient(opts.Simple()), nil
+	case RedisSentinel:
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	}
+	return nil, fmt.Errorf("unknown redis mode: %q", cfg.RedisMode)
+}
+
+func redisOptions(cfg *config.Config) (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.RedisAddrs,
+		DB:               cfg.RedisDB,
+		Username:         cfg.RedisUser,
+		Password:         cfg.RedisPass,
+		SentinelUsername: cfg.RedisSentinelUser,
+		SentinelPassword: cfg.RedisSentinelPass,
+		MasterName:       cfg.RedisMaster,
+	}
+
+	if len(opts.Addrs) == 0 {
+		opts.Addrs = []string{net.JoinHostPort(cfg.RedisHost, strconv.Itoa(int(cfg.RedisPort)))}
+	}
+
+	if cfg.RedisMode == RedisSentinel && opts.MasterName == "" {
+		return nil, fmt.Errorf("sentinel needs REDIS_MASTER")
+	} else if cfg.RedisMode == RedisCluster && opts.DB != 0 {
+		return nil, fmt.Errorf("cluster only has db 0")
+	}
+
+	var err error
+	opts.TLSConfig, err = redisTLS(cfg)
+	return opts, err
+}
+
+// redisTLS is nil when REDIS_TLS is off
+func redisTLS(cfg *config.Config) (*tls.Config, error) {
+	if !cfg.RedisTLS {
+		return nil, nil
+	}
+
+	result := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.RedisServerName,
+	}
+
+	if cfg.RedisCAFile != "" {
+		ca, err := os.ReadFile(cfg.RedisCAFile)
+		if err != nil {
+			return nil, err
+		}
+		result.RootCAs = x509.NewCertPool()
+		if !result.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.RedisCAFile)
+		}
+	}
+
+	if cfg.RedisCertFile != "" || cfg.RedisKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RedisCertFile, cfg.RedisKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+
+	return result, nil
+}
+
+// Scan goes through every master in one go, so the cursor always comes
+// back 0
+func (s clusterStore) Scan(ctx context.Context, _ uint64, match string, count int64) *redis.ScanCmd {
+	var mu sync.Mutex
+	result := []string{}
+	err := s.cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		iter := node.Scan(ctx, 0, match, count).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			result = append(result, iter.Val())
+			mu.Unlock()
+		}
+		return iter.Err()
+	})
+	return redis.NewScanCmdResult(result, 0, err)
+}
@@ -0,0 +1,199 @@
+package valid
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/config"
+)
+
+func Test_NewRedisClient(t *testing.T) {
+	t.Parallel()
+
+	tcs := map[string]struct {
+		cfg    config.Config
+		client interface{}
+		err    error
+	}{
+		"standalone": {
+			cfg:    config.Config{RedisMode: RedisStandalone, RedisHost: "redis", RedisPort: 6379},
+			client: &redis.Client{},
+		},
+		"sentinel": {
+			cfg: config.Config{
+				RedisMode:   RedisSentinel,
+				RedisAddrs:  []string{"s1:26379", "s2:26379"},
+				RedisMaster: "mymaster",
+			},
+			client: &redis.Client{},
+		},
+		"sentinel_without_master": {
+			cfg: config.Config{RedisMode: RedisSentinel, RedisAddrs: []string{"s1:26379"}},
+			err: fmt.Errorf("sentinel needs REDIS_MASTER"),
+		},
+		"cluster": {
+			cfg:    config.Config{RedisMode: RedisCluster, RedisAddrs: []string{"c1:6379", "c2:6379"}},
+			client: &redis.ClusterClient{},
+		},
+		"cluster_with_db": {
+			cfg: config.Config{RedisMode: RedisCluster, RedisAddrs: []string{"c1:6379"}, RedisDB: 2},
+			err: fmt.Errorf("cluster only has db 0"),
+		},
+		"unknown_mode": {
+			cfg: config.Config{RedisMode: "ring", RedisHost: "redis", RedisPort: 6379},
+			err: fmt.Errorf(`unknown redis mode: "ring"`),
+		},
+		"bad_tls": {
+			cfg: config.Config{
+				RedisMode:   RedisStandalone,
+				RedisHost:   "redis",
+				RedisPort:   6379,
+				RedisTLS:    true,
+				RedisCAFile: "missing.pem",
+			},
+			err: fmt.Errorf("open missing.pem: no such file or directory"),
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client, err := NewRedisClient(&tc.cfg)
+			if tc.err != nil {
+				require.Nil(t, client)
+				require.Equal(t, tc.err.Error(), err.Error())
+				return
+			}
+			require.Nil(t, err)
+			require.IsType(t, tc.client, client)
+			require.Nil(t, client.Close())
+		})
+	}
+}
+
+func Test_redisOptions(t *testing.T) {
+	t.Parallel()
+
+	opts, err := redisOptions(&config.Config{
+		RedisMode:         RedisSentinel,
+		RedisHost:         "redis",
+		RedisPort:         6379,
+		RedisUser:         "user",
+		RedisPass:         "pass",
+		RedisMaster:       "mymaster",
+		RedisSentinelUser: "suser",
+		RedisSentinelPass: "spass",
+		RedisDB:           3,
+	})
+	require.Nil(t, err)
+	require.Equal(t, &redis.UniversalOptions{
+		Addrs:            []string{"redis:6379"}, // no REDIS_ADDRS
+		DB:               3,
+		Username:         "user",
+		Password:         "pass",
+		SentinelUsername: "suser",
+		SentinelPassword: "spass",
+		MasterName:       "mymaster",
+	}, opts)
+}
+
+func Test_redisTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ca, cert, key := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, ca, cert, key)
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "empty.pem"), []byte("nothing"), 0o600))
+
+	tcs := map[string]struct {
+		cfg   config.Config
+		nil   bool
+		roots bool
+		certs int
+		err   string
+	}{
+		"off": {
+			cfg: config.Config{RedisCAFile: ca},
+			nil: true,
+		},
+		"system_roots": {
+			cfg: config.Config{RedisTLS: true},
+		},
+		"ca": {
+			cfg:   config.Config{RedisTLS: true, RedisCAFile: ca},
+			roots: true,
+		},
+		"client_cert": {
+			cfg:   config.Config{RedisTLS: true, RedisCAFile: ca, RedisCertFile: cert, RedisKeyFile: key},
+			roots: true,
+			certs: 1,
+		},
+		"empty_ca": {
+			cfg: config.Config{RedisTLS: true, RedisCAFile: filepath.Join(dir, "empty.pem")},
+			err: "no certificates in " + filepath.Join(dir, "empty.pem"),
+		},
+		"cert_without_key": {
+			cfg: config.Config{RedisTLS: true, RedisCertFile: cert},
+			err: "open : no such file or directory",
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			result, err := redisTLS(&tc.cfg)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.Nil(t, err)
+			if tc.nil {
+				require.Nil(t, result)
+				return
+			}
+			require.Equal(t, tc.roots, result.RootCAs != nil)
+			require.Len(t, result.Certificates, tc.certs)
+		})
+	}
+}
+
+// writeCert makes a self signed certificate, and uses it for both the ca and
+// the client
+func writeCert(t *testing.T, ca, cert, key string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	require.Nil(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.Nil(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.Nil(t, os.WriteFile(ca, certPEM, 0o600))
+	require.Nil(t, os.WriteFile(cert, certPEM, 0o600))
+	require.Nil(t, os.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0o600))
+}
@@ -0,0 +1,202 @@
+package valid
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// a refresh token is only ever stored as a hash; the rest of the hash is
+// enough to start a new session without asking the database
+//
+//	refresh:<sha256>  {userid, name, family, rotated}
+//	family:<id>       every refresh key ever issued in the family, and every
+//	                  session key they were traded in for
+//	families:<uid>    every live family for a user
+
+var ReusedRefreshToken = fmt.Errorf("refresh token was already used")
+
+// NewRefresh starts a new family with its first token; every login gets one
+func (v *core) NewRefresh(ctx context.Context, uid shared.UUID, name string) (*http.Cookie, int) {
+	t := v.tracker(ctx, "NewRefresh")
+
+	fam := uuid.NewString()
+	families := "families:" + string(uid)
+	if token, err := v.addRefresh(ctx, uid, name, fam, func(tx Cmds) {
+		tx.SAdd(ctx, families, fam)
+		tx.Expire(ctx, families, v.refreshTimeout)
+	}); err != nil {
+		return nil, t.sc(http.StatusInternalServerError).err(err).done("issuing refresh token").sc()
+	} else {
+		return v.refreshCookie(token), t.sc(http.StatusOK).ok().sc()
+	}
+}
+
+// Refresh trades a refresh token for a new session and the next token in the
+// same family; a token that was already traded in means someone else has a
+// copy, so the whole family goes, along with the sessions it started. So does
+// the family of a user that active says is gone
+func (v *core) Refresh(ctx context.Context, token string, c Client, active Active) (*http.Cookie, *http.Cookie, int) {
+	t := v.tracker(ctx, "Refresh")
+
+	key := refreshKey(token)
+	result, err := v.authn.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, nil, t.sc(http.StatusInternalServerError).err(err).done(err.Error()).sc()
+	} else if result[userid] == "" {
+		return nil, nil, t.sc(http.StatusUnauthorized).err(NotAuthorized).done("unknown or expired refresh token").sc()
+	}
+
+	uid, fam := shared.UUID(result[userid]), result[family]
+	t = t.fields(logrus.Fields{"family": fam})
+	if code := active(ctx, uid); code == http.StatusUnauthorized {
+		if err = v.revokeFamily(ctx, uid, fam); err != nil {
+			return nil, nil, t.sc(http.StatusInternalServerError).err(err).done("revoking inactive user's family").sc()
+		}
+		return nil, nil, t.sc(http.StatusUnauthorized).err(NotAuthorized).done("user was deleted or locked, revoked the family").sc()
+	} else if code != http.StatusOK {
+		return nil, nil, t.sc(code).err(fmt.Errorf("couldn't check user")).done("checking user").sc()
+	}
+
+	if n, err := v.authn.HIncrBy(ctx, key, rotated, 1).Result(); err != nil {
+		return nil, nil, t.sc(http.StatusInternalServerError).err(err).done("rotating refresh token").sc()
+	} else if n > 1 {
+		if err = v.revokeFamily(ctx, uid, fam); err != nil {
+			return nil, nil, t.sc(http.StatusInternalServerError).err(err).done("revoking reused family").sc()
+		}
+		return nil, nil, t.sc(http.StatusUnauthorized).
+			err(ReusedRefreshToken).
+			done("refresh token reuse, revoked the family").
+			sc()
+	} else if session, code := v.Login(ctx, uid, result[username], RefreshLogin, c); code != http.StatusOK {
+		return nil, nil, t.sc(code).err(fmt.Errorf("failed redis login")).done("starting session").sc()
+	} else if skey, _, err := v.session(session.Value); err != nil {
+		return nil, nil, t.sc(http.StatusInternalServerError).err(err).done("reading new session").sc()
+	} else if next, err := v.addRefresh(ctx, uid, result[username], fam, func(tx Cmds) {
+		tx.SAdd(ctx, "family:"+fam, skey) // so revoking the family ends it too
+	}); err != nil {
+		return nil, nil, t.sc(http.StatusInternalServerError).err(err).done("issuing next refresh token").sc()
+	} else {
+		return session, v.refreshCookie(next), t.sc(http.StatusOK).ok().sc()
+	}
+}
+
+// RevokeRefresh throws away the family a token belongs to, for logging out
+func (v *core) RevokeRefresh(ctx context.Context, token string) (*http.Cookie, int) {
+	t := v.tracker(ctx, "RevokeRefresh")
+
+	if result, err := v.authn.HGetAll(ctx, refreshKey(token)).Result(); err != nil {
+		return nil, t.sc(http.StatusInternalServerError).err(err).done(err.Error()).sc()
+	} else if result[userid] == "" {
+		return v.refreshCookie(""), t.sc(http.StatusNoContent).done("nothing to revoke").sc()
+	} else if err = v.revokeFamily(ctx, shared.UUID(result[userid]), result[family]); err != nil {
+		return nil, t.sc(http.StatusInternalServerError).err(err).done("revoking family").sc()
+	}
+
+	return v.refreshCookie(""), t.sc(http.StatusNoContent).ok().sc()
+}
+
+// addRefresh stores a new token in its family; index, when there is one, goes
+// in the same transaction so a new family can't exist without being indexed
+func (v *core) addRefresh(ctx context.Context, uid shared.UUID, name, fam string, index func(Cmds)) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	key := refreshKey(token)
+	err := v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, key, map[string]interface{}{
+			userid:   string(uid),
+			username: name,
+			family:   fam,
+			rotated:  0,
+		})
+		tx.Expire(ctx, key, v.refreshTimeout)
+		tx.SAdd(ctx, "family:"+fam, key)
+		tx.Expire(ctx, "family:"+fam, v.refreshTimeout)
+		if index != nil {
+			index(tx)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// revokeFamily deletes a family's refresh tokens and ends the sessions they
+// started
+func (v *core) revokeFamily(ctx context.Context, uid shared.UUID, fam string) error {
+	members, err := v.authn.SMembers(ctx, "family:"+fam).Result()
+	if err != nil {
+		return err
+	}
+
+	var keys, sessions []string
+	for _, member := range members {
+		if strings.HasPrefix(member, "token:") {
+			sessions = append(sessions, member)
+		} else {
+			keys = append(keys, member)
+		}
+	}
+
+	err = v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.Del(ctx, append(keys, "family:"+fam)...)
+		tx.SRem(ctx, "families:"+string(uid), fam)
+		for _, key := range sessions {
+			tx.HDel(ctx, key, sessionFields...)
+			tx.SRem(ctx, "logins:"+string(uid), key)
+		}
+	})
+	return err
+}
+
+// clearFamilies is what a password reset does to refresh tokens
+func (v *core) clearFamilies(ctx context.Context, uid shared.UUID) error {
+	families, err := v.authn.SMembers(ctx, "families:"+string(uid)).Result()
+	if err != nil || len(families) == 0 {
+		return err
+	}
+
+	for _, fam := range families {
+		if err = v.revokeFamily(ctx, uid, fam); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *core) refreshCookie(token string) *http.Cookie {
+	result := &http.Cookie{
+		Name:     v.refreshName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	}
+	if token != "" {
+		result.Expires = time.Now().UTC().Add(v.refreshTimeout)
+		result.MaxAge = int(v.refreshTimeout.Seconds())
+	}
+	return result
+}
+
+func refreshKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "refresh:" + hex.EncodeToString(sum[:])
+}
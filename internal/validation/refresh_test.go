@@ -0,0 +1,223 @@
+package valid
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_NewRefresh(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_NewRefresh")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("storing the token fails")
+	s.fail("HSet", "refresh:*", broken)
+	cookie, sc := v.NewRefresh(ctx, userid, "name")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Nil(t, cookie)
+
+	ctx = setcid("indexing the family fails")
+	s.fail("SAdd", "families:userid", broken)
+	cookie, sc = v.NewRefresh(ctx, userid, "name")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Nil(t, cookie)
+
+	ctx = setcid("indexing is part of the same transaction, so exec failing loses both")
+	families := s.SMembers(ctx, "families:userid").Val()
+	s.fail("Atomic", "*", broken)
+	cookie, sc = v.NewRefresh(ctx, userid, "name")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Nil(t, cookie)
+	require.Equal(t, families, s.SMembers(ctx, "families:userid").Val())
+
+	ctx = setcid("happy path")
+	cookie, sc = v.NewRefresh(ctx, userid, "name")
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, cfg.RefreshCookie, cookie.Name)
+	require.NotEmpty(t, cookie.Value)
+	require.True(t, cookie.HttpOnly)
+	key := refreshKey(cookie.Value)
+	fields := s.HGetAll(ctx, key).Val()
+	require.Equal(t, userid, fields[userid])
+	require.Equal(t, "name", fields[username])
+	require.Equal(t, "0", fields[rotated])
+	require.Contains(t, s.SMembers(ctx, "families:userid").Val(), fields[family])
+	require.Equal(t, []string{key}, s.SMembers(ctx, "family:"+fields[family]).Val())
+	require.Equal(t, cfg.RefreshTimeout, s.TTL(ctx, key).Val())
+	require.Equal(t, cfg.RefreshTimeout, s.TTL(ctx, "families:userid").Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_Refresh(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_Refresh")
+	v := NewValidator(s, cfg, l)
+
+	key := refreshKey("token")
+	active := func(context.Context, shared.UUID) int { return http.StatusOK }
+	gone := func(context.Context, shared.UUID) int { return http.StatusUnauthorized }
+	unknown := func(context.Context, shared.UUID) int { return http.StatusInternalServerError }
+
+	// issue puts "token" back in family fam, traded in n times
+	issue := func(n int) {
+		seed(s, key, map[string]interface{}{userid: userid, username: "name", family: "fam", rotated: n}, "family:fam")
+		s.SAdd(ctx, "families:userid", "fam")
+	}
+
+	ctx := setcid("lookup fails")
+	s.fail("HGetAll", key, broken)
+	_, _, sc := v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("unknown token")
+	_, _, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusUnauthorized, sc)
+
+	ctx = setcid("a deleted or locked user's family is revoked")
+	issue(0)
+	session, refresh, sc := v.Refresh(ctx, "token", testClient, gone)
+	require.Equal(t, http.StatusUnauthorized, sc)
+	require.Nil(t, session)
+	require.Nil(t, refresh)
+	require.Equal(t, int64(0), s.Exists(ctx, key, "family:fam", "families:userid").Val())
+
+	ctx = setcid("revoking an inactive user's family fails")
+	issue(0)
+	s.fail("SMembers", "family:fam", broken)
+	_, _, sc = v.Refresh(ctx, "token", testClient, gone)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("checking the user fails")
+	_, _, sc = v.Refresh(ctx, "token", testClient, unknown)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, "0", s.HGet(ctx, key, rotated).Val(), "nothing was traded in")
+
+	ctx = setcid("rotating fails")
+	s.fail("HIncrBy", key, broken)
+	_, _, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("reuse revokes the family and ends its sessions")
+	issue(1)
+	seed(s, "token:minted", loginFields(time.Now()), "logins:userid", "family:fam")
+	seed(s, "refresh:next", map[string]interface{}{userid: userid, family: "fam"}, "family:fam")
+	session, refresh, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusUnauthorized, sc)
+	require.Nil(t, session)
+	require.Nil(t, refresh)
+	require.Equal(t, int64(0), s.Exists(ctx, key, "refresh:next", "family:fam", "token:minted", "logins:userid").Val())
+
+	ctx = setcid("revoking a reused family fails")
+	issue(1)
+	s.fail("SMembers", "family:fam", broken)
+	_, _, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("login fails")
+	issue(0)
+	s.fail("SMembers", "logins:userid", broken)
+	_, _, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("next token fails")
+	issue(0)
+	s.fail("HSet", "refresh:*", broken)
+	_, _, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("happy path")
+	issue(0)
+	session, refresh, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, cfg.CookieName, session.Name)
+	require.Equal(t, cfg.RefreshCookie, refresh.Name)
+	require.NotEqual(t, "token", refresh.Value)
+	require.Equal(t, RefreshLogin, s.HGet(ctx, "token:"+session.Value, via).Val())
+	require.Equal(t, "fam", s.HGet(ctx, refreshKey(refresh.Value), family).Val())
+	require.Subset(t, s.SMembers(ctx, "family:fam").Val(), []string{key, refreshKey(refresh.Value), "token:" + session.Value})
+	require.Equal(t, "1", s.HGet(ctx, key, rotated).Val())
+
+	ctx = setcid("trading the old token in again ends what it was traded for")
+	_, _, sc = v.Refresh(ctx, "token", testClient, active)
+	require.Equal(t, http.StatusUnauthorized, sc)
+	require.Equal(t, int64(0), s.Exists(ctx, "token:"+session.Value, refreshKey(refresh.Value)).Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_RevokeRefresh(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_RevokeRefresh")
+	v := NewValidator(s, cfg, l)
+
+	key := refreshKey("token")
+
+	ctx := setcid("lookup fails")
+	s.fail("HGetAll", key, broken)
+	_, sc := v.RevokeRefresh(ctx, "token")
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("already gone")
+	cookie, sc := v.RevokeRefresh(ctx, "token")
+	require.Equal(t, http.StatusNoContent, sc)
+	require.Equal(t, -1, cookie.MaxAge)
+
+	seed(s, key, map[string]interface{}{userid: userid, family: "fam"}, "family:fam")
+	s.SAdd(ctx, "families:userid", "fam")
+
+	ctx = setcid("revoke fails")
+	s.fail("Del", key, broken)
+	_, sc = v.RevokeRefresh(ctx, "token")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, int64(1), s.Exists(ctx, key).Val())
+
+	ctx = setcid("happy path")
+	cookie, sc = v.RevokeRefresh(ctx, "token")
+	require.Equal(t, http.StatusNoContent, sc)
+	require.Equal(t, "", cookie.Value)
+	require.Equal(t, -1, cookie.MaxAge)
+	require.Equal(t, int64(0), s.Exists(ctx, key, "family:fam").Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_clearFamilies(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_clearFamilies")
+	v := NewValidator(s, cfg, l).(*core)
+
+	refresh := func(key, fam string) {
+		seed(s, key, map[string]interface{}{userid: userid, family: fam}, "family:"+fam)
+		s.SAdd(ctx, "families:userid", fam)
+	}
+
+	ctx := setcid("a password reset kills every family")
+	refresh("refresh:1", "a")
+	refresh("refresh:2", "b")
+	refresh("refresh:3", "b")
+	require.Equal(t, http.StatusGone, v.clearLogins(ctx, userid))
+	require.Equal(t, int64(0), s.Exists(ctx, "families:userid", "family:a", "family:b", "refresh:1", "refresh:2", "refresh:3").Val())
+
+	ctx = setcid("one family fails")
+	refresh("refresh:1", "a")
+	s.fail("SMembers", "family:a", broken)
+	require.NotNil(t, v.clearFamilies(ctx, userid))
+
+	require.Empty(t, s.faults)
+}
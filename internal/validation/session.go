@@ -0,0 +1,99 @@
+package valid
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// sessionTokens puts a short-lived signed token in the login cookie instead
+// of the bare session id; redis still has the final say, the signature only
+// lets other services skip the round trip to /valid
+type sessionTokens struct {
+	issuer  string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+	timeout time.Duration
+}
+
+func newSessionTokens(cfg *config.Config) (*sessionTokens, error) {
+	b, err := os.ReadFile(cfg.SessionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &sessionTokens{issuer: cfg.SessionIssuer, timeout: cfg.SessionJWTTimeout}
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(b); err == nil {
+		result.method, result.private, result.public = jwt.SigningMethodRS256, key, &key.PublicKey
+	} else if key, err := jwt.ParseEdPrivateKeyFromPEM(b); err == nil {
+		ed := key.(ed25519.PrivateKey) // it's the only thing the parser returns
+		result.method, result.private, result.public = jwt.SigningMethodEdDSA, ed, ed.Public()
+	} else {
+		return nil, fmt.Errorf("%s isn't an RSA or Ed25519 private key", cfg.SessionKeyFile)
+	}
+
+	return result, nil
+}
+
+func (st *sessionTokens) sign(uid shared.UUID, name, sid string) (string, error) {
+	now := time.Now().UTC()
+	return jwt.NewWithClaims(st.method, &shared.SessionClaims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    st.issuer,
+			Subject:   string(uid),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(st.timeout).Unix(),
+		},
+		Name:      name,
+		SessionID: sid,
+	}).SignedString(st.private)
+}
+
+// claims trusts the signature but not the expiry: an expired token still
+// names a session, and whether that session is alive is up to redis
+func (st *sessionTokens) claims(token string) (*shared.SessionClaims, error) {
+	claims := &shared.SessionClaims{}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != st.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+		}
+		return st.public, nil
+	}); err != nil {
+		return nil, err
+	} else if !claims.VerifyIssuer(st.issuer, true) || claims.SessionID == "" {
+		return nil, shared.BadSessionTokenError
+	}
+	return claims, nil
+}
+
+// mint is what goes in the cookie for a session: the id itself, or a token
+// that carries it
+func (v *core) mint(uid shared.UUID, name, sid string) (string, error) {
+	if v.tokens == nil {
+		return sid, nil
+	}
+	return v.tokens.sign(uid, name, sid)
+}
+
+// session is the other direction: the redis key for whatever's in the cookie,
+// plus the claims if it was a token
+func (v *core) session(token string) (string, *shared.SessionClaims, error) {
+	if v.tokens == nil {
+		return "token:" + token, nil, nil
+	}
+
+	claims, err := v.tokens.claims(token)
+	if err != nil {
+		return "", nil, err
+	}
+	return "token:" + claims.SessionID, claims, nil
+}
@@ -0,0 +1,200 @@
+package valid
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/internal/config"
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// writeKeys leaves a private key where the config can find it and hands back
+// the public half the way a downstream service would get it
+func writeKeys(t *testing.T, alg string) (*config.Config, []byte) {
+	var priv, pub []byte
+	switch alg {
+	case "RS256":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.Nil(t, err)
+		priv = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.Nil(t, err)
+		pub = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	case "EdDSA":
+		pk, key, err := ed25519.GenerateKey(rand.Reader)
+		require.Nil(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.Nil(t, err)
+		priv = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		der, err = x509.MarshalPKIXPublicKey(pk)
+		require.Nil(t, err)
+		pub = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+
+	file := filepath.Join(t.TempDir(), "session.pem")
+	require.Nil(t, os.WriteFile(file, priv, 0600))
+
+	result := *cfg
+	result.SessionJWT = true
+	result.SessionKeyFile = file
+	result.SessionJWTTimeout = time.Minute
+	result.SessionIssuer = "userservice"
+	return &result, pub
+}
+
+func Test_newSessionTokens(t *testing.T) {
+	t.Parallel()
+
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	require.Nil(t, os.WriteFile(garbage, []byte("not a key"), 0600))
+
+	tcs := map[string]struct {
+		file func(*testing.T) string
+		alg  string
+		err  bool
+	}{
+		"rsa": {
+			file: func(t *testing.T) string {
+				c, _ := writeKeys(t, "RS256")
+				return c.SessionKeyFile
+			},
+			alg: "RS256",
+		},
+		"ed25519": {
+			file: func(t *testing.T) string {
+				c, _ := writeKeys(t, "EdDSA")
+				return c.SessionKeyFile
+			},
+			alg: "EdDSA",
+		},
+		"missing_file": {
+			file: func(*testing.T) string { return "/nowhere/session.pem" },
+			err:  true,
+		},
+		"not_a_key": {
+			file: func(*testing.T) string { return garbage },
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			st, err := newSessionTokens(&config.Config{SessionKeyFile: tc.file(t)})
+			require.Equal(t, tc.err, err != nil, err)
+			if !tc.err {
+				require.Equal(t, tc.alg, st.method.Alg())
+			}
+		})
+	}
+}
+
+func Test_NewValidatorBadKey(t *testing.T) {
+	t.Parallel()
+
+	bad := *cfg
+	bad.SessionJWT = true
+	bad.SessionKeyFile = "/nowhere/session.pem"
+
+	require.Panics(t, func() {
+		_ = NewValidator(nil, &bad, logrus.WithField("test", "Test_NewValidatorBadKey"))
+	})
+}
+
+func Test_SessionTokens(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range []string{"RS256", "EdDSA"} {
+		alg := alg
+
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+
+			signed, pub := writeKeys(t, alg)
+			other, _ := writeKeys(t, alg)
+			verifier, err := shared.NewTokenVerifier(pub, signed.SessionIssuer)
+			require.Nil(t, err)
+
+			s := newFaultyStore()
+			v := NewValidator(s, signed, logrus.WithField("test", "Test_SessionTokens"))
+			forger := NewValidator(nil, other, logrus.WithField("test", "Test_SessionTokens")).(*core)
+
+			ctx := setcid("signed login")
+			cookie, sc := v.Login(ctx, userid, "name", PasswordLogin, testClient)
+			require.Equal(t, http.StatusOK, sc)
+
+			claims, err := verifier.Verify(cookie.Value)
+			require.Nil(t, err)
+			require.Equal(t, userid, claims.Subject)
+			require.Equal(t, "name", claims.Name)
+			require.NotEmpty(t, claims.SessionID)
+			key := "token:" + claims.SessionID
+			require.Equal(t, userid, s.HGet(ctx, key, userid).Val(), "the session is under the sid, not the token")
+
+			ctx = setcid("valid reissues the token")
+			refreshed, sc := v.Valid(ctx, cookie.Value, testClient)
+			require.Equal(t, http.StatusNoContent, sc)
+			again, err := verifier.Verify(refreshed.Value)
+			require.Nil(t, err)
+			require.Equal(t, claims.SessionID, again.SessionID)
+			require.Equal(t, claims.Name, again.Name)
+
+			ctx = setcid("an expired token still names a live session")
+			v.(*core).tokens.timeout = -time.Minute
+			stale, err := v.(*core).tokens.sign(userid, "name", claims.SessionID)
+			require.Nil(t, err)
+			v.(*core).tokens.timeout = time.Minute
+			_, err = verifier.Verify(stale)
+			require.ErrorIs(t, err, shared.BadSessionTokenError)
+			_, sc = v.Valid(ctx, stale, testClient)
+			require.Equal(t, http.StatusNoContent, sc)
+
+			ctx = setcid("garbage never reaches the store")
+			s.fail("HMGet", "*", broken)
+			_, sc = v.Valid(ctx, claims.SessionID, testClient)
+			require.Equal(t, http.StatusTemporaryRedirect, sc)
+			pending, _ := s.fault("HMGet", "*")
+			require.True(t, pending, "the fault is still waiting for a read that never happened")
+
+			ctx = setcid("someone else's key")
+			forged, err := forger.mint(userid, "name", claims.SessionID)
+			require.Nil(t, err)
+			_, sc = v.Valid(ctx, forged, testClient)
+			require.Equal(t, http.StatusTemporaryRedirect, sc)
+			_, sc = v.Session(ctx, forged)
+			require.Equal(t, http.StatusUnauthorized, sc)
+
+			ctx = setcid("session reads the sid")
+			uid, sc := v.Session(ctx, cookie.Value)
+			require.Equal(t, http.StatusOK, sc)
+			require.Equal(t, shared.UUID(userid), uid)
+
+			ctx = setcid("logout reads the sid")
+			_, sc = v.Logout(ctx, cookie.Value, testClient)
+			require.Equal(t, http.StatusNoContent, sc)
+			require.Equal(t, int64(0), s.Exists(ctx, key).Val())
+
+			ctx = setcid("the store still revokes a good token")
+			_, err = verifier.Verify(cookie.Value)
+			require.Nil(t, err)
+			_, sc = v.Valid(ctx, cookie.Value, testClient)
+			require.Equal(t, http.StatusTemporaryRedirect, sc)
+
+			require.Empty(t, s.faults)
+		})
+	}
+}
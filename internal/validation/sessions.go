@@ -0,0 +1,110 @@
+package valid
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+// Sessions lists a user's live logins, then the ones that were evicted
+// recently; OTP pads share the logins set but aren't sessions until they're
+// redeemed, so they're left out, and so are tokens that expired without
+// logging out
+func (v *core) Sessions(ctx context.Context, uid shared.UUID) ([]shared.Session, int) {
+	t := v.tracker(ctx, "Sessions")
+
+	keys, err := v.authn.SMembers(ctx, "logins:"+string(uid)).Result()
+	if err != nil {
+		return nil, t.sc(http.StatusInternalServerError).err(err).done("couldn't get logins").sc()
+	}
+
+	evicted, err := v.authn.SMembers(ctx, "evicted:"+string(uid)).Result()
+	if err != nil {
+		return nil, t.sc(http.StatusInternalServerError).err(err).done("couldn't get evicted logins").sc()
+	}
+	keys = append(keys, evicted...)
+
+	result := []shared.Session{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "token:") {
+			continue
+		} else if s, err := v.loadSession(ctx, key); err != nil {
+			return nil, t.sc(http.StatusInternalServerError).err(err).done("couldn't read session").sc()
+		} else if s != nil {
+			result = append(result, *s)
+		}
+	}
+
+	return result, t.sc(http.StatusOK).ok().sc()
+}
+
+// EndSession logs out one of the user's sessions by the id Sessions gave it
+func (v *core) EndSession(ctx context.Context, uid shared.UUID, id string) int {
+	t := v.tracker(ctx, "EndSession")
+
+	keys, err := v.authn.SMembers(ctx, "logins:"+string(uid)).Result()
+	if err != nil {
+		return t.sc(http.StatusInternalServerError).err(err).done("couldn't get logins").sc()
+	}
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "token:") || sessionID(key) != id {
+			continue
+		} else if err = v.endSession(ctx, uid, key); err != nil {
+			return t.sc(http.StatusInternalServerError).err(err).done("couldn't end session").sc()
+		}
+		return t.sc(http.StatusNoContent).ok().sc()
+	}
+
+	return t.sc(http.StatusNotFound).err(shared.SessionNotFoundError).done("no such session").sc()
+}
+
+// EndSessions is logging out everywhere, refresh tokens included
+func (v *core) EndSessions(ctx context.Context, uid shared.UUID) int {
+	t := v.tracker(ctx, "EndSessions")
+
+	if code := v.clearLogins(ctx, uid); code != http.StatusGone {
+		return t.sc(code).done("clearing logins").sc()
+	}
+
+	return t.sc(http.StatusNoContent).ok().sc()
+}
+
+// loadSession is nil for a token that's already gone
+func (v *core) loadSession(ctx context.Context, key string) (*shared.Session, error) {
+	fields, err := v.authn.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	} else if fields[userid] == "" && fields[ended] == "" {
+		return nil, nil
+	}
+
+	var end *time.Time
+	if fields[ended] != "" {
+		ts := stamp(fields[ended])
+		end = &ts
+	}
+
+	return &shared.Session{
+		ID:        sessionID(key),
+		Remote:    fields[remote],
+		UserAgent: fields[agent],
+		Method:    fields[via],
+		Created:   stamp(fields[created]),
+		LastSeen:  stamp(fields[seen]),
+		Ended:     end,
+		Reason:    fields[reason],
+	}, nil
+}
+
+// sessionID is safe to show: the redis key is the cookie value, or is named
+// by it, so it can't go out as-is
+func sessionID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,142 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jsmit257/userservice/shared/v1"
+)
+
+func Test_Sessions(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_Sessions")
+	v := NewValidator(s, cfg, l)
+
+	ctime := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+
+	ctx := setcid("getting logins fails")
+	s.fail("SMembers", "logins:userid", broken)
+	_, sc := v.Sessions(ctx, userid)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("getting evicted logins fails")
+	s.fail("SMembers", "evicted:userid", broken)
+	_, sc = v.Sessions(ctx, userid)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	fields := loginFields(ctime)
+	fields[seen] = ctime.Add(time.Minute).Unix()
+	seed(s, "token:1", fields, "logins:userid")
+
+	ctx = setcid("reading a session fails")
+	s.fail("HGetAll", "token:1", broken)
+	_, sc = v.Sessions(ctx, userid)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("happy path skips pads and expired tokens")
+	seed(s, "pad:0", map[string]interface{}{userid: userid, redirect: redirect}, "logins:userid")
+	s.SAdd(ctx, "logins:userid", "token:2")
+	seed(s, "token:3", map[string]interface{}{
+		remote:  remote,
+		created: ctime.Unix(),
+		seen:    ctime.Unix(),
+		ended:   ctime.Add(time.Minute).Unix(),
+		reason:  "evicted",
+	}, "evicted:userid")
+	sessions, sc := v.Sessions(ctx, userid)
+	require.Equal(t, http.StatusOK, sc)
+	end := ctime.Add(time.Minute)
+	require.Equal(t, []shared.Session{{
+		ID:        sessionID("token:1"),
+		Remote:    remote,
+		UserAgent: "agent",
+		Method:    PasswordLogin,
+		Created:   ctime,
+		LastSeen:  ctime.Add(time.Minute),
+	}, {
+		ID:       sessionID("token:3"),
+		Remote:   remote,
+		Created:  ctime,
+		LastSeen: ctime,
+		Ended:    &end,
+		Reason:   "evicted",
+	}}, sessions)
+
+	require.Empty(t, s.faults)
+}
+
+func Test_EndSession(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_EndSession")
+	v := NewValidator(s, cfg, l)
+
+	id := sessionID("token:1")
+
+	ctx := setcid("getting logins fails")
+	s.fail("SMembers", "logins:userid", broken)
+	sc := v.EndSession(ctx, userid, id)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("not found")
+	seed(s, "pad:1", map[string]interface{}{userid: userid, redirect: redirect}, "logins:userid")
+	seed(s, "token:2", loginFields(time.Now()), "logins:userid")
+	sc = v.EndSession(ctx, userid, id)
+	require.Equal(t, http.StatusNotFound, sc)
+
+	ctx = setcid("removing the token fails")
+	seed(s, "token:1", loginFields(time.Now()), "logins:userid")
+	s.fail("HDel", "token:1", broken)
+	sc = v.EndSession(ctx, userid, id)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("removing from logins fails")
+	seed(s, "token:1", loginFields(time.Now()), "logins:userid")
+	s.fail("SRem", "logins:userid", broken)
+	sc = v.EndSession(ctx, userid, id)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("happy path")
+	seed(s, "token:1", loginFields(time.Now()), "logins:userid")
+	sc = v.EndSession(ctx, userid, id)
+	require.Equal(t, http.StatusNoContent, sc)
+	require.Equal(t, int64(0), s.Exists(ctx, "token:1").Val())
+	require.Equal(t, []string{"pad:1", "token:2"}, s.SMembers(ctx, "logins:userid").Val(), "the others are left alone")
+
+	require.Empty(t, s.faults)
+}
+
+func Test_EndSessions(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_EndSessions")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("clearing fails")
+	s.fail("SMembers", "families:userid", broken)
+	sc := v.EndSessions(ctx, userid)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("nothing to clear")
+	sc = v.EndSessions(ctx, userid)
+	require.Equal(t, http.StatusNoContent, sc)
+
+	ctx = setcid("happy path")
+	seed(s, "token:1", loginFields(time.Now()), "logins:userid")
+	s.SAdd(ctx, "families:userid", "fam")
+	s.SAdd(ctx, "family:fam", "refresh:1")
+	s.HSet(ctx, "refresh:1", userid, userid, family, "fam")
+	sc = v.EndSessions(ctx, userid)
+	require.Equal(t, http.StatusNoContent, sc)
+	require.Equal(t, int64(0), s.Exists(ctx, "token:1", "logins:userid", "families:userid", "family:fam", "refresh:1").Val())
+
+	require.Empty(t, s.faults)
+}
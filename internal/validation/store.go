@@ -0,0 +1,76 @@
+package valid
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// what SESSION_STORE can be
+const (
+	RedisStore  = "redis"
+	MemoryStore = "memory" // one process only, for dev and tests
+	MySQLStore  = "mysql"
+)
+
+type (
+	// Cmds is everything the validator asks of a session store: strings,
+	// hashes and sets under keys that expire. It's spelled the way redis
+	// spells it because redis came first, and every store hands back go-redis
+	// results so the validator reads them all the same way; a store that
+	// isn't redis builds its results with redis.New*Result
+	Cmds interface {
+		Del(context.Context, ...string) *redis.IntCmd
+		Exists(context.Context, ...string) *redis.IntCmd
+		Expire(context.Context, string, time.Duration) *redis.BoolCmd
+		HDel(context.Context, string, ...string) *redis.IntCmd
+		HGet(context.Context, string, string) *redis.StringCmd
+		HGetAll(context.Context, string) *redis.MapStringStringCmd
+		HIncrBy(context.Context, string, string, int64) *redis.IntCmd
+		HMGet(context.Context, string, ...string) *redis.SliceCmd
+		HSet(context.Context, string, ...interface{}) *redis.IntCmd
+		SAdd(context.Context, string, ...interface{}) *redis.IntCmd
+		SetNX(context.Context, string, interface{}, time.Duration) *redis.BoolCmd
+		SMembers(context.Context, string) *redis.StringSliceCmd
+		SRem(context.Context, string, ...interface{}) *redis.IntCmd
+		TTL(context.Context, string) *redis.DurationCmd
+		Scan(context.Context, uint64, string, int64) *redis.ScanCmd
+	}
+
+	// Store runs Cmds one at a time, or all together with Atomic: nobody
+	// sees some of fn's writes without the rest, and a crash part way
+	// through leaves none of them. Results inside fn aren't ready until
+	// Atomic returns, and Atomic's error is the first one any of them had
+	Store interface {
+		Cmds
+		Atomic(context.Context, func(Cmds)) error
+	}
+
+	redisStore struct {
+		redis.UniversalClient
+	}
+)
+
+// NewRedisStore is a Store on top of a redis client, whatever kind
+func NewRedisStore(client redis.UniversalClient) Store {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return clusterStore{redisStore{client}, cluster}
+	}
+	return redisStore{client}
+}
+
+// Atomic is MULTI/EXEC; on a cluster that's one per hash slot, so keys that
+// live on different nodes can be seen half written, and Sweep cleans up
+// after any that die part way
+func (s redisStore) Atomic(ctx context.Context, fn func(Cmds)) error {
+	_, err := s.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		fn(pipe)
+		return nil
+	})
+	return err
+}
+
+// WrongTypeError is what redis says about a hash command on a set and so on
+var WrongTypeError = fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value")
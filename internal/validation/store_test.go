@@ -0,0 +1,192 @@
+package valid
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// faultyStore is the memory store with a way to make any one command
+	// go wrong, for the paths a store that works never takes
+	faultyStore struct {
+		faultyCmds
+		store  Store
+		mu     sync.Mutex
+		faults []fault
+	}
+
+	// faultyCmds checks for a fault before every command, inside Atomic and
+	// out
+	faultyCmds struct {
+		Cmds
+		s *faultyStore
+	}
+
+	fault struct {
+		cmd, match string
+		err        error
+	}
+)
+
+// broken is whatever a store says when it's having a bad day
+var broken = fmt.Errorf("some error")
+
+func newFaultyStore() *faultyStore {
+	s := &faultyStore{store: NewMemoryStore()}
+	s.faultyCmds = faultyCmds{Cmds: s.store, s: s}
+	return s
+}
+
+// fail makes the next cmd on a key that matches come back with err instead
+// of running; a nil err is the command running and finding nothing to do.
+// Atomic fails as a whole, before any of it runs, the way EXEC does
+func (s *faultyStore) fail(cmd, match string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, fault{cmd: cmd, match: match, err: err})
+}
+
+// fault uses up the first fault for cmd on any of keys
+func (s *faultyStore) fault(cmd string, keys ...string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if f.cmd != cmd {
+			continue
+		}
+		for _, key := range keys {
+			if ok, _ := path.Match(f.match, key); ok {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+				return true, f.err
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *faultyStore) Atomic(ctx context.Context, fn func(Cmds)) error {
+	if ok, err := s.fault("Atomic", ""); ok {
+		return err
+	}
+
+	var rec *recorder
+	err := s.store.Atomic(ctx, func(tx Cmds) {
+		rec = &recorder{Cmds: faultyCmds{Cmds: tx, s: s}}
+		fn(rec)
+	})
+	if rec != nil && rec.err != nil {
+		return rec.err
+	}
+	return err
+}
+
+func (c faultyCmds) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	if ok, err := c.s.fault("Del", keys...); ok {
+		return redis.NewIntResult(0, err)
+	}
+	return c.Cmds.Del(ctx, keys...)
+}
+
+func (c faultyCmds) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	if ok, err := c.s.fault("Exists", keys...); ok {
+		return redis.NewIntResult(0, err)
+	}
+	return c.Cmds.Exists(ctx, keys...)
+}
+
+func (c faultyCmds) Expire(ctx context.Context, key string, d time.Duration) *redis.BoolCmd {
+	if ok, err := c.s.fault("Expire", key); ok {
+		return redis.NewBoolResult(false, err)
+	}
+	return c.Cmds.Expire(ctx, key, d)
+}
+
+func (c faultyCmds) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	if ok, err := c.s.fault("HDel", key); ok {
+		return redis.NewIntResult(0, err)
+	}
+	return c.Cmds.HDel(ctx, key, fields...)
+}
+
+func (c faultyCmds) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	if ok, err := c.s.fault("HGet", key); ok {
+		return redis.NewStringResult("", err)
+	}
+	return c.Cmds.HGet(ctx, key, field)
+}
+
+func (c faultyCmds) HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd {
+	if ok, err := c.s.fault("HGetAll", key); ok {
+		return redis.NewMapStringStringResult(map[string]string{}, err)
+	}
+	return c.Cmds.HGetAll(ctx, key)
+}
+
+func (c faultyCmds) HIncrBy(ctx context.Context, key, field string, n int64) *redis.IntCmd {
+	if ok, err := c.s.fault("HIncrBy", key); ok {
+		return redis.NewIntResult(0, err)
+	}
+	return c.Cmds.HIncrBy(ctx, key, field, n)
+}
+
+func (c faultyCmds) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	if ok, err := c.s.fault("HMGet", key); ok {
+		return redis.NewSliceResult(make([]interface{}, len(fields)), err)
+	}
+	return c.Cmds.HMGet(ctx, key, fields...)
+}
+
+func (c faultyCmds) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	if ok, err := c.s.fault("HSet", key); ok {
+		return redis.NewIntResult(0, err)
+	}
+	return c.Cmds.HSet(ctx, key, values...)
+}
+
+func (c faultyCmds) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	if ok, err := c.s.fault("SAdd", key); ok {
+		return redis.NewIntResult(0, err)
+	}
+	return c.Cmds.SAdd(ctx, key, members...)
+}
+
+func (c faultyCmds) SetNX(ctx context.Context, key string, value interface{}, d time.Duration) *redis.BoolCmd {
+	if ok, err := c.s.fault("SetNX", key); ok {
+		return redis.NewBoolResult(false, err)
+	}
+	return c.Cmds.SetNX(ctx, key, value, d)
+}
+
+func (c faultyCmds) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	if ok, err := c.s.fault("SMembers", key); ok {
+		return redis.NewStringSliceResult([]string{}, err)
+	}
+	return c.Cmds.SMembers(ctx, key)
+}
+
+func (c faultyCmds) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	if ok, err := c.s.fault("SRem", key); ok {
+		return redis.NewIntResult(0, err)
+	}
+	return c.Cmds.SRem(ctx, key, members...)
+}
+
+func (c faultyCmds) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	if ok, err := c.s.fault("TTL", key); ok {
+		return redis.NewDurationResult(-2, err)
+	}
+	return c.Cmds.TTL(ctx, key)
+}
+
+func (c faultyCmds) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	if ok, err := c.s.fault("Scan", match); ok {
+		return redis.NewScanCmdResult([]string{}, 0, err)
+	}
+	return c.Cmds.Scan(ctx, cursor, match, count)
+}
@@ -0,0 +1,89 @@
+package valid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// how many keys to ask SCAN for at a time
+const sweepBatch = 100
+
+var (
+	// sets whose members are keys that expire on their own
+	sweptSets = []string{"logins:*", "evicted:*", "family:*"}
+
+	// keys that are supposed to expire; one without a ttl was never handed
+	// to anyone, or outlived the session it belonged to
+	sweptKeys = []string{"token:*", "pad:*", "mfa:*", "mfacode:*", "authz:*", "refresh:*", "magic:*", "magicsent:*"}
+)
+
+// Sweep repairs what the writes here used to leave behind when they died part
+// way, before they were transactions, and what still happens on its own:
+// members of a set that outlived their keys, and a key with no ttl, like the
+// one Valid makes when it notes last seen on a token that expired a moment
+// before. Running it from more than one instance at a time is harmless
+func (v *core) Sweep(ctx context.Context) int {
+	t := v.tracker(ctx, "Sweep")
+
+	var pruned, dropped int
+	for _, match := range sweptSets {
+		if err := v.scan(ctx, match, func(set string) error {
+			members, err := v.authn.SMembers(ctx, set).Result()
+			if err != nil {
+				return err
+			}
+			live, err := v.prune(ctx, set, members)
+			if err == nil {
+				pruned += len(members) - len(live)
+			}
+			return err
+		}); err != nil {
+			return t.sc(http.StatusInternalServerError).
+				err(err).
+				fields(logrus.Fields{"match": match, "pruned": pruned}).
+				done("sweeping sets").
+				sc()
+		}
+	}
+
+	for _, match := range sweptKeys {
+		if err := v.scan(ctx, match, func(key string) error {
+			if ttl, err := v.authn.TTL(ctx, key).Result(); err != nil || ttl != -1 {
+				return err // -2 is already gone
+			} else if err = v.authn.Del(ctx, key).Err(); err != nil {
+				return err
+			}
+			dropped++
+			return nil
+		}); err != nil {
+			return t.sc(http.StatusInternalServerError).
+				err(err).
+				fields(logrus.Fields{"match": match, "pruned": pruned, "dropped": dropped}).
+				done("sweeping keys").
+				sc()
+		}
+	}
+
+	return t.sc(http.StatusOK).fields(logrus.Fields{"pruned": pruned, "dropped": dropped}).ok().sc()
+}
+
+// scan calls fn with every key that matches, a batch at a time
+func (v *core) scan(ctx context.Context, match string, fn func(string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := v.authn.Scan(ctx, cursor, match, sweepBatch).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err = fn(key); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
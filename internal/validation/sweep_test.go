@@ -0,0 +1,59 @@
+package valid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Sweep(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_Sweep")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("scanning fails")
+	s.fail("Scan", "logins:*", broken)
+	require.Equal(t, http.StatusInternalServerError, v.Sweep(ctx))
+
+	s.SAdd(ctx, "logins:a", "token:1")
+
+	ctx = setcid("reading a set fails")
+	s.fail("SMembers", "logins:a", broken)
+	require.Equal(t, http.StatusInternalServerError, v.Sweep(ctx))
+
+	ctx = setcid("pruning a set fails")
+	s.fail("SRem", "logins:a", broken)
+	require.Equal(t, http.StatusInternalServerError, v.Sweep(ctx))
+	require.Equal(t, []string{"token:1"}, s.SMembers(ctx, "logins:a").Val())
+
+	seed(s, "token:1", loginFields(time.Now()))
+
+	ctx = setcid("reading a ttl fails")
+	s.fail("TTL", "token:1", broken)
+	require.Equal(t, http.StatusInternalServerError, v.Sweep(ctx))
+
+	s.HSet(ctx, "token:2", seen, time.Now().Unix()) // never got a ttl
+
+	ctx = setcid("dropping a key fails")
+	s.fail("Del", "token:2", broken)
+	require.Equal(t, http.StatusInternalServerError, v.Sweep(ctx))
+
+	ctx = setcid("happy path")
+	s.SAdd(ctx, "logins:a", "pad:2")
+	s.SAdd(ctx, "family:f", "refresh:1")
+	seed(s, "refresh:1", map[string]interface{}{userid: userid, family: "f"})
+	s.HSet(ctx, "token:3", seen, time.Now().Unix())
+	s.fail("TTL", "token:3", nil) // expired since the scan
+	require.Equal(t, http.StatusOK, v.Sweep(ctx))
+	require.Equal(t, []string{"token:1"}, s.SMembers(ctx, "logins:a").Val())
+	require.Equal(t, []string{"refresh:1"}, s.SMembers(ctx, "family:f").Val())
+	require.Equal(t, int64(0), s.Exists(ctx, "token:2").Val())
+	require.Equal(t, int64(2), s.Exists(ctx, "token:1", "refresh:1").Val())
+
+	require.Empty(t, s.faults)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,43 +23,138 @@ const (
 	remote   = "remote"
 	otp      = "pad"
 	redirect = "redirect"
+	attempts = "attempts"
+	mfacode  = "code"
+	clientid = "clientid"
+	scope    = "scope"
+	nonce    = "nonce"
+	pkce     = "challenge"
+	username = "name"
+	family   = "family"
+	rotated  = "rotated"
+	created  = "created"
+	seen     = "seen"
+	agent    = "agent"
+	via      = "method"
+	ended    = "ended"
+	reason   = "reason"
+
+	// how a session came to be, for the session list
+	PasswordLogin = "password"
+	MFALogin      = "mfa"
+	ResetLogin    = "reset"
+	RefreshLogin  = "refresh"
+	MagicLogin    = "magic"
+
+	// what MAX_LOGINS_POLICY can be: turn the new login away, or end the
+	// session that was started first or used last to make room
+	RejectLogins = "reject"
+	EvictOldest  = "oldest"
+	EvictLRU     = "lru"
+
+	// how long an evicted session stays in the list, so its owner can see
+	// what became of it
+	evictedTimeout = 24 * time.Hour
 )
 
 type (
+	// Client is what a request says about whoever sent it
+	Client struct {
+		Remote    string
+		UserAgent string
+	}
+
+	// Active is how Refresh asks about a user before starting them a new
+	// session: http.StatusOK if they can have one, http.StatusUnauthorized if
+	// they were deleted or locked out since, anything else if it couldn't tell
+	Active func(context.Context, shared.UUID) int
+
 	Validator interface {
-		Login(context.Context, shared.UUID, string) (*http.Cookie, int)
-		Logout(context.Context, string) (*http.Cookie, int)
-		Valid(context.Context, string) (*http.Cookie, int)
+		Login(context.Context, shared.UUID, string, string, Client) (*http.Cookie, int)
+		Logout(context.Context, string, Client) (*http.Cookie, int)
+		Valid(context.Context, string, Client) (*http.Cookie, int)
 		OTP(context.Context, shared.UUID, string, string) (string, int)
 		LoginOTP(context.Context, string) (string, int)
+		CheckOTP(context.Context, string) (shared.UUID, int)
 		CompleteOTP(context.Context, string) (shared.UUID, int)
-	}
-
-	authn interface {
-		Exists(context.Context, ...string) *redis.IntCmd
-		Expire(context.Context, string, time.Duration) *redis.BoolCmd
-		HDel(context.Context, string, ...string) *redis.IntCmd
-		HGet(context.Context, string, string) *redis.StringCmd
-		HGetAll(context.Context, string) *redis.MapStringStringCmd
-		HSet(context.Context, string, ...interface{}) *redis.IntCmd
-		SAdd(context.Context, string, ...interface{}) *redis.IntCmd
-		SMembers(context.Context, string) *redis.StringSliceCmd
-		SRem(context.Context, string, ...interface{}) *redis.IntCmd
+		BeginMFA(context.Context, shared.UUID, string) (string, int)
+		PendingMFA(context.Context, string) (shared.UUID, int)
+		EndMFA(context.Context, string) int
+		SendMFACode(context.Context, string) (string, int)
+		CheckMFACode(context.Context, string, string) int
+		SendMagicLink(context.Context, shared.UUID, string) (string, int)
+		RedeemMagicLink(context.Context, string) (shared.UUID, int)
+		Session(context.Context, string) (shared.UUID, int)
+		BeginAuthCode(context.Context, *shared.AuthCode) (string, int)
+		RedeemAuthCode(context.Context, string) (*shared.AuthCode, int)
+		NewRefresh(context.Context, shared.UUID, string) (*http.Cookie, int)
+		Refresh(context.Context, string, Client, Active) (*http.Cookie, *http.Cookie, int)
+		RevokeRefresh(context.Context, string) (*http.Cookie, int)
+		Sessions(context.Context, shared.UUID) ([]shared.Session, int)
+		EndSession(context.Context, shared.UUID, string) int
+		EndSessions(context.Context, shared.UUID) int
+		Sweep(context.Context) int
 	}
 
 	core struct {
-		authn
-		maxLogins    int
-		log          *logrus.Entry
-		metrics      *prometheus.CounterVec
-		loginCookie  func(string) *http.Cookie
-		logoutCookie *http.Cookie
+		authn          Store
+		maxLogins      int
+		loginsPolicy   string
+		maxLifetime    time.Duration
+		seenInterval   time.Duration
+		binding        binding
+		mfaTimeout     time.Duration
+		mfaAttempts    int64
+		codeDigits     int
+		codeTimeout    time.Duration
+		codeAttempts   int64
+		codeResend     time.Duration
+		magicTimeout   time.Duration
+		magicResend    time.Duration
+		magicMaxSends  int64
+		magicWindow    time.Duration
+		authzTimeout   time.Duration
+		tokens         *sessionTokens
+		refreshName    string
+		refreshTimeout time.Duration
+		log            *logrus.Entry
+		metrics        *prometheus.CounterVec
+		loginCookie    func(string) *http.Cookie
+		logoutCookie   *http.Cookie
 	}
 )
 
-var NotAuthorized = fmt.Errorf("not authorized")
+var (
+	NotAuthorized = fmt.Errorf("not authorized")
+
+	// sessionFields is everything Login puts in a token: to log it out, they
+	// all have to go
+	sessionFields = []string{userid, remote, created, seen, agent, via}
+)
+
+// NewValidator panics if signed session tokens are turned on and the key
+// can't be loaded, or the session binding or max logins policy make no
+// sense, same as a bad config would
+func NewValidator(client Store, cfg *config.Config, logger *logrus.Entry) Validator {
+	var tokens *sessionTokens
+	if cfg.SessionJWT {
+		var err error
+		if tokens, err = newSessionTokens(cfg); err != nil {
+			panic(fmt.Errorf("failed to load session key: %w", err))
+		}
+	}
+
+	bind, err := newBinding(cfg.SessionBinding)
+	if err != nil {
+		panic(err)
+	}
+
+	switch cfg.MaxLoginsPolicy {
+	case RejectLogins, EvictOldest, EvictLRU:
+	default:
+		panic(fmt.Errorf("unknown max logins policy: %q", cfg.MaxLoginsPolicy))
+	}
 
-func NewValidator(client authn, cfg *config.Config, logger *logrus.Entry) Validator {
 	genCookie := http.Cookie{
 		Name:     cfg.CookieName,
 		Value:    "",
@@ -68,8 +165,26 @@ func NewValidator(client authn, cfg *config.Config, logger *logrus.Entry) Valida
 	}
 
 	return &core{
-		authn:     client,
-		maxLogins: cfg.MaxLogins,
+		authn:          client,
+		maxLogins:      cfg.MaxLogins,
+		loginsPolicy:   cfg.MaxLoginsPolicy,
+		maxLifetime:    cfg.SessionMaxLifetime,
+		seenInterval:   cfg.SessionSeenInterval,
+		binding:        bind,
+		mfaTimeout:     time.Duration(cfg.MFATimeout) * time.Minute,
+		mfaAttempts:    int64(cfg.MFAMaxAttempts),
+		codeDigits:     cfg.MFACodeDigits,
+		codeTimeout:    cfg.MFACodeTimeout,
+		codeAttempts:   int64(cfg.MFACodeAttempts),
+		codeResend:     cfg.MFACodeResend,
+		magicTimeout:   cfg.MagicLinkTimeout,
+		magicResend:    cfg.MagicLinkResend,
+		magicMaxSends:  int64(cfg.MagicLinkMaxSends),
+		magicWindow:    cfg.MagicLinkWindow,
+		authzTimeout:   cfg.OIDCCodeTimeout,
+		tokens:         tokens,
+		refreshName:    cfg.RefreshCookie,
+		refreshTimeout: cfg.RefreshTimeout,
 		log: logger.WithFields(logrus.Fields{
 			"pkg": "valid",
 			"db":  "redis",
@@ -89,57 +204,62 @@ func NewValidator(client authn, cfg *config.Config, logger *logrus.Entry) Valida
 	}
 }
 
-func (v *core) Login(ctx context.Context, uid shared.UUID, rmt string) (*http.Cookie, int) {
-	t := v.tracker(ctx, "Login")
+// Login starts a session; method is how the user proved who they are, one of
+// the *Login constants
+func (v *core) Login(ctx context.Context, uid shared.UUID, name, method string, c Client) (*http.Cookie, int) {
+	t := v.tracker(ctx, "Login").fields(logrus.Fields{
+		"remote": c.Remote,
+		"agent":  c.UserAgent,
+		"method": method,
+	})
 
-	cookie := v.loginCookie(uuid.NewString())
+	var err error
+	now := time.Now().UTC().Unix()
+	sid := uuid.NewString()
+	cookie := v.loginCookie(sid)
 	logins := "logins:" + string(uid)
-	token := "token:" + cookie.Value
+	token := "token:" + sid
 
-	if code := v.checkCount(ctx, logins); code != http.StatusOK {
+	if code := v.checkCount(ctx, uid); code != http.StatusOK {
 		return v.logoutCookie, t.sc(code).
 			err(fmt.Errorf("too many logins")).
 			done("check count fails").
 			sc()
-	} else if err := v.authn.HSet(ctx, token, map[string]interface{}{
-		userid: string(uid),
-		remote: rmt,
-	}).Err(); err != nil {
-		return v.logoutCookie, t.sc(http.StatusInternalServerError).
-			err(err).
-			done("couldn't set new token").
-			sc()
-	} else if exp, err := v.authn.Expire(
-		ctx,
-		token,
-		time.Duration(cookie.MaxAge)*time.Second,
-	).Result(); err != nil {
+	} else if cookie.Value, err = v.mint(uid, name, sid); err != nil {
 		return v.logoutCookie, t.sc(http.StatusInternalServerError).
 			err(err).
-			done("failed to set expire on token").
-			sc()
-	} else if !exp {
-		return v.logoutCookie, t.sc(http.StatusInternalServerError).
-			err(fmt.Errorf("no token was updated?")).
-			done("no token was updated?").
+			done("couldn't sign session token").
 			sc()
-	} else if err = v.authn.SAdd(ctx, logins, token).Err(); err != nil {
+	} else if err = v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, token, map[string]interface{}{
+			userid:  string(uid),
+			remote:  c.Remote,
+			created: now,
+			seen:    now,
+			agent:   c.UserAgent,
+			via:     method,
+		})
+		tx.Expire(ctx, token, time.Duration(cookie.MaxAge)*time.Second)
+		tx.SAdd(ctx, logins, token)
+	}); err != nil {
 		return v.logoutCookie, t.sc(http.StatusInternalServerError).
 			err(err).
-			done("couldn't add new login to current").
+			done("couldn't save new login").
 			sc()
 	}
 
 	return cookie, t.sc(http.StatusOK).ok().sc()
 }
 
-func (v *core) Logout(ctx context.Context, token string) (*http.Cookie, int) {
+func (v *core) Logout(ctx context.Context, token string, c Client) (*http.Cookie, int) {
 	t := v.tracker(ctx, "Logout")
 
-	key := "token:" + token
-	if _, code := v.Valid(ctx, token); code != http.StatusNoContent {
+	if _, code := v.Valid(ctx, token, c); code != http.StatusNoContent {
 		return nil, t.sc(code).err(NotAuthorized).done("logout request isn't valid").sc()
-	} else if uid, err := v.authn.HGet(ctx, key, userid).Result(); err != nil && err != redis.Nil {
+	}
+
+	key, _, _ := v.session(token) // Valid already turned away anything unreadable
+	if uid, err := v.authn.HGet(ctx, key, userid).Result(); err != nil && err != redis.Nil {
 		return nil, t.sc(http.StatusInternalServerError).
 			err(err).
 			done("couldn't get userid for token").
@@ -149,37 +269,85 @@ func (v *core) Logout(ctx context.Context, token string) (*http.Cookie, int) {
 			err(NotAuthorized).
 			done("user isn't logged in").
 			sc()
-	} else if err := v.authn.HDel(ctx, key, userid, remote).Err(); err != nil {
+	} else if err = v.endSession(ctx, shared.UUID(uid), key); err != nil {
 		return nil, t.sc(http.StatusInternalServerError).
 			err(err).
 			done("couldn't remove token").
 			sc()
-	} else if err = v.authn.SRem(ctx, "logins:"+uid, key).Err(); err != nil {
-		return nil, t.sc(http.StatusInternalServerError).
-			err(err).
-			done("couldn't remove auth token from user").
-			sc()
 	}
 
 	return v.logoutCookie, t.sc(http.StatusNoContent).ok().sc() // liked StatusGone better, but it's a 4xx series
 }
 
-func (v *core) Valid(ctx context.Context, token string) (*http.Cookie, int) {
+// Valid slides a live session's expiry forward, as long as that doesn't take
+// it past its maximum lifetime and c is allowed to use it, and notes that it
+// was seen; last seen only gets written once per seenInterval so every
+// request isn't a write
+func (v *core) Valid(ctx context.Context, token string, c Client) (*http.Cookie, int) {
 	t := v.tracker(ctx, "Valid")
 
+	now := time.Now().UTC()
 	cookie := v.loginCookie(token)
-	key := "token:" + token
-	if count, err := v.authn.Exists(ctx, key).Result(); err != nil {
+	key, claims, err := v.session(token)
+	if err != nil {
+		return v.logoutCookie, t.sc(http.StatusTemporaryRedirect).
+			err(err).
+			done("unreadable token").
+			sc()
+	}
+
+	fields, err := v.authn.HMGet(ctx, key, userid, created, seen, remote, agent).Result()
+	if err != nil {
 		return v.logoutCookie, t.sc(http.StatusInternalServerError).
 			err(err).
-			done("checking exists").
+			done("reading session").
 			sc()
-	} else if count == 0 {
+	} else if fields[0] == nil {
 		return v.logoutCookie, t.sc(http.StatusTemporaryRedirect).
 			err(fmt.Errorf("token doesn't exist")).
 			done("token doesn't exist").
 			sc()
-	} else if found, err := v.authn.Expire(ctx, key, time.Duration(cookie.MaxAge)*time.Second).Result(); err != nil {
+	}
+
+	uid, ctime, last := fields[0].(string), stamp(fields[1]), stamp(fields[2])
+	login := Client{}
+	login.Remote, _ = fields[3].(string)
+	login.UserAgent, _ = fields[4].(string)
+	if !v.binding.allows(login, c) {
+		return v.logoutCookie, t.sc(http.StatusTemporaryRedirect).
+			err(NotAuthorized).
+			fields(logrus.Fields{
+				"bound":  login.Remote,
+				"remote": c.Remote,
+				"agent":  c.UserAgent,
+			}).
+			done("session is bound to another client").
+			sc()
+	}
+
+	ttl := time.Duration(cookie.MaxAge) * time.Second
+	if v.maxLifetime > 0 && !ctime.IsZero() { // sessions from before created was kept get a pass
+		left := v.maxLifetime - now.Sub(ctime)
+		if left <= 0 {
+			t = t.fields(logrus.Fields{"created": ctime})
+			if err = v.endSession(ctx, shared.UUID(uid), key); err != nil {
+				return v.logoutCookie, t.sc(http.StatusInternalServerError).
+					err(err).
+					done("ending expired session").
+					sc()
+			}
+			return v.logoutCookie, t.sc(http.StatusTemporaryRedirect).
+				err(fmt.Errorf("session is too old")).
+				done("session outlived its maximum lifetime").
+				sc()
+		} else if left < ttl {
+			ttl = left.Truncate(time.Second) + time.Second
+			cookie.MaxAge = int(ttl / time.Second)
+			cookie.Expires = now.Add(ttl)
+		}
+	}
+
+	if found, err := v.authn.Expire(ctx, key, ttl).Result(); err != nil {
 		return v.logoutCookie, t.sc(http.StatusInternalServerError).
 			err(err).
 			done("setting new expiry").
@@ -191,41 +359,64 @@ func (v *core) Valid(ctx context.Context, token string) (*http.Cookie, int) {
 			sc()
 	}
 
+	if now.Sub(last) >= v.seenInterval {
+		if err = v.authn.HSet(ctx, key, seen, now.Unix()).Err(); err != nil {
+			t.warn("couldn't update last seen: %v", err) // the session's still good
+		}
+	}
+
+	// a signed token gets a fresh expiry along with the session; opaque ones
+	// never change
+	if claims != nil {
+		if cookie.Value, err = v.mint(shared.UUID(claims.Subject), claims.Name, claims.SessionID); err != nil {
+			return v.logoutCookie, t.sc(http.StatusInternalServerError).
+				err(err).
+				done("couldn't sign session token").
+				sc()
+		}
+	}
+
 	return cookie, t.sc(http.StatusNoContent).ok().sc()
 }
 
+// endSession is the part of logging out that redis cares about; the token
+// and its place in logins go together or not at all
+func (v *core) endSession(ctx context.Context, uid shared.UUID, key string) error {
+	err := v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HDel(ctx, key, sessionFields...)
+		tx.SRem(ctx, "logins:"+string(uid), key)
+	})
+	return err
+}
+
 func (v *core) OTP(ctx context.Context, uid shared.UUID, rmt, three02 string) (string, int) {
 	t := v.tracker(ctx, "OTP")
 
 	pad := uuid.NewString()
-	logins := "logins:" + string(uid)
 	key := "pad:" + pad
 
-	if code := v.checkCount(ctx, logins); code != http.StatusOK {
+	if code := v.checkCount(ctx, uid); code != http.StatusOK {
 		return "", t.sc(code).
 			err(fmt.Errorf("too many logins")).
 			done("check count fails").
 			sc()
-	} else if err := v.authn.HSet(ctx, key, map[string]interface{}{
-		userid:   string(uid),
-		remote:   rmt,
-		redirect: three02,
-	}).Err(); err != nil {
+	}
+
+	var added *redis.IntCmd
+	if err := v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HSet(ctx, key, map[string]interface{}{
+			userid:   string(uid),
+			remote:   rmt,
+			redirect: three02,
+		})
+		tx.Expire(ctx, key, 15*time.Minute)
+		added = tx.SAdd(ctx, "logins:"+string(uid), key)
+	}); err != nil {
 		return err.Error(), t.sc(http.StatusInternalServerError).
 			err(err).
 			done("creating pad entry").
 			sc()
-	} else if err := v.authn.Expire(ctx, key, 15*time.Minute).Err(); err != nil {
-		return err.Error(), t.sc(http.StatusInternalServerError).
-			err(err).
-			done("expiring new pad entry").
-			sc()
-	} else if n, err := v.authn.SAdd(ctx, "logins:"+string(uid), key).Result(); err != nil {
-		return err.Error(), t.sc(http.StatusInternalServerError).
-			err(err).
-			done("expiring new pad entry").
-			sc()
-	} else if n != 1 {
+	} else if n := added.Val(); n != 1 {
 		return "no row was updated", t.sc(http.StatusInternalServerError).
 			err(fmt.Errorf("wrong number of rows updated: %d", n)).
 			done("no row was updated").
@@ -259,6 +450,22 @@ func (v *core) LoginOTP(ctx context.Context, pad string) (location string, code
 	return three02, t.sc(http.StatusFound).ok().sc()
 }
 
+// CheckOTP says whose pad it is without using it up, so a reset can be
+// turned away before anything else about it gets looked at; a pad that isn't
+// there, or was already used, is http.StatusForbidden
+func (v *core) CheckOTP(ctx context.Context, pad string) (shared.UUID, int) {
+	t := v.tracker(ctx, "CheckOTP")
+
+	uid, err := v.authn.HGet(ctx, "pad:"+pad, userid).Result()
+	if err == redis.Nil || err == nil && uid == "" {
+		return "", t.sc(http.StatusForbidden).err(NotAuthorized).done("no such pad").sc()
+	} else if err != nil {
+		return "", t.sc(http.StatusInternalServerError).err(err).done(err.Error()).sc()
+	}
+
+	return shared.UUID(uid), t.sc(http.StatusOK).ok().sc()
+}
+
 func (v *core) CompleteOTP(ctx context.Context, pad string) (shared.UUID, int) {
 	t := v.tracker(ctx, "CompleteOTP")
 
@@ -276,35 +483,29 @@ func (v *core) CompleteOTP(ctx context.Context, pad string) (shared.UUID, int) {
 	return shared.UUID(result[userid]), http.StatusOK
 }
 
-func (v *core) clearTokens(ctx context.Context, tokens []string) ([]interface{}, error) {
-	t := v.tracker(ctx, "clearTokens")
-
-	var result []interface{}
-	for _, token := range tokens {
-		if err := v.authn.HDel(ctx, token, userid, remote, redirect).Err(); err != nil {
-			return result, t.err(err).done("couldn't clear all tokens").err() // what if it just expired?
-		} else {
-			result = append(result, token)
-		}
-	}
-
-	return result, t.ok().err()
-}
-
+// clearLogins ends every login a user has in one transaction, so a failure
+// part way through can't leave tokens behind that logins forgot about
 func (v *core) clearLogins(ctx context.Context, uid shared.UUID) int {
 	t := v.tracker(ctx, "clearLogins")
 
 	key := "logins:" + string(uid)
-	if tokens, err := v.authn.SMembers(ctx, key).Result(); err != nil && err != redis.Nil {
+	if err := v.clearFamilies(ctx, uid); err != nil {
+		return t.sc(http.StatusInternalServerError).err(err).done("couldn't revoke refresh tokens").sc()
+	} else if tokens, err := v.authn.SMembers(ctx, key).Result(); err != nil && err != redis.Nil {
 		return t.sc(http.StatusInternalServerError).err(err).done("couldn't get userid for token").sc()
 	} else if err == redis.Nil {
 		return t.sc(http.StatusGone).err(NotAuthorized).done("user isn't logged in").sc()
 	} else if l := len(tokens); l == 0 { // redundant?
 		return t.sc(http.StatusGone).err(NotAuthorized).done("no tokens to clear").sc()
-	} else if els, err := v.clearTokens(ctx, tokens); err != nil {
+	} else if err = v.authn.Atomic(ctx, func(tx Cmds) {
+		els := make([]interface{}, 0, l)
+		for _, token := range tokens {
+			tx.HDel(ctx, token, append([]string{redirect}, sessionFields...)...)
+			els = append(els, token)
+		}
+		tx.SRem(ctx, key, els...)
+	}); err != nil {
 		return t.sc(http.StatusInternalServerError).err(err).done("clearing tokens").sc()
-	} else if err = v.authn.SRem(ctx, key, els...).Err(); err != nil {
-		return t.sc(http.StatusInternalServerError).err(err).done("couldn't remove logins").sc()
 	} else {
 		t.fields(logrus.Fields{"count": l})
 	}
@@ -312,9 +513,13 @@ func (v *core) clearLogins(ctx context.Context, uid shared.UUID) int {
 	return t.sc(http.StatusGone).ok().sc()
 }
 
-func (v *core) checkCount(ctx context.Context, key string) int {
+// checkCount makes sure there's room for one more login, clearing out logins
+// that expired on their own first and then, depending on the policy,
+// evicting a live one
+func (v *core) checkCount(ctx context.Context, uid shared.UUID) int {
 	t := v.tracker(ctx, "checkCount")
 
+	key := "logins:" + string(uid)
 	tokens, err := v.authn.SMembers(ctx, key).Result()
 	if err != nil {
 		return t.sc(http.StatusInternalServerError).
@@ -325,33 +530,99 @@ func (v *core) checkCount(ctx context.Context, key string) int {
 		return t.sc(http.StatusOK).ok().sc()
 	}
 
+	live, err := v.prune(ctx, key, tokens)
+	if err != nil {
+		return t.sc(http.StatusInternalServerError).
+			err(err).
+			done("couldn't remove stale tokens").
+			sc()
+	} else if len(live) < v.maxLogins {
+		return t.sc(http.StatusOK).ok().sc()
+	}
+
+	return v.makeRoom(ctx, uid, live)
+}
+
+// prune takes members whose keys expired out of set and returns the rest
+func (v *core) prune(ctx context.Context, set string, members []string) ([]string, error) {
 	var expired []interface{}
-	for _, token := range tokens {
-		exists, err := v.authn.Exists(ctx, token).Result()
-		if err != nil {
-			return t.sc(http.StatusInternalServerError).
-				err(err).
-				done("failed checking for token").
-				sc()
+	var live []string
+	for _, member := range members {
+		if exists, err := v.authn.Exists(ctx, member).Result(); err != nil {
+			return nil, err
 		} else if exists == 0 {
-			t.debug("found expired token")
-			expired = append(expired, token)
+			expired = append(expired, member)
+		} else {
+			live = append(live, member)
 		}
 	}
 
 	if len(expired) == 0 {
+		return live, nil
+	}
+	return live, v.authn.SRem(ctx, set, expired...).Err()
+}
+
+// makeRoom is for when every login is live; pads for OTP logins count
+// against the limit but can't be evicted
+func (v *core) makeRoom(ctx context.Context, uid shared.UUID, live []string) int {
+	t := v.tracker(ctx, "makeRoom").fields(logrus.Fields{"policy": v.loginsPolicy})
+
+	if v.loginsPolicy == RejectLogins {
 		return t.sc(http.StatusTooManyRequests).done("too many logins").sc()
-	} else if removed, err := v.authn.SRem(ctx, key, expired...).Result(); err != nil {
-		return t.sc(http.StatusInternalServerError).
-			err(err).
-			fields(logrus.Fields{"expired": expired}).
-			done("couldn't remove stale tokens").
-			sc()
-	} else if len(tokens)-int(removed) < v.maxLogins {
-		return t.sc(http.StatusOK).ok().sc()
 	}
 
-	return t.sc(http.StatusTooManyRequests).done("too many logins").sc()
+	field, why := created, "too many logins, the oldest session was ended"
+	if v.loginsPolicy == EvictLRU {
+		field, why = seen, "too many logins, the least recently used session was ended"
+	}
+
+	var victim string
+	var since time.Time
+	for _, token := range live {
+		if !strings.HasPrefix(token, "token:") {
+			continue
+		} else if when, err := v.authn.HGet(ctx, token, field).Result(); err != nil && err != redis.Nil {
+			return t.sc(http.StatusInternalServerError).err(err).done("reading session").sc()
+		} else if ts := stamp(when); victim == "" || ts.Before(since) {
+			victim, since = token, ts
+		}
+	}
+
+	if victim == "" {
+		return t.sc(http.StatusTooManyRequests).done("too many logins, none of them sessions").sc()
+	} else if err := v.evict(ctx, uid, victim, why); err != nil {
+		return t.sc(http.StatusInternalServerError).err(err).done("evicting session").sc()
+	}
+
+	return t.sc(http.StatusOK).fields(logrus.Fields{"evicted": sessionID(victim)}).ok().sc()
+}
+
+// evict ends a session but leaves enough of it behind, under evicted:<uid>,
+// for the session list to say why
+func (v *core) evict(ctx context.Context, uid shared.UUID, key, why string) error {
+	evicted := "evicted:" + string(uid)
+	err := v.authn.Atomic(ctx, func(tx Cmds) {
+		tx.HDel(ctx, key, userid)
+		tx.HSet(ctx, key, map[string]interface{}{
+			ended:  time.Now().UTC().Unix(),
+			reason: why,
+		})
+		tx.Expire(ctx, key, evictedTimeout)
+		tx.SRem(ctx, "logins:"+string(uid), key)
+		tx.SAdd(ctx, evicted, key)
+		tx.Expire(ctx, evicted, evictedTimeout)
+	})
+	return err
+}
+
+// stamp reads back the unix times sessions keep; a missing one is zero
+func stamp(v interface{}) time.Time {
+	s, _ := v.(string)
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC()
+	}
+	return time.Time{}
 }
 
 func (v *core) tracker(ctx context.Context, fn string) tracker {
@@ -2,12 +2,10 @@ package valid
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"testing"
 	"time"
 
-	"github.com/go-redis/redismock/v9"
 	"github.com/jsmit257/userservice/internal/config"
 	"github.com/jsmit257/userservice/internal/metrics"
 	"github.com/jsmit257/userservice/shared/v1"
@@ -37,176 +35,222 @@ var (
 	)
 
 	cfg = &config.Config{
-		AuthnTimeout: 15,
-		CookieName:   "foobar",
-		MaxLogins:    5,
+		AuthnTimeout:        15,
+		CookieName:          "foobar",
+		MaxLogins:           5,
+		MaxLoginsPolicy:     RejectLogins,
+		MFATimeout:          5,
+		MFAMaxAttempts:      5,
+		MFACodeDigits:       6,
+		MFACodeTimeout:      10 * time.Minute,
+		MFACodeAttempts:     3,
+		MFACodeResend:       30 * time.Second,
+		MagicLinkTimeout:    10 * time.Minute,
+		MagicLinkResend:     time.Minute,
+		MagicLinkMaxSends:   3,
+		MagicLinkWindow:     time.Hour,
+		OIDCCodeTimeout:     time.Minute,
+		RefreshTimeout:      time.Hour,
+		RefreshCookie:       "us-refresh",
+		SessionSeenInterval: time.Minute,
 	}
 	expireme = time.Duration(cfg.AuthnTimeout*60) * time.Second
+
+	testClient = Client{Remote: remote, UserAgent: "agent"}
 )
 
 func Test_Login(t *testing.T) {
 	t.Parallel()
 
-	db, mock := redismock.NewClientMock()
+	s := newFaultyStore()
 	l := logrus.WithField("test", "Test_Login")
-	v := NewValidator(db, cfg, l)
+	v := NewValidator(s, cfg, l)
 	logoutCookie := v.(*core).logoutCookie
 
 	ctx := setcid("count fails, any reason")
-	mock.ExpectSMembers("logins:" + userid).SetErr(fmt.Errorf("some error"))
-	valid, sc := v.Login(ctx, userid, remote)
+	s.fail("SMembers", "logins:userid", broken)
+	valid, sc := v.Login(ctx, userid, "name", PasswordLogin, testClient)
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, logoutCookie, valid)
 
 	ctx = setcid("failed to set token")
-	mock.ExpectSMembers("logins:userid").SetVal([]string{})
-	mock.Regexp().ExpectHSet("token:.*", map[string]interface{}{
-		userid: userid,
-		remote: remote,
-	}).SetErr(fmt.Errorf("some error"))
-	valid, sc = v.Login(ctx, userid, remote)
+	s.fail("HSet", "token:*", broken)
+	valid, sc = v.Login(ctx, userid, "name", PasswordLogin, testClient)
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, logoutCookie, valid)
 
 	ctx = setcid("failed to set expiry")
-	mock.ExpectSMembers("logins:userid").SetVal([]string{})
-	mock.Regexp().ExpectHSet("token:.*", map[string]interface{}{
-		userid: userid,
-		remote: remote,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("token:.*", expireme).SetErr(fmt.Errorf("some error"))
-	valid, sc = v.Login(ctx, userid, remote)
+	s.fail("Expire", "token:*", broken)
+	valid, sc = v.Login(ctx, userid, "name", PasswordLogin, testClient)
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, logoutCookie, valid)
 
-	ctx = setcid("couldn't find the token we just created - ???")
-	mock.ExpectSMembers("logins:userid").SetVal([]string{})
-	mock.Regexp().ExpectHSet("token:.*", map[string]interface{}{
-		userid: userid,
-		remote: remote,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("token:.*", expireme).SetVal(false)
-	valid, sc = v.Login(ctx, userid, remote)
+	ctx = setcid("add to index fails")
+	s.fail("SAdd", "logins:userid", broken)
+	valid, sc = v.Login(ctx, userid, "name", PasswordLogin, testClient)
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, logoutCookie, valid)
 
-	ctx = setcid("add to index fails")
-	mock.ExpectSMembers("logins:userid").SetVal([]string{})
-	mock.Regexp().ExpectHSet("token:.*", map[string]interface{}{
-		userid: userid,
-		remote: remote,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("token:.*", expireme).SetVal(true)
-	mock.Regexp().ExpectSAdd("logins:userid", "token:.*").SetErr(fmt.Errorf("some error"))
-	valid, sc = v.Login(ctx, userid, remote)
+	ctx = setcid("exec fails, so nothing was written")
+	logins := s.SMembers(ctx, "logins:userid").Val()
+	s.fail("Atomic", "*", broken)
+	valid, sc = v.Login(ctx, userid, "name", PasswordLogin, testClient)
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, logoutCookie, valid)
+	require.Equal(t, logins, s.SMembers(ctx, "logins:userid").Val())
 
 	ctx = setcid("finally! the happy login path")
-	mock.ExpectSMembers("logins:userid").SetVal([]string{})
-	mock.Regexp().ExpectHSet("token:.*", map[string]interface{}{
-		userid: userid,
-		remote: remote,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("token:.*", expireme).SetVal(true)
-	mock.Regexp().ExpectSAdd("logins:userid", "token:.*").SetVal(1)
-	valid, sc = v.Login(ctx, userid, remote)
+	valid, sc = v.Login(ctx, userid, "name", PasswordLogin, testClient)
 	require.Equal(t, http.StatusOK, sc)
 	require.NotNil(t, valid)
 	require.NotEmpty(t, valid.Value)
+	key := "token:" + valid.Value
+	fields := s.HGetAll(ctx, key).Val()
+	require.Equal(t, userid, fields[userid])
+	require.Equal(t, remote, fields[remote])
+	require.Equal(t, "agent", fields[agent])
+	require.Equal(t, PasswordLogin, fields[via])
+	require.NotEmpty(t, fields[created])
+	require.Equal(t, expireme, s.TTL(ctx, key).Val())
+	require.Contains(t, s.SMembers(ctx, "logins:userid").Val(), key)
+
+	require.Empty(t, s.faults)
 }
 
 func Test_Valid(t *testing.T) {
 	t.Parallel()
 
-	db, mock := redismock.NewClientMock()
+	s := newFaultyStore()
 	l := logrus.WithField("test", "Test_Valid")
-	v := NewValidator(db, cfg, l)
+	v := NewValidator(s, cfg, l)
 
-	ctx := setcid("exists fails")
-	mock.ExpectExists("token:token").SetErr(fmt.Errorf("some error"))
-	_, sc := v.Valid(ctx, "token")
+	ctx := setcid("reading the session fails")
+	s.fail("HMGet", "token:token", broken)
+	_, sc := v.Valid(ctx, "token", testClient)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
 	ctx = setcid("doesn't exist")
-	mock.ExpectExists("token:token").SetVal(0)
-	_, sc = v.Valid(ctx, "token")
+	_, sc = v.Valid(ctx, "token", testClient)
 	require.Equal(t, http.StatusTemporaryRedirect, sc)
 
+	seed(s, "token:token", loginFields(time.Now()))
+
 	ctx = setcid("update expiry fails")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetErr(fmt.Errorf("some error"))
-	_, sc = v.Valid(ctx, "token")
+	s.fail("Expire", "token:token", broken)
+	_, sc = v.Valid(ctx, "token", testClient)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
-	ctx = setcid("token doesn't exist (any more? how?)")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetVal(false)
-	_, sc = v.Valid(ctx, "token")
+	ctx = setcid("token expired between reading it and sliding it")
+	s.fail("Expire", "token:token", nil)
+	_, sc = v.Valid(ctx, "token", testClient)
 	require.Equal(t, http.StatusTemporaryRedirect, sc)
 
 	ctx = setcid("happy path for valid")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetVal(true)
-	_, sc = v.Valid(ctx, "token")
+	s.Expire(ctx, "token:token", time.Minute)
+	_, sc = v.Valid(ctx, "token", testClient)
+	require.Equal(t, http.StatusNoContent, sc)
+	require.Equal(t, expireme, s.TTL(ctx, "token:token").Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_ValidMetadata(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_ValidMetadata")
+	aged := *cfg
+	aged.SessionMaxLifetime = time.Hour
+	v := NewValidator(s, &aged, l)
+
+	ago := func(d time.Duration) time.Time {
+		return time.Now().Add(-d).Truncate(time.Second)
+	}
+
+	ctx := setcid("stale last seen gets written")
+	stale := ago(time.Minute)
+	seed(s, "token:token", loginFields(stale))
+	_, sc := v.Valid(ctx, "token", testClient)
+	require.Equal(t, http.StatusNoContent, sc)
+	require.True(t, stamp(s.HGet(ctx, "token:token", seen).Val()).After(stale))
+
+	ctx = setcid("writing last seen fails")
+	s.HDel(ctx, "token:token", seen)
+	s.fail("HSet", "token:token", broken)
+	_, sc = v.Valid(ctx, "token", testClient)
 	require.Equal(t, http.StatusNoContent, sc)
+	require.Equal(t, redis.Nil, s.HGet(ctx, "token:token", seen).Err())
+
+	ctx = setcid("old sessions only slide as far as the max lifetime")
+	seed(s, "token:token", loginFields(ago(time.Hour-5*time.Minute)))
+	cookie, sc := v.Valid(ctx, "token", testClient)
+	require.Equal(t, http.StatusNoContent, sc)
+	require.Equal(t, 300, cookie.MaxAge)
+	require.Equal(t, 5*time.Minute, s.TTL(ctx, "token:token").Val())
+
+	ctx = setcid("ending a session past its lifetime fails")
+	seed(s, "token:token", loginFields(ago(time.Hour)), "logins:userid")
+	s.fail("HDel", "token:token", broken)
+	_, sc = v.Valid(ctx, "token", testClient)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
+	ctx = setcid("sessions past their lifetime are logged out")
+	seed(s, "token:token", loginFields(ago(2*time.Hour)), "logins:userid")
+	_, sc = v.Valid(ctx, "token", testClient)
+	require.Equal(t, http.StatusTemporaryRedirect, sc)
+	require.Equal(t, int64(0), s.Exists(ctx, "token:token").Val())
+	require.Empty(t, s.SMembers(ctx, "logins:userid").Val())
+
+	ctx = setcid("sessions from before created was kept don't age out")
+	seed(s, "token:token", loginFields(ago(0)))
+	s.HDel(ctx, "token:token", created)
+	_, sc = v.Valid(ctx, "token", testClient)
+	require.Equal(t, http.StatusNoContent, sc)
+
+	require.Empty(t, s.faults)
 }
 
 func Test_Logout(t *testing.T) {
 	t.Parallel()
 
-	db, mock := redismock.NewClientMock()
+	s := newFaultyStore()
 	l := logrus.WithField("test", "Test_Logout")
-	v := NewValidator(db, cfg, l)
+	v := NewValidator(s, cfg, l)
 
 	ctx := setcid("valid gets an error")
-	mock.ExpectExists("token:token").SetVal(0)
-	cookie, code := v.Logout(ctx, "token")
+	cookie, code := v.Logout(ctx, "token", testClient)
 	require.Equal(t, http.StatusTemporaryRedirect, code)
 	require.Nil(t, cookie)
 
 	ctx = setcid("get userid fails")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetVal(true)
-	mock.ExpectHGet("token:token", userid).SetErr(fmt.Errorf("some error"))
-	cookie, code = v.Logout(ctx, "token")
+	seed(s, "token:token", loginFields(time.Now()), "logins:userid")
+	s.fail("HGet", "token:token", broken)
+	cookie, code = v.Logout(ctx, "token", testClient)
 	require.Equal(t, http.StatusInternalServerError, code)
 	require.Nil(t, cookie)
 
 	ctx = setcid("get userid nil")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetVal(true)
-	mock.ExpectHGet("token:token", userid).SetErr(redis.Nil)
-	cookie, code = v.Logout(ctx, "token")
+	s.fail("HGet", "token:token", redis.Nil)
+	cookie, code = v.Logout(ctx, "token", testClient)
 	require.Equal(t, http.StatusForbidden, code)
 	require.Nil(t, cookie)
 
 	ctx = setcid("delete hash fails")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetVal(true)
-	mock.ExpectHGet("token:token", userid).SetVal("12345")
-	mock.ExpectHDel("token:token", userid, remote).SetErr(fmt.Errorf("some error"))
-	cookie, code = v.Logout(ctx, "token")
+	s.fail("HDel", "token:token", broken)
+	cookie, code = v.Logout(ctx, "token", testClient)
 	require.Equal(t, http.StatusInternalServerError, code)
 	require.Nil(t, cookie)
 
 	ctx = setcid("remove from index fails")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetVal(true)
-	mock.ExpectHGet("token:token", userid).SetVal("12345")
-	mock.ExpectHDel("token:token", userid, remote).SetVal(1)
-	mock.Regexp().ExpectSRem("logins:.*", "token:.*").SetErr(fmt.Errorf("some error"))
-	cookie, code = v.Logout(ctx, "token")
+	seed(s, "token:token", loginFields(time.Now()), "logins:userid")
+	s.fail("SRem", "logins:*", broken)
+	cookie, code = v.Logout(ctx, "token", testClient)
 	require.Equal(t, http.StatusInternalServerError, code)
 	require.Nil(t, cookie)
 
 	ctx = setcid("happy logout")
-	mock.ExpectExists("token:token").SetVal(1)
-	mock.ExpectExpire("token:token", expireme).SetVal(true)
-	mock.ExpectHGet("token:token", userid).SetVal("12345")
-	mock.ExpectHDel("token:token", userid, remote).SetVal(1)
-	mock.Regexp().ExpectSRem("logins:.*", "token:.*").SetVal(1)
-	cookie, code = v.Logout(ctx, "token")
+	seed(s, "token:token", loginFields(time.Now()), "logins:userid")
+	cookie, code = v.Logout(ctx, "token", testClient)
 	require.Equal(t, http.StatusNoContent, code)
 	require.Equal(t, &http.Cookie{
 		Name:     cfg.CookieName,
@@ -216,279 +260,405 @@ func Test_Logout(t *testing.T) {
 		MaxAge:   -1,
 		HttpOnly: true,
 	}, cookie)
+	require.Equal(t, int64(0), s.Exists(ctx, "token:token").Val())
+	require.Empty(t, s.SMembers(ctx, "logins:userid").Val())
+
+	require.Empty(t, s.faults)
 }
 
 func Test_OTP(t *testing.T) {
 	t.Parallel()
 
-	db, mock := redismock.NewClientMock()
+	s := newFaultyStore()
 	l := logrus.WithField("test", "Test_OTP")
-	v := NewValidator(db, cfg, l)
+	v := NewValidator(s, cfg, l)
 
 	ctx := setcid("count fails, any reason")
-	mock.ExpectSMembers("logins:" + userid).SetErr(fmt.Errorf("some error"))
+	s.fail("SMembers", "logins:userid", broken)
 	pad, sc := v.OTP(ctx, userid, remote, redirect)
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Empty(t, pad)
 
 	ctx = setcid("happy path")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{})
-	mock.Regexp().ExpectHSet("pad:.*", map[string]interface{}{
-		userid:   userid,
-		remote:   remote,
-		redirect: redirect,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("pad:.*", expireme).SetVal(true)
-	mock.Regexp().ExpectSAdd("logins:userid", "pad:.*").SetVal(1)
 	pad, sc = v.OTP(ctx, userid, remote, redirect)
 	require.Equal(t, http.StatusOK, sc, pad)
 	require.NotEmpty(t, pad)
-
-	ctx = setcid("err creating hash")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{})
-	mock.Regexp().ExpectHSet("pad:.*", map[string]interface{}{
+	require.Equal(t, map[string]string{
 		userid:   userid,
 		remote:   remote,
 		redirect: redirect,
-	}).SetErr(fmt.Errorf("some error"))
+	}, s.HGetAll(ctx, "pad:"+pad).Val())
+	require.Equal(t, 15*time.Minute, s.TTL(ctx, "pad:"+pad).Val())
+	require.Contains(t, s.SMembers(ctx, "logins:userid").Val(), "pad:"+pad)
+
+	ctx = setcid("err creating hash")
+	s.fail("HSet", "pad:*", broken)
 	pad, sc = v.OTP(ctx, userid, remote, redirect)
 	require.Equal(t, http.StatusInternalServerError, sc, pad)
 
 	ctx = setcid("err expiring new hash")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{})
-	mock.Regexp().ExpectHSet("pad:.*", map[string]interface{}{
-		userid:   userid,
-		remote:   remote,
-		redirect: redirect,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("pad:.*", expireme).SetErr(fmt.Errorf("some error"))
+	s.fail("Expire", "pad:*", broken)
 	pad, sc = v.OTP(ctx, userid, remote, redirect)
 	require.Equal(t, http.StatusInternalServerError, sc, pad)
 
 	ctx = setcid("error adding pad to logins")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{})
-	mock.Regexp().ExpectHSet("pad:.*", map[string]interface{}{
-		userid:   userid,
-		remote:   remote,
-		redirect: redirect,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("pad:.*", expireme).SetVal(true)
-	mock.Regexp().ExpectSAdd("logins:userid", "pad:.*").SetErr(fmt.Errorf("some error"))
+	s.fail("SAdd", "logins:userid", broken)
 	pad, sc = v.OTP(ctx, userid, remote, redirect)
 	require.Equal(t, http.StatusInternalServerError, sc, pad)
 	require.NotEmpty(t, pad)
 
 	ctx = setcid("adding pad to login returns 0")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{})
-	mock.Regexp().ExpectHSet("pad:.*", map[string]interface{}{
-		userid:   userid,
-		remote:   remote,
-		redirect: redirect,
-	}).SetVal(1)
-	mock.Regexp().ExpectExpire("pad:.*", expireme).SetVal(true)
-	mock.Regexp().ExpectSAdd("logins:userid", "pad:.*").SetVal(0)
+	s.fail("SAdd", "logins:userid", nil)
 	pad, sc = v.OTP(ctx, userid, remote, redirect)
 	require.Equal(t, http.StatusInternalServerError, sc, pad)
 	require.NotEmpty(t, pad)
+
+	require.Empty(t, s.faults)
 }
 
 func Test_LoginOTP(t *testing.T) {
 	t.Parallel()
 
-	db, mock := redismock.NewClientMock()
+	s := newFaultyStore()
 	l := logrus.WithField("test", "Test_LoginOTP")
-	v := NewValidator(db, cfg, l)
+	v := NewValidator(s, cfg, l)
+
+	pad := func(fields ...interface{}) {
+		s.Del(ctx, "pad:1")
+		if len(fields) > 0 {
+			s.HSet(ctx, "pad:1", fields...)
+		}
+	}
 
 	ctx := setcid("fails finding a pad")
-	mock.ExpectHGetAll("pad:1").SetErr(fmt.Errorf("some error"))
+	s.fail("HGetAll", "pad:1", broken)
 	loc, sc := v.LoginOTP(ctx, "1")
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, "/", loc)
 
 	ctx = setcid("missing userid")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{})
 	loc, sc = v.LoginOTP(ctx, "1")
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, "/", loc)
 
 	ctx = setcid("empty userid")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{userid: ""})
+	pad(userid, "")
 	loc, sc = v.LoginOTP(ctx, "1")
 	require.Equal(t, http.StatusBadRequest, sc)
 	require.Equal(t, "/", loc)
 
 	ctx = setcid("redirect is missing")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{userid: userid})
+	pad(userid, userid)
 	loc, sc = v.LoginOTP(ctx, "1")
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, "/", loc)
 
 	ctx = setcid("redirect is empty")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{
-		userid:   userid,
-		redirect: "",
-	})
+	pad(userid, userid, redirect, "")
 	loc, sc = v.LoginOTP(ctx, "1")
 	require.Equal(t, http.StatusBadRequest, sc)
 	require.Equal(t, "/", loc)
 
 	ctx = setcid("all happy")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{
-		userid:   userid,
-		redirect: redirect,
-	})
+	pad(userid, userid, redirect, redirect)
 	loc, sc = v.LoginOTP(ctx, "1")
 	require.Equal(t, http.StatusFound, sc)
 	require.Equal(t, redirect, loc)
+
+	require.Empty(t, s.faults)
+}
+
+func Test_CheckOTP(t *testing.T) {
+	t.Parallel()
+
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_CheckOTP")
+	v := NewValidator(s, cfg, l)
+
+	ctx := setcid("fails finding a pad")
+	s.fail("HGet", "pad:1", broken)
+	id, sc := v.CheckOTP(ctx, "1")
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, shared.UUID(""), id)
+
+	ctx = setcid("no such pad")
+	id, sc = v.CheckOTP(ctx, "1")
+	require.Equal(t, http.StatusForbidden, sc)
+	require.Equal(t, shared.UUID(""), id)
+
+	ctx = setcid("empty userid")
+	s.HSet(ctx, "pad:1", userid, "", redirect, redirect)
+	id, sc = v.CheckOTP(ctx, "1")
+	require.Equal(t, http.StatusForbidden, sc)
+	require.Equal(t, shared.UUID(""), id)
+
+	ctx = setcid("happy path")
+	s.HSet(ctx, "pad:1", userid, userid)
+	id, sc = v.CheckOTP(ctx, "1")
+	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, shared.UUID(userid), id)
+	require.Equal(t, int64(1), s.Exists(ctx, "pad:1").Val(), "the pad is still there")
+
+	ctx = setcid("used up")
+	s.SAdd(ctx, "logins:userid", "pad:1")
+	_, sc = v.CompleteOTP(ctx, "1")
+	require.Equal(t, http.StatusOK, sc)
+	id, sc = v.CheckOTP(ctx, "1")
+	require.Equal(t, http.StatusForbidden, sc)
+	require.Equal(t, shared.UUID(""), id)
+
+	require.Empty(t, s.faults)
 }
 
 func Test_CompleteOTP(t *testing.T) {
 	t.Parallel()
 
-	db, mock := redismock.NewClientMock()
+	s := newFaultyStore()
 	l := logrus.WithField("test", "Test_CompleteOTP")
-	v := NewValidator(db, cfg, l)
+	v := NewValidator(s, cfg, l)
+
+	pad := func(fields ...interface{}) {
+		s.Del(ctx, "pad:1")
+		s.HSet(ctx, "pad:1", fields...)
+	}
 
 	ctx := setcid("fails finding a pad")
-	mock.ExpectHGetAll("pad:1").SetErr(fmt.Errorf("some error"))
+	s.fail("HGetAll", "pad:1", broken)
 	id, sc := v.CompleteOTP(ctx, "1")
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, shared.UUID(""), id)
 
 	ctx = setcid("missing userid")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{})
 	id, sc = v.CompleteOTP(ctx, "1")
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, shared.UUID(""), id)
 
 	ctx = setcid("empty userid")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{userid: ""})
+	pad(userid, "")
 	id, sc = v.CompleteOTP(ctx, "1")
 	require.Equal(t, http.StatusBadRequest, sc)
 	require.Equal(t, shared.UUID(""), id)
 
 	ctx = setcid("clear logins fails (any reason)")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{userid: userid})
-	mock.ExpectSMembers("logins:" + userid).SetErr(fmt.Errorf("some error"))
+	pad(userid, userid, redirect, redirect)
+	s.fail("SMembers", "logins:userid", broken)
 	id, sc = v.CompleteOTP(ctx, "1")
 	require.Equal(t, http.StatusInternalServerError, sc)
 	require.Equal(t, shared.UUID(""), id)
 
 	ctx = setcid("happy path")
-	mock.ExpectHGetAll("pad:1").SetVal(map[string]string{userid: userid})
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{})
+	s.SAdd(ctx, "logins:userid", "pad:1")
 	id, sc = v.CompleteOTP(ctx, "1")
 	require.Equal(t, http.StatusOK, sc)
 	require.Equal(t, shared.UUID(userid), id)
+	require.Equal(t, int64(0), s.Exists(ctx, "pad:1", "logins:userid").Val(), "the pad is used up")
+
+	require.Empty(t, s.faults)
 }
 
 func Test_clearLogins(t *testing.T) {
 	t.Parallel()
 
-	db, mock := redismock.NewClientMock()
-	l := logrus.WithField("test", "Test_CompleteOTP")
-	v := NewValidator(db, cfg, l).(*core)
+	s := newFaultyStore()
+	l := logrus.WithField("test", "Test_clearLogins")
+	v := NewValidator(s, cfg, l).(*core)
 
-	ctx = setcid("fails getting logins for cleartokens")
-	mock.ExpectSMembers("logins:" + userid).SetErr(fmt.Errorf("some error"))
+	pad := map[string]interface{}{userid: userid, redirect: redirect}
+
+	ctx := setcid("fails revoking refresh families")
+	s.fail("SMembers", "families:userid", broken)
 	sc := v.clearLogins(ctx, userid)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
+	ctx = setcid("fails getting logins for cleartokens")
+	s.fail("SMembers", "logins:userid", broken)
+	sc = v.clearLogins(ctx, userid)
+	require.Equal(t, http.StatusInternalServerError, sc)
+
 	ctx = setcid("happy path with no logins (redis.Nil)")
-	mock.ExpectSMembers("logins:" + userid).SetErr(redis.Nil)
+	s.fail("SMembers", "logins:userid", redis.Nil)
 	sc = v.clearLogins(ctx, userid)
 	require.Equal(t, http.StatusGone, sc)
 
 	ctx = setcid("happy path with no logins (empty list)")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{})
 	sc = v.clearLogins(ctx, userid)
 	require.Equal(t, http.StatusGone, sc)
 
 	ctx = setcid("failed to vacuum a login")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{"pad:1"})
-	mock.ExpectHDel("pad:1", userid, remote, redirect).SetErr(fmt.Errorf("some error"))
+	seed(s, "pad:1", pad, "logins:userid")
+	s.fail("HDel", "pad:1", broken)
 	sc = v.clearLogins(ctx, userid)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
 	ctx = setcid("can't remove token from logins")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{"pad:1"})
-	mock.ExpectHDel("pad:1", userid, remote, redirect).SetVal(3)
-	mock.ExpectSRem("logins:"+userid, "pad:1").SetErr(fmt.Errorf("some error"))
+	seed(s, "pad:1", pad, "logins:userid")
+	s.fail("SRem", "logins:userid", broken)
 	sc = v.clearLogins(ctx, userid)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
+	ctx = setcid("exec fails, so every token is still there")
+	seed(s, "pad:1", pad, "logins:userid")
+	seed(s, "token:2", loginFields(time.Now()), "logins:userid")
+	s.fail("Atomic", "*", broken)
+	sc = v.clearLogins(ctx, userid)
+	require.Equal(t, http.StatusInternalServerError, sc)
+	require.Equal(t, []string{"pad:1", "token:2"}, s.SMembers(ctx, "logins:userid").Val())
+	require.Equal(t, int64(2), s.Exists(ctx, "pad:1", "token:2").Val())
+
 	ctx = setcid("happy path")
-	mock.ExpectSMembers("logins:" + userid).SetVal([]string{"pad:1"})
-	mock.ExpectHDel("pad:1", userid, remote, redirect).SetVal(3)
-	mock.ExpectSRem("logins:"+userid, "pad:1").SetVal(1)
 	sc = v.clearLogins(ctx, userid)
 	require.Equal(t, http.StatusGone, sc)
+	require.Equal(t, int64(0), s.Exists(ctx, "pad:1", "token:2", "logins:userid").Val())
+
+	require.Empty(t, s.faults)
 }
 
 func Test_checkCount(t *testing.T) {
 	t.Parallel()
 
-	logins := []string{"1", "2", "3", "4", "5", "6"}
+	logins := []interface{}{"1", "2", "3", "4", "5", "6"}
 
-	db, mock := redismock.NewClientMock()
+	s := newFaultyStore()
 	l := logrus.WithField("test", "Test_checkCount")
-	v := NewValidator(db, cfg, l).(*core)
+	v := NewValidator(s, cfg, l).(*core)
+
+	for _, k := range logins {
+		s.SetNX(ctx, k.(string), 1, expireme)
+	}
 
 	ctx := setcid("count fails")
-	mock.ExpectSMembers("logins:" + userid).SetErr(fmt.Errorf("some error"))
-	sc := v.checkCount(ctx, "logins:"+userid)
+	s.fail("SMembers", "logins:userid", broken)
+	sc := v.checkCount(ctx, userid)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
+	ctx = setcid("happy path (nothing to remove)")
+	s.SAdd(ctx, "logins:userid", logins[:2]...)
+	sc = v.checkCount(ctx, userid)
+	require.Equal(t, http.StatusOK, sc)
+
+	s.SAdd(ctx, "logins:userid", logins[2:]...)
+
 	ctx = setcid("failed exists token")
-	mock.ExpectSMembers("logins:" + userid).SetVal(logins)
-	mock.ExpectExists("1").SetErr(fmt.Errorf("some error"))
-	sc = v.checkCount(ctx, "logins:"+userid)
+	s.fail("Exists", "1", broken)
+	sc = v.checkCount(ctx, userid)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
 	ctx = setcid("too many members")
-	mock.ExpectSMembers("logins:" + userid).SetVal(logins)
-	for _, v := range logins {
-		mock.ExpectExists(v).SetVal(1)
-	}
-	sc = v.checkCount(ctx, "logins:"+userid)
+	sc = v.checkCount(ctx, userid)
 	require.Equal(t, http.StatusTooManyRequests, sc)
 
-	ctx = setcid("happy path (nothing to remove)")
-	mock.ExpectSMembers("logins:" + userid).SetVal(logins[:2])
-	sc = v.checkCount(ctx, "logins:"+userid)
-	require.Equal(t, http.StatusOK, sc)
-
 	ctx = setcid("error removing tokens")
-	mock.ExpectSMembers("logins:" + userid).SetVal(logins)
-	mock.ExpectExists("1").SetVal(0)
-	mock.ExpectExists("2").SetVal(0)
-	for _, v := range logins[2:] {
-		mock.ExpectExists(v).SetVal(1)
-	}
-	mock.ExpectSRem("logins:"+userid, "1", "2").SetErr(fmt.Errorf("some error"))
-	sc = v.checkCount(ctx, "logins:"+userid)
+	s.Del(ctx, "1", "2")
+	s.fail("SRem", "logins:userid", broken)
+	sc = v.checkCount(ctx, userid)
 	require.Equal(t, http.StatusInternalServerError, sc)
 
 	ctx = setcid("did cleanup but still too many members")
-	mock.ExpectSMembers("logins:" + userid).SetVal(logins)
-	mock.ExpectExists("1").SetVal(0)
-	for _, v := range logins[1:] {
-		mock.ExpectExists(v).SetVal(1)
-	}
-	mock.ExpectSRem("logins:"+userid, "1").SetVal(1)
-	sc = v.checkCount(ctx, "logins:"+userid)
+	s.SetNX(ctx, "2", 1, expireme)
+	sc = v.checkCount(ctx, userid)
 	require.Equal(t, http.StatusTooManyRequests, sc)
+	require.Equal(t, []string{"2", "3", "4", "5", "6"}, s.SMembers(ctx, "logins:userid").Val())
 
 	ctx = setcid("happy path (after remove)")
-	mock.ExpectSMembers("logins:" + userid).SetVal(logins)
-	mock.ExpectExists("1").SetVal(0)
-	mock.ExpectExists("2").SetVal(0)
-	for _, v := range logins[2:] {
-		mock.ExpectExists(v).SetVal(1)
-	}
-	mock.ExpectSRem("logins:"+userid, "1", "2").SetVal(2)
-	sc = v.checkCount(ctx, "logins:"+userid)
+	s.Del(ctx, "2")
+	sc = v.checkCount(ctx, userid)
 	require.Equal(t, http.StatusOK, sc)
+	require.Equal(t, []string{"3", "4", "5", "6"}, s.SMembers(ctx, "logins:userid").Val())
+
+	require.Empty(t, s.faults)
+}
+
+func Test_makeRoom(t *testing.T) {
+	t.Parallel()
+
+	live := []string{"pad:1", "token:a", "token:b"}
+
+	// evicted is a session that was ended for why, and kept for the session
+	// list to say so
+	evicted := func(t *testing.T, s Store, key, why string) {
+		fields := s.HGetAll(ctx, key).Val()
+		require.Empty(t, fields[userid])
+		require.Equal(t, why, fields[reason])
+		require.NotEmpty(t, fields[ended])
+		require.Equal(t, evictedTimeout, s.TTL(ctx, key).Val())
+		require.NotContains(t, s.SMembers(ctx, "logins:userid").Val(), key)
+		require.Contains(t, s.SMembers(ctx, "evicted:userid").Val(), key)
+	}
+
+	t.Run("oldest", func(t *testing.T) {
+		t.Parallel()
+
+		s := newFaultyStore()
+		oldest := *cfg
+		oldest.MaxLoginsPolicy = EvictOldest
+		v := NewValidator(s, &oldest, logrus.WithField("test", "Test_makeRoom")).(*core)
+		why := "too many logins, the oldest session was ended"
+
+		reset := func() {
+			s.Del(ctx, "token:a", "token:b", "logins:userid", "evicted:userid")
+			seed(s, "token:a", map[string]interface{}{userid: userid, created: 200}, "logins:userid")
+			seed(s, "token:b", map[string]interface{}{userid: userid, created: 100}, "logins:userid")
+		}
+
+		ctx := setcid("reading a session fails")
+		reset()
+		s.fail("HGet", "token:a", broken)
+		require.Equal(t, http.StatusInternalServerError, v.makeRoom(ctx, userid, live))
+
+		ctx = setcid("nothing but pads")
+		require.Equal(t, http.StatusTooManyRequests, v.makeRoom(ctx, userid, live[:1]))
+
+		ctx = setcid("evicting fails")
+		s.fail("HDel", "token:b", broken)
+		require.Equal(t, http.StatusInternalServerError, v.makeRoom(ctx, userid, live))
+
+		ctx = setcid("exec fails, so the session is still live")
+		reset()
+		s.fail("Atomic", "*", broken)
+		require.Equal(t, http.StatusInternalServerError, v.makeRoom(ctx, userid, live))
+		require.Equal(t, userid, s.HGet(ctx, "token:b", userid).Val())
+		require.Contains(t, s.SMembers(ctx, "logins:userid").Val(), "token:b")
+
+		ctx = setcid("happy path")
+		require.Equal(t, http.StatusOK, v.makeRoom(ctx, userid, live))
+		evicted(t, s, "token:b", why)
+		require.Equal(t, userid, s.HGet(ctx, "token:a", userid).Val())
+
+		ctx = setcid("checkCount evicts when it's full")
+		s.Del(ctx, "token:a", "logins:userid", "evicted:userid")
+		for _, key := range []string{"token:1", "token:2", "token:3", "token:4", "token:5"} {
+			seed(s, key, map[string]interface{}{userid: userid, created: 100}, "logins:userid")
+		}
+		require.Equal(t, http.StatusOK, v.checkCount(ctx, userid))
+		evicted(t, s, "token:1", why)
+
+		require.Empty(t, s.faults)
+	})
+
+	t.Run("lru", func(t *testing.T) {
+		t.Parallel()
+
+		s := newFaultyStore()
+		lru := *cfg
+		lru.MaxLoginsPolicy = EvictLRU
+		v := NewValidator(s, &lru, logrus.WithField("test", "Test_makeRoom")).(*core)
+
+		ctx := setcid("happy path")
+		seed(s, "token:a", map[string]interface{}{userid: userid, seen: 100}, "logins:userid")
+		seed(s, "token:b", map[string]interface{}{userid: userid}, "logins:userid") // never seen sorts first
+		require.Equal(t, http.StatusOK, v.makeRoom(ctx, userid, live))
+		evicted(t, s, "token:b", "too many logins, the least recently used session was ended")
+	})
+
+	t.Run("bad_policy", func(t *testing.T) {
+		t.Parallel()
+
+		bad := *cfg
+		bad.MaxLoginsPolicy = "random"
+		require.Panics(t, func() {
+			_ = NewValidator(nil, &bad, logrus.WithField("test", "Test_makeRoom"))
+		})
+	})
 }
 
 func Test_trackererror(t *testing.T) {
@@ -497,15 +667,37 @@ func Test_trackererror(t *testing.T) {
 	}()
 	t.Parallel()
 
-	db, _ := redismock.NewClientMock()
 	l := logrus.WithField("test", "Test_CheckOTP")
-	v := NewValidator(db, cfg, l)
+	v := NewValidator(NewMemoryStore(), cfg, l)
 
 	tracker := v.(*core).tracker(ctx, "test_trackerror")
 	tracker = tracker.err(NotAuthorized)
 	tracker.err(NotAuthorized)
 }
 
+// loginFields is the hash Login leaves for a session that started, and was
+// last seen, at when
+func loginFields(when time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		userid:  userid,
+		remote:  remote,
+		created: when.Unix(),
+		seen:    when.Unix(),
+		agent:   "agent",
+		via:     PasswordLogin,
+	}
+}
+
+// seed writes a hash with the login ttl, the way the validator would, and
+// adds it to sets
+func seed(s Cmds, key string, fields map[string]interface{}, sets ...string) {
+	s.HSet(ctx, key, fields)
+	s.Expire(ctx, key, expireme)
+	for _, set := range sets {
+		s.SAdd(ctx, set, key)
+	}
+}
+
 func setcid(val string) context.Context {
 	return context.WithValue(ctx, shared.CTXKey("cid"), shared.CID(val))
 }
@@ -10,26 +10,94 @@ type (
 		GetAddress(context.Context, UUID) (*Address, error)
 		AddAddress(context.Context, *Address) (UUID, error)
 		UpdateAddress(context.Context, *Address) error
+		OwnsAddress(context.Context, UUID, UUID) (bool, error)
+	}
+
+	APIKeyer interface {
+		GetAPIKeys(context.Context, UUID) ([]APIKey, error)
+		AddAPIKey(context.Context, UUID, *APIKey) (string, error)
+		CheckAPIKey(context.Context, string) (*APIKey, error)
+		DeleteAPIKey(context.Context, UUID, string) error
 	}
 
 	Auther interface {
 		GetAuthByAttrs(context.Context, *UUID, *string) (*BasicAuth, error)
 		ChangePassword(context.Context, UUID, Password, Password) error
+		CheckPassword(context.Context, UUID, Password) error
+		Active(context.Context, UUID) error
 		Login(context.Context, *BasicAuth) (*BasicAuth, error)
 		ResetPassword(context.Context, *UUID) (Password, error)
+		Unlock(context.Context, UUID) error
+	}
+
+	// Authorizer is the role based access control side of things; Grant and
+	// Assign are idempotent, Revoke and Unassign are not
+	Authorizer interface {
+		GetRoles(context.Context) ([]Role, error)
+		GetRole(context.Context, UUID) (*Role, error)
+		AddRole(context.Context, *Role) (UUID, error)
+		UpdateRole(context.Context, *Role) error
+		DeleteRole(context.Context, UUID) error
+		GrantPermission(context.Context, UUID, string) error
+		RevokePermission(context.Context, UUID, string) error
+		GetUserRoles(context.Context, UUID) ([]Role, error)
+		AssignRole(context.Context, UUID, UUID) error
+		UnassignRole(context.Context, UUID, UUID) error
+		HasPermission(context.Context, UUID, string) (bool, error)
 	}
 
 	BasicAuther interface{}
 
+	Clienter interface {
+		GetClient(context.Context, string) (*OAuthClient, error)
+		AddClient(context.Context, *OAuthClient) (string, error)
+		CheckClient(context.Context, string, string) (*OAuthClient, error)
+		DeleteClient(context.Context, string) error
+	}
+
 	Contacter interface {
 		UpdateContact(context.Context, UUID, *Contact) error
 	}
 
+	// Inviter hands out accounts by invitation; AddInvitation returns the
+	// token for the link and AcceptInvitation takes it back, once
+	Inviter interface {
+		GetInvitations(context.Context) ([]Invitation, error)
+		GetInvitation(context.Context, string) (*Invitation, error)
+		AddInvitation(context.Context, *Invitation) (string, error)
+		RevokeInvitation(context.Context, UUID, UUID) error
+		AcceptInvitation(context.Context, string, *User, Password) (UUID, error)
+	}
+
+	MagicLinker interface {
+		GetMagicLink(context.Context, UUID) (*MagicLink, error)
+		EnableMagicLink(context.Context, UUID, Channel) error
+		DisableMagicLink(context.Context, UUID) error
+	}
+
+	MFAer interface {
+		GetTOTP(context.Context, UUID) (*TOTP, error)
+		EnrollTOTP(context.Context, UUID, string) error
+		ConfirmTOTP(context.Context, UUID) error
+		UseTOTP(context.Context, UUID, int64) error
+		DeleteTOTP(context.Context, UUID) error
+		GetMFAChannel(context.Context, UUID) (*MFAChannel, error)
+		EnrollMFAChannel(context.Context, UUID, Channel) error
+		ConfirmMFAChannel(context.Context, UUID) error
+		DeleteMFAChannel(context.Context, UUID) error
+		ReplaceRecoveryCodes(context.Context, UUID, []string) error
+		UseRecoveryCode(context.Context, UUID, string) error
+		CountRecoveryCodes(context.Context, UUID) (int, error)
+	}
+
 	Userer interface {
 		GetAllUsers(context.Context) ([]User, error)
 		GetUser(context.Context, UUID) (*User, error)
 		AddUser(context.Context, *User) (UUID, error)
+		SignUp(context.Context, *User, Password) (UUID, error)
 		UpdateUser(context.Context, *User) error
+		VerifyEmail(context.Context, UUID, Email) error
+		VerifyCell(context.Context, UUID, Cell) error
 		DeleteUser(context.Context, UUID) error
 		CreateContact(context.Context, *User, Contact) (*Contact, error)
 	}
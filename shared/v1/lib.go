@@ -3,8 +3,12 @@ package shared
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/go-gomail/gomail"
+	"github.com/golang-jwt/jwt"
 	"github.com/sirupsen/logrus"
 	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 )
@@ -88,11 +92,285 @@ func (u *User) PasswordResetSMS(host, token string) *twilioApi.CreateMessagePara
 		))
 }
 
+// Verified is a copy of u without any address it hasn't proven it owns
+func (u *User) Verified() *User {
+	result := *u
+	if result.EmailVerified == nil {
+		result.Email = nil
+	}
+	if result.CellVerified == nil {
+		result.Cell = nil
+	}
+	return &result
+}
+
+func (u *User) VerificationEmail(host, token string) *gomail.Message {
+	if u.Email == nil {
+		return nil
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("To", string(*u.Email))
+	m.SetHeader("Subject", "Verify your email address")
+	m.SetBody("text/html", fmt.Sprintf(
+		`<a href="https://%s/user/%s/email/verify?token=%s">Verify this address</a>`,
+		host,
+		u.UUID,
+		token,
+	))
+
+	return m
+}
+
+// EmailChangedEmail goes to the address u used to have, so the owner hears
+// about it if it wasn't them
+func (u *User) EmailChangedEmail(old Email) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("To", string(old))
+	m.SetHeader("Subject", "Your email address was changed")
+	m.SetBody("text/plain", fmt.Sprintf(
+		"The email address for %s was changed and this one will no longer be used. If you didn't do this, reset your password now.",
+		u.Name,
+	))
+
+	return m
+}
+
+// Reachable says whether the user has somewhere to receive codes on ch
+func (u *User) Reachable(ch Channel) bool {
+	switch ch {
+	case EmailChannel:
+		return u.Email != nil && len(*u.Email) != 0
+	case SMSChannel:
+		return u.Cell != nil && len(*u.Cell) != 0
+	}
+	return false
+}
+
+func (u *User) MFACodeEmail(code string) *gomail.Message {
+	if u.Email == nil {
+		return nil
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("To", string(*u.Email))
+	m.SetHeader("Subject", "Your verification code")
+	m.SetBody("text/plain", fmt.Sprintf("Your verification code is %s", code))
+
+	return m
+}
+
+func (u *User) MFACodeSMS(code string) *twilioApi.CreateMessageParams {
+	if u.Cell == nil {
+		return nil
+	}
+
+	return (&twilioApi.CreateMessageParams{}).
+		SetTo(string(*u.Cell)).
+		SetBody(fmt.Sprintf("Your verification code is %s", code))
+}
+
+func (u *User) MagicLinkEmail(host, token string) *gomail.Message {
+	if u.Email == nil {
+		return nil
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("To", string(*u.Email))
+	m.SetHeader("Subject", "Your login link")
+	m.SetBody("text/html", fmt.Sprintf(`<a href="https://%s/auth/magic/%s">Log in</a>`, host, token))
+
+	return m
+}
+
+func (u *User) MagicLinkSMS(host, token string) *twilioApi.CreateMessageParams {
+	if u.Cell == nil {
+		return nil
+	}
+
+	return (&twilioApi.CreateMessageParams{}).
+		SetTo(string(*u.Cell)).
+		SetBody(fmt.Sprintf("Log in: https://%s/auth/magic/%s", host, token))
+}
+
+// Open says whether i can still be accepted
+func (i *Invitation) Open(now time.Time) bool {
+	return i.Accepted == nil && i.Revoked == nil && now.Before(i.Expires)
+}
+
+func (i *Invitation) InvitationEmail(host, token string) *gomail.Message {
+	if i.Email == nil {
+		return nil
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("To", string(*i.Email))
+	m.SetHeader("Subject", "You're invited")
+	m.SetBody("text/html", fmt.Sprintf(`<a href="https://%s/invite/%s">Create your account</a>`, host, token))
+
+	return m
+}
+
+func (i *Invitation) InvitationSMS(host, token string) *twilioApi.CreateMessageParams {
+	if i.Cell == nil {
+		return nil
+	}
+
+	return (&twilioApi.CreateMessageParams{}).
+		SetTo(string(*i.Cell)).
+		SetBody(fmt.Sprintf("Create your account: https://%s/invite/%s", host, token))
+}
+
+// AllowsRedirect only takes an exact match; no prefixes, no wildcards
+func (c *OAuthClient) AllowsRedirect(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if uri == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope is true for an empty scope, too; a key without scopes can still
+// prove who it belongs to, it just can't be used for anything narrower
+func (k *APIKey) HasScope(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTokenVerifier takes the PEM encoded public half of the service's session
+// key (RSA or Ed25519) and the issuer it was configured with
+func NewTokenVerifier(key []byte, issuer string) (*TokenVerifier, error) {
+	if pub, err := jwt.ParseRSAPublicKeyFromPEM(key); err == nil {
+		return &TokenVerifier{issuer: issuer, method: jwt.SigningMethodRS256, key: pub}, nil
+	} else if pub, err := jwt.ParseEdPublicKeyFromPEM(key); err == nil {
+		return &TokenVerifier{issuer: issuer, method: jwt.SigningMethodEdDSA, key: pub}, nil
+	}
+	return nil, fmt.Errorf("not an RSA or Ed25519 public key")
+}
+
+// Verify is the offline half of /valid: a token that passes was signed by
+// the service and hasn't expired, but it may still have been logged out
+// since; tokens are short-lived so that window stays small
+func (tv *TokenVerifier) Verify(token string) (*SessionClaims, error) {
+	claims := &SessionClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != tv.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+		}
+		return tv.key, nil
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %v", BadSessionTokenError, err)
+	} else if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: no expiry", BadSessionTokenError)
+	} else if !claims.VerifyIssuer(tv.issuer, true) {
+		return nil, fmt.Errorf("%w: wrong issuer", BadSessionTokenError)
+	} else if claims.Subject == "" || claims.SessionID == "" {
+		return nil, fmt.Errorf("%w: missing subject or session", BadSessionTokenError)
+	}
+	return claims, nil
+}
+
+func (c Channel) Valid() bool {
+	return c == EmailChannel || c == SMSChannel
+}
+
 func (p Password) Valid() bool {
-	if len(p) < 8 {
-		return false
+	return len(DefaultPasswordPolicy.Check(p)) == 0
+}
+
+// Check returns every rule the password breaks, or nothing; personal is
+// anything identifying the user (username, email local-part, etc) that
+// shouldn't appear in their password
+func (pp *PasswordPolicy) Check(p Password, personal ...string) PolicyViolations {
+	var result PolicyViolations
+	violate := func(rule, format string, args ...any) {
+		result = append(result, PolicyViolation{Rule: rule, Detail: fmt.Sprintf(format, args...)})
 	}
-	return true
+
+	runes := []rune(string(p))
+	if len(runes) < pp.MinLength {
+		violate(MinLengthRule, "must be at least %d characters", pp.MinLength)
+	}
+	if pp.MaxLength > 0 && len(runes) > pp.MaxLength {
+		violate(MaxLengthRule, "must be at most %d characters", pp.MaxLength)
+	} else if pp.MaxBytes > 0 && len(p) > pp.MaxBytes {
+		violate(MaxLengthRule, "must be at most %d bytes", pp.MaxBytes)
+	}
+
+	var lower, upper, digit, symbol bool
+	var run, longest int
+	for i, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+
+		if i > 0 && r == runes[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	if pp.RequireLower && !lower {
+		violate(LowerRule, "must contain a lowercase letter")
+	}
+	if pp.RequireUpper && !upper {
+		violate(UpperRule, "must contain an uppercase letter")
+	}
+	if pp.RequireDigit && !digit {
+		violate(DigitRule, "must contain a digit")
+	}
+	if pp.RequireSymbol && !symbol {
+		violate(SymbolRule, "must contain a symbol")
+	}
+	if pp.MaxRepeat > 0 && longest > pp.MaxRepeat {
+		violate(RepeatRule, "must not repeat a character more than %d times in a row", pp.MaxRepeat)
+	}
+
+	folded := strings.ToLower(string(p))
+	for _, b := range pp.Banned {
+		if b != "" && strings.Contains(folded, strings.ToLower(b)) {
+			violate(BannedRule, "must not contain %q", b)
+		}
+	}
+	for _, s := range personal {
+		// really short names would ban half the alphabet
+		if len([]rune(s)) >= 3 && strings.Contains(folded, strings.ToLower(s)) {
+			violate(PersonalRule, "must not contain your username or email")
+			break
+		}
+	}
+	if _, ok := pp.Breached[string(p)]; ok {
+		violate(BreachedRule, "appears in a list of breached passwords")
+	}
+
+	return result
+}
+
+func (pv PolicyViolations) Error() string {
+	rules := make([]string, 0, len(pv))
+	for _, v := range pv {
+		rules = append(rules, v.Rule)
+	}
+	return fmt.Sprintf("password policy violations: %s", strings.Join(rules, ", "))
 }
 
 func (e *Email) Valid() bool {
@@ -104,6 +382,15 @@ func (e *Email) Valid() bool {
 	return true
 }
 
+// LocalPart is everything before the @, or nothing
+func (e *Email) LocalPart() string {
+	if e == nil {
+		return ""
+	}
+	local, _, _ := strings.Cut(string(*e), "@")
+	return local
+}
+
 func (c *Cell) Valid() bool {
 	if c == nil {
 		return false
@@ -119,3 +406,35 @@ func (a BasicAuth) Redact() BasicAuth {
 
 	return a
 }
+
+// Locked is true while an account that was locked at `since` should still
+// refuse logins
+func (lp *LockoutPolicy) Locked(since *time.Time, now time.Time) bool {
+	return since != nil && (lp.Duration == 0 || now.Before(since.Add(lp.Duration)))
+}
+
+// Lock is true once `failures` consecutive bad passwords should lock the account
+func (lp *LockoutPolicy) Lock(failures uint8) bool {
+	return lp.Threshold != 0 && failures >= lp.Threshold
+}
+
+// Wait is how much longer a client has to hold off before trying again after
+// `failures` consecutive failures, the last of which happened at `last`
+func (lp *LockoutPolicy) Wait(failures uint8, last *time.Time, now time.Time) time.Duration {
+	if lp.Backoff == 0 || failures == 0 || last == nil {
+		return 0
+	}
+
+	delay := lp.Backoff
+	for i := uint8(1); i < failures && i < 32; i++ { // 32 doublings is plenty
+		if delay *= 2; lp.MaxBackoff != 0 && delay >= lp.MaxBackoff {
+			delay = lp.MaxBackoff
+			break
+		}
+	}
+
+	if wait := last.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -1,11 +1,18 @@
 package shared
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/require"
 )
@@ -145,6 +152,262 @@ func Test_PasswordResetSMS(t *testing.T) {
 	require.NotNil(t, (&User{Cell: &sms}).PasswordResetSMS("host", "token"))
 }
 
+func Test_Verified(t *testing.T) {
+	t.Parallel()
+
+	email, cell := Email("email"), Cell("cell")
+
+	u := &User{Email: &email, Cell: &cell, CellVerified: &now}
+	require.Equal(t, &User{Cell: &cell, CellVerified: &now}, u.Verified())
+	require.Equal(t, &email, u.Email) // leaves the original alone
+
+	u = &User{Email: &email, EmailVerified: &now, Cell: &cell}
+	require.Equal(t, &User{Email: &email, EmailVerified: &now}, u.Verified())
+
+	u.CellVerified = &now
+	require.Equal(t, u, u.Verified())
+}
+
+func Test_VerificationEmail(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, (&User{}).VerificationEmail("host", "token"))
+	email := Email("email")
+	require.NotNil(t, (&User{Email: &email}).VerificationEmail("host", "token"))
+}
+
+func Test_EmailChangedEmail(t *testing.T) {
+	t.Parallel()
+
+	m := (&User{Name: "name"}).EmailChangedEmail("old")
+	require.Equal(t, []string{"old"}, m.GetHeader("To"))
+}
+
+func Test_Reachable(t *testing.T) {
+	t.Parallel()
+
+	e := Email("point to me")
+	c := Cell("point to me")
+
+	tcs := map[string]struct {
+		user   *User
+		ch     Channel
+		result bool
+	}{
+		"email":           {user: &User{Email: &e}, ch: EmailChannel, result: true},
+		"sms":             {user: &User{Cell: &c}, ch: SMSChannel, result: true},
+		"missing_email":   {user: &User{Cell: &c}, ch: EmailChannel},
+		"missing_cell":    {user: &User{Email: &e}, ch: SMSChannel},
+		"unknown_channel": {user: &User{Email: &e, Cell: &c}, ch: "pigeon"},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.result, tc.user.Reachable(tc.ch))
+		})
+	}
+}
+
+func Test_MFACodeEmail(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, (&User{}).MFACodeEmail("123456"))
+	email := Email("email")
+	require.NotNil(t, (&User{Email: &email}).MFACodeEmail("123456"))
+}
+
+func Test_MFACodeSMS(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, (&User{}).MFACodeSMS("123456"))
+	sms := Cell("cell")
+	require.NotNil(t, (&User{Cell: &sms}).MFACodeSMS("123456"))
+}
+
+func Test_MagicLinkEmail(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, (&User{}).MagicLinkEmail("host", "token"))
+	email := Email("email")
+	require.NotNil(t, (&User{Email: &email}).MagicLinkEmail("host", "token"))
+}
+
+func Test_MagicLinkSMS(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, (&User{}).MagicLinkSMS("host", "token"))
+	sms := Cell("cell")
+	require.NotNil(t, (&User{Cell: &sms}).MagicLinkSMS("host", "token"))
+}
+
+func Test_InvitationOpen(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	require.True(t, (&Invitation{Expires: now.Add(time.Hour)}).Open(now))
+	require.False(t, (&Invitation{Expires: now}).Open(now))
+	require.False(t, (&Invitation{Expires: now.Add(time.Hour), Accepted: &now}).Open(now))
+	require.False(t, (&Invitation{Expires: now.Add(time.Hour), Revoked: &now}).Open(now))
+}
+
+func Test_InvitationEmail(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, (&Invitation{}).InvitationEmail("host", "token"))
+	email := Email("email")
+	require.NotNil(t, (&Invitation{Email: &email}).InvitationEmail("host", "token"))
+}
+
+func Test_InvitationSMS(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, (&Invitation{}).InvitationSMS("host", "token"))
+	sms := Cell("cell")
+	require.NotNil(t, (&Invitation{Cell: &sms}).InvitationSMS("host", "token"))
+}
+
+func Test_AllowsRedirect(t *testing.T) {
+	t.Parallel()
+
+	c := &OAuthClient{RedirectURIs: []string{"https://rp.example.com/cb", "http://localhost:8080/cb"}}
+
+	require.True(t, c.AllowsRedirect("https://rp.example.com/cb"))
+	require.True(t, c.AllowsRedirect("http://localhost:8080/cb"))
+	require.False(t, c.AllowsRedirect("https://rp.example.com/cb/"))
+	require.False(t, c.AllowsRedirect("https://rp.example.com/cb?next=/"))
+	require.False(t, c.AllowsRedirect(""))
+	require.False(t, (&OAuthClient{}).AllowsRedirect("https://rp.example.com/cb"))
+}
+
+func Test_HasScope(t *testing.T) {
+	t.Parallel()
+
+	k := &APIKey{Scopes: []string{"read", "write"}}
+
+	require.True(t, k.HasScope("read"))
+	require.True(t, k.HasScope("write"))
+	require.True(t, k.HasScope(""))
+	require.False(t, k.HasScope("admin"))
+	require.False(t, k.HasScope("rea"))
+	require.False(t, (&APIKey{}).HasScope("read"))
+}
+
+func Test_TokenVerifier(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	publicPEM := func(pub any) []byte {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		require.Nil(t, err)
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+	sign := func(m jwt.SigningMethod, key any, c *SessionClaims) string {
+		token, err := jwt.NewWithClaims(m, c).SignedString(key)
+		require.Nil(t, err)
+		return token
+	}
+	claims := func(iss, sub, sid string, exp time.Duration) *SessionClaims {
+		c := &SessionClaims{
+			StandardClaims: jwt.StandardClaims{Issuer: iss, Subject: sub},
+			Name:           "name",
+			SessionID:      sid,
+		}
+		if exp != 0 {
+			c.ExpiresAt = time.Now().Add(exp).Unix()
+		}
+		return c
+	}
+	good := claims("userservice", "uuid", "sid", time.Minute)
+
+	tcs := map[string]struct {
+		pub   []byte
+		token string
+		err   bool
+	}{
+		"rsa": {
+			pub:   publicPEM(&rsaKey.PublicKey),
+			token: sign(jwt.SigningMethodRS256, rsaKey, good),
+		},
+		"ed25519": {
+			pub:   publicPEM(edPub),
+			token: sign(jwt.SigningMethodEdDSA, edKey, good),
+		},
+		"expired": {
+			pub:   publicPEM(edPub),
+			token: sign(jwt.SigningMethodEdDSA, edKey, claims("userservice", "uuid", "sid", -time.Minute)),
+			err:   true,
+		},
+		"no_expiry": {
+			pub:   publicPEM(edPub),
+			token: sign(jwt.SigningMethodEdDSA, edKey, claims("userservice", "uuid", "sid", 0)),
+			err:   true,
+		},
+		"wrong_issuer": {
+			pub:   publicPEM(edPub),
+			token: sign(jwt.SigningMethodEdDSA, edKey, claims("someone", "uuid", "sid", time.Minute)),
+			err:   true,
+		},
+		"no_session": {
+			pub:   publicPEM(edPub),
+			token: sign(jwt.SigningMethodEdDSA, edKey, claims("userservice", "uuid", "", time.Minute)),
+			err:   true,
+		},
+		"wrong_key": {
+			pub:   publicPEM(&rsaKey.PublicKey),
+			token: sign(jwt.SigningMethodEdDSA, edKey, good),
+			err:   true,
+		},
+		"hmac_with_the_public_key": {
+			pub:   publicPEM(&rsaKey.PublicKey),
+			token: sign(jwt.SigningMethodHS256, publicPEM(&rsaKey.PublicKey), good),
+			err:   true,
+		},
+		"garbage": {
+			pub:   publicPEM(edPub),
+			token: "garbage",
+			err:   true,
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tv, err := NewTokenVerifier(tc.pub, "userservice")
+			require.Nil(t, err)
+
+			c, err := tv.Verify(tc.token)
+			if tc.err {
+				require.ErrorIs(t, err, BadSessionTokenError)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, "uuid", c.Subject)
+			require.Equal(t, "name", c.Name)
+			require.Equal(t, "sid", c.SessionID)
+		})
+	}
+
+	_, err := NewTokenVerifier([]byte("not a key"), "userservice")
+	require.NotNil(t, err)
+}
+
+func Test_ChannelValid(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, EmailChannel.Valid())
+	require.True(t, SMSChannel.Valid())
+	require.False(t, Channel("").Valid())
+	require.False(t, Channel("pigeon").Valid())
+}
+
 func Test_PasswordValid(t *testing.T) {
 	t.Parallel()
 
@@ -152,6 +415,124 @@ func Test_PasswordValid(t *testing.T) {
 	require.True(t, Password("01234567").Valid())
 }
 
+func Test_PasswordPolicyCheck(t *testing.T) {
+	t.Parallel()
+
+	strict := &PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     16,
+		RequireLower:  true,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		MaxRepeat:     2,
+		Banned:        []string{"Password", ""},
+		Breached:      map[string]struct{}{"Tr0ub4dor&3": {}},
+	}
+
+	tcs := map[string]struct {
+		policy   *PasswordPolicy
+		pass     Password
+		personal []string
+		rules    []string
+	}{
+		"default_ok": {
+			policy: &DefaultPasswordPolicy,
+			pass:   "01234567",
+		},
+		"default_short": {
+			policy: &DefaultPasswordPolicy,
+			pass:   "0123456",
+			rules:  []string{MinLengthRule},
+		},
+		"strict_ok": {
+			policy: strict,
+			pass:   "c0rrect-H0rse",
+		},
+		"strict_everything": {
+			policy: strict,
+			pass:   "aaa",
+			rules:  []string{MinLengthRule, UpperRule, DigitRule, SymbolRule, RepeatRule},
+		},
+		"too_long": {
+			policy: strict,
+			pass:   "c0rrect-H0rse-battery",
+			rules:  []string{MaxLengthRule},
+		},
+		"too_many_bytes": {
+			// 30 characters, but 90 bytes
+			policy: &PasswordPolicy{MinLength: 8, MaxLength: 72, MaxBytes: 72},
+			pass:   Password(strings.Repeat("パスワ", 10)),
+			rules:  []string{MaxLengthRule},
+		},
+		"lower": {
+			policy: strict,
+			pass:   "C0RRECT-H0RSE",
+			rules:  []string{LowerRule},
+		},
+		"banned_any_case": {
+			policy: strict,
+			pass:   "my-PASSWORD-1",
+			rules:  []string{BannedRule},
+		},
+		"personal": {
+			policy:   strict,
+			pass:     "Hi-Jimbo-123",
+			personal: []string{"", "jimbo"},
+			rules:    []string{PersonalRule},
+		},
+		"personal_too_short": {
+			policy:   strict,
+			pass:     "c0rrect-H0rse",
+			personal: []string{"c0"},
+		},
+		"breached": {
+			policy: strict,
+			pass:   "Tr0ub4dor&3",
+			rules:  []string{BreachedRule},
+		},
+		"unicode_length": {
+			policy: &DefaultPasswordPolicy,
+			pass:   "ñññññññ", // 14 bytes, 7 runes
+			rules:  []string{MinLengthRule},
+		},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var rules []string
+			for _, v := range tc.policy.Check(tc.pass, tc.personal...) {
+				require.NotEmpty(t, v.Detail)
+				rules = append(rules, v.Rule)
+			}
+			require.Equal(t, tc.rules, rules)
+		})
+	}
+}
+
+func Test_PolicyViolationsError(t *testing.T) {
+	t.Parallel()
+
+	var err error = PolicyViolations{{Rule: MinLengthRule}, {Rule: DigitRule}}
+	require.Equal(t, "password policy violations: min_length, digit", err.Error())
+}
+
+func Test_EmailLocalPart(t *testing.T) {
+	t.Parallel()
+
+	var email *Email
+	require.Empty(t, email.LocalPart())
+	temp := Email("jimbo@example.com")
+	email = &temp
+	require.Equal(t, "jimbo", email.LocalPart())
+	*email = "no-at-sign"
+	require.Equal(t, "no-at-sign", email.LocalPart())
+}
+
 func Test_EmailValid(t *testing.T) {
 	t.Parallel()
 
@@ -186,3 +567,59 @@ func Test_Redact(t *testing.T) {
 	require.Empty(t, b.Pass)
 	require.Empty(t, b.Salt)
 }
+
+func Test_LockoutPolicyLocked(t *testing.T) {
+	t.Parallel()
+
+	since := now.Add(-time.Minute)
+
+	require.False(t, (&LockoutPolicy{}).Locked(nil, now))
+	require.True(t, (&LockoutPolicy{}).Locked(&since, now), "zero duration locks forever")
+	require.True(t, (&LockoutPolicy{Duration: time.Hour}).Locked(&since, now))
+	require.False(t, (&LockoutPolicy{Duration: time.Second}).Locked(&since, now))
+}
+
+func Test_LockoutPolicyLock(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, (&LockoutPolicy{}).Lock(200), "zero threshold never locks")
+	require.False(t, (&LockoutPolicy{Threshold: 3}).Lock(2))
+	require.True(t, (&LockoutPolicy{Threshold: 3}).Lock(3))
+}
+
+func Test_LockoutPolicyWait(t *testing.T) {
+	t.Parallel()
+
+	last := now.Add(-time.Second)
+
+	tcs := map[string]struct {
+		lp       LockoutPolicy
+		failures uint8
+		last     *time.Time
+		wait     time.Duration
+	}{
+		"disabled":    {lp: LockoutPolicy{}, failures: 3, last: &last},
+		"no_failures": {lp: LockoutPolicy{Backoff: time.Minute}, last: &last},
+		"no_last":     {lp: LockoutPolicy{Backoff: time.Minute}, failures: 3},
+		"first":       {lp: LockoutPolicy{Backoff: 2 * time.Second}, failures: 1, last: &last, wait: time.Second},
+		"doubles":     {lp: LockoutPolicy{Backoff: 2 * time.Second}, failures: 3, last: &last, wait: 7 * time.Second},
+		"capped": {
+			lp:       LockoutPolicy{Backoff: 2 * time.Second, MaxBackoff: 5 * time.Second},
+			failures: 3,
+			last:     &last,
+			wait:     4 * time.Second,
+		},
+		"no_overflow": {lp: LockoutPolicy{Backoff: time.Second}, failures: 255, last: &last, wait: time.Duration(1<<31)*time.Second - time.Second},
+		"elapsed":     {lp: LockoutPolicy{Backoff: time.Millisecond}, failures: 1, last: &last},
+	}
+
+	for name, tc := range tcs {
+		name, tc := name, tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wait, tc.lp.Wait(tc.failures, tc.last, now))
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package shared
 
 import (
+	"crypto"
 	"time"
+
+	"github.com/golang-jwt/jwt"
 )
 
 type (
 	Cell        string
+	Channel     string // where one-time codes get delivered
 	CID         string
 	CTXKey      string
 	CustomError error
@@ -25,6 +29,31 @@ type (
 		CTime   time.Time `json:"ctime"`
 	}
 
+	// APIKey is a long-lived credential a user hands to a script or service;
+	// Secret is the whole bearer token and only comes back from AddAPIKey,
+	// after that there's just a hash of it
+	APIKey struct {
+		ID       string     `json:"id"`
+		UserID   UUID       `json:"user_id" mysql:"user_uuid"`
+		Name     string     `json:"name"`
+		Secret   string     `json:"token,omitempty"`
+		Scopes   []string   `json:"scopes,omitempty"`
+		Expires  *time.Time `json:"expires,omitempty"`
+		LastUsed *time.Time `json:"last_used,omitempty" mysql:"lastused"`
+		CTime    time.Time  `json:"ctime"`
+	}
+
+	// AuthCode is what an authorization code stands for until a client trades
+	// it in at the token endpoint
+	AuthCode struct {
+		ClientID    string `json:"client_id"`
+		UserID      UUID   `json:"user_id"`
+		RedirectURI string `json:"redirect_uri"`
+		Scope       string `json:"scope"`
+		Nonce       string `json:"nonce,omitempty"`
+		Challenge   string `json:"code_challenge"` // PKCE, always S256
+	}
+
 	BasicAuth struct {
 		UUID         UUID       `json:"id" mysql:"uuid"`
 		Name         string     `json:"username" mysql:"name"`
@@ -35,6 +64,8 @@ type (
 		FailureCount uint8      `json:"failure_count,omitempty" mysql:"failurecount"`
 		MTime        time.Time  `json:"mtime"`
 		CTime        time.Time  `json:"ctime"`
+		Email        *Email     `json:"-" mysql:"email"` // only for password policy checks
+		Locked       *time.Time `json:"locked,omitempty" mysql:"locked"`
 	}
 
 	Contact struct {
@@ -47,14 +78,160 @@ type (
 		CTime     time.Time `json:"ctime"`
 	}
 
+	// OAuthClient is a relying party allowed to send users through the
+	// authorization code flow; Secret is only filled in right after it's
+	// created, and a Public client doesn't get one at all
+	OAuthClient struct {
+		ID           string    `json:"client_id" mysql:"client_id"`
+		Name         string    `json:"name"`
+		Secret       string    `json:"client_secret,omitempty"`
+		RedirectURIs []string  `json:"redirect_uris"`
+		Public       bool      `json:"public,omitempty"`
+		MTime        time.Time `json:"mtime"`
+		CTime        time.Time `json:"ctime"`
+	}
+
+	// PasswordPolicy is the declarative set of rules a new password has to
+	// satisfy; zero values disable a rule
+	PasswordPolicy struct {
+		MinLength     int                 `json:"min_length"`
+		MaxLength     int                 `json:"max_length,omitempty"`
+		MaxBytes      int                 `json:"max_bytes,omitempty"` // what the hash can take, for scripts that need more than a byte a character
+		RequireLower  bool                `json:"require_lower,omitempty"`
+		RequireUpper  bool                `json:"require_upper,omitempty"`
+		RequireDigit  bool                `json:"require_digit,omitempty"`
+		RequireSymbol bool                `json:"require_symbol,omitempty"`
+		MaxRepeat     int                 `json:"max_repeat,omitempty"` // identical characters in a row
+		History       int                 `json:"history,omitempty"`    // how many previous passwords can't be reused
+		Banned        []string            `json:"-"`
+		Breached      map[string]struct{} `json:"-"`
+	}
+
+	// TOTP is an authenticator app enrollment; it doesn't count as a second
+	// factor until the user proves they can generate codes with it
+	TOTP struct {
+		Secret    string     `json:"secret,omitempty"`
+		URI       string     `json:"uri,omitempty"`
+		Confirmed *time.Time `json:"confirmed,omitempty"`
+		LastStep  int64      `json:"-"` // the last period a code was accepted for
+		MTime     time.Time  `json:"mtime"`
+		CTime     time.Time  `json:"ctime"`
+	}
+
+	// MFAChannel is the user's preferred way to receive one-time codes; like
+	// TOTP, it isn't a second factor until a code sent to it comes back
+	MFAChannel struct {
+		Channel   Channel    `json:"channel"`
+		Confirmed *time.Time `json:"confirmed,omitempty"`
+		MTime     time.Time  `json:"mtime"`
+		CTime     time.Time  `json:"ctime"`
+	}
+
+	// MagicLink is a user's opt in to logging in with a link sent to them
+	// instead of a password; the address comes from users.email or users.cell
+	// at send time, same as MFAChannel
+	MagicLink struct {
+		Channel Channel   `json:"channel"`
+		MTime   time.Time `json:"mtime"`
+		CTime   time.Time `json:"ctime"`
+	}
+
+	// RecoveryCodes only carries Codes right after they're generated; they're
+	// hashed at rest and can't be shown again
+	RecoveryCodes struct {
+		Codes     []string `json:"codes,omitempty"`
+		Remaining int      `json:"remaining"`
+	}
+
+	// Invitation is an admin's offer of an account to whoever answers at
+	// Email or Cell, only one of them; the link that goes there is the only
+	// copy of the token. Invitations are never deleted, accepting or revoking
+	// one just closes it, so the list doubles as an audit trail
+	Invitation struct {
+		UUID       UUID       `json:"id" mysql:"uuid"`
+		Email      *Email     `json:"email,omitempty"`
+		Cell       *Cell      `json:"cell,omitempty"`
+		CreatedBy  UUID       `json:"created_by,omitempty" mysql:"created_by"`
+		Expires    time.Time  `json:"expires"`
+		Accepted   *time.Time `json:"accepted,omitempty"`
+		AcceptedBy *UUID      `json:"accepted_by,omitempty" mysql:"accepted_by"` // the user it turned into
+		Revoked    *time.Time `json:"revoked,omitempty"`
+		RevokedBy  *UUID      `json:"revoked_by,omitempty" mysql:"revoked_by"`
+		CTime      time.Time  `json:"ctime"`
+	}
+
+	// LockoutPolicy slows down and eventually locks out repeated login
+	// failures; a zero Threshold never locks and a zero Duration locks until
+	// an admin unlocks the account or the password is reset
+	LockoutPolicy struct {
+		Threshold  uint8         `json:"threshold"`
+		Duration   time.Duration `json:"duration,omitempty"`
+		Backoff    time.Duration `json:"backoff,omitempty"` // doubles with each consecutive failure
+		MaxBackoff time.Duration `json:"max_backoff,omitempty"`
+	}
+
+	PolicyViolation struct {
+		Rule   string `json:"rule"`
+		Detail string `json:"detail"`
+	}
+
+	// PolicyViolations is an error so it can travel up from the data layer
+	// unchanged and be rendered for the client as-is
+	PolicyViolations []PolicyViolation
+
+	// Role is a named set of permissions; users get permissions by being
+	// assigned roles, never directly
+	Role struct {
+		UUID        UUID      `json:"id" mysql:"uuid"`
+		Name        string    `json:"name"`
+		Description string    `json:"description,omitempty"`
+		Permissions []string  `json:"permissions"`
+		MTime       time.Time `json:"mtime"`
+		CTime       time.Time `json:"ctime"`
+	}
+
+	// Session is one of a user's logins as a listing shows it; ID is only
+	// good for ending it, it isn't the cookie, and LastSeen can lag by as
+	// much as SESSION_SEEN_INTERVAL. Ended and Reason are only set for a
+	// session that was evicted to make room for a newer one
+	Session struct {
+		ID        string     `json:"id"`
+		Remote    string     `json:"remote"`
+		UserAgent string     `json:"user_agent,omitempty"`
+		Method    string     `json:"method,omitempty"` // how they logged in: password, mfa, reset, refresh or magic
+		Created   time.Time  `json:"created"`
+		LastSeen  time.Time  `json:"last_seen"`
+		Ended     *time.Time `json:"ended,omitempty"`
+		Reason    string     `json:"reason,omitempty"`
+	}
+
+	// SessionClaims is what a signed session token says about its bearer;
+	// Subject is the user's id and SessionID names the redis session that
+	// can still revoke it
+	SessionClaims struct {
+		jwt.StandardClaims
+		Name      string `json:"name"`
+		SessionID string `json:"sid"`
+	}
+
+	// TokenVerifier checks session tokens without calling back to the
+	// service; build one with NewTokenVerifier
+	TokenVerifier struct {
+		issuer string
+		method jwt.SigningMethod
+		key    crypto.PublicKey
+	}
+
 	User struct {
-		UUID    UUID       `json:"id" mysql:"uuid"`
-		Name    string     `json:"username" mysql:"name"`
-		Contact *Contact   `json:"contact,omitempty"`
-		Email   *Email     `json:"email,omitempty"`
-		Cell    *Cell      `json:"cell,omitempty"`
-		MTime   time.Time  `json:"mtime" mysql:"mtime"`
-		CTime   time.Time  `json:"ctime" mysql:"ctime"`
-		DTime   *time.Time `json:"dtime,omitempty" mysql:"dtime"`
+		UUID          UUID       `json:"id" mysql:"uuid"`
+		Name          string     `json:"username" mysql:"name"`
+		Contact       *Contact   `json:"contact,omitempty"`
+		Email         *Email     `json:"email,omitempty"`
+		Cell          *Cell      `json:"cell,omitempty"`
+		EmailVerified *time.Time `json:"email_verified,omitempty" mysql:"email_verified"` // read only; set by verifying
+		CellVerified  *time.Time `json:"cell_verified,omitempty" mysql:"cell_verified"`
+		MTime         time.Time  `json:"mtime" mysql:"mtime"`
+		CTime         time.Time  `json:"ctime" mysql:"ctime"`
+		DTime         *time.Time `json:"dtime,omitempty" mysql:"dtime"`
 	}
 )
@@ -2,22 +2,68 @@ package shared
 
 import "fmt"
 
+const (
+	MinLengthRule = "min_length"
+	MaxLengthRule = "max_length"
+	LowerRule     = "lower"
+	UpperRule     = "upper"
+	DigitRule     = "digit"
+	SymbolRule    = "symbol"
+	RepeatRule    = "repeat"
+	BannedRule    = "banned"
+	PersonalRule  = "personal"
+	BreachedRule  = "breached"
+
+	EmailChannel Channel = "email"
+	SMSChannel   Channel = "sms"
+)
+
 var (
+	DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
 	UserExistsError     = fmt.Errorf("user already exists")
 	UserNotAddedError   = fmt.Errorf("user was not added")
 	UserNotUpdatedError = fmt.Errorf("user was not updated")
 	UserNotDeletedError = fmt.Errorf("user was not deleted")
+	UserDeletedError    = fmt.Errorf("user was deleted")
 
 	AddressNotAddedError   = fmt.Errorf("address was not added")
 	AddressNotUpdatedError = fmt.Errorf("address was not updated")
 
 	BadUserOrPassError  CustomError = fmt.Errorf("bad username or password")
 	MaxFailedLoginError CustomError = fmt.Errorf("too many failed login attempts")
+	LoginBackoffError   CustomError = fmt.Errorf("too soon after a failed login attempt")
 	MissingAuthToken    CustomError = fmt.Errorf("missing auth token")
 	TransactionError    CustomError = fmt.Errorf("transaction error")
 
+	MFANotEnrolledError = fmt.Errorf("no second factor is enrolled")
+	MFARequiredError    = fmt.Errorf("a verification code is required")
+	MFAConfirmedError   = fmt.Errorf("second factor is already confirmed")
+	BadMFACodeError     = fmt.Errorf("bad verification code")
+
+	MagicLinkNotEnabledError = fmt.Errorf("magic link login isn't enabled")
+
+	InvitationNotFoundError = fmt.Errorf("unknown invitation")
+	InvitationClosedError   = fmt.Errorf("invitation was already accepted, revoked or has expired")
+
+	ClientNotFoundError = fmt.Errorf("unknown client")
+	BadClientError      = fmt.Errorf("bad client credentials")
+
+	APIKeyNotFoundError = fmt.Errorf("unknown api key")
+	BadAPIKeyError      = fmt.Errorf("bad api key")
+	APIKeyExpiredError  = fmt.Errorf("api key is expired")
+
+	RoleExistsError         = fmt.Errorf("role already exists")
+	RoleNotFoundError       = fmt.Errorf("unknown role")
+	PermissionNotFoundError = fmt.Errorf("role doesn't have that permission")
+	RoleNotAssignedError    = fmt.Errorf("user doesn't have that role")
+	PermissionDeniedError   = fmt.Errorf("permission denied")
+
 	RedisTokenFail = fmt.Errorf("failed redis login token")
 
+	BadSessionTokenError = fmt.Errorf("bad session token")
+	SessionNotFoundError = fmt.Errorf("unknown session")
+
 	MissingParams = fmt.Errorf("parameter missing from URL")
 
 	PasswordsMatch = fmt.Errorf("passwords match")
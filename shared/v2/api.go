@@ -3,9 +3,15 @@ package shared
 import sharedv1 "github.com/jsmit257/userservice/shared/v1"
 
 var (
+	APIKeyer    sharedv1.APIKeyer
 	Addresser   sharedv1.Addresser
 	Auther      sharedv1.Auther
+	Authorizer  sharedv1.Authorizer
 	BasicAuther sharedv1.BasicAuther
+	Clienter    sharedv1.Clienter
 	Contacter   sharedv1.Contacter
+	Inviter     sharedv1.Inviter
+	MagicLinker sharedv1.MagicLinker
+	MFAer       sharedv1.MFAer
 	Userer      sharedv1.Userer
 )
@@ -3,5 +3,6 @@ package shared
 import sharedv1 "github.com/jsmit257/userservice/shared/v1"
 
 var (
-	CheckValid = sharedv1.CheckValid
+	CheckValid       = sharedv1.CheckValid
+	NewTokenVerifier = sharedv1.NewTokenVerifier
 )
@@ -12,14 +12,30 @@ import (
 // from types.go
 type (
 	Cell     sharedv1.Cell
+	Channel  sharedv1.Channel
 	CID      sharedv1.CID
 	CTXKey   sharedv1.CTXKey
 	Email    sharedv1.Email
 	Password sharedv1.Password
 	UUID     sharedv1.UUID
 
-	Address   sharedv1.Address
-	BasicAuth sharedv1.BasicAuth
-	Contact   sharedv1.Contact
-	User      sharedv1.User
+	APIKey           sharedv1.APIKey
+	Address          sharedv1.Address
+	AuthCode         sharedv1.AuthCode
+	BasicAuth        sharedv1.BasicAuth
+	Contact          sharedv1.Contact
+	Invitation       sharedv1.Invitation
+	LockoutPolicy    sharedv1.LockoutPolicy
+	MagicLink        sharedv1.MagicLink
+	MFAChannel       sharedv1.MFAChannel
+	OAuthClient      sharedv1.OAuthClient
+	PasswordPolicy   sharedv1.PasswordPolicy
+	PolicyViolation  sharedv1.PolicyViolation
+	PolicyViolations sharedv1.PolicyViolations
+	RecoveryCodes    sharedv1.RecoveryCodes
+	Role             sharedv1.Role
+	Session          sharedv1.Session
+	SessionClaims    sharedv1.SessionClaims
+	TOTP             sharedv1.TOTP
+	User             sharedv1.User
 )
@@ -7,16 +7,44 @@ var (
 	UserNotAddedError   = sharedv1.UserNotAddedError
 	UserNotUpdatedError = sharedv1.UserNotUpdatedError
 	UserNotDeletedError = sharedv1.UserNotDeletedError
+	UserDeletedError    = sharedv1.UserDeletedError
 
 	AddressNotAddedError   = sharedv1.AddressNotAddedError
 	AddressNotUpdatedError = sharedv1.AddressNotUpdatedError
 
 	BadUserOrPassError  = sharedv1.BadUserOrPassError
 	MaxFailedLoginError = sharedv1.MaxFailedLoginError
+	LoginBackoffError   = sharedv1.LoginBackoffError
 	MissingAuthToken    = sharedv1.MissingAuthToken
 
+	MFANotEnrolledError = sharedv1.MFANotEnrolledError
+	MFARequiredError    = sharedv1.MFARequiredError
+	MFAConfirmedError   = sharedv1.MFAConfirmedError
+	BadMFACodeError     = sharedv1.BadMFACodeError
+
+	MagicLinkNotEnabledError = sharedv1.MagicLinkNotEnabledError
+
+	InvitationNotFoundError = sharedv1.InvitationNotFoundError
+	InvitationClosedError   = sharedv1.InvitationClosedError
+
+	ClientNotFoundError = sharedv1.ClientNotFoundError
+	BadClientError      = sharedv1.BadClientError
+
+	APIKeyNotFoundError = sharedv1.APIKeyNotFoundError
+	BadAPIKeyError      = sharedv1.BadAPIKeyError
+	APIKeyExpiredError  = sharedv1.APIKeyExpiredError
+
+	RoleExistsError         = sharedv1.RoleExistsError
+	RoleNotFoundError       = sharedv1.RoleNotFoundError
+	PermissionNotFoundError = sharedv1.PermissionNotFoundError
+	RoleNotAssignedError    = sharedv1.RoleNotAssignedError
+	PermissionDeniedError   = sharedv1.PermissionDeniedError
+
 	RedisTokenFail = sharedv1.RedisTokenFail
 
+	BadSessionTokenError = sharedv1.BadSessionTokenError
+	SessionNotFoundError = sharedv1.SessionNotFoundError
+
 	MissingParams = sharedv1.MissingParams
 
 	PasswordsMatch = sharedv1.PasswordsMatch
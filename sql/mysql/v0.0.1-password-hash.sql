@@ -0,0 +1,10 @@
+use userservice;
+
+-- passwords are stored as self-describing PHC strings now, e.g.:
+--   $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+-- the salt is embedded in the string, so the salt column only matters for
+-- rows that still hold the legacy password+salt value; those get rehashed
+-- the next time the user logs in successfully, and salt is emptied
+alter table users
+  modify  password  varchar(255)  not null,
+  modify  salt      varchar(32)   not null default '';
@@ -0,0 +1,20 @@
+use userservice;
+
+-- with SESSION_STORE=mysql, what would have gone to redis lives here: a key
+-- holds a hash, a set or a plain string and is gone once expires passes;
+-- hash fields and set members are rows in session_fields, a set member just
+-- has no value and a string is the one field named ''
+create table if not exists session_keys(
+  name     varchar(255)  not null primary key,
+  kind     enum('hash', 'set', 'string')  not null,
+  expires  datetime(3),
+  index (expires)
+) engine=InnoDB;
+
+create table if not exists session_fields(
+  name   varchar(255)   not null,
+  field  varchar(255)   not null,
+  value  varchar(2048)  not null default '',
+  primary key (name, field),
+  foreign key (name) references session_keys(name) on delete cascade
+) engine=InnoDB;
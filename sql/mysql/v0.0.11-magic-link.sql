@@ -0,0 +1,11 @@
+use userservice;
+
+-- users who'd rather log in with a link sent to them than a password; like
+-- mfa_channel, the address is looked up at send time
+create table if not exists magic_links(
+  user_uuid  varchar(36)  not null primary key,
+  channel    varchar(8)   not null,
+  mtime      datetime     not null default current_timestamp,
+  ctime      datetime     not null default current_timestamp,
+  foreign key (user_uuid) references users(uuid)
+) engine=InnoDB;
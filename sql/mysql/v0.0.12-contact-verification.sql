@@ -0,0 +1,11 @@
+use userservice;
+
+-- when each contact method was last proven to belong to the user; changing an
+-- address clears its timestamp, and unverified email doesn't get reset links
+alter table users
+  add column email_verified datetime null after cell,
+  add column cell_verified  datetime null after email_verified;
+
+-- addresses from before verification existed are taken on trust, otherwise
+-- nobody could reset a password until they'd verified
+update users set email_verified = mtime where email is not null and email != '';
@@ -0,0 +1,6 @@
+use userservice;
+
+-- cell_verified came along with email_verified; now that numbers get checked
+-- too, the ones from before are taken on trust the same way, unless they've
+-- already been verified
+update users set cell_verified = mtime where cell is not null and cell != '' and cell_verified is null;
@@ -0,0 +1,22 @@
+use userservice;
+
+-- accounts an admin offered to an email or a cell; the token in the link is
+-- only stored hashed, like api keys. Rows are closed by accepting or revoking
+-- them, never deleted, so there's always a record of who invited whom
+create table if not exists invitations(
+  uuid         varchar(36)   not null primary key,
+  email        varchar(256)  null,
+  cell         varchar(20)   null,
+  hash         char(64)      not null,
+  created_by   varchar(36)   not null,
+  expires      datetime      not null,
+  accepted     datetime      null,
+  accepted_by  varchar(36)   null,
+  revoked      datetime      null,
+  revoked_by   varchar(36)   null,
+  ctime        datetime      not null default current_timestamp,
+  index (ctime),
+  foreign key (created_by) references users(uuid),
+  foreign key (accepted_by) references users(uuid),
+  foreign key (revoked_by) references users(uuid)
+) engine=InnoDB;
@@ -0,0 +1,13 @@
+use userservice;
+
+-- the last few password hashes per user, so they can't be reused; pruned
+-- to US_PASSWORD_HISTORY entries every time a password is set
+create table if not exists password_history(
+  id         bigint unsigned  not null auto_increment primary key,
+  user_uuid  varchar(36)      not null,
+  password   varchar(255)     not null,
+  salt       varchar(32)      not null default '',
+  ctime      datetime         not null default current_timestamp,
+  index      (user_uuid, ctime),
+  foreign key (user_uuid) references users(uuid)
+) engine=InnoDB;
@@ -0,0 +1,15 @@
+use userservice;
+
+-- authenticator app enrollments; a row with a null confirmed hasn't been
+-- proven with a first code yet and doesn't count as a second factor.
+-- last_step is the last 30s period a code was accepted for, codes from it or
+-- any period before it are spent
+create table if not exists totp(
+  user_uuid  varchar(36)  not null primary key,
+  secret     varchar(64)  not null,
+  confirmed  datetime     null,
+  last_step  bigint       not null default 0,
+  mtime      datetime     not null default current_timestamp,
+  ctime      datetime     not null default current_timestamp,
+  foreign key (user_uuid) references users(uuid)
+) engine=InnoDB;
@@ -0,0 +1,13 @@
+use userservice;
+
+-- single-use codes for getting past the second factor without the device;
+-- only a sha256 of each code is kept, they're random enough not to need more
+create table if not exists recovery_codes(
+  id         bigint unsigned  not null auto_increment primary key,
+  user_uuid  varchar(36)      not null,
+  code       char(64)         not null,
+  used       datetime         null,
+  ctime      datetime         not null default current_timestamp,
+  unique     (user_uuid, code),
+  foreign key (user_uuid) references users(uuid)
+) engine=InnoDB;
@@ -0,0 +1,13 @@
+use userservice;
+
+-- where a user wants emailed/texted codes sent; the address itself comes from
+-- users.email or users.cell at send time, so changing either one moves the
+-- codes along with it
+create table if not exists mfa_channel(
+  user_uuid  varchar(36)  not null primary key,
+  channel    varchar(8)   not null,
+  confirmed  datetime     null,
+  mtime      datetime     not null default current_timestamp,
+  ctime      datetime     not null default current_timestamp,
+  foreign key (user_uuid) references users(uuid)
+) engine=InnoDB;
@@ -0,0 +1,14 @@
+use userservice;
+
+-- relying parties for the openid connect provider; public clients (SPAs,
+-- native apps) have no secret and get by on PKCE alone, everyone else's
+-- secret is hashed the same way passwords are
+create table if not exists oauth_clients(
+  client_id      varchar(36)   not null primary key,
+  name           varchar(128)  not null,
+  secret         varchar(255)  not null default '',
+  redirect_uris  text          not null, -- space separated, matched exactly
+  public         boolean       not null default false,
+  mtime          datetime      not null default current_timestamp,
+  ctime          datetime      not null default current_timestamp
+) engine=InnoDB;
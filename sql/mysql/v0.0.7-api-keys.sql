@@ -0,0 +1,17 @@
+use userservice;
+
+-- personal access tokens for scripts and services; the bearer token is
+-- usk_<id>.<secret> and only a sha256 of the secret is kept, since it's
+-- random enough not to need a slow hash and gets checked on every request
+create table if not exists api_keys(
+  id         varchar(36)   not null primary key,
+  user_uuid  varchar(36)   not null,
+  name       varchar(128)  not null,
+  hash       char(64)      not null,
+  scopes     text          not null, -- space separated
+  expires    datetime      null,
+  lastused   datetime      null,
+  ctime      datetime      not null default current_timestamp,
+  foreign key (user_uuid) references users(uuid),
+  index (user_uuid)
+) engine=InnoDB;
@@ -0,0 +1,30 @@
+use userservice;
+
+-- role based access control: a role is a named bag of permissions, and a
+-- user has whatever permissions their roles add up to; permissions are just
+-- strings the downstream apps agree on, there's no separate catalog of them
+create table if not exists roles(
+  uuid         varchar(36)   not null primary key,
+  name         varchar(128)  not null unique,
+  description  varchar(512)  not null default '',
+  mtime        datetime      not null default current_timestamp,
+  ctime        datetime      not null default current_timestamp
+) engine=InnoDB;
+
+create table if not exists permissions(
+  role_uuid  varchar(36)   not null,
+  name       varchar(128)  not null,
+  ctime      datetime      not null default current_timestamp,
+  primary key (role_uuid, name),
+  index (name),
+  foreign key (role_uuid) references roles(uuid) on delete cascade
+) engine=InnoDB;
+
+create table if not exists user_roles(
+  user_uuid  varchar(36)  not null,
+  role_uuid  varchar(36)  not null,
+  ctime      datetime     not null default current_timestamp,
+  primary key (user_uuid, role_uuid),
+  foreign key (user_uuid) references users(uuid),
+  foreign key (role_uuid) references roles(uuid) on delete cascade
+) engine=InnoDB;
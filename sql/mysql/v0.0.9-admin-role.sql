@@ -0,0 +1,14 @@
+use userservice;
+
+-- the CRUD routes only let a user at their own records unless they hold the
+-- admin permission (ADMIN_PERMISSION, 'admin' by default); this seeds a role
+-- that grants it, the first admin still has to be assigned by hand:
+--   insert into user_roles(user_uuid, role_uuid)
+--   select '<user uuid>', uuid from roles where name = 'admin';
+insert into roles(uuid, name, description)
+select uuid(), 'admin', 'can read and change any user'
+where not exists (select 1 from roles where name = 'admin');
+
+insert into permissions(role_uuid, name)
+select uuid, 'admin' from roles where name = 'admin'
+on duplicate key update name = permissions.name;